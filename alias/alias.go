@@ -0,0 +1,163 @@
+// Package alias keeps the user's short names for libraries. An aliased
+// library resolves without a search and counts as a favorite, listed first
+// in the interactive picker.
+package alias
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Alias is a short name for a library
+type Alias struct {
+	LibraryID string    `json:"library_id"`
+	Title     string    `json:"title,omitempty"`
+	Added     time.Time `json:"added"`
+}
+
+// Entry is a named alias, for listing
+type Entry struct {
+	Name string
+	Alias
+}
+
+// Store holds the aliases by name. The zero value is not usable; Load one
+// from a file.
+type Store struct {
+	Aliases map[string]Alias `json:"aliases"`
+
+	path string
+}
+
+// DefaultPath returns the aliases location (~/.config/ctx7/aliases.json)
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "ctx7", "aliases.json"), nil
+}
+
+// Load reads the store from path; a missing file yields an empty store
+func Load(path string) (*Store, error) {
+	s := &Store{Aliases: map[string]Alias{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return s, fmt.Errorf("failed to parse aliases: %w", err)
+	}
+	if s.Aliases == nil {
+		s.Aliases = map[string]Alias{}
+	}
+	return s, nil
+}
+
+// Save writes the store back to its file
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create aliases directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal aliases: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	return nil
+}
+
+// normalize folds names that differ only in case or surrounding space
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// ValidateName checks that name can be told apart from library IDs and
+// versions on the command line
+func ValidateName(name string) error {
+	switch {
+	case normalize(name) == "":
+		return fmt.Errorf("an alias needs a name")
+	case strings.HasPrefix(name, "/"):
+		return fmt.Errorf("alias %q would be taken for a library ID; drop the leading /", name)
+	case strings.ContainsAny(name, "@ \t"):
+		return fmt.Errorf("alias %q can't contain @ or spaces", name)
+	}
+	return nil
+}
+
+// Add names lib, replacing an alias of the same name
+func (s *Store) Add(name string, lib client.Library, now time.Time) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	s.Aliases[normalize(name)] = Alias{LibraryID: lib.ID, Title: lib.Title, Added: now}
+	return nil
+}
+
+// Remove deletes the alias name, reporting whether there was one
+func (s *Store) Remove(name string) bool {
+	if _, ok := s.Aliases[normalize(name)]; !ok {
+		return false
+	}
+	delete(s.Aliases, normalize(name))
+	return true
+}
+
+// Lookup returns the library aliased as name
+func (s *Store) Lookup(name string) (client.Library, bool) {
+	if s == nil {
+		return client.Library{}, false
+	}
+	a, ok := s.Aliases[normalize(name)]
+	if !ok {
+		return client.Library{}, false
+	}
+	title := a.Title
+	if title == "" {
+		title = a.LibraryID
+	}
+	return client.Library{ID: a.LibraryID, Title: title}, true
+}
+
+// Favorite reports whether libraryID has an alias
+func (s *Store) Favorite(libraryID string) bool {
+	if s == nil {
+		return false
+	}
+	for _, a := range s.Aliases {
+		if a.LibraryID == libraryID {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns every alias, by name
+func (s *Store) Entries() []Entry {
+	if s == nil {
+		return nil
+	}
+	entries := make([]Entry, 0, len(s.Aliases))
+	for name, a := range s.Aliases {
+		entries = append(entries, Entry{Name: name, Alias: a})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
@@ -0,0 +1,175 @@
+package budget
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Mode controls what happens once the budget is exhausted
+type Mode string
+
+const (
+	// ModeStop skips every document that no longer fits in the budget
+	ModeStop Mode = "stop"
+	// ModeSummary replaces documents that don't fit with a short summary
+	ModeSummary Mode = "summary"
+)
+
+// ParseMode validates a --budget-mode value
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeStop, ModeSummary:
+		return Mode(s), nil
+	default:
+		return "", fmt.Errorf("invalid budget mode %q (expected: stop, summary)", s)
+	}
+}
+
+// EstimateTokens approximates the token count of content (~4 characters per token)
+func EstimateTokens(content string) int {
+	return EstimateTokensForSize(int64(len(content)))
+}
+
+// EstimateTokensForSize is EstimateTokens for content of size bytes
+func EstimateTokensForSize(size int64) int {
+	return int((size + 3) / 4)
+}
+
+// Split divides total tokens among documents of the given sizes (in
+// tokens). Each gets an equal share; what a smaller document leaves unused
+// goes to the larger ones.
+func Split(sizes []int, total int) []int {
+	return SplitWeighted(sizes, nil, total)
+}
+
+// SplitWeighted is Split with shares in proportion to weights; a nil
+// weights weighs every document the same
+func SplitWeighted(sizes []int, weights []float64, total int) []int {
+	weight := func(i int) float64 {
+		if weights == nil || weights[i] <= 0 {
+			return 1
+		}
+		return weights[i]
+	}
+
+	// Documents that need the least per unit of weight are settled first,
+	// so whatever they leave is spread over the rest
+	order := make([]int, len(sizes))
+	rest := 0.0
+	for i := range order {
+		order[i] = i
+		rest += weight(i)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return float64(sizes[order[a]])/weight(order[a]) < float64(sizes[order[b]])/weight(order[b])
+	})
+
+	shares := make([]int, len(sizes))
+	remaining := total
+	for _, i := range order {
+		shares[i] = min(sizes[i], int(float64(remaining)*weight(i)/rest))
+		remaining -= shares[i]
+		rest -= weight(i)
+	}
+	return shares
+}
+
+// Skipped describes a document that was not emitted in full
+type Skipped struct {
+	Name       string
+	Tokens     int
+	Summarized bool
+}
+
+// Tracker keeps a running total of estimated tokens emitted during a session
+type Tracker struct {
+	limit   int
+	mode    Mode
+	used    int
+	skipped []Skipped
+}
+
+// NewTracker creates a tracker for the given limit. A limit of zero or less disables the budget.
+func NewTracker(limit int, mode Mode) *Tracker {
+	if mode == "" {
+		mode = ModeStop
+	}
+	return &Tracker{limit: limit, mode: mode}
+}
+
+// Enabled reports whether a budget is being enforced
+func (t *Tracker) Enabled() bool {
+	return t != nil && t.limit > 0
+}
+
+// Exhausted reports whether no budget is left for further documents
+func (t *Tracker) Exhausted() bool {
+	return t.Enabled() && t.used >= t.limit
+}
+
+// Used returns the number of estimated tokens emitted so far
+func (t *Tracker) Used() int {
+	return t.used
+}
+
+// Remaining returns the number of tokens left in the budget
+func (t *Tracker) Remaining() int {
+	if t.used >= t.limit {
+		return 0
+	}
+	return t.limit - t.used
+}
+
+// Admit decides what to emit for a document. It returns the content to
+// output (the original, a summary, or "") and records the decision.
+func (t *Tracker) Admit(name, content, summary string) string {
+	tokens := EstimateTokens(content)
+	if !t.Enabled() || tokens <= t.Remaining() {
+		t.used += tokens
+		return content
+	}
+
+	if t.mode == ModeSummary {
+		summaryTokens := EstimateTokens(summary)
+		if summaryTokens <= t.Remaining() {
+			t.used += summaryTokens
+			t.skipped = append(t.skipped, Skipped{Name: name, Tokens: tokens, Summarized: true})
+			return summary
+		}
+	}
+
+	t.skipped = append(t.skipped, Skipped{Name: name, Tokens: tokens})
+	return ""
+}
+
+// Skip records a document that was never fetched because the budget ran out
+func (t *Tracker) Skip(name string) {
+	t.skipped = append(t.skipped, Skipped{Name: name})
+}
+
+// Skipped returns every document that was skipped or summarized
+func (t *Tracker) Skipped() []Skipped {
+	return t.skipped
+}
+
+// Report returns a human-readable summary of budget usage, or "" when nothing was skipped
+func (t *Tracker) Report() string {
+	if !t.Enabled() || len(t.skipped) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Token budget reached: %d/%d tokens used\n", t.used, t.limit)
+	for _, s := range t.skipped {
+		switch {
+		case s.Summarized:
+			fmt.Fprintf(&b, "  └─ %s summarized (~%d tokens omitted)\n", s.Name, s.Tokens)
+		case s.Tokens > 0:
+			fmt.Fprintf(&b, "  └─ %s skipped (~%d tokens)\n", s.Name, s.Tokens)
+		default:
+			fmt.Fprintf(&b, "  └─ %s skipped (not fetched)\n", s.Name)
+		}
+	}
+	return b.String()
+}
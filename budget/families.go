@@ -0,0 +1,87 @@
+package budget
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Family is a group of models whose tokenizers split text alike. Count
+// approximates their token counts without their vocabularies, the way
+// tiktoken-style BPE tokenizers split text: words, digit groups,
+// punctuation and whitespace each become tokens of their own.
+type Family struct {
+	Name string
+	// Models lists models of the family, for reports
+	Models string
+	// charsPerToken is how many letters of a word a token covers on average
+	charsPerToken float64
+}
+
+// Families are the model families Count approximates, recent OpenAI
+// tokenizers first
+var Families = []Family{
+	{Name: "gpt-4o", Models: "GPT-4o, o1, o3 (o200k)", charsPerToken: 4.6},
+	{Name: "gpt-4", Models: "GPT-4, GPT-3.5 (cl100k)", charsPerToken: 4.2},
+	{Name: "claude", Models: "Claude", charsPerToken: 3.6},
+	{Name: "llama", Models: "Llama 3", charsPerToken: 4.2},
+}
+
+// maxDigitsPerToken is how many digits of a number these tokenizers
+// put in one token
+const maxDigitsPerToken = 3
+
+// Count approximates the number of tokens the family's tokenizers split
+// text into
+func (f Family) Count(text string) int {
+	n := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		j := i + size
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			// A word, split into pieces of about charsPerToken letters
+			letters := 1
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if !unicode.Is(unicode.Latin, r) {
+					break
+				}
+				j += size
+				letters++
+			}
+			n += int(math.Ceil(float64(letters) / f.charsPerToken))
+		case r >= '0' && r <= '9':
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			n += (j - i + maxDigitsPerToken - 1) / maxDigitsPerToken
+		case r == ' ' && j < len(text) && text[j] != ' ' && text[j] != '\n':
+			// A single space joins the token that follows it
+		case unicode.IsSpace(r):
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				j += size
+			}
+			n++
+		case r < utf8.RuneSelf:
+			// Punctuation and operators pair up, e.g. "=>", "();"
+			for j < len(text) && j-i < 2 && text[j] < utf8.RuneSelf && isPunct(text[j]) {
+				j++
+			}
+			n++
+		default:
+			// Other scripts, such as CJK, take about a token per character
+			n++
+		}
+		i = j
+	}
+	return n
+}
+
+func isPunct(b byte) bool {
+	return b > ' ' && b < utf8.RuneSelf && !('a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9')
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// staleTempAge is how long a temp file can go untouched before it is taken
+// for the leftover of an interrupted write. Streaming writes touch their
+// file with every chunk, so live writes are never this old.
+const staleTempAge = time.Hour
+
+// tempDir returns where writes are staged before being moved into place:
+// $CTX7_TMP_DIR, or tmp/ inside the cache root
+func tempDir(root string) string {
+	if dir := os.Getenv("CTX7_TMP_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(root, "tmp")
+}
+
+// prepareTempDir creates dir and removes stale files left in it by
+// interrupted writes. Cleanup is best-effort.
+func prepareTempDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	cutoff := time.Now().Add(-staleTempAge)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		os.Remove(filepath.Join(dir, entry.Name()))
+	}
+	return nil
+}
+
+// atomicFile is a uniquely named temp file that replaces its target on
+// Commit, so concurrent writers never share a temp file and readers never
+// see a partial target
+type atomicFile struct {
+	*os.File
+	target string
+}
+
+// createAtomic starts a write that will replace target
+func createAtomic(tmpDir, target string) (*atomicFile, error) {
+	f, err := createTemp(tmpDir, filepath.Base(target)+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, target: target}, nil
+}
+
+// createTemp is os.CreateTemp for cache files, which are readable like
+// any other file rather than private
+func createTemp(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// Commit flushes the temp file to disk and moves it over the target
+func (f *atomicFile) Commit() error {
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := replaceFile(f.Name(), f.target); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temp file, leaving the target untouched
+func (f *atomicFile) Abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// writeFileAtomic replaces target with data through a temp file in tmpDir
+func writeFileAtomic(tmpDir, target string, data []byte) error {
+	f, err := createAtomic(tmpDir, target)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// replaceFile moves src over dst. When that fails, e.g. because src is on
+// another filesystem ($CTX7_TMP_DIR on tmpfs), src is copied next to dst
+// first so dst is still replaced by a rename.
+func replaceFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	sibling, copyErr := copyBeside(src, dst)
+	if copyErr != nil {
+		return err
+	}
+	if err := moveOver(sibling, dst); err != nil {
+		os.Remove(sibling)
+		return err
+	}
+	os.Remove(src)
+	return nil
+}
+
+// moveOver renames src to dst in the same directory, removing dst first on
+// filesystems that refuse to rename over an existing file
+func moveOver(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if _, statErr := os.Lstat(dst); statErr != nil {
+		return err
+	}
+	if os.Remove(dst) != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// copyBeside copies src to a new temp file in dst's directory and syncs it
+func copyBeside(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := createTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,33 +11,139 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/hsbacot/ctx7/client"
+	"golang.org/x/text/unicode/norm"
 )
 
+// ErrExpired is returned by GetWithVersion for entries older than maxAge
+var ErrExpired = errors.New("cache expired")
+
+// ErrNoIndex is returned by Reindex for backends that keep no index
+var ErrNoIndex = errors.New("cache backend keeps no index")
+
+// ErrInvalidID is returned for library IDs and versions that can't be
+// stored, such as ones with ".." parts
+var ErrInvalidID = errors.New("invalid library ID")
+
 // Cache manages the local file cache for ctx7
 type Cache struct {
 	baseDir string
+	tmpDir  string
+	store   Store
+	now     func() time.Time
+}
+
+// DefaultDir returns the cache location: $CTX7_CACHE_DIR, then
+// $XDG_CACHE_HOME/ctx7, then the platform cache directory (~/.cache/ctx7 on
+// Linux, ~/Library/Caches/ctx7 on macOS, %LocalAppData%\ctx7 on Windows).
+// A cache already at the old ~/.cache/ctx7 keeps being used until the
+// platform directory exists.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("CTX7_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	if xdg := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "ctx7"), nil
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	dir := filepath.Join(userCacheDir, "ctx7")
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			legacy := filepath.Join(homeDir, ".cache", "ctx7")
+			if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+				return legacy, nil
+			}
+		}
+	}
+	return dir, nil
 }
 
 // NewCache creates a new cache manager with the specified directory
 func NewCache(dir string) (*Cache, error) {
+	return NewCacheWithBackend(dir, BackendFiles)
+}
+
+// NewCacheWithBackend creates a cache manager using the named storage backend
+func NewCacheWithBackend(dir string, backend Backend) (*Cache, error) {
+	return NewCacheWithShared(dir, backend, nil)
+}
+
+// NewCacheWithShared creates a cache manager like NewCacheWithBackend that
+// also reads documents from the shared caches in sharedDirs, read-only and
+// in order, beneath the one in dir. Shared directories that don't exist
+// are skipped.
+func NewCacheWithShared(dir string, backend Backend, sharedDirs []string) (*Cache, error) {
 	// Create base directory if it doesn't exist
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	// Create subdirectories
-	libsDir := filepath.Join(dir, "libraries")
-	searchDir := filepath.Join(dir, "searches")
+	store, err := OpenStore(backend, dir)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := os.MkdirAll(libsDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create libraries directory: %w", err)
+	var shared []Store
+	for _, sharedDir := range sharedDirs {
+		s, err := OpenSharedStore(sharedDir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		shared = append(shared, s)
+	}
+	if len(shared) > 0 {
+		store = NewLayeredStore(store, shared...)
 	}
 
+	return NewCacheWithStore(dir, store)
+}
+
+// NewCacheWithStore creates a cache manager on top of an existing Store.
+// Search and snippet results are still kept as files under dir/searches and dir/snippets.
+func NewCacheWithStore(dir string, store Store) (*Cache, error) {
+	searchDir := filepath.Join(dir, "searches")
+	snippetsDir := filepath.Join(dir, "snippets")
+
 	if err := os.MkdirAll(searchDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create searches directory: %w", err)
 	}
 
-	return &Cache{baseDir: dir}, nil
+	if err := os.MkdirAll(snippetsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snippets directory: %w", err)
+	}
+
+	tmpDir := tempDir(dir)
+	if err := prepareTempDir(tmpDir); err != nil {
+		return nil, err
+	}
+	if err := recoverJournals(dir, store); err != nil {
+		return nil, err
+	}
+
+	return &Cache{baseDir: dir, tmpDir: tmpDir, store: store, now: time.Now}, nil
+}
+
+// SetClock overrides the clock used for expiry checks (for deterministic tests)
+func (c *Cache) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// SetScanProgress has fn called as the store rebuilds its index by reading
+// every cached version, which is slow for large caches on network
+// filesystems. Stores without an index never call it.
+func (c *Cache) SetScanProgress(fn ScanProgressFunc) {
+	if s, ok := c.store.(scanReporter); ok {
+		s.setScanProgress(fn)
+	}
 }
 
 // Get retrieves a cache entry for the given library ID
@@ -44,41 +151,34 @@ func (c *Cache) Get(libraryID string, maxAge time.Duration) (*CacheEntry, error)
 	return c.GetWithVersion(libraryID, "", maxAge)
 }
 
-// GetWithVersion retrieves a cache entry for a specific version
+// GetWithVersion retrieves a cache entry for a specific version; an entry
+// older than maxAge is an ErrExpired error
 func (c *Cache) GetWithVersion(libraryID, version string, maxAge time.Duration) (*CacheEntry, error) {
-	cacheDir := c.getCacheDir(libraryID, version)
-
-	// Check if cache exists and is valid
-	metadataPath := filepath.Join(cacheDir, "metadata.json")
-	contentPath := filepath.Join(cacheDir, "content.txt")
-
-	// Read metadata
-	metadataFile, err := os.Open(metadataPath)
+	entry, err := c.GetEntry(libraryID, version, maxAge)
 	if err != nil {
-		return nil, fmt.Errorf("cache miss: %w", err)
-	}
-	defer metadataFile.Close()
-
-	var metadata Metadata
-	if err := json.NewDecoder(metadataFile).Decode(&metadata); err != nil {
-		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		return nil, err
 	}
-
-	// Check if cache is still valid
-	if time.Since(metadata.FetchedAt) > maxAge {
-		return nil, fmt.Errorf("cache expired")
+	if entry.Expired {
+		return nil, ErrExpired
 	}
+	return entry, nil
+}
 
-	// Read content
-	contentBytes, err := os.ReadFile(contentPath)
+// GetEntry retrieves a cache entry for a specific version. Unlike
+// GetWithVersion, an entry older than maxAge is still returned, with
+// Expired set, so the caller decides whether stale content will do.
+func (c *Cache) GetEntry(libraryID, version string, maxAge time.Duration) (*CacheEntry, error) {
+	entry, err := c.store.Get(libraryID, version)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read content: %w", err)
+		return nil, err
 	}
+	entry.Expired = c.now().Sub(entry.Metadata.FetchedAt) > maxAge
+	return entry, nil
+}
 
-	return &CacheEntry{
-		Metadata: metadata,
-		Content:  string(contentBytes),
-	}, nil
+// Lookup returns the cached entry for a version regardless of its age
+func (c *Cache) Lookup(libraryID, version string) (*CacheEntry, error) {
+	return c.store.Get(libraryID, version)
 }
 
 // Set saves content and metadata to the cache
@@ -86,76 +186,60 @@ func (c *Cache) Set(libraryID, content string, metadata Metadata) error {
 	return c.SetWithVersion(libraryID, "", content, metadata)
 }
 
-// SetWithVersion saves content for a specific version
+// SetWithVersion saves content for a specific version; a pinned entry
+// stays pinned
 func (c *Cache) SetWithVersion(libraryID, version, content string, metadata Metadata) error {
-	cacheDir := c.getCacheDir(libraryID, version)
-
-	// Create cache directory
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
-	}
-
-	// Write content atomically (write to temp file, then rename)
-	contentPath := filepath.Join(cacheDir, "content.txt")
-	tmpContentPath := contentPath + ".tmp"
-
-	if err := os.WriteFile(tmpContentPath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write content: %w", err)
-	}
+	metadata.Pinned = metadata.Pinned || c.isPinned(libraryID, version)
+	return c.store.Set(libraryID, version, content, metadata)
+}
 
-	if err := os.Rename(tmpContentPath, contentPath); err != nil {
-		os.Remove(tmpContentPath)
-		return fmt.Errorf("failed to save content: %w", err)
+// SetWithVersionFrom saves the content read from r for a specific version,
+// streaming it to disk when the store supports it
+func (c *Cache) SetWithVersionFrom(libraryID, version string, r io.Reader, metadata Metadata) (int64, error) {
+	metadata.Pinned = metadata.Pinned || c.isPinned(libraryID, version)
+	if s, ok := c.store.(StreamingStore); ok {
+		return s.SetFrom(libraryID, version, r, metadata)
 	}
 
-	// Write metadata atomically
-	metadataPath := filepath.Join(cacheDir, "metadata.json")
-	tmpMetadataPath := metadataPath + ".tmp"
-
-	metadataFile, err := os.Create(tmpMetadataPath)
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("failed to create metadata file: %w", err)
+		return 0, fmt.Errorf("failed to write content: %w", err)
 	}
+	return int64(len(data)), c.store.Set(libraryID, version, string(data), metadata)
+}
 
-	encoder := json.NewEncoder(metadataFile)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(metadata); err != nil {
-		metadataFile.Close()
-		os.Remove(tmpMetadataPath)
-		return fmt.Errorf("failed to encode metadata: %w", err)
+// OpenWithVersion returns a reader over the cached content for a version
+// regardless of its age
+func (c *Cache) OpenWithVersion(libraryID, version string) (io.ReadCloser, error) {
+	if s, ok := c.store.(StreamingStore); ok {
+		return s.Open(libraryID, version)
 	}
-	metadataFile.Close()
 
-	if err := os.Rename(tmpMetadataPath, metadataPath); err != nil {
-		os.Remove(tmpMetadataPath)
-		return fmt.Errorf("failed to save metadata: %w", err)
+	entry, err := c.store.Get(libraryID, version)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return io.NopCloser(strings.NewReader(entry.Content)), nil
 }
 
 // Clear removes all cached content
 func (c *Cache) Clear() error {
-	libsDir := filepath.Join(c.baseDir, "libraries")
-	searchDir := filepath.Join(c.baseDir, "searches")
-
-	// Remove libraries directory
-	if err := os.RemoveAll(libsDir); err != nil {
-		return fmt.Errorf("failed to clear libraries cache: %w", err)
+	if err := c.store.Clear(); err != nil {
+		return err
 	}
 
-	// Remove searches directory
-	if err := os.RemoveAll(searchDir); err != nil {
-		return fmt.Errorf("failed to clear searches cache: %w", err)
-	}
+	for _, name := range []string{"searches", "snippets"} {
+		dir := filepath.Join(c.baseDir, name)
 
-	// Recreate directories
-	if err := os.MkdirAll(libsDir, 0755); err != nil {
-		return fmt.Errorf("failed to recreate libraries directory: %w", err)
-	}
+		// Remove directory
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to clear %s cache: %w", name, err)
+		}
 
-	if err := os.MkdirAll(searchDir, 0755); err != nil {
-		return fmt.Errorf("failed to recreate searches directory: %w", err)
+		// Recreate directory
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to recreate %s directory: %w", name, err)
+		}
 	}
 
 	return nil
@@ -163,77 +247,73 @@ func (c *Cache) Clear() error {
 
 // GetStats returns statistics about the cache
 func (c *Cache) GetStats() (*CacheStats, error) {
-	stats := &CacheStats{
-		CacheDir:    c.baseDir,
-		OldestEntry: time.Now(),
-		NewestEntry: time.Time{},
-	}
-
-	libsDir := filepath.Join(c.baseDir, "libraries")
-
-	err := filepath.Walk(libsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-
-		if !info.IsDir() && filepath.Base(path) == "metadata.json" {
-			stats.TotalEntries++
-			stats.TotalSize += info.Size()
-
-			// Update oldest/newest
-			if info.ModTime().Before(stats.OldestEntry) {
-				stats.OldestEntry = info.ModTime()
-			}
-			if info.ModTime().After(stats.NewestEntry) {
-				stats.NewestEntry = info.ModTime()
-			}
-
-			// Also count content file size
-			contentPath := filepath.Join(filepath.Dir(path), "content.txt")
-			if contentInfo, err := os.Stat(contentPath); err == nil {
-				stats.TotalSize += contentInfo.Size()
-			}
-		}
-
-		return nil
-	})
-
+	stats, err := c.store.Stats()
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk cache directory: %w", err)
+		return nil, err
 	}
-
+	stats.CacheDir = c.baseDir
 	return stats, nil
 }
 
+// Reindex rebuilds the index that cached libraries are listed from, for
+// when it has drifted from the files, e.g. after they were copied in by
+// hand. It returns the number of versions indexed.
+func (c *Cache) Reindex() (int, error) {
+	if s, ok := c.store.(Indexer); ok {
+		return s.Reindex()
+	}
+	return 0, ErrNoIndex
+}
+
 // IsValid checks if a cache entry exists and is still valid
 func (c *Cache) IsValid(libraryID string, maxAge time.Duration) bool {
 	_, err := c.Get(libraryID, maxAge)
 	return err == nil
 }
 
-// getCacheDir returns the cache directory path for a library
-func (c *Cache) getCacheDir(libraryID, version string) string {
-	// libraryID format: /org/library
-	// Remove leading slash and split
-	parts := strings.Split(strings.TrimPrefix(libraryID, "/"), "/")
+// RefKey returns the version key used to cache docs for a version or branch.
+// Branches are stored alongside versions as "branch-<name>" so the two never collide.
+func RefKey(version, branch string) string {
+	if branch == "" {
+		return version
+	}
+	return "branch-" + strings.ReplaceAll(branch, "/", "_")
+}
 
-	var versionDir string
-	if version != "" {
-		versionDir = version
-	} else {
-		versionDir = "default"
+// VariantKey returns the version key of a topic-scoped document fetched for
+// the ref key, e.g. "default+topic-3f2a9c1b04d7". Variants are stored next
+// to the full document and expire on their own, so neither masks the other.
+func VariantKey(ref, topic string) string {
+	if topic == "" {
+		return ref
 	}
+	return versionKey(ref) + "+topic-" + hashQuery(topic)[:12]
+}
 
-	// Build path: baseDir/libraries/org/library/version
-	pathParts := append([]string{c.baseDir, "libraries"}, parts...)
-	pathParts = append(pathParts, versionDir)
+// KeptKey returns the version key a cached copy is kept under once a
+// refresh replaced it, e.g. "v14+kept"
+func KeptKey(key string) string {
+	return versionKey(key) + "+kept"
+}
 
-	return filepath.Join(pathParts...)
+// SearchScope is where a search was answered: the context7 instance and
+// the language hint sent with the query. Results of one scope are never
+// served for another.
+type SearchScope struct {
+	BaseURL string
+	Lang    string
 }
 
-// CacheSearchResults caches search results with a hash of the query
-func (c *Cache) CacheSearchResults(query string, results interface{}) error {
-	hash := hashQuery(query)
+// searchHash keys a search by its query as the client sends it (composed,
+// see client.SearchLibraries) and its scope
+func searchHash(query string, scope SearchScope) string {
+	return hashQuery(norm.NFC.String(query) + "\x00" + scope.Lang + "\x00" + scope.BaseURL)
+}
+
+// CacheSearchResults caches search results with a hash of the query and
+// its scope
+func (c *Cache) CacheSearchResults(query string, scope SearchScope, results interface{}) error {
+	hash := searchHash(query, scope)
 	searchDir := filepath.Join(c.baseDir, "searches")
 	searchPath := filepath.Join(searchDir, hash+".json")
 
@@ -244,8 +324,7 @@ func (c *Cache) CacheSearchResults(query string, results interface{}) error {
 		"results":    results,
 	}
 
-	tmpPath := searchPath + ".tmp"
-	file, err := os.Create(tmpPath)
+	file, err := createAtomic(c.tmpDir, searchPath)
 	if err != nil {
 		return fmt.Errorf("failed to create search cache file: %w", err)
 	}
@@ -253,23 +332,20 @@ func (c *Cache) CacheSearchResults(query string, results interface{}) error {
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(cacheData); err != nil {
-		file.Close()
-		os.Remove(tmpPath)
+		file.Abort()
 		return fmt.Errorf("failed to encode search cache: %w", err)
 	}
-	file.Close()
 
-	if err := os.Rename(tmpPath, searchPath); err != nil {
-		os.Remove(tmpPath)
+	if err := file.Commit(); err != nil {
 		return fmt.Errorf("failed to save search cache: %w", err)
 	}
 
 	return nil
 }
 
-// GetCachedSearchResults retrieves cached search results
-func (c *Cache) GetCachedSearchResults(query string, maxAge time.Duration, results interface{}) error {
-	hash := hashQuery(query)
+// GetCachedSearchResults retrieves cached search results of query in scope
+func (c *Cache) GetCachedSearchResults(query string, scope SearchScope, maxAge time.Duration, results interface{}) error {
+	hash := searchHash(query, scope)
 	searchPath := filepath.Join(c.baseDir, "searches", hash+".json")
 
 	file, err := os.Open(searchPath)
@@ -317,195 +393,77 @@ func (c *Cache) GetCachedSearchResults(query string, maxAge time.Duration, resul
 	return nil
 }
 
-// hashQuery creates a hash of the query string for caching
-func hashQuery(query string) string {
-	h := sha256.New()
-	io.WriteString(h, query)
-	return fmt.Sprintf("%x", h.Sum(nil))
-}
-
-// ListCachedLibraries returns all cached libraries with their versions
-func (c *Cache) ListCachedLibraries() ([]CachedLibrary, error) {
-	librariesDir := filepath.Join(c.baseDir, "libraries")
+// CacheSnippets caches snippet search results for a library and query
+func (c *Cache) CacheSnippets(libraryID, query, content string) error {
+	hash := hashQuery(libraryID + "\x00" + query)
+	snippetsPath := filepath.Join(c.baseDir, "snippets", hash+".txt")
 
-	// Check if libraries directory exists
-	if _, err := os.Stat(librariesDir); os.IsNotExist(err) {
-		return []CachedLibrary{}, nil
+	if err := writeFileAtomic(c.tmpDir, snippetsPath, []byte(content)); err != nil {
+		return fmt.Errorf("failed to save snippets cache: %w", err)
 	}
 
-	// Map to group versions by library ID
-	libraryMap := make(map[string]*CachedLibrary)
-
-	// Walk the libraries directory
-	err := filepath.Walk(librariesDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip if not a metadata.json file
-		if info.IsDir() || info.Name() != "metadata.json" {
-			return nil
-		}
-
-		// Read and parse metadata
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil // Skip corrupted files
-		}
-
-		var metadata Metadata
-		if err := json.Unmarshal(data, &metadata); err != nil {
-			return nil // Skip corrupted metadata
-		}
-
-		// Calculate size (metadata.json + content.txt)
-		contentPath := filepath.Join(filepath.Dir(path), "content.txt")
-		var size int64 = info.Size()
-		if contentInfo, err := os.Stat(contentPath); err == nil {
-			size += contentInfo.Size()
-		}
-
-		// Extract version from path
-		// Path format: .../libraries/org/library/version/metadata.json
-		relPath, _ := filepath.Rel(librariesDir, path)
-		parts := strings.Split(relPath, string(filepath.Separator))
-
-		if len(parts) < 3 {
-			return nil // Invalid path structure
-		}
-
-		org := parts[0]
-		name := parts[1]
-		version := parts[2]
-		libraryID := "/" + org + "/" + name
-
-		// Create or get library entry
-		lib, exists := libraryMap[libraryID]
-		if !exists {
-			lib = &CachedLibrary{
-				LibraryID:    libraryID,
-				Organization: org,
-				Name:         name,
-				Versions:     []VersionInfo{},
-			}
-			libraryMap[libraryID] = lib
-		}
-
-		// Add version info
-		versionInfo := VersionInfo{
-			Version:    version,
-			IsDefault:  version == "default",
-			Size:       size,
-			FetchedAt:  metadata.FetchedAt,
-			Metadata:   metadata,
-		}
-		lib.Versions = append(lib.Versions, versionInfo)
+	return nil
+}
 
-		return nil
-	})
+// GetCachedSnippets retrieves cached snippet results, using the file's
+// modification time as the fetch time
+func (c *Cache) GetCachedSnippets(libraryID, query string, maxAge time.Duration) (string, error) {
+	hash := hashQuery(libraryID + "\x00" + query)
+	snippetsPath := filepath.Join(c.baseDir, "snippets", hash+".txt")
 
+	info, err := os.Stat(snippetsPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk cache directory: %w", err)
+		return "", fmt.Errorf("snippets cache miss: %w", err)
 	}
 
-	// Convert map to slice
-	result := make([]CachedLibrary, 0, len(libraryMap))
-	for _, lib := range libraryMap {
-		// Sort versions: default first, then by version string
-		sort.Slice(lib.Versions, func(i, j int) bool {
-			if lib.Versions[i].IsDefault {
-				return true
-			}
-			if lib.Versions[j].IsDefault {
-				return false
-			}
-			return lib.Versions[i].Version < lib.Versions[j].Version
-		})
-		result = append(result, *lib)
+	if time.Since(info.ModTime()) > maxAge {
+		return "", fmt.Errorf("snippets cache expired")
 	}
 
-	// Sort libraries by ID
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].LibraryID < result[j].LibraryID
-	})
-
-	return result, nil
-}
-
-// RemoveLibrary removes all cached versions of a specific library
-func (c *Cache) RemoveLibrary(libraryID string) error {
-	// Normalize library ID - remove leading slash if present
-	libraryID = strings.TrimPrefix(libraryID, "/")
-
-	// Parse org and library name
-	parts := strings.Split(libraryID, "/")
-	if len(parts) < 2 {
-		return fmt.Errorf("invalid library ID format: %s (expected: org/library)", libraryID)
+	content, err := os.ReadFile(snippetsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read snippets cache: %w", err)
 	}
 
-	org := parts[0]
-	library := parts[1]
+	return string(content), nil
+}
 
-	// Construct library directory path
-	libraryDir := filepath.Join(c.baseDir, "libraries", org, library)
+// hashQuery creates a hash of the query string for caching
+func hashQuery(query string) string {
+	h := sha256.New()
+	io.WriteString(h, query)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
 
-	// Check if library exists
-	if _, err := os.Stat(libraryDir); os.IsNotExist(err) {
-		return fmt.Errorf("library not found in cache: %s", libraryID)
-	}
+// ListCachedLibraries returns all cached libraries with their versions
+func (c *Cache) ListCachedLibraries() ([]CachedLibrary, error) {
+	return c.store.List()
+}
 
-	// Remove the library directory
-	if err := os.RemoveAll(libraryDir); err != nil {
-		return fmt.Errorf("failed to remove library: %w", err)
+// MatchLibraries returns the cached libraries whose IDs match p
+func (c *Cache) MatchLibraries(p client.Pattern) ([]CachedLibrary, error) {
+	libraries, err := c.ListCachedLibraries()
+	if err != nil {
+		return nil, err
 	}
 
-	// Clean up empty parent directory (org folder)
-	orgDir := filepath.Join(c.baseDir, "libraries", org)
-	if entries, err := os.ReadDir(orgDir); err == nil && len(entries) == 0 {
-		os.Remove(orgDir)
+	var matched []CachedLibrary
+	for _, lib := range libraries {
+		if p.Match(lib.LibraryID) {
+			matched = append(matched, lib)
+		}
 	}
+	return matched, nil
+}
 
-	return nil
+// RemoveLibrary removes all cached versions of a specific library
+func (c *Cache) RemoveLibrary(libraryID string) error {
+	return c.store.Remove(libraryID, "")
 }
 
 // RemoveLibraryVersion removes a specific version of a library
 func (c *Cache) RemoveLibraryVersion(libraryID, version string) error {
-	// Normalize library ID
-	libraryID = strings.TrimPrefix(libraryID, "/")
-
-	// Get version directory path
-	versionDir := c.getCacheDir(libraryID, version)
-
-	// Check if version exists
-	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
-		return fmt.Errorf("version not found in cache: %s@%s", libraryID, version)
-	}
-
-	// Remove the version directory
-	if err := os.RemoveAll(versionDir); err != nil {
-		return fmt.Errorf("failed to remove version: %w", err)
-	}
-
-	// Clean up empty parent directories
-	parts := strings.Split(libraryID, "/")
-	if len(parts) >= 2 {
-		org := parts[0]
-		library := parts[1]
-
-		// Check if library directory is now empty
-		libraryDir := filepath.Join(c.baseDir, "libraries", org, library)
-		if entries, err := os.ReadDir(libraryDir); err == nil && len(entries) == 0 {
-			os.Remove(libraryDir)
-
-			// Check if org directory is now empty
-			orgDir := filepath.Join(c.baseDir, "libraries", org)
-			if entries, err := os.ReadDir(orgDir); err == nil && len(entries) == 0 {
-				os.Remove(orgDir)
-			}
-		}
-	}
-
-	return nil
+	return c.store.Remove(libraryID, versionKey(version))
 }
 
 // GetDetailedStats returns comprehensive cache statistics with per-library breakdown
@@ -568,15 +526,20 @@ func (c *Cache) GetDetailedStats() (*DetailedCacheStats, error) {
 		}
 	}
 
+	usage, _ := c.GetUsageStats()
+
 	return &DetailedCacheStats{
 		CacheStats:         *basicStats,
 		LibraryBreakdown:   libraryBreakdown,
 		SearchCacheSize:    searchCacheSize,
 		SearchCacheEntries: searchCacheEntries,
+		Usage:              usage,
 	}, nil
 }
 
-// Prune removes cache entries based on age criteria
+// Prune removes cache entries older than MaxAge and then, with a
+// TargetSize, the least recently fetched ones until the cache fits.
+// Pinned entries are never removed.
 func (c *Cache) Prune(opts PruneOptions) (*PruneResult, error) {
 	libraries, err := c.ListCachedLibraries()
 	if err != nil {
@@ -607,31 +570,71 @@ func (c *Cache) Prune(opts PruneOptions) (*PruneResult, error) {
 		}
 	}
 
-	// Iterate through all libraries and versions
+	remove := func(libraryID string, v VersionInfo) {
+		if !opts.DryRun {
+			// Remove the version
+			if err := c.RemoveLibraryVersion(libraryID, v.Version); err != nil {
+				// Log error but continue
+				return
+			}
+		}
+
+		result.RemovedCount++
+		result.FreedSpace += v.Size
+		result.RemovedItems = append(result.RemovedItems, libraryID+"@"+v.Version)
+	}
+
+	// Iterate through all libraries and versions, keeping what survives the
+	// age check for the size target
+	type candidate struct {
+		libraryID string
+		version   VersionInfo
+	}
+	var remaining []candidate
+	var remainingSize int64
 	for _, lib := range libraries {
 		for _, v := range lib.Versions {
+			// Shared caches are read-only and don't count towards the size
+			if v.Shared {
+				continue
+			}
+
 			// Check if this is the latest version and should be kept
 			if opts.KeepLatest && latestVersions[lib.LibraryID] == v.Version {
+				remainingSize += v.Size
 				continue
 			}
 
 			// Check age
 			age := now.Sub(v.FetchedAt)
-			if age > opts.MaxAge {
-				itemName := lib.LibraryID + "@" + v.Version
-
-				if !opts.DryRun {
-					// Remove the version
-					if err := c.RemoveLibraryVersion(lib.LibraryID, v.Version); err != nil {
-						// Log error but continue
-						continue
-					}
+			if opts.MaxAge > 0 && age > opts.MaxAge {
+				if v.Metadata.Pinned {
+					result.KeptPinned++
+					remainingSize += v.Size
+					continue
 				}
+				remove(lib.LibraryID, v)
+				continue
+			}
+
+			remainingSize += v.Size
+			if !v.Metadata.Pinned {
+				remaining = append(remaining, candidate{lib.LibraryID, v})
+			}
+		}
+	}
 
-				result.RemovedCount++
-				result.FreedSpace += v.Size
-				result.RemovedItems = append(result.RemovedItems, itemName)
+	// Evict the least recently fetched versions until the cache fits
+	if opts.TargetSize > 0 && remainingSize > opts.TargetSize {
+		sort.Slice(remaining, func(i, j int) bool {
+			return remaining[i].version.FetchedAt.Before(remaining[j].version.FetchedAt)
+		})
+		for _, cand := range remaining {
+			if remainingSize <= opts.TargetSize {
+				break
 			}
+			remove(cand.libraryID, cand.version)
+			remainingSize -= cand.version.Size
 		}
 	}
 
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+
+	"github.com/hsbacot/ctx7/budget"
+)
+
+// digestReader hashes and counts content as a store writes it, so the
+// SHA-256 and token estimate land in the metadata without a second pass
+type digestReader struct {
+	r    io.Reader
+	hash hash.Hash
+	size int64
+}
+
+func newDigestReader(r io.Reader) *digestReader {
+	h := sha256.New()
+	return &digestReader{r: io.TeeReader(r, h), hash: h}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.size += int64(n)
+	return n, err
+}
+
+// stamp records the digest of everything read so far in metadata
+func (d *digestReader) stamp(metadata *Metadata) {
+	metadata.SHA256 = hex.EncodeToString(d.hash.Sum(nil))
+	metadata.EstimatedTokens = budget.EstimateTokensForSize(d.size)
+}
+
+// stampDigest records the digest of in-memory content in metadata
+func stampDigest(content string, metadata *Metadata) {
+	sum := sha256.Sum256([]byte(content))
+	metadata.SHA256 = hex.EncodeToString(sum[:])
+	metadata.EstimatedTokens = budget.EstimateTokens(content)
+}
@@ -0,0 +1,368 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileStore keeps each cached version in its own directory:
+// baseDir/libraries/org/library/version/{metadata.json,content.txt}. List
+// and Stats are served from baseDir/index.json (see fileIndex).
+type fileStore struct {
+	baseDir string
+	tmpDir  string // Staging area for atomic writes
+
+	// progress, if set, follows rebuilds of the index
+	progress ScanProgressFunc
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	libsDir := filepath.Join(dir, "libraries")
+	if err := os.MkdirAll(libsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create libraries directory: %w", err)
+	}
+	tmpDir := tempDir(dir)
+	if err := prepareTempDir(tmpDir); err != nil {
+		return nil, err
+	}
+	return &fileStore{baseDir: dir, tmpDir: tmpDir}, nil
+}
+
+// Get reads the metadata and content for a library version
+func (s *fileStore) Get(libraryID, version string) (*CacheEntry, error) {
+	cacheDir, err := s.getCacheDir(libraryID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataPath := filepath.Join(cacheDir, "metadata.json")
+	contentPath := filepath.Join(cacheDir, "content.txt")
+
+	// Read metadata
+	metadataFile, err := os.Open(metadataPath)
+	if err != nil {
+		return nil, fmt.Errorf("cache miss: %w", err)
+	}
+	defer metadataFile.Close()
+
+	var metadata Metadata
+	if err := json.NewDecoder(metadataFile).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	// Read content
+	contentBytes, err := os.ReadFile(contentPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+
+	return &CacheEntry{
+		Metadata: metadata,
+		Content:  string(contentBytes),
+	}, nil
+}
+
+// Set writes content and metadata atomically
+func (s *fileStore) Set(libraryID, version, content string, metadata Metadata) error {
+	_, err := s.SetFrom(libraryID, version, strings.NewReader(content), metadata)
+	return err
+}
+
+// SetFrom streams r to a temp file, then atomically replaces the content
+// and metadata
+func (s *fileStore) SetFrom(libraryID, version string, r io.Reader, metadata Metadata) (int64, error) {
+	cacheDir, err := s.getCacheDir(libraryID, version)
+	if err != nil {
+		return 0, err
+	}
+
+	// Create cache directory
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write content atomically (write to temp file, then rename)
+	contentFile, err := createAtomic(s.tmpDir, filepath.Join(cacheDir, "content.txt"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create content file: %w", err)
+	}
+
+	digest := newDigestReader(r)
+	written, err := io.Copy(contentFile, digest)
+	if err != nil {
+		contentFile.Abort()
+		return 0, fmt.Errorf("failed to write content: %w", err)
+	}
+
+	if err := contentFile.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save content: %w", err)
+	}
+
+	digest.stamp(&metadata)
+	if err := s.writeMetadata(cacheDir, metadata); err != nil {
+		return 0, err
+	}
+	s.indexVersion(cacheDir, metadata)
+	return written, nil
+}
+
+// Open returns the content file of a library version
+func (s *fileStore) Open(libraryID, version string) (io.ReadCloser, error) {
+	cacheDir, err := s.getCacheDir(libraryID, version)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(cacheDir, "content.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("cache miss: %w", err)
+	}
+	return f, nil
+}
+
+// writeMetadata atomically replaces the metadata.json in cacheDir
+func (s *fileStore) writeMetadata(cacheDir string, metadata Metadata) error {
+	// Write metadata atomically
+	metadataFile, err := createAtomic(s.tmpDir, filepath.Join(cacheDir, "metadata.json"))
+	if err != nil {
+		return fmt.Errorf("failed to create metadata file: %w", err)
+	}
+
+	encoder := json.NewEncoder(metadataFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(metadata); err != nil {
+		metadataFile.Abort()
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if err := metadataFile.Commit(); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return nil
+}
+
+// List groups the indexed versions by library
+func (s *fileStore) List() ([]CachedLibrary, error) {
+	indexMu.Lock()
+	idx, err := s.index()
+	indexMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map to group versions by library ID
+	libraryMap := make(map[string]*CachedLibrary)
+
+	for key, entry := range idx.Entries {
+		// Key format: org/library/version
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) < 3 {
+			continue
+		}
+
+		org := parts[0]
+		name := parts[1]
+		version := parts[2]
+		libraryID := "/" + org + "/" + name
+
+		// Create or get library entry
+		lib, exists := libraryMap[libraryID]
+		if !exists {
+			lib = &CachedLibrary{
+				LibraryID:    libraryID,
+				Organization: org,
+				Name:         name,
+				Versions:     []VersionInfo{},
+			}
+			libraryMap[libraryID] = lib
+		}
+
+		// Add version info
+		versionInfo := VersionInfo{
+			Version:   version,
+			IsDefault: version == "default",
+			Size:      entry.Size,
+			FetchedAt: entry.Metadata.FetchedAt,
+			Metadata:  entry.Metadata,
+		}
+		lib.Versions = append(lib.Versions, versionInfo)
+	}
+
+	// Convert map to slice
+	result := make([]CachedLibrary, 0, len(libraryMap))
+	for _, lib := range libraryMap {
+		sortVersions(lib.Versions)
+		result = append(result, *lib)
+	}
+
+	// Sort libraries by ID
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LibraryID < result[j].LibraryID
+	})
+
+	return result, nil
+}
+
+// Remove deletes a version directory, or the whole library when version is ""
+func (s *fileStore) Remove(libraryID, version string) error {
+	// Normalize library ID - remove leading slash if present
+	libraryID = strings.TrimPrefix(libraryID, "/")
+
+	org, library, err := splitLibraryID(libraryID)
+	if err != nil {
+		return err
+	}
+	versionDir, err := s.getCacheDir(libraryID, version)
+	if err != nil {
+		return err
+	}
+
+	libraryDir := filepath.Join(s.baseDir, "libraries", org, library)
+	orgDir := filepath.Join(s.baseDir, "libraries", org)
+
+	if version == "" {
+		// Check if library exists
+		if _, err := os.Stat(libraryDir); os.IsNotExist(err) {
+			return fmt.Errorf("library not found in cache: %s", libraryID)
+		}
+
+		// Remove the library directory
+		if err := os.RemoveAll(libraryDir); err != nil {
+			return fmt.Errorf("failed to remove library: %w", err)
+		}
+
+		// Clean up empty parent directory (org folder)
+		if entries, err := os.ReadDir(orgDir); err == nil && len(entries) == 0 {
+			os.Remove(orgDir)
+		}
+
+		prefix := s.indexKey(libraryDir) + "/"
+		s.updateIndex(func(idx *fileIndex) {
+			for key := range idx.Entries {
+				if strings.HasPrefix(key, prefix) {
+					delete(idx.Entries, key)
+				}
+			}
+		})
+		return nil
+	}
+
+	// Check if version exists
+	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
+		return fmt.Errorf("version not found in cache: %s@%s", libraryID, version)
+	}
+
+	// Remove the version directory
+	if err := os.RemoveAll(versionDir); err != nil {
+		return fmt.Errorf("failed to remove version: %w", err)
+	}
+	s.updateIndex(func(idx *fileIndex) {
+		delete(idx.Entries, s.indexKey(versionDir))
+	})
+
+	// Clean up empty parent directories
+	if entries, err := os.ReadDir(libraryDir); err == nil && len(entries) == 0 {
+		os.Remove(libraryDir)
+
+		// Check if org directory is now empty
+		if entries, err := os.ReadDir(orgDir); err == nil && len(entries) == 0 {
+			os.Remove(orgDir)
+		}
+	}
+
+	return nil
+}
+
+// Stats sums the sizes and age bounds of the indexed versions
+func (s *fileStore) Stats() (*CacheStats, error) {
+	indexMu.Lock()
+	idx, err := s.index()
+	indexMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CacheStats{
+		OldestEntry: time.Now(),
+		NewestEntry: time.Time{},
+	}
+
+	for _, entry := range idx.Entries {
+		stats.TotalEntries++
+		stats.TotalSize += entry.Size
+
+		// Update oldest/newest
+		if entry.Modified.Before(stats.OldestEntry) {
+			stats.OldestEntry = entry.Modified
+		}
+		if entry.Modified.After(stats.NewestEntry) {
+			stats.NewestEntry = entry.Modified
+		}
+	}
+
+	return stats, nil
+}
+
+// Clear removes and recreates the libraries directory
+func (s *fileStore) Clear() error {
+	libsDir := filepath.Join(s.baseDir, "libraries")
+
+	// Remove libraries directory
+	if err := os.RemoveAll(libsDir); err != nil {
+		return fmt.Errorf("failed to clear libraries cache: %w", err)
+	}
+
+	// Recreate directory
+	if err := os.MkdirAll(libsDir, 0755); err != nil {
+		return fmt.Errorf("failed to recreate libraries directory: %w", err)
+	}
+
+	s.updateIndex(func(idx *fileIndex) {
+		clear(idx.Entries)
+	})
+	return nil
+}
+
+// Location returns the directory holding a version's metadata.json and content.txt
+func (s *fileStore) Location(libraryID, version string) string {
+	dir, _ := s.getCacheDir(libraryID, version)
+	return dir
+}
+
+// getCacheDir returns the cache directory path for a library version:
+// baseDir/libraries/org/library/version. IDs and versions with empty, "."
+// or ".." parts are refused, as they would lead elsewhere; they come from
+// users and, through ctx7 serve, from URLs.
+func (s *fileStore) getCacheDir(libraryID, version string) (string, error) {
+	libsDir := filepath.Join(s.baseDir, "libraries")
+	parts := strings.Split(strings.TrimPrefix(libraryID, "/"), "/")
+	parts = append(parts, strings.Split(versionKey(version), "/")...)
+	if !validPathParts(parts) {
+		return "", fmt.Errorf("%w: %s@%s", ErrInvalidID, libraryID, versionKey(version))
+	}
+
+	dir := filepath.Join(append([]string{libsDir}, parts...)...)
+	if rel, err := filepath.Rel(libsDir, dir); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s@%s", ErrInvalidID, libraryID, versionKey(version))
+	}
+	return dir, nil
+}
+
+// sortVersions orders versions: default first, then by version string
+func sortVersions(versions []VersionInfo) {
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].IsDefault {
+			return true
+		}
+		if versions[j].IsDefault {
+			return false
+		}
+		return versions[i].Version < versions[j].Version
+	})
+}
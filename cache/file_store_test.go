@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// IDs and versions with "..", "." or empty parts would be stored outside
+// the libraries directory
+func TestFileStoreRefusesTraversal(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "cache")
+	s, err := newFileStore(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs := []struct{ id, version string }{
+		{"/../../../x/y", ""},
+		{"/x/../../y", ""},
+		{"/x/y", "../../../z"},
+		{"/x/y", ".."},
+		{"/x//y", ""},
+		{"/./y", ""},
+		{`/x\..\..\y/z`, ""},
+	}
+	for _, ref := range refs {
+		if err := s.Set(ref.id, ref.version, "escaped", Metadata{LibraryID: ref.id}); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Set(%q, %q) = %v, want %v", ref.id, ref.version, err, ErrInvalidID)
+		}
+		if _, err := s.Get(ref.id, ref.version); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Get(%q, %q) = %v, want %v", ref.id, ref.version, err, ErrInvalidID)
+		}
+		if err := s.Remove(ref.id, ref.version); err == nil {
+			t.Errorf("Remove(%q, %q) succeeded", ref.id, ref.version)
+		}
+		if loc := s.Location(ref.id, ref.version); loc != "" {
+			t.Errorf("Location(%q, %q) = %q, want none", ref.id, ref.version, loc)
+		}
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("wrote next to the cache: %v", entries)
+	}
+	if libs, err := s.List(); err != nil || len(libs) != 0 {
+		t.Errorf("List() = %v, %v, want nothing", libs, err)
+	}
+}
+
+// An index key leading out of the libraries directory, left by a ctx7
+// without the check, is dropped when the index is read
+func TestIndexDropsTraversalKeys(t *testing.T) {
+	s, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("/x/y", "", "doc", Metadata{LibraryID: "/x/y"}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := s.loadIndex()
+	idx.Entries["../../../x/y/default"] = idx.Entries["x/y/default"]
+	if err := s.saveIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	libs, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(libs) != 1 || libs[0].LibraryID != "/x/y" {
+		t.Errorf("List() = %+v, want /x/y alone", libs)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import "time"
+
+// Freshness classifies a cache entry's age against the cache TTL
+type Freshness int
+
+const (
+	// Fresh entries are younger than half the TTL
+	Fresh Freshness = iota
+	// Stale entries are still served but will expire soon
+	Stale
+	// Expired entries are older than the TTL and refetched on next use
+	Expired
+)
+
+var freshnessNames = map[Freshness]string{
+	Fresh:   "fresh",
+	Stale:   "stale",
+	Expired: "expired",
+}
+
+func (f Freshness) String() string {
+	return freshnessNames[f]
+}
+
+// FreshnessOf classifies an entry fetched at fetchedAt as of now
+func FreshnessOf(fetchedAt, now time.Time, maxAge time.Duration) Freshness {
+	age := now.Sub(fetchedAt)
+	switch {
+	case age > maxAge:
+		return Expired
+	case age > maxAge/2:
+		return Stale
+	default:
+		return Fresh
+	}
+}
+
+// Staleness is how far a cached document may have fallen behind context7:
+// its age, or 0 when the library is known not to have changed since the
+// fetch. upstreamUpdated is the library's last update as context7 reports
+// it now (zero when unknown); the date stored with the entry was recorded
+// at fetch time and says nothing about later changes.
+func Staleness(fetchedAt, upstreamUpdated, now time.Time) time.Duration {
+	if !upstreamUpdated.IsZero() && !upstreamUpdated.After(fetchedAt) {
+		return 0
+	}
+	return now.Sub(fetchedAt)
+}
@@ -0,0 +1,339 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexFormat is bumped whenever indexEntry changes, so an index written
+// by an older ctx7 is rebuilt rather than misread
+const indexFormat = 1
+
+// indexMu serializes read-modify-write of index.json within a process;
+// index.json.lock serializes it between processes (see lockIndex)
+var indexMu sync.Mutex
+
+// scanWorkers is how many library directories are read at once when the
+// index is rebuilt. The scan waits on the filesystem rather than the CPU,
+// and on NFS or WSL overlapping the reads pays off.
+const scanWorkers = 8
+
+// ScanProgressFunc is called as the index of a files cache is rebuilt,
+// with the number of library directories scanned so far out of total.
+// Calls never overlap.
+type ScanProgressFunc func(done, total int)
+
+// fileIndex is index.json at the root of a files cache. It holds what List
+// and Stats would otherwise walk every version directory for, and is
+// updated by each write and removal.
+type fileIndex struct {
+	Format  int                   `json:"format"`
+	Entries map[string]indexEntry `json:"entries"` // By org/library/version
+}
+
+// indexEntry describes one stored version
+type indexEntry struct {
+	Size     int64     `json:"size"`     // metadata.json + content.txt
+	Modified time.Time `json:"modified"` // When metadata.json was written
+	Metadata Metadata  `json:"metadata"`
+}
+
+// Reindex rebuilds index.json from the version directories, returning the
+// number of versions indexed
+func (s *fileStore) Reindex() (int, error) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	idx, err := s.scan()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.saveIndex(idx); err != nil {
+		return 0, err
+	}
+	return len(idx.Entries), nil
+}
+
+// index returns the index, rebuilt from the version directories when
+// index.json is missing, unreadable or from another format. The rebuilt
+// index is saved under index.json.lock; read-only stores, and stores whose
+// lock is held for too long, keep it in memory only. Callers hold indexMu.
+func (s *fileStore) index() (*fileIndex, error) {
+	if idx := s.loadIndex(); idx != nil {
+		return idx, nil
+	}
+	if s.readOnly() {
+		return s.scan()
+	}
+
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return s.scan()
+	}
+	defer unlock()
+	return s.lockedIndex()
+}
+
+// lockedIndex is index for callers holding index.json.lock. Another
+// process may have saved the index while the lock was awaited.
+func (s *fileStore) lockedIndex() (*fileIndex, error) {
+	if idx := s.loadIndex(); idx != nil {
+		return idx, nil
+	}
+	idx, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+	// Best-effort: an index that failed to save is rebuilt next time
+	_ = s.saveIndex(idx)
+	return idx, nil
+}
+
+// loadIndex reads index.json, or returns nil when it is missing,
+// unreadable or from another format
+func (s *fileStore) loadIndex() *fileIndex {
+	data, err := os.ReadFile(s.indexPath())
+	if err != nil {
+		return nil
+	}
+	var idx fileIndex
+	if json.Unmarshal(data, &idx) != nil || idx.Format != indexFormat || idx.Entries == nil {
+		return nil
+	}
+	// Keys with empty, "." or ".." parts don't name a version directory;
+	// drop any that older ctx7 versions let in
+	for key := range idx.Entries {
+		if !validPathParts(strings.Split(key, "/")) {
+			delete(idx.Entries, key)
+		}
+	}
+	return &idx
+}
+
+// updateIndex applies fn to the index under index.json.lock, so updates
+// from concurrent ctx7 processes don't undo each other. An index that
+// can't be updated is removed instead, so the next List or Stats rebuilds
+// it rather than serving it stale.
+func (s *fileStore) updateIndex(fn func(idx *fileIndex)) {
+	if s.readOnly() {
+		return
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	unlock, err := s.lockIndex()
+	if err == nil {
+		defer unlock()
+		var idx *fileIndex
+		if idx, err = s.lockedIndex(); err == nil {
+			fn(idx)
+			err = s.saveIndex(idx)
+		}
+	}
+	if err != nil {
+		os.Remove(s.indexPath())
+	}
+}
+
+// lockIndex takes index.json.lock (see lockFile)
+func (s *fileStore) lockIndex() (unlock func(), err error) {
+	unlock, err = lockFile(s.indexPath() + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cache index: %w", err)
+	}
+	return unlock, nil
+}
+
+// indexVersion records the version stored in cacheDir with its metadata
+func (s *fileStore) indexVersion(cacheDir string, metadata Metadata) {
+	key := s.indexKey(cacheDir)
+	if key == "" {
+		return
+	}
+	entry, err := newIndexEntry(cacheDir, metadata)
+	s.updateIndex(func(idx *fileIndex) {
+		if err != nil {
+			// Unreadable right after being written: leave it to the next rebuild
+			delete(idx.Entries, key)
+			return
+		}
+		idx.Entries[key] = entry
+	})
+}
+
+// saveIndex atomically replaces index.json
+func (s *fileStore) saveIndex(idx *fileIndex) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache index: %w", err)
+	}
+	if err := writeFileAtomic(s.tmpDir, s.indexPath(), data); err != nil {
+		return fmt.Errorf("failed to save cache index: %w", err)
+	}
+	return nil
+}
+
+// scan walks the library directories for the stored versions, several at
+// once, reporting each one done to s.progress. Versions with unreadable
+// metadata are left out.
+func (s *fileStore) scan() (*fileIndex, error) {
+	idx := &fileIndex{Format: indexFormat, Entries: make(map[string]indexEntry)}
+
+	dirs, err := libraryDirs(filepath.Join(s.baseDir, "libraries"))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		done     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	queue := make(chan string)
+	for range min(scanWorkers, len(dirs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range queue {
+				entries, err := s.scanLibrary(dir)
+
+				mu.Lock()
+				maps.Copy(idx.Entries, entries)
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				done++
+				if s.progress != nil {
+					s.progress(done, len(dirs))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, dir := range dirs {
+		queue <- dir
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, fmt.Errorf("failed to walk cache directory: %w", firstErr)
+	}
+	return idx, nil
+}
+
+// libraryDirs returns the org/library directories in librariesDir
+func libraryDirs(librariesDir string) ([]string, error) {
+	orgs, err := os.ReadDir(librariesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var dirs []string
+	for _, org := range orgs {
+		if !org.IsDir() {
+			continue
+		}
+		libs, err := os.ReadDir(filepath.Join(librariesDir, org.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		for _, lib := range libs {
+			if lib.IsDir() {
+				dirs = append(dirs, filepath.Join(librariesDir, org.Name(), lib.Name()))
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// scanLibrary walks one library directory for its stored versions
+func (s *fileStore) scanLibrary(libraryDir string) (map[string]indexEntry, error) {
+	entries := make(map[string]indexEntry)
+	err := filepath.Walk(libraryDir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil // Removed while being scanned
+		}
+		if err != nil {
+			return err
+		}
+
+		// Skip if not a metadata.json file
+		if info.IsDir() || info.Name() != "metadata.json" {
+			return nil
+		}
+
+		// Path format: .../libraries/org/library/version/metadata.json
+		key := s.indexKey(filepath.Dir(path))
+		if strings.Count(key, "/") < 2 {
+			return nil // Invalid path structure
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil // Skip corrupted files
+		}
+		var metadata Metadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil // Skip corrupted metadata
+		}
+
+		if entry, err := newIndexEntry(filepath.Dir(path), metadata); err == nil {
+			entries[key] = entry
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// newIndexEntry describes the version stored in cacheDir
+func newIndexEntry(cacheDir string, metadata Metadata) (indexEntry, error) {
+	info, err := os.Stat(filepath.Join(cacheDir, "metadata.json"))
+	if err != nil {
+		return indexEntry{}, err
+	}
+	entry := indexEntry{Size: info.Size(), Modified: info.ModTime(), Metadata: metadata}
+	if contentInfo, err := os.Stat(filepath.Join(cacheDir, "content.txt")); err == nil {
+		entry.Size += contentInfo.Size()
+	}
+	return entry, nil
+}
+
+// indexKey returns the org/library/version key of a version directory
+// indexKey returns the org/library/version key of a version directory,
+// or "" for a directory outside the libraries directory
+func (s *fileStore) indexKey(cacheDir string) string {
+	rel, err := filepath.Rel(filepath.Join(s.baseDir, "libraries"), cacheDir)
+	if err != nil || !filepath.IsLocal(rel) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (s *fileStore) indexPath() string {
+	return filepath.Join(s.baseDir, "index.json")
+}
+
+func (s *fileStore) setScanProgress(fn ScanProgressFunc) {
+	s.progress = fn
+}
+
+// readOnly reports whether the store was opened as a shared cache
+func (s *fileStore) readOnly() bool {
+	return s.tmpDir == ""
+}
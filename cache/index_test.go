@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestFileStore(t *testing.T, dir string) *fileStore {
+	t.Helper()
+	s, err := newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func setDoc(t *testing.T, s Store, id, version string) {
+	t.Helper()
+	if err := s.Set(id, version, "docs for "+id+"@"+version, Metadata{LibraryID: id, FetchedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The index kept up to date by writes and removals holds what a full scan
+// of the version directories finds
+func TestReindexMatchesUpdates(t *testing.T) {
+	s := newTestFileStore(t, t.TempDir())
+	for _, id := range []string{"/facebook/react", "/vercel/next.js", "/zod/zod"} {
+		setDoc(t, s, id, "")
+		setDoc(t, s, id, "v1.0.0")
+	}
+	setDoc(t, s, "/facebook/react", "")              // Replaced
+	if err := s.Remove("/zod/zod", ""); err != nil { // Every version
+		t.Fatal(err)
+	}
+	if err := s.Remove("/vercel/next.js", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := s.loadIndex()
+	if updated == nil {
+		t.Fatal("no index.json after writing")
+	}
+	n, err := s.Reindex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned := s.loadIndex()
+	if n != len(scanned.Entries) || n != 3 {
+		t.Errorf("Reindex = %d with %d entries saved, want 3", n, len(scanned.Entries))
+	}
+
+	for _, key := range sortedKeys(updated, scanned) {
+		u, ok1 := updated.Entries[key]
+		sc, ok2 := scanned.Entries[key]
+		switch {
+		case !ok1 || !ok2:
+			t.Errorf("%s only in one index: updated %v, scanned %v", key, ok1, ok2)
+		case u.Size != sc.Size || !u.Modified.Equal(sc.Modified) || u.Metadata.SHA256 != sc.Metadata.SHA256:
+			t.Errorf("%s: updated %+v, scanned %+v", key, u, sc)
+		}
+	}
+}
+
+// A lock left by a process that died holding it is taken over
+func TestStaleIndexLock(t *testing.T) {
+	s := newTestFileStore(t, t.TempDir())
+	lock := s.indexPath() + ".lock"
+	if err := os.WriteFile(lock, []byte("99999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	then := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(lock, then, then); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	setDoc(t, s, "/facebook/react", "")
+	if took := time.Since(start); took > lockWait/2 {
+		t.Errorf("Set took %v behind a stale lock", took)
+	}
+	if idx := s.loadIndex(); idx == nil || len(idx.Entries) != 1 {
+		t.Errorf("index after Set = %+v, want the new version", idx)
+	}
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Errorf("lock left behind: %v", err)
+	}
+	if left, _ := filepath.Glob(lock + "*"); len(left) != 0 {
+		t.Errorf("left behind: %q", left)
+	}
+}
+
+// A holder whose lock was taken over doesn't release the lock of the
+// process that took it
+func TestTakenOverLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json.lock")
+	unlockDead, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	then := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(path, then, then); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockDead()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the taken over holder released the new lock: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock left after unlock: %v", err)
+	}
+}
+
+// Versions written at once by several ctx7 processes all end up in
+// index.json
+func TestConcurrentSetIndexed(t *testing.T) {
+	dir := t.TempDir()
+	const procs = 6
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		want []string
+	)
+	for i := range procs {
+		id := fmt.Sprintf("/org/lib%d", i)
+		want = append(want, strings.TrimPrefix(id, "/")+"/default")
+		wg.Go(func() {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperSet$")
+			cmd.Env = append(os.Environ(), "CTX7_TEST_SET_DIR="+dir, "CTX7_TEST_SET_ID="+id)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				mu.Lock()
+				t.Errorf("setting %s: %v\n%s", id, err, out)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	idx := newTestFileStore(t, dir).loadIndex()
+	if idx == nil {
+		t.Fatal("no index.json")
+	}
+	if got := sortedKeys(idx); !slices.Equal(got, want) {
+		t.Errorf("index.json holds %q, want %q", got, want)
+	}
+}
+
+// TestHelperSet writes one version for TestConcurrentSetIndexed from a
+// process of its own
+func TestHelperSet(t *testing.T) {
+	dir, id := os.Getenv("CTX7_TEST_SET_DIR"), os.Getenv("CTX7_TEST_SET_ID")
+	if dir == "" {
+		t.Skip("run by TestConcurrentSetIndexed")
+	}
+	setDoc(t, newTestFileStore(t, dir), id, "")
+}
+
+// sortedKeys returns the keys in any of the indexes, sorted
+func sortedKeys(indexes ...*fileIndex) []string {
+	var keys []string
+	for _, idx := range indexes {
+		for key := range idx.Entries {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,15 @@
+package cache
+
+// Library returns the cached library with the given ID and all its versions
+func (c *Cache) Library(libraryID string) (*CachedLibrary, error) {
+	return c.findLibrary(libraryID)
+}
+
+// Location returns where a library version is stored on disk, or "" for
+// stores that keep nothing on disk
+func (c *Cache) Location(libraryID, version string) string {
+	if l, ok := c.store.(Locator); ok {
+		return l.Location(libraryID, version)
+	}
+	return ""
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrShared is returned when removing an entry that only a read-only
+// shared cache holds
+var ErrShared = errors.New("entry is in a read-only shared cache")
+
+// LayeredStore reads through a writable user store to read-only shared
+// stores beneath it, such as a system cache in /var/cache/ctx7 seeded in a
+// container image. Writes and removals only touch the user store, and a
+// version in the user store shadows the same version in the shared ones.
+type LayeredStore struct {
+	user   Store
+	shared []Store
+}
+
+// NewLayeredStore layers user over shared, searched in order
+func NewLayeredStore(user Store, shared ...Store) *LayeredStore {
+	return &LayeredStore{user: user, shared: shared}
+}
+
+// SharedDirs returns the shared cache directories listed in
+// $CTX7_SHARED_CACHE, separated like $PATH
+func SharedDirs() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv("CTX7_SHARED_CACHE")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// OpenSharedStore opens the cache at dir for reading only. The backend is
+// detected (a cache.db file means sqlite) and nothing is created in dir.
+func OpenSharedStore(dir string) (Store, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shared cache: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("failed to open shared cache: %s is not a directory", dir)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "cache.db")); err == nil {
+		return openSQLiteStoreReadOnly(dir)
+	}
+	return &fileStore{baseDir: dir}, nil
+}
+
+func (s *LayeredStore) layers() []Store {
+	return append([]Store{s.user}, s.shared...)
+}
+
+// Get returns the entry from the first layer that has it
+func (s *LayeredStore) Get(libraryID, version string) (*CacheEntry, error) {
+	entry, err := s.user.Get(libraryID, version)
+	if err == nil {
+		return entry, nil
+	}
+	for _, shared := range s.shared {
+		if entry, sharedErr := shared.Get(libraryID, version); sharedErr == nil {
+			return entry, nil
+		}
+	}
+	return nil, err
+}
+
+// Set saves to the user store
+func (s *LayeredStore) Set(libraryID, version, content string, metadata Metadata) error {
+	return s.user.Set(libraryID, version, content, metadata)
+}
+
+// SetFrom saves to the user store, streaming when it supports it
+func (s *LayeredStore) SetFrom(libraryID, version string, r io.Reader, metadata Metadata) (int64, error) {
+	if streaming, ok := s.user.(StreamingStore); ok {
+		return streaming.SetFrom(libraryID, version, r, metadata)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write content: %w", err)
+	}
+	return int64(len(data)), s.user.Set(libraryID, version, string(data), metadata)
+}
+
+// Open returns a reader over the content from the first layer that has it
+func (s *LayeredStore) Open(libraryID, version string) (io.ReadCloser, error) {
+	var firstErr error
+	for _, layer := range s.layers() {
+		r, err := openStore(layer, libraryID, version)
+		if err == nil {
+			return r, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// openStore opens a version's content, reading it whole from stores that
+// can't stream
+func openStore(store Store, libraryID, version string) (io.ReadCloser, error) {
+	if streaming, ok := store.(StreamingStore); ok {
+		return streaming.Open(libraryID, version)
+	}
+	entry, err := store.Get(libraryID, version)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(entry.Content)), nil
+}
+
+// Location returns where the layer holding the version keeps it
+func (s *LayeredStore) Location(libraryID, version string) string {
+	for _, layer := range s.layers() {
+		if _, err := layer.Get(libraryID, version); err != nil {
+			continue
+		}
+		if l, ok := layer.(Locator); ok {
+			return l.Location(libraryID, version)
+		}
+		return ""
+	}
+	return ""
+}
+
+// List merges the libraries of every layer. Versions from shared layers
+// are marked Shared; a version in an upper layer hides the same version
+// below it.
+func (s *LayeredStore) List() ([]CachedLibrary, error) {
+	libraries, err := s.user.List()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(libraries))
+	for i, lib := range libraries {
+		index[lib.LibraryID] = i
+	}
+
+	for _, shared := range s.shared {
+		sharedLibs, err := shared.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, lib := range sharedLibs {
+			i, ok := index[lib.LibraryID]
+			if !ok {
+				i = len(libraries)
+				index[lib.LibraryID] = i
+				libraries = append(libraries, CachedLibrary{
+					LibraryID:    lib.LibraryID,
+					Organization: lib.Organization,
+					Name:         lib.Name,
+				})
+			}
+			for _, v := range lib.Versions {
+				if hasVersion(libraries[i].Versions, v.Version) {
+					continue
+				}
+				v.Shared = true
+				libraries[i].Versions = append(libraries[i].Versions, v)
+			}
+		}
+	}
+
+	for i := range libraries {
+		sortVersions(libraries[i].Versions)
+	}
+	sort.Slice(libraries, func(i, j int) bool {
+		return libraries[i].LibraryID < libraries[j].LibraryID
+	})
+	return libraries, nil
+}
+
+// Reindex rebuilds the index of the user store; shared stores are read-only
+func (s *LayeredStore) Reindex() (int, error) {
+	if indexer, ok := s.user.(Indexer); ok {
+		return indexer.Reindex()
+	}
+	return 0, ErrNoIndex
+}
+
+func (s *LayeredStore) setScanProgress(fn ScanProgressFunc) {
+	for _, layer := range s.layers() {
+		if p, ok := layer.(scanReporter); ok {
+			p.setScanProgress(fn)
+		}
+	}
+}
+
+func hasVersion(versions []VersionInfo, version string) bool {
+	for _, v := range versions {
+		if v.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes from the user store. Removing a version only a shared
+// layer holds is an ErrShared error.
+func (s *LayeredStore) Remove(libraryID, version string) error {
+	err := s.user.Remove(libraryID, version)
+	if err == nil {
+		return nil
+	}
+	for _, shared := range s.shared {
+		if sharedHas(shared, libraryID, version) {
+			return fmt.Errorf("failed to remove %s: %w", libraryID, ErrShared)
+		}
+	}
+	return err
+}
+
+// sharedHas reports whether store holds the version, or any version of
+// the library when version is ""
+func sharedHas(store Store, libraryID, version string) bool {
+	if version != "" {
+		_, err := store.Get(libraryID, version)
+		return err == nil
+	}
+	libraries, err := store.List()
+	if err != nil {
+		return false
+	}
+	for _, lib := range libraries {
+		if strings.TrimPrefix(lib.LibraryID, "/") == strings.TrimPrefix(libraryID, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats totals the merged listing, so shadowed versions count once
+func (s *LayeredStore) Stats() (*CacheStats, error) {
+	libraries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CacheStats{OldestEntry: time.Now()}
+	for _, lib := range libraries {
+		for _, v := range lib.Versions {
+			stats.TotalEntries++
+			stats.TotalSize += v.Size
+			if v.FetchedAt.Before(stats.OldestEntry) {
+				stats.OldestEntry = v.FetchedAt
+			}
+			if v.FetchedAt.After(stats.NewestEntry) {
+				stats.NewestEntry = v.FetchedAt
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Clear empties the user store; shared caches are left alone
+func (s *LayeredStore) Clear() error {
+	return s.user.Clear()
+}
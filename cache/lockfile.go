@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// lockWait bounds how long a lock file is waited for while another
+	// process holds it
+	lockWait = 10 * time.Second
+	// lockStale is the age past which a lock file is taken to be left by
+	// a process that died holding it. Holders touch it every lockStale/4,
+	// so a live lock never gets this old.
+	lockStale = 2 * time.Minute
+	// lockPoll is how often a held lock is retried
+	lockPoll = 10 * time.Millisecond
+)
+
+// errLockHeld is returned by lockFile when the lock stays held past lockWait
+var errLockHeld = errors.New("held by another ctx7 process")
+
+// lockFile takes the lock file at path, creating it exclusively, and
+// returns the function that releases it. The lock's mtime is touched
+// while it is held; a lock untouched for lockStale is taken over.
+func lockFile(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			// The PID is for people; the time tells this holder's lock
+			// from a later one that reused the inode
+			owner := fmt.Sprintf("%d %d\n", os.Getpid(), time.Now().UnixNano())
+			_, err = f.WriteString(owner)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return holdLock(path, owner), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			stealLock(path, info)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is %w", path, errLockHeld)
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// holdLock touches the lock at path every lockStale/4 until the returned
+// unlock is called, which removes it. The lock is only touched or removed
+// while it still holds owner, so a holder that was taken over never
+// releases the lock of the process that took it.
+func holdLock(path, owner string) (unlock func()) {
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockStale / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if ownsLock(path, owner) {
+					now := time.Now()
+					os.Chtimes(path, now, now)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		if ownsLock(path, owner) {
+			os.Remove(path)
+		}
+	}
+}
+
+// stealLock removes the stale lock at path, seen as stale. It is first
+// moved to a name of its own, so of several processes taking it over only
+// one removes it; a lock that turns out to have been replaced in the
+// meantime is put back.
+func stealLock(path string, stale os.FileInfo) {
+	taken := path + ".stale." + strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if os.Rename(path, taken) != nil {
+		return
+	}
+	if info, err := os.Stat(taken); err == nil && os.SameFile(info, stale) && info.ModTime().Equal(stale.ModTime()) {
+		os.Remove(taken)
+		return
+	}
+	// Link rather than rename, so a lock taken since isn't overwritten
+	os.Link(taken, path)
+	os.Remove(taken)
+}
+
+// ownsLock reports whether the lock at path is still the one written by owner
+func ownsLock(path, owner string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && string(data) == owner
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store, useful for tests and for embedding
+// ctx7 where nothing should touch disk
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]map[string]CacheEntry // libraryID -> version -> entry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]map[string]CacheEntry)}
+}
+
+// Get returns a copy of the stored entry
+func (s *MemoryStore) Get(libraryID, version string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[normalizeID(libraryID)][versionKey(version)]
+	if !ok {
+		return nil, fmt.Errorf("cache miss: %s@%s", libraryID, versionKey(version))
+	}
+	return &entry, nil
+}
+
+// Set stores content and metadata
+func (s *MemoryStore) Set(libraryID, version, content string, metadata Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stampDigest(content, &metadata)
+
+	id := normalizeID(libraryID)
+	if s.entries[id] == nil {
+		s.entries[id] = make(map[string]CacheEntry)
+	}
+	s.entries[id][versionKey(version)] = CacheEntry{Metadata: metadata, Content: content}
+	return nil
+}
+
+// List returns all libraries sorted by ID
+func (s *MemoryStore) List() ([]CachedLibrary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]CachedLibrary, 0, len(s.entries))
+	for id, versions := range s.entries {
+		org, name, _ := splitLibraryID(id)
+		lib := CachedLibrary{LibraryID: id, Organization: org, Name: name, Versions: []VersionInfo{}}
+		for version, entry := range versions {
+			lib.Versions = append(lib.Versions, VersionInfo{
+				Version:   version,
+				IsDefault: version == "default",
+				Size:      int64(len(entry.Content)),
+				FetchedAt: entry.Metadata.FetchedAt,
+				Metadata:  entry.Metadata,
+			})
+		}
+		sortVersions(lib.Versions)
+		result = append(result, lib)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LibraryID < result[j].LibraryID
+	})
+	return result, nil
+}
+
+// Remove deletes one version, or every version when version is ""
+func (s *MemoryStore) Remove(libraryID, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := normalizeID(libraryID)
+	versions, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("library not found in cache: %s", libraryID)
+	}
+
+	if version == "" {
+		delete(s.entries, id)
+		return nil
+	}
+
+	if _, ok := versions[version]; !ok {
+		return fmt.Errorf("version not found in cache: %s@%s", libraryID, version)
+	}
+	delete(versions, version)
+	if len(versions) == 0 {
+		delete(s.entries, id)
+	}
+	return nil
+}
+
+// Stats summarizes stored entries
+func (s *MemoryStore) Stats() (*CacheStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := &CacheStats{OldestEntry: time.Now()}
+	for _, versions := range s.entries {
+		for _, entry := range versions {
+			stats.TotalEntries++
+			stats.TotalSize += int64(len(entry.Content))
+			if entry.Metadata.FetchedAt.Before(stats.OldestEntry) {
+				stats.OldestEntry = entry.Metadata.FetchedAt
+			}
+			if entry.Metadata.FetchedAt.After(stats.NewestEntry) {
+				stats.NewestEntry = entry.Metadata.FetchedAt
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Clear removes every entry
+func (s *MemoryStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]map[string]CacheEntry)
+	return nil
+}
@@ -0,0 +1,68 @@
+package cache
+
+import "fmt"
+
+// SetPinned pins or unpins one version of a library, or every cached
+// version when version is "". Pinned entries are skipped by Prune and stay
+// pinned when they are refreshed. It returns the affected versions.
+func (c *Cache) SetPinned(libraryID, version string, pinned bool) ([]string, error) {
+	lib, err := c.findLibrary(libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, v := range lib.Versions {
+		if version != "" && v.Version != versionKey(version) {
+			continue
+		}
+		if v.Metadata.Pinned == pinned {
+			changed = append(changed, v.Version)
+			continue
+		}
+
+		entry, err := c.store.Get(lib.LibraryID, v.Version)
+		if err != nil {
+			return changed, fmt.Errorf("failed to read %s@%s: %w", lib.LibraryID, v.Version, err)
+		}
+		entry.Metadata.Pinned = pinned
+		if err := c.store.Set(lib.LibraryID, v.Version, entry.Content, entry.Metadata); err != nil {
+			return changed, fmt.Errorf("failed to update %s@%s: %w", lib.LibraryID, v.Version, err)
+		}
+		changed = append(changed, v.Version)
+	}
+
+	if len(changed) == 0 {
+		return nil, fmt.Errorf("version not found in cache: %s@%s", lib.LibraryID, version)
+	}
+	return changed, nil
+}
+
+// isPinned reports whether the cached copy of a library version is pinned
+func (c *Cache) isPinned(libraryID, version string) bool {
+	lib, err := c.findLibrary(libraryID)
+	if err != nil {
+		return false
+	}
+	for _, v := range lib.Versions {
+		if v.Version == versionKey(version) {
+			return v.Metadata.Pinned
+		}
+	}
+	return false
+}
+
+// findLibrary returns the cached library with the given ID
+func (c *Cache) findLibrary(libraryID string) (*CachedLibrary, error) {
+	libraries, err := c.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range libraries {
+		if libraries[i].LibraryID == normalizeID(libraryID) {
+			return &libraries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("library not found in cache: %s", libraryID)
+}
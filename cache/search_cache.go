@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CachedSearch describes one cached search query
+type CachedSearch struct {
+	Query       string
+	CachedAt    time.Time
+	ResultCount int
+	Size        int64
+	path        string
+}
+
+// ListCachedSearches returns the cached search queries, newest first.
+// Unreadable entries are skipped.
+func (c *Cache) ListCachedSearches() ([]CachedSearch, error) {
+	searchDir := filepath.Join(c.baseDir, "searches")
+	entries, err := os.ReadDir(searchDir)
+	if os.IsNotExist(err) {
+		return []CachedSearch{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search cache: %w", err)
+	}
+
+	searches := []CachedSearch{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(searchDir, entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var cached struct {
+			Query     string            `json:"query"`
+			Timestamp time.Time         `json:"timestamp"`
+			Results   []json.RawMessage `json:"results"`
+		}
+		if err := json.Unmarshal(data, &cached); err != nil {
+			continue
+		}
+
+		searches = append(searches, CachedSearch{
+			Query:       cached.Query,
+			CachedAt:    cached.Timestamp,
+			ResultCount: len(cached.Results),
+			Size:        int64(len(data)),
+			path:        path,
+		})
+	}
+
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].CachedAt.After(searches[j].CachedAt)
+	})
+	return searches, nil
+}
+
+// PruneSearches removes cached searches older than maxAge
+func (c *Cache) PruneSearches(maxAge time.Duration, dryRun bool) (*PruneResult, error) {
+	searches, err := c.ListCachedSearches()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PruneResult{RemovedItems: []string{}}
+	now := c.now()
+	for _, s := range searches {
+		if now.Sub(s.CachedAt) <= maxAge {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(s.path); err != nil {
+				continue
+			}
+		}
+		result.RemovedCount++
+		result.FreedSpace += s.Size
+		result.RemovedItems = append(result.RemovedItems, fmt.Sprintf("%q", s.Query))
+	}
+	return result, nil
+}
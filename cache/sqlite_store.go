@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	library_id TEXT    NOT NULL,
+	version    TEXT    NOT NULL,
+	metadata   TEXT    NOT NULL,
+	content    BLOB    NOT NULL,
+	size       INTEGER NOT NULL,
+	fetched_at INTEGER NOT NULL,
+	PRIMARY KEY (library_id, version)
+);`
+
+// sqliteStore keeps every cached version in a single cache.db file,
+// which avoids thousands of small files and makes listing and stats a
+// single query.
+type sqliteStore struct {
+	db   *sql.DB
+	path string
+}
+
+func newSQLiteStore(dir string) (*sqliteStore, error) {
+	path := filepath.Join(dir, "cache.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA journal_mode=WAL; PRAGMA busy_timeout=5000;"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure cache database: %w", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create cache schema: %w", err)
+	}
+
+	return &sqliteStore{db: db, path: path}, nil
+}
+
+// openSQLiteStoreReadOnly opens an existing cache.db in dir without
+// creating or migrating anything, for shared caches
+func openSQLiteStoreReadOnly(dir string) (*sqliteStore, error) {
+	path := filepath.Join(dir, "cache.db")
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shared cache database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open shared cache database: %w", err)
+	}
+	return &sqliteStore{db: db, path: path}, nil
+}
+
+// Location returns the database file, which holds every version
+func (s *sqliteStore) Location(libraryID, version string) string {
+	return s.path
+}
+
+// Get reads an entry row and decodes its metadata
+func (s *sqliteStore) Get(libraryID, version string) (*CacheEntry, error) {
+	var metadataJSON string
+	var content []byte
+
+	err := s.db.QueryRow(
+		"SELECT metadata, content FROM entries WHERE library_id = ? AND version = ?",
+		normalizeID(libraryID), versionKey(version),
+	).Scan(&metadataJSON, &content)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("cache miss: %s@%s", libraryID, versionKey(version))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	return &CacheEntry{
+		Metadata: metadata,
+		Content:  string(content),
+	}, nil
+}
+
+// Set inserts or replaces an entry row
+func (s *sqliteStore) Set(libraryID, version, content string, metadata Metadata) error {
+	stampDigest(content, &metadata)
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO entries (library_id, version, metadata, content, size, fetched_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		normalizeID(libraryID), versionKey(version), string(metadataJSON), []byte(content),
+		int64(len(metadataJSON)+len(content)), metadata.FetchedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// List returns every library and version without reading content
+func (s *sqliteStore) List() ([]CachedLibrary, error) {
+	rows, err := s.db.Query("SELECT library_id, version, metadata, size FROM entries ORDER BY library_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	result := []CachedLibrary{}
+	for rows.Next() {
+		var libraryID, version, metadataJSON string
+		var size int64
+		if err := rows.Scan(&libraryID, &version, &metadataJSON, &size); err != nil {
+			return nil, fmt.Errorf("failed to read cache entry: %w", err)
+		}
+
+		var metadata Metadata
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			continue // Skip corrupted metadata
+		}
+
+		if len(result) == 0 || result[len(result)-1].LibraryID != libraryID {
+			org, name, _ := splitLibraryID(libraryID)
+			result = append(result, CachedLibrary{
+				LibraryID:    libraryID,
+				Organization: org,
+				Name:         name,
+				Versions:     []VersionInfo{},
+			})
+		}
+
+		lib := &result[len(result)-1]
+		lib.Versions = append(lib.Versions, VersionInfo{
+			Version:   version,
+			IsDefault: version == "default",
+			Size:      size,
+			FetchedAt: metadata.FetchedAt,
+			Metadata:  metadata,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+
+	for i := range result {
+		sortVersions(result[i].Versions)
+	}
+
+	return result, nil
+}
+
+// Remove deletes one version, or all versions when version is ""
+func (s *sqliteStore) Remove(libraryID, version string) error {
+	if _, _, err := splitLibraryID(libraryID); err != nil {
+		return err
+	}
+
+	var res sql.Result
+	var err error
+	if version == "" {
+		res, err = s.db.Exec("DELETE FROM entries WHERE library_id = ?", normalizeID(libraryID))
+	} else {
+		res, err = s.db.Exec("DELETE FROM entries WHERE library_id = ? AND version = ?",
+			normalizeID(libraryID), version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove cache entry: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		if version == "" {
+			return fmt.Errorf("library not found in cache: %s", libraryID)
+		}
+		return fmt.Errorf("version not found in cache: %s@%s", libraryID, version)
+	}
+
+	return nil
+}
+
+// Stats aggregates counts, sizes and fetch times in one query
+func (s *sqliteStore) Stats() (*CacheStats, error) {
+	var count int
+	var size, oldest, newest sql.NullInt64
+
+	err := s.db.QueryRow(
+		"SELECT COUNT(*), SUM(size), MIN(fetched_at), MAX(fetched_at) FROM entries",
+	).Scan(&count, &size, &oldest, &newest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache stats: %w", err)
+	}
+
+	stats := &CacheStats{
+		TotalEntries: count,
+		TotalSize:    size.Int64,
+		OldestEntry:  time.Now(),
+	}
+	if oldest.Valid {
+		stats.OldestEntry = time.Unix(0, oldest.Int64)
+	}
+	if newest.Valid {
+		stats.NewestEntry = time.Unix(0, newest.Int64)
+	}
+
+	return stats, nil
+}
+
+// Clear deletes every entry row
+func (s *sqliteStore) Clear() error {
+	if _, err := s.db.Exec("DELETE FROM entries"); err != nil {
+		return fmt.Errorf("failed to clear cache database: %w", err)
+	}
+	return nil
+}
+
+// normalizeID ensures a library ID has its leading slash
+func normalizeID(libraryID string) string {
+	if len(libraryID) > 0 && libraryID[0] == '/' {
+		return libraryID
+	}
+	return "/" + libraryID
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Store is the storage backend behind Cache. Implementations persist
+// library content and metadata; expiry, pruning and search caching are
+// handled by Cache on top of it.
+type Store interface {
+	// Get returns the entry for a library version ("" means the default version)
+	Get(libraryID, version string) (*CacheEntry, error)
+	// Set saves content and metadata for a library version, recording the
+	// content's SHA-256 and token estimate in the metadata
+	Set(libraryID, version, content string, metadata Metadata) error
+	// List returns all cached libraries with their versions
+	List() ([]CachedLibrary, error)
+	// Remove deletes one version of a library, or every version when version is ""
+	Remove(libraryID, version string) error
+	// Stats returns entry counts, sizes and age bounds for stored libraries
+	Stats() (*CacheStats, error)
+	// Clear removes every stored library
+	Clear() error
+}
+
+// StreamingStore is implemented by stores that can save content as it
+// arrives and read it back without holding it in memory whole
+type StreamingStore interface {
+	// SetFrom saves everything read from r as a library version's content,
+	// returning the number of bytes written. The digest is taken in the same
+	// pass as the write. Nothing is replaced if r fails.
+	SetFrom(libraryID, version string, r io.Reader, metadata Metadata) (int64, error)
+	// Open returns a reader over a library version's content
+	Open(libraryID, version string) (io.ReadCloser, error)
+}
+
+// Locator is implemented by stores that keep entries on disk
+type Locator interface {
+	// Location returns where a library version is stored
+	Location(libraryID, version string) string
+}
+
+// Indexer is implemented by stores that serve List and Stats from an index
+type Indexer interface {
+	// Reindex rebuilds the index from the stored entries, returning how
+	// many it holds
+	Reindex() (int, error)
+}
+
+// scanReporter is implemented by stores whose index rebuilds can be followed
+type scanReporter interface {
+	setScanProgress(fn ScanProgressFunc)
+}
+
+// Backend names a Store implementation
+type Backend string
+
+const (
+	// BackendFiles stores each version as metadata.json + content.txt under libraries/
+	BackendFiles Backend = "files"
+	// BackendSQLite stores everything in a single cache.db file
+	BackendSQLite Backend = "sqlite"
+)
+
+// OpenStore creates the Store for a backend rooted at dir
+func OpenStore(backend Backend, dir string) (Store, error) {
+	switch backend {
+	case "", BackendFiles:
+		return newFileStore(dir)
+	case BackendSQLite:
+		return newSQLiteStore(dir)
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s (expected: files, sqlite)", backend)
+	}
+}
+
+// splitLibraryID splits "/org/library" (leading slash optional) into its parts
+func splitLibraryID(libraryID string) (org, library string, err error) {
+	parts := strings.Split(strings.TrimPrefix(libraryID, "/"), "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("invalid library ID format: %s (expected: org/library)", libraryID)
+	}
+	return parts[0], parts[1], nil
+}
+
+// validPathParts reports whether each part of a library ID or version
+// names a directory of its own: not empty, "." or "..", and without a
+// backslash, which Windows reads as a separator
+func validPathParts(parts []string) bool {
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." || strings.ContainsRune(p, '\\') {
+			return false
+		}
+	}
+	return true
+}
+
+// versionKey maps the empty version to the "default" key used on disk
+func versionKey(version string) string {
+	if version == "" {
+		return "default"
+	}
+	return version
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// forEachBackend runs test against an empty Store of every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, s Store)) {
+	for _, backend := range []Backend{BackendFiles, BackendSQLite} {
+		t.Run(string(backend), func(t *testing.T) {
+			s, err := OpenStore(backend, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, s)
+		})
+	}
+}
+
+var fetched = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func TestStoreGetSet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		if _, err := s.Get("/facebook/react", ""); err == nil {
+			t.Fatal("Get on an empty store succeeded")
+		}
+
+		meta := Metadata{LibraryID: "/facebook/react", Title: "React", FetchedAt: fetched}
+		if err := s.Set("/facebook/react", "", "# React", meta); err != nil {
+			t.Fatal(err)
+		}
+		entry, err := s.Get("/facebook/react", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Content != "# React" || entry.Metadata.Title != "React" || !entry.Metadata.FetchedAt.Equal(fetched) {
+			t.Errorf("Get = %q, %+v, want what was set", entry.Content, entry.Metadata)
+		}
+		if entry.Metadata.SHA256 == "" || entry.Metadata.EstimatedTokens == 0 {
+			t.Errorf("metadata = %+v, want the digest and token estimate filled in", entry.Metadata)
+		}
+
+		// Set replaces the version rather than adding one, with or without the
+		// leading slash
+		if err := s.Set("facebook/react", "", "# React 19", meta); err != nil {
+			t.Fatal(err)
+		}
+		if entry, err := s.Get("/facebook/react", ""); err != nil || entry.Content != "# React 19" {
+			t.Errorf("Get after replacing = %v, %v", entry, err)
+		}
+		if stats, err := s.Stats(); err != nil || stats.TotalEntries != 1 {
+			t.Errorf("Stats = %+v, %v, want 1 entry", stats, err)
+		}
+	})
+}
+
+func TestStoreVersions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		set := func(id, version string, age time.Duration) {
+			t.Helper()
+			meta := Metadata{LibraryID: id, Version: version, FetchedAt: fetched.Add(-age)}
+			if err := s.Set(id, version, "docs for "+id+"@"+version, meta); err != nil {
+				t.Fatal(err)
+			}
+		}
+		set("/facebook/react", "v18.3.1", time.Hour)
+		set("/facebook/react", "", 2*time.Hour)
+		set("/facebook/react", "v17.0.2", 3*time.Hour)
+		set("/vercel/next.js", "", 4*time.Hour)
+
+		if got := listed(t, s); !slices.Equal(got, []string{
+			"/facebook/react@default", "/facebook/react@v17.0.2", "/facebook/react@v18.3.1", "/vercel/next.js@default",
+		}) {
+			t.Errorf("List = %q", got)
+		}
+		if entry, err := s.Get("/facebook/react", "v17.0.2"); err != nil || entry.Content != "docs for /facebook/react@v17.0.2" {
+			t.Errorf("Get(v17.0.2) = %v, %v", entry, err)
+		}
+
+		stats, err := s.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.TotalEntries != 4 || stats.TotalSize == 0 || stats.OldestEntry.After(stats.NewestEntry) {
+			t.Errorf("Stats = %+v", stats)
+		}
+
+		if err := s.Remove("/facebook/react", "v17.0.2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Remove("/facebook/react", "v17.0.2"); err == nil {
+			t.Error("removing a removed version succeeded")
+		}
+		if got := listed(t, s); !slices.Equal(got, []string{"/facebook/react@default", "/facebook/react@v18.3.1", "/vercel/next.js@default"}) {
+			t.Errorf("List after removing v17.0.2 = %q", got)
+		}
+
+		if err := s.Remove("/facebook/react", ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := listed(t, s); !slices.Equal(got, []string{"/vercel/next.js@default"}) {
+			t.Errorf("List after removing React = %q", got)
+		}
+
+		if err := s.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		if got := listed(t, s); len(got) != 0 {
+			t.Errorf("List after Clear = %q", got)
+		}
+	})
+}
+
+// Pruning to a size removes the least recently fetched versions first,
+// across libraries, and never a pinned one
+func TestPruneOrder(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		c, err := NewCacheWithStore(t.TempDir(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		set := func(id, version string, age time.Duration, pinned bool) {
+			t.Helper()
+			meta := Metadata{LibraryID: id, FetchedAt: now.Add(-age), Pinned: pinned}
+			if err := c.SetWithVersion(id, version, "docs for "+id, meta); err != nil {
+				t.Fatal(err)
+			}
+		}
+		set("/zod/zod", "", 5*time.Hour, false)
+		set("/mui/material-ui", "", 4*time.Hour, true)
+		set("/facebook/react", "v17.0.2", 3*time.Hour, false)
+		set("/vercel/next.js", "", 2*time.Hour, false)
+		set("/facebook/react", "", time.Hour, false)
+
+		sizes := map[string]int64{}
+		libraries, err := c.ListCachedLibraries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, lib := range libraries {
+			for _, v := range lib.Versions {
+				sizes[lib.LibraryID+"@"+v.Version] = v.Size
+			}
+		}
+
+		tests := []struct {
+			name    string
+			opts    PruneOptions
+			removed []string
+			pinned  int
+		}{
+			{
+				name:    "to size",
+				opts:    PruneOptions{DryRun: true, TargetSize: sizes["/mui/material-ui@default"] + sizes["/facebook/react@default"]},
+				removed: []string{"/zod/zod@default", "/facebook/react@v17.0.2", "/vercel/next.js@default"},
+			},
+			{
+				name:    "to size, keeping the latest version",
+				opts:    PruneOptions{DryRun: true, KeepLatest: true, TargetSize: 1},
+				removed: []string{"/facebook/react@v17.0.2"},
+			},
+			{
+				name:    "by age",
+				opts:    PruneOptions{DryRun: true, MaxAge: 150 * time.Minute},
+				removed: []string{"/facebook/react@v17.0.2", "/zod/zod@default"},
+				pinned:  1,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result, err := c.Prune(tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				removed := result.RemovedItems
+				if tt.opts.TargetSize == 0 {
+					slices.Sort(removed) // Age pruning goes in listing order
+				}
+				if !slices.Equal(removed, tt.removed) || result.KeptPinned != tt.pinned {
+					t.Errorf("removed %q, kept %d pinned, want %q, %d", removed, result.KeptPinned, tt.removed, tt.pinned)
+				}
+			})
+		}
+
+		// Without a dry run the same versions go
+		if _, err := c.Prune(PruneOptions{TargetSize: tests[0].opts.TargetSize}); err != nil {
+			t.Fatal(err)
+		}
+		if got := listed(t, s); !slices.Equal(got, []string{"/facebook/react@default", "/mui/material-ui@default"}) {
+			t.Errorf("List after pruning = %q", got)
+		}
+	})
+}
+
+// listed returns the versions in s as "libraryID@version", sorted
+func listed(t *testing.T, s Store) []string {
+	t.Helper()
+	libraries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, lib := range libraries {
+		for _, v := range lib.Versions {
+			versions = append(versions, lib.LibraryID+"@"+v.Version)
+		}
+	}
+	slices.Sort(versions)
+	return versions
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalGrace is how long a journal can go untouched before it is taken
+// for the leftover of a crashed commit. Committing touches the journal
+// after every entry, so a live commit is never this old.
+const journalGrace = time.Minute
+
+// journalFile is the commit record inside a transaction's staging directory
+const journalFile = "journal.json"
+
+// journal lists the entries a committed transaction is moving into the
+// store; it is written before the first of them and removed after the last
+type journal struct {
+	Entries []journalEntry `json:"entries"`
+}
+
+// journalEntry is one staged library version
+type journalEntry struct {
+	LibraryID string   `json:"library_id"`
+	Version   string   `json:"version"`
+	File      string   `json:"file"` // Content, in the staging directory
+	Metadata  Metadata `json:"metadata"`
+}
+
+// Tx is a batch of cache writes that lands whole or not at all. Writes
+// through Cache are staged under dir/journal until Commit, which records
+// them in a journal before moving them into the store: a crash before then
+// leaves the store as it was, and one during the move is finished the next
+// time the cache is opened. A nil *Tx commits nothing.
+type Tx struct {
+	base *Cache
+	dir  string // Staging directory
+
+	mu        sync.Mutex
+	entries   map[string]journalEntry // By library ID and version
+	seq       int                     // Names staged files
+	committed bool                    // The journal is written
+}
+
+// Begin starts a transaction on the cache
+func (c *Cache) Begin() (*Tx, error) {
+	root := filepath.Join(c.baseDir, "journal")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(root, "tx-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start cache transaction: %w", err)
+	}
+	return &Tx{base: c, dir: dir, entries: make(map[string]journalEntry)}, nil
+}
+
+// Cache returns the cache to write the transaction through. It reads what
+// the transaction staged ahead of the store; listings, stats and removals
+// see only the store.
+func (t *Tx) Cache() *Cache {
+	c := *t.base
+	c.store = &txStore{Store: t.base.store, tx: t}
+	return &c
+}
+
+// Commit moves every staged write into the store
+func (t *Tx) Commit() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.entries) == 0 {
+		return os.RemoveAll(t.dir)
+	}
+
+	j := journal{Entries: make([]journalEntry, 0, len(t.entries))}
+	for _, e := range t.entries {
+		j.Entries = append(j.Entries, e)
+	}
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache journal: %w", err)
+	}
+	if err := writeFileAtomic(t.dir, filepath.Join(t.dir, journalFile), data); err != nil {
+		return fmt.Errorf("failed to write cache journal: %w", err)
+	}
+	t.committed = true
+
+	if err := replay(t.base.store, t.dir, j); err != nil {
+		return err
+	}
+	return os.RemoveAll(t.dir)
+}
+
+// Rollback discards every staged write. Once Commit has started it does
+// nothing: a commit that failed midway is finished when the cache is next
+// opened.
+func (t *Tx) Rollback() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.committed {
+		os.RemoveAll(t.dir)
+	}
+}
+
+// stage writes a library version's content to the staging directory
+func (t *Tx) stage(libraryID, version string, r io.Reader, metadata Metadata) (int64, error) {
+	t.mu.Lock()
+	t.seq++
+	file := strconv.Itoa(t.seq) + ".txt"
+	t.mu.Unlock()
+
+	f, err := createAtomic(t.dir, filepath.Join(t.dir, file))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create content file: %w", err)
+	}
+	digest := newDigestReader(r)
+	written, err := io.Copy(f, digest)
+	if err != nil {
+		f.Abort()
+		return 0, fmt.Errorf("failed to write content: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save content: %w", err)
+	}
+	digest.stamp(&metadata)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := txKey(libraryID, version)
+	if previous, ok := t.entries[key]; ok {
+		os.Remove(filepath.Join(t.dir, previous.File))
+	}
+	t.entries[key] = journalEntry{LibraryID: libraryID, Version: version, File: file, Metadata: metadata}
+	return written, nil
+}
+
+// staged returns the staged entry for a library version
+func (t *Tx) staged(libraryID, version string) (journalEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[txKey(libraryID, version)]
+	return e, ok
+}
+
+func txKey(libraryID, version string) string {
+	return strings.TrimPrefix(libraryID, "/") + "@" + versionKey(version)
+}
+
+// replay moves the entries of a journal from dir into store. Setting an
+// entry twice is harmless, so a crashed replay can simply be run again.
+func replay(store Store, dir string, j journal) error {
+	journalPath := filepath.Join(dir, journalFile)
+	for _, e := range j.Entries {
+		f, err := os.Open(filepath.Join(dir, e.File))
+		if err != nil {
+			return fmt.Errorf("failed to read staged %s: %w", e.LibraryID, err)
+		}
+		if s, ok := store.(StreamingStore); ok {
+			_, err = s.SetFrom(e.LibraryID, e.Version, f, e.Metadata)
+		} else {
+			var data []byte
+			if data, err = io.ReadAll(f); err == nil {
+				err = store.Set(e.LibraryID, e.Version, string(data), e.Metadata)
+			}
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to commit %s: %w", e.LibraryID, err)
+		}
+		now := time.Now()
+		os.Chtimes(journalPath, now, now)
+	}
+	return nil
+}
+
+// recoverJournals finishes the commits that crashed midway and discards
+// transactions that never committed, once they have been left alone long
+// enough not to belong to a running ctx7
+func recoverJournals(baseDir string, store Store) error {
+	root := filepath.Join(baseDir, "journal")
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	for _, d := range dirs {
+		dir := filepath.Join(root, d.Name())
+		if !d.IsDir() || !strings.HasPrefix(d.Name(), "tx-") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, journalFile))
+		if errors.Is(err, os.ErrNotExist) {
+			// Never committed: roll back once nothing has been staged for a while
+			if !touchedSince(dir, time.Now().Add(-staleTempAge)) {
+				os.RemoveAll(dir)
+			}
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, journalFile)); err != nil || info.ModTime().After(time.Now().Add(-journalGrace)) {
+			continue
+		}
+
+		var j journal
+		if err := json.Unmarshal(data, &j); err != nil {
+			return fmt.Errorf("failed to parse cache journal %s: %w", dir, err)
+		}
+		if err := replay(store, dir, j); err != nil {
+			return fmt.Errorf("failed to replay cache journal %s: %w", dir, err)
+		}
+		os.RemoveAll(dir)
+	}
+	return nil
+}
+
+// touchedSince reports whether dir or a file in it was modified after t
+func touchedSince(dir string, t time.Time) bool {
+	if info, err := os.Stat(dir); err == nil && info.ModTime().After(t) {
+		return true
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// txStore is the Store of Tx.Cache: writes are staged in the transaction
+// and staged entries read back ahead of the store's
+type txStore struct {
+	Store
+	tx *Tx
+}
+
+// Get returns the staged entry, or the store's
+func (s *txStore) Get(libraryID, version string) (*CacheEntry, error) {
+	e, ok := s.tx.staged(libraryID, version)
+	if !ok {
+		return s.Store.Get(libraryID, version)
+	}
+	data, err := os.ReadFile(filepath.Join(s.tx.dir, e.File))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	return &CacheEntry{Metadata: e.Metadata, Content: string(data)}, nil
+}
+
+// Set stages content and metadata
+func (s *txStore) Set(libraryID, version, content string, metadata Metadata) error {
+	_, err := s.tx.stage(libraryID, version, strings.NewReader(content), metadata)
+	return err
+}
+
+// SetFrom stages the content read from r
+func (s *txStore) SetFrom(libraryID, version string, r io.Reader, metadata Metadata) (int64, error) {
+	return s.tx.stage(libraryID, version, r, metadata)
+}
+
+// Open returns a reader over the staged content, or the store's
+func (s *txStore) Open(libraryID, version string) (io.ReadCloser, error) {
+	e, ok := s.tx.staged(libraryID, version)
+	if !ok {
+		return openStore(s.Store, libraryID, version)
+	}
+	f, err := os.Open(filepath.Join(s.tx.dir, e.File))
+	if err != nil {
+		return nil, fmt.Errorf("cache miss: %w", err)
+	}
+	return f, nil
+}
+
+// Location returns where the store keeps a version; staged versions have
+// not arrived there yet
+func (s *txStore) Location(libraryID, version string) string {
+	if l, ok := s.Store.(Locator); ok {
+		return l.Location(libraryID, version)
+	}
+	return ""
+}
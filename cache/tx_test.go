@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var errCrashed = errors.New("crashed")
+
+// crashingStore fails every write of one library, as if ctx7 died there
+type crashingStore struct {
+	Store
+	libraryID string
+}
+
+func (s *crashingStore) Set(libraryID, version, content string, metadata Metadata) error {
+	if libraryID == s.libraryID {
+		return errCrashed
+	}
+	return s.Store.Set(libraryID, version, content, metadata)
+}
+
+// crashedCommit stages React and Next.js in a transaction on dir and
+// commits it through a store that fails on Next.js, leaving the journal
+// behind. It returns the transaction's staging directory.
+func crashedCommit(t *testing.T, dir string, store Store) string {
+	t.Helper()
+	c, err := NewCacheWithStore(dir, &crashingStore{Store: store, libraryID: "/vercel/next.js"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := c.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"/facebook/react", "/vercel/next.js"} {
+		if err := tx.Cache().Set(id, "docs for "+id, Metadata{LibraryID: id, FetchedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); !errors.Is(err, errCrashed) {
+		t.Fatalf("Commit = %v, want %v", err, errCrashed)
+	}
+	tx.Rollback()
+
+	if _, err := os.Stat(filepath.Join(tx.dir, journalFile)); err != nil {
+		t.Fatalf("no journal after the crash: %v", err)
+	}
+	if _, err := store.Get("/vercel/next.js", ""); err == nil {
+		t.Fatal("Next.js was stored by the crashed commit")
+	}
+	return tx.dir
+}
+
+// age makes dir and everything in it look untouched for d
+func age(t *testing.T, dir string, d time.Duration) {
+	t.Helper()
+	then := time.Now().Add(-d)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := os.Chtimes(filepath.Join(dir, e.Name()), then, then); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(dir, then, then); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Opening the cache finishes a commit that crashed midway
+func TestRecoverCrashedCommit(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMemoryStore()
+	txDir := crashedCommit(t, dir, store)
+	age(t, txDir, 2*journalGrace)
+
+	c, err := NewCacheWithStore(dir, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"/facebook/react", "/vercel/next.js"} {
+		if entry, err := c.Lookup(id, ""); err != nil || entry.Content != "docs for "+id {
+			t.Errorf("Lookup(%s) after recovery = %v, %v", id, entry, err)
+		}
+	}
+	if _, err := os.Stat(txDir); !os.IsNotExist(err) {
+		t.Errorf("journal left after recovery: %v", err)
+	}
+}
+
+// A journal touched within journalGrace may belong to a commit still
+// running in another ctx7, and is left to it
+func TestRecoverSkipsRecentJournal(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMemoryStore()
+	txDir := crashedCommit(t, dir, store)
+	age(t, txDir, journalGrace/2)
+
+	if err := recoverJournals(dir, store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("/vercel/next.js", ""); err == nil {
+		t.Error("a journal younger than journalGrace was replayed")
+	}
+	if _, err := os.Stat(filepath.Join(txDir, journalFile)); err != nil {
+		t.Errorf("a journal younger than journalGrace was removed: %v", err)
+	}
+}
+
+// Transactions that never committed are discarded once left alone for
+// staleTempAge, without reaching the store
+func TestRecoverDiscardsUncommitted(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMemoryStore()
+	c, err := NewCacheWithStore(dir, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var txDirs []string
+	for range 2 {
+		tx, err := c.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Cache().Set("/facebook/react", "staged", Metadata{LibraryID: "/facebook/react"}); err != nil {
+			t.Fatal(err)
+		}
+		txDirs = append(txDirs, tx.dir)
+	}
+	age(t, txDirs[0], 2*staleTempAge)
+	age(t, txDirs[1], staleTempAge/2)
+
+	if err := recoverJournals(dir, store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(txDirs[0]); !os.IsNotExist(err) {
+		t.Errorf("stale uncommitted transaction kept: %v", err)
+	}
+	if _, err := os.Stat(txDirs[1]); err != nil {
+		t.Errorf("recent uncommitted transaction removed: %v", err)
+	}
+	if _, err := store.Get("/facebook/react", ""); err == nil {
+		t.Error("an uncommitted write reached the store")
+	}
+}
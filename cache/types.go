@@ -7,6 +7,7 @@ type Metadata struct {
 	LibraryID      string    `json:"library_id"`
 	Title          string    `json:"title"`
 	Version        string    `json:"version,omitempty"`
+	Branch         string    `json:"branch,omitempty"`
 	FetchedAt      time.Time `json:"fetched_at"`
 	LastUpdateDate string    `json:"last_update_date"`
 	TotalTokens    int       `json:"total_tokens"`
@@ -14,12 +15,57 @@ type Metadata struct {
 	Stars          int       `json:"stars"`
 	TrustScore     float64   `json:"trust_score"`
 	Versions       []string  `json:"versions"`
+	// SHA256 and EstimatedTokens describe the stored content; stores fill
+	// them in on write
+	SHA256          string `json:"sha256,omitempty"`
+	EstimatedTokens int    `json:"estimated_tokens,omitempty"`
+	// Pinned entries are never pruned (ctx7 cache pin)
+	Pinned bool `json:"pinned,omitempty"`
+	// Topic is set on topic-scoped variants of a document (see VariantKey)
+	Topic string `json:"topic,omitempty"`
+	// Patches are the refreshes since the last full download that fetched
+	// only the changed sections, oldest first
+	Patches []Patch `json:"patches,omitempty"`
+	// Superseded is set on a copy kept beside the document that replaced
+	// it, when the refresh looked like a regression (see KeptKey)
+	Superseded bool `json:"superseded,omitempty"`
+	// BaseURL is the context7 instance the document came from; empty on
+	// entries cached before it was recorded, which came from the public one
+	BaseURL string `json:"base_url,omitempty"`
+}
+
+// MaxPatches bounds the patch history kept for a document
+const MaxPatches = 20
+
+// Patch records a refresh that reused the unchanged sections of the cached
+// document and downloaded the rest
+type Patch struct {
+	PatchedAt time.Time `json:"patched_at"`
+	// Reused, Fetched and Dropped count the sections kept from the cached
+	// copy, downloaded, and no longer in the document
+	Reused  int `json:"reused"`
+	Fetched int `json:"fetched"`
+	Dropped int `json:"dropped"`
+	// Bytes is what was downloaded, Size the patched document's length
+	Bytes int64 `json:"bytes"`
+	Size  int64 `json:"size"`
+}
+
+// AddPatch appends p to the history, keeping the last MaxPatches
+func (m *Metadata) AddPatch(p Patch) {
+	m.Patches = append(m.Patches, p)
+	if len(m.Patches) > MaxPatches {
+		m.Patches = m.Patches[len(m.Patches)-MaxPatches:]
+	}
 }
 
 // CacheEntry represents a complete cache entry with metadata and content
 type CacheEntry struct {
 	Metadata Metadata
 	Content  string
+	// Expired is set by GetEntry when the entry is older than the TTL it
+	// was read with
+	Expired bool
 }
 
 // CacheStats contains statistics about the cache
@@ -46,6 +92,14 @@ type VersionInfo struct {
 	Size       int64
 	FetchedAt  time.Time
 	Metadata   Metadata
+	// Shared is set for versions read from a read-only shared cache
+	Shared     bool
+}
+
+// IsVariant reports whether the version holds a topic-scoped variant or a
+// superseded copy rather than the full document
+func (v VersionInfo) IsVariant() bool {
+	return v.Metadata.Topic != "" || v.Metadata.Superseded
 }
 
 // DetailedCacheStats extends CacheStats with per-library breakdown
@@ -54,6 +108,7 @@ type DetailedCacheStats struct {
 	LibraryBreakdown    []LibraryStats
 	SearchCacheSize     int64
 	SearchCacheEntries  int
+	Usage               UsageStats
 }
 
 // LibraryStats contains statistics for a single library
@@ -70,6 +125,9 @@ type PruneOptions struct {
 	MaxAge       time.Duration
 	DryRun       bool
 	KeepLatest   bool  // Keep latest version of each library
+	// TargetSize, if set, also removes the least recently fetched versions
+	// until the cache takes at most this many bytes
+	TargetSize int64
 }
 
 // PruneResult contains information about pruned entries
@@ -77,4 +135,5 @@ type PruneResult struct {
 	RemovedCount  int
 	FreedSpace    int64
 	RemovedItems  []string
+	KeptPinned    int // Entries that matched but are pinned
 }
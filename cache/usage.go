@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// UsageStats records how effective the cache has been
+type UsageStats struct {
+	Hits          int64         `json:"hits"`
+	Misses        int64         `json:"misses"`
+	BytesServed   int64         `json:"bytes_served"`
+	Fetches       int64         `json:"fetches"`
+	BytesFetched  int64         `json:"bytes_fetched"`
+	FetchDuration time.Duration `json:"fetch_duration_ns"`
+	Since         time.Time     `json:"since"`
+	// Libraries counts documents served per library ID. Only counts and
+	// the day of last use are kept, never queries or content.
+	Libraries map[string]*LibraryUsage `json:"libraries,omitempty"`
+}
+
+// LibraryUsage counts the documents served for one library
+type LibraryUsage struct {
+	Hits     int64  `json:"hits"`
+	Fetches  int64  `json:"fetches"`
+	LastUsed string `json:"last_used"` // YYYY-MM-DD
+}
+
+// Pulls returns the number of documents served, from cache or network
+func (l LibraryUsage) Pulls() int64 {
+	return l.Hits + l.Fetches
+}
+
+// library returns the counters for libraryID, marking it used at now
+func (u *UsageStats) library(libraryID string, now time.Time) *LibraryUsage {
+	if u.Libraries == nil {
+		u.Libraries = make(map[string]*LibraryUsage)
+	}
+	lib, ok := u.Libraries[libraryID]
+	if !ok {
+		lib = &LibraryUsage{}
+		u.Libraries[libraryID] = lib
+	}
+	lib.LastUsed = now.UTC().Format(time.DateOnly)
+	return lib
+}
+
+// HitRate returns the fraction of lookups served from cache (0-1)
+func (u UsageStats) HitRate() float64 {
+	total := u.Hits + u.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(u.Hits) / float64(total)
+}
+
+// EstimatedTimeSaved extrapolates network time saved from the observed
+// download throughput and the bytes served from cache
+func (u UsageStats) EstimatedTimeSaved() time.Duration {
+	if u.BytesFetched == 0 || u.FetchDuration == 0 {
+		return 0
+	}
+	perByte := float64(u.FetchDuration) / float64(u.BytesFetched)
+	return time.Duration(perByte * float64(u.BytesServed))
+}
+
+// usageMu serializes read-modify-write of the stats file within a process;
+// stats.json.lock serializes it between processes (see lockFile)
+var usageMu sync.Mutex
+
+// RecordHit counts a lookup of libraryID served from cache
+func (c *Cache) RecordHit(libraryID string, bytes int64) {
+	c.updateUsage(func(u *UsageStats) {
+		u.Hits++
+		u.BytesServed += bytes
+		u.library(libraryID, c.now()).Hits++
+	})
+}
+
+// RecordMiss counts a lookup that had to go to the network
+func (c *Cache) RecordMiss() {
+	c.updateUsage(func(u *UsageStats) {
+		u.Misses++
+	})
+}
+
+// RecordFetch records a network download of libraryID, used to estimate
+// time saved
+func (c *Cache) RecordFetch(libraryID string, bytes int64, duration time.Duration) {
+	c.updateUsage(func(u *UsageStats) {
+		u.Fetches++
+		u.BytesFetched += bytes
+		u.FetchDuration += duration
+		u.library(libraryID, c.now()).Fetches++
+	})
+}
+
+// GetUsageStats returns the recorded hit/miss statistics
+func (c *Cache) GetUsageStats() (UsageStats, error) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return c.readUsage()
+}
+
+// ResetUsageStats clears the recorded hit/miss statistics
+func (c *Cache) ResetUsageStats() error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	unlock, err := c.lockUsage()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.writeUsage(UsageStats{Since: c.now()})
+}
+
+// updateUsage applies fn to the stats file under stats.json.lock. Failures
+// are ignored: usage stats are best-effort and must never break a fetch. A
+// stats file that can't be read is left as it is rather than started over.
+func (c *Cache) updateUsage(fn func(*UsageStats)) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	unlock, err := c.lockUsage()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	usage, err := c.readUsage()
+	if err != nil {
+		return
+	}
+	fn(&usage)
+	_ = c.writeUsage(usage)
+}
+
+// lockUsage takes stats.json.lock (see lockFile)
+func (c *Cache) lockUsage() (unlock func(), err error) {
+	unlock, err = lockFile(c.usagePath() + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock usage stats: %w", err)
+	}
+	return unlock, nil
+}
+
+func (c *Cache) readUsage() (UsageStats, error) {
+	usage := UsageStats{Since: c.now()}
+
+	data, err := os.ReadFile(c.usagePath())
+	if os.IsNotExist(err) {
+		return usage, nil
+	}
+	if err != nil {
+		return usage, fmt.Errorf("failed to read usage stats: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return UsageStats{Since: c.now()}, fmt.Errorf("failed to decode usage stats: %w", err)
+	}
+	return usage, nil
+}
+
+func (c *Cache) writeUsage(usage UsageStats) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode usage stats: %w", err)
+	}
+
+	if err := writeFileAtomic(c.tmpDir, c.usagePath(), data); err != nil {
+		return fmt.Errorf("failed to save usage stats: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) usagePath() string {
+	return filepath.Join(c.baseDir, "stats.json")
+}
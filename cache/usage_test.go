@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+// Hits recorded at once through caches sharing a directory all end up in
+// stats.json
+func TestUsageConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	var caches []*Cache
+	for range 2 {
+		c, err := NewCache(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		caches = append(caches, c)
+	}
+
+	const hits = 50
+	var wg sync.WaitGroup
+	for i := range hits {
+		wg.Go(func() { caches[i%2].RecordHit("/facebook/react", 10) })
+	}
+	wg.Wait()
+
+	usage, err := caches[0].GetUsageStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Hits != hits || usage.BytesServed != hits*10 || usage.Libraries["/facebook/react"].Hits != hits {
+		t.Errorf("usage = %+v, want %d hits", usage, hits)
+	}
+	if _, err := os.Stat(caches[0].usagePath() + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("stats.json.lock left behind: %v", err)
+	}
+}
+
+// A stats file that can't be parsed is kept rather than started over
+func TestUsageUnreadable(t *testing.T) {
+	c, err := NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.usagePath(), []byte(`{"hits": 12`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.RecordHit("/facebook/react", 10)
+	c.RecordMiss()
+	if data, _ := os.ReadFile(c.usagePath()); string(data) != `{"hits": 12` {
+		t.Errorf("stats.json = %q, want it untouched", data)
+	}
+	if _, err := c.GetUsageStats(); err == nil {
+		t.Error("GetUsageStats succeeded on an unreadable stats file")
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIError is a non-200 response from context7. JSON error bodies, such
+// as rate limit or maintenance notices, are reduced to their message and
+// error code; other bodies are kept as the message, shortened.
+type APIError struct {
+	// Request names the request that failed, e.g. "search" or "llms.txt"
+	Request    string
+	StatusCode int
+	// Code is the API's machine-readable error code, if it sent one
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	s := fmt.Sprintf("%s request failed with status %d", e.Request, e.StatusCode)
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.Code != "" {
+		s += " (" + e.Code + ")"
+	}
+	return s
+}
+
+// maxErrorBody bounds how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// maxRawMessage bounds how much of a non-JSON error body ends up in the
+// message
+const maxRawMessage = 200
+
+// newAPIError reads the error body of resp
+func newAPIError(request string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	e := &APIError{Request: request, StatusCode: resp.StatusCode}
+
+	var fields map[string]any
+	if json.Unmarshal(body, &fields) == nil {
+		e.Code, e.Message = parseErrorFields(fields)
+		if e.Message != "" || e.Code != "" {
+			return e
+		}
+	}
+
+	// An error page's markup says nothing useful on a terminal
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return e
+	}
+	message := strings.Join(strings.Fields(string(body)), " ")
+	if runes := []rune(message); len(runes) > maxRawMessage {
+		message = string(runes[:maxRawMessage]) + "..."
+	}
+	e.Message = message
+	return e
+}
+
+// parseErrorFields extracts the message and code of a JSON error body in
+// its common shapes: {"error": "..."}, {"message": "...", "code": "..."},
+// {"error": "code", "message": "..."} and {"error": {"message": ...}}
+func parseErrorFields(fields map[string]any) (code, message string) {
+	if nested, ok := fields["error"].(map[string]any); ok {
+		fields = nested
+	}
+
+	for _, key := range []string{"message", "detail", "error_description"} {
+		if s, ok := fields[key].(string); ok && s != "" {
+			message = s
+			break
+		}
+	}
+
+	switch c := fields["code"].(type) {
+	case string:
+		code = c
+	case float64:
+		code = strconv.FormatFloat(c, 'f', -1, 64)
+	}
+
+	if s, ok := fields["error"].(string); ok && s != "" {
+		switch {
+		case message == "":
+			message = s
+		case code == "":
+			code = s
+		}
+	}
+	return code, message
+}
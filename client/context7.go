@@ -1,17 +1,40 @@
 package client
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/hsbacot/ctx7/content"
+	"github.com/hsbacot/ctx7/verify"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultBaseURL is the public context7 instance
+const DefaultBaseURL = "https://context7.com"
+
+// Default per-request timeouts: searches should fail fast, while large
+// documents can legitimately take minutes to download
+const (
+	DefaultSearchTimeout = 5 * time.Second
+	DefaultFetchTimeout  = 5 * time.Minute
 )
 
 const (
-	baseURL    = "https://context7.com"
 	searchPath = "/api/v2/libs/search"
+	codePath   = "/api/v2/docs/code"
 )
 
 // Library represents a library result from context7.com
@@ -32,74 +55,343 @@ type Library struct {
 	VIP             bool     `json:"vip"`
 }
 
+// Deprecated reports whether context7 marks the library deprecated
+func (l Library) Deprecated() bool {
+	return strings.EqualFold(l.State, "deprecated")
+}
+
 // SearchResponse represents the API response from the search endpoint
 type SearchResponse struct {
 	Results []Library `json:"results"`
 }
 
+// Searcher finds the libraries matching a query
+type Searcher interface {
+	SearchLibraries(ctx context.Context, query string) ([]Library, error)
+}
+
+// Fetcher downloads the llms.txt of a library
+type Fetcher interface {
+	FetchLLMsTxt(ctx context.Context, libraryID string, opts FetchOptions) (string, error)
+}
+
 // Client is an HTTP client for context7.com
 type Client struct {
-	httpClient *http.Client
+	httpClient    *http.Client
+	baseURL       string
+	searchTimeout time.Duration
+	fetchTimeout  time.Duration
+	language      string
+
+	// Checksum manifest, loaded on the first document download; manifestMu
+	// guards manifest, which stays nil until a load succeeds
+	manifestURL string
+	manifestKey *verify.PublicKey
+	manifestMu  sync.Mutex
+	manifest    *verify.Manifest
+}
+
+// Options configures a Client
+type Options struct {
+	// BaseURL points the client at a mirror or self-hosted instance (defaults to DefaultBaseURL)
+	BaseURL string
+	// CABundle is a PEM file of extra trusted certificates, e.g. for a corporate TLS-intercepting proxy
+	CABundle string
+	// InsecureSkipVerify disables TLS certificate verification
+	InsecureSkipVerify bool
+	// SearchTimeout bounds library searches (defaults to DefaultSearchTimeout)
+	SearchTimeout time.Duration
+	// FetchTimeout bounds document and snippet downloads, including reading
+	// the body (defaults to DefaultFetchTimeout)
+	FetchTimeout time.Duration
+	// Language is a hint for the language of search queries, e.g. "ja",
+	// sent as the lang parameter; instances that don't support it ignore it
+	Language string
+	// ManifestURL is a SHA-256 manifest (URL or local file) published by a
+	// mirror; documents it doesn't vouch for are rejected. See verify.ParseManifest.
+	ManifestURL string
+	// ManifestKey is a minisign public key; when set the manifest must come
+	// with a valid signature at ManifestURL + ".minisig"
+	ManifestKey string
 }
 
 // NewClient creates a new context7 API client
 func NewClient() *Client {
+	c, _ := NewClientWithOptions(Options{})
+	return c
+}
+
+// NewClientWithOptions creates a context7 API client with custom settings.
+// Requests go through the proxy given by HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+func NewClientWithOptions(opts Options) (*Client, error) {
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if opts.CABundle != "" || opts.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+		if opts.CABundle != "" {
+			pem, err := os.ReadFile(opts.CABundle)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+			}
+
+			// Trust the bundle in addition to the system roots
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	searchTimeout := opts.SearchTimeout
+	if searchTimeout <= 0 {
+		searchTimeout = DefaultSearchTimeout
+	}
+	fetchTimeout := opts.FetchTimeout
+	if fetchTimeout <= 0 {
+		fetchTimeout = DefaultFetchTimeout
+	}
+
+	var manifestKey *verify.PublicKey
+	if opts.ManifestKey != "" {
+		if opts.ManifestURL == "" {
+			return nil, fmt.Errorf("a manifest key requires a manifest URL")
+		}
+		key, err := verify.ParsePublicKey(opts.ManifestKey)
+		if err != nil {
+			return nil, err
+		}
+		manifestKey = key
+	}
+
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient:    &http.Client{Transport: transport},
+		baseURL:       baseURL,
+		searchTimeout: searchTimeout,
+		fetchTimeout:  fetchTimeout,
+		language:      opts.Language,
+		manifestURL:   opts.ManifestURL,
+		manifestKey:   manifestKey,
+	}, nil
+}
+
+// BaseURL returns the context7 instance the client queries
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Host returns the host name of the instance, e.g. "context7.com", for
+// messages
+func (c *Client) Host() string {
+	return HostOf(c.baseURL)
+}
+
+// HostOf returns the host name of an instance's base URL, or the URL itself
+// when it has none
+func HostOf(baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return u.Host
 	}
+	return baseURL
 }
 
-// SearchLibraries searches for libraries matching the query
-func (c *Client) SearchLibraries(query string) ([]Library, error) {
-	// Build search URL
-	searchURL := fmt.Sprintf("%s%s?query=%s", baseURL, searchPath, url.QueryEscape(query))
+// Language returns the language hint sent with searches, if any
+func (c *Client) Language() string {
+	return c.language
+}
+
+// get issues a GET request bound to ctx
+func (c *Client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// timedOut reports whether err came from a request's own deadline rather
+// than the caller cancelling parent
+func timedOut(parent context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil
+}
+
+// SearchLibraries searches for libraries matching the query; cancelling ctx
+// aborts the request
+func (c *Client) SearchLibraries(ctx context.Context, query string) ([]Library, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, c.searchTimeout)
+	defer cancel()
+
+	// Build search URL. Queries are sent in composed form so that accented
+	// terms typed on terminals that emit decomposed input (e.g. macOS) match.
+	params := url.Values{}
+	params.Set("query", norm.NFC.String(query))
+	if c.language != "" {
+		params.Set("lang", c.language)
+	}
+	searchURL := fmt.Sprintf("%s%s?%s", c.baseURL, searchPath, params.Encode())
 
 	// Make HTTP request
-	resp, err := c.httpClient.Get(searchURL)
+	resp, err := c.get(reqCtx, searchURL)
 	if err != nil {
+		if timedOut(ctx, err) {
+			return nil, fmt.Errorf("search request timed out after %s: %w", c.searchTimeout, err)
+		}
 		return nil, fmt.Errorf("failed to make search request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("search request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("search", resp)
 	}
 
 	// Parse JSON response
 	var searchResp SearchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		if timedOut(ctx, err) {
+			return nil, fmt.Errorf("search request timed out after %s: %w", c.searchTimeout, err)
+		}
 		return nil, fmt.Errorf("failed to parse search response: %w", err)
 	}
 
 	return searchResp.Results, nil
 }
 
-// FetchLLMsTxt fetches the llms.txt content for a library
-func (c *Client) FetchLLMsTxt(libraryID string) (string, error) {
+// FetchOptions narrows which llms.txt document is requested
+type FetchOptions struct {
+	// Branch requests docs for a non-default branch
+	Branch string
+	// Topic asks context7 to return only content relevant to the topic
+	Topic string
+	// Progress, if set, is called as the document downloads
+	Progress ProgressFunc
+}
+
+// query encodes the options as URL query parameters
+func (o FetchOptions) query() string {
+	params := url.Values{}
+	if o.Branch != "" {
+		params.Set("branch", o.Branch)
+	}
+	if o.Topic != "" {
+		params.Set("topic", o.Topic)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
+
+// FetchLLMsTxt fetches the llms.txt content for a library; cancelling ctx
+// aborts the request
+func (c *Client) FetchLLMsTxt(ctx context.Context, libraryID string, opts FetchOptions) (string, error) {
+	var body strings.Builder
+	if _, err := c.StreamLLMsTxt(ctx, libraryID, opts, &body); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// StreamLLMsTxt writes the llms.txt content for a library to w as it
+// downloads, returning the number of bytes written
+func (c *Client) StreamLLMsTxt(ctx context.Context, libraryID string, opts FetchOptions, w io.Writer) (int64, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, c.fetchTimeout)
+	defer cancel()
+
 	// Build llms.txt URL
-	llmsURL := fmt.Sprintf("%s%s/llms.txt", baseURL, libraryID)
+	llmsURL := fmt.Sprintf("%s%s/llms.txt%s", c.baseURL, libraryID, opts.query())
+
+	// Make HTTP request
+	resp, err := c.get(reqCtx, llmsURL)
+	if err != nil {
+		if timedOut(ctx, err) {
+			return 0, fmt.Errorf("llms.txt request timed out after %s: %w", c.fetchTimeout, err)
+		}
+		return 0, fmt.Errorf("failed to fetch llms.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return 0, newAPIError("llms.txt", resp)
+	}
+
+	// Topic results are generated per request, so only whole documents
+	// can be checked against the manifest
+	var digest hash.Hash
+	if c.manifestURL != "" && opts.Topic == "" {
+		digest = sha256.New()
+		w = io.MultiWriter(w, digest)
+	}
+
+	// Copy content, reporting progress as it streams in
+	var reader io.Reader = resp.Body
+	if opts.Progress != nil {
+		reader = &progressReader{r: resp.Body, total: resp.ContentLength, report: opts.Progress}
+	}
+	written, err := io.Copy(w, content.SkipBOM(reader))
+	if err != nil {
+		if timedOut(ctx, err) {
+			return written, fmt.Errorf("llms.txt download timed out after %s: %w", c.fetchTimeout, err)
+		}
+		return written, fmt.Errorf("failed to read llms.txt content: %w", err)
+	}
+
+	if digest != nil {
+		manifest, err := c.loadManifest(ctx)
+		if err != nil {
+			return written, err
+		}
+		path := strings.TrimPrefix(libraryID, "/") + "/llms.txt" + opts.query()
+		if err := manifest.Verify(path, digest.Sum(nil)); err != nil {
+			return written, fmt.Errorf("failed to verify llms.txt: %w", err)
+		}
+	}
+
+	return written, nil
+}
+
+// SearchSnippets queries the code snippets endpoint for examples in a library
+// matching the query, returning the snippets as text
+func (c *Client) SearchSnippets(ctx context.Context, libraryID, query string) (string, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, c.fetchTimeout)
+	defer cancel()
+
+	// Build snippets URL
+	snippetsURL := fmt.Sprintf("%s%s%s?type=txt&topic=%s", c.baseURL, codePath, libraryID, url.QueryEscape(query))
 
 	// Make HTTP request
-	resp, err := c.httpClient.Get(llmsURL)
+	resp, err := c.get(reqCtx, snippetsURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch llms.txt: %w", err)
+		if timedOut(ctx, err) {
+			return "", fmt.Errorf("snippets request timed out after %s: %w", c.fetchTimeout, err)
+		}
+		return "", fmt.Errorf("failed to make snippets request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("llms.txt request failed with status %d", resp.StatusCode)
+		return "", newAPIError("snippets", resp)
 	}
 
 	// Read content
-	content, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read llms.txt content: %w", err)
+		return "", fmt.Errorf("failed to read snippets: %w", err)
 	}
 
-	return string(content), nil
+	return content.StripBOM(string(body)), nil
 }
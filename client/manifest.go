@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hsbacot/ctx7/verify"
+)
+
+// loadManifest downloads and, with a manifest key, checks the signature of
+// the checksum manifest once per client. Only a manifest that loaded is
+// kept: after an error, such as a cancelled or timed-out request, the next
+// download tries again rather than failing for the life of the client.
+func (c *Client) loadManifest(ctx context.Context) (*verify.Manifest, error) {
+	c.manifestMu.Lock()
+	defer c.manifestMu.Unlock()
+	if c.manifest != nil {
+		return c.manifest, nil
+	}
+
+	data, err := c.readManifestFile(ctx, c.manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	if c.manifestKey != nil {
+		sig, err := c.readManifestFile(ctx, c.manifestURL+".minisig")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifest signature: %w", err)
+		}
+		if err := c.manifestKey.VerifyMinisign(data, sig); err != nil {
+			return nil, fmt.Errorf("failed to verify manifest %s: %w", c.manifestURL, err)
+		}
+	}
+
+	manifest, err := verify.ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	c.manifest = manifest
+	return manifest, nil
+}
+
+// readManifestFile fetches location over HTTP(S), or reads it from disk
+// when it isn't a URL
+func (c *Client) readManifestFile(ctx context.Context, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(location)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, c.fetchTimeout)
+	defer cancel()
+
+	resp, err := c.get(reqCtx, location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", location, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const testManifest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  vercel/next.js/llms.txt\n"
+
+// A manifest load that was cancelled is retried by the next download, and
+// one that succeeded is kept
+func TestLoadManifestRetriesAfterError(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(testManifest))
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOptions(Options{ManifestURL: srv.URL + "/manifest.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.loadManifest(cancelled); err == nil {
+		t.Fatal("loading with a cancelled context succeeded")
+	}
+
+	m, err := c.loadManifest(context.Background())
+	if err != nil || m == nil {
+		t.Fatalf("loading after a cancelled load = %v, %v, want the manifest", m, err)
+	}
+	if again, err := c.loadManifest(context.Background()); err != nil || again != m {
+		t.Errorf("second load = %v, %v, want the kept manifest", again, err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d manifest requests, want 1", n)
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Pattern matches library IDs: an exact ID such as /vercel/next.js, or a
+// glob such as /vercel/* for every library of an org. The leading slash
+// is optional.
+type Pattern struct {
+	glob string
+}
+
+// ParsePattern validates a library ID or glob
+func ParsePattern(s string) (Pattern, error) {
+	glob := "/" + strings.TrimPrefix(s, "/")
+	if strings.Count(glob, "/") != 2 {
+		return Pattern{}, fmt.Errorf("invalid library pattern: %s (expected: org/library or org/*)", s)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return Pattern{}, fmt.Errorf("invalid library pattern %s: %w", s, err)
+	}
+	return Pattern{glob: glob}, nil
+}
+
+// SplitVersion splits "name@version" into the name and version, e.g.
+// react-router@6.4.0. Only an "@" after the start counts, so scoped names
+// such as @tanstack/query keep theirs.
+func SplitVersion(s string) (name, version string) {
+	if i := strings.LastIndex(s, "@"); i > 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// OrgPattern matches every library of org
+func OrgPattern(org string) Pattern {
+	return Pattern{glob: "/" + strings.Trim(org, "/") + "/*"}
+}
+
+// Match reports whether libraryID matches the pattern
+func (p Pattern) Match(libraryID string) bool {
+	matched, _ := path.Match(p.glob, "/"+strings.TrimPrefix(libraryID, "/"))
+	return matched
+}
+
+// IsWildcard reports whether the pattern can match more than one library
+func (p Pattern) IsWildcard() bool {
+	return strings.ContainsAny(p.glob, "*?[\\")
+}
+
+// String returns the pattern with its leading slash
+func (p Pattern) String() string {
+	return p.glob
+}
+
+// FilterLibraries returns the libraries whose IDs match p, keeping their order
+func FilterLibraries(libs []Library, p Pattern) []Library {
+	var matched []Library
+	for _, lib := range libs {
+		if p.Match(lib.ID) {
+			matched = append(matched, lib)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,24 @@
+package client
+
+import "io"
+
+// ProgressFunc is called as a response body downloads with the bytes read
+// so far and the expected total (-1 when the server did not say)
+type ProgressFunc func(read, total int64)
+
+// progressReader reports every read from r to report
+type progressReader struct {
+	r      io.Reader
+	read   int64
+	total  int64
+	report ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.report(p.read, p.total)
+	}
+	return n, err
+}
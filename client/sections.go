@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrNoSections is returned when the server does not index the sections
+// of a document; context7.com doesn't, mirrors may
+var ErrNoSections = errors.New("the server does not index document sections")
+
+// sectionIndex is the body of <library>/llms.txt.sections
+type sectionIndex struct {
+	// Sections are the SHA-256 digests of the document's sections, in order
+	Sections []string `json:"sections"`
+}
+
+// FetchSectionIndex returns the digests of the sections of a document, in
+// order, from <library>/llms.txt.sections. Concatenated, the sections the
+// digests name are the document. context7.com is not asked.
+func (c *Client) FetchSectionIndex(ctx context.Context, libraryID string, opts FetchOptions) ([]string, error) {
+	if c.baseURL == DefaultBaseURL {
+		return nil, ErrNoSections
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, c.fetchTimeout)
+	defer cancel()
+
+	resp, err := c.get(reqCtx, fmt.Sprintf("%s%s/llms.txt.sections%s", c.baseURL, libraryID, opts.query()))
+	if err != nil {
+		if timedOut(ctx, err) {
+			return nil, fmt.Errorf("section index request timed out after %s: %w", c.fetchTimeout, err)
+		}
+		return nil, fmt.Errorf("failed to fetch section index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoSections
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("section index", resp)
+	}
+
+	var index sectionIndex
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("failed to parse section index: %w", err)
+	}
+	return index.Sections, nil
+}
+
+// FetchSection downloads the section of a document named by digest, from
+// <library>/sections/<digest>.txt, and checks it against the digest
+func (c *Client) FetchSection(ctx context.Context, libraryID, digest string, opts FetchOptions) (string, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, c.fetchTimeout)
+	defer cancel()
+
+	resp, err := c.get(reqCtx, fmt.Sprintf("%s%s/sections/%s.txt%s", c.baseURL, libraryID, digest, opts.query()))
+	if err != nil {
+		if timedOut(ctx, err) {
+			return "", fmt.Errorf("section request timed out after %s: %w", c.fetchTimeout, err)
+		}
+		return "", fmt.Errorf("failed to fetch section: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("section", resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read section: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), digest) {
+		return "", fmt.Errorf("section %s does not match its digest", digest)
+	}
+	return string(body), nil
+}
+
+// VerifyLLMsTxt checks a document assembled from sections against the
+// checksum manifest, as StreamLLMsTxt checks downloads; without a manifest
+// every document passes
+func (c *Client) VerifyLLMsTxt(ctx context.Context, libraryID string, opts FetchOptions, doc string) error {
+	if c.manifestURL == "" || opts.Topic != "" {
+		return nil
+	}
+
+	manifest, err := c.loadManifest(ctx)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(doc))
+	path := strings.TrimPrefix(libraryID, "/") + "/llms.txt" + opts.query()
+	if err := manifest.Verify(path, sum[:]); err != nil {
+		return fmt.Errorf("failed to verify llms.txt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hsbacot/ctx7/alias"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// RunAliasCommand adds, lists or removes the short names of libraries.
+// Library names given to add are resolved through cacheManager and c, with
+// the document and search TTLs maxAge and searchMaxAge.
+func RunAliasCommand(args []string, store *alias.Store, cacheManager *cache.Cache, c *client.Client, maxAge, searchMaxAge time.Duration) {
+	if len(args) == 0 {
+		printAliasUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			printAliasUsage()
+			os.Exit(1)
+		}
+		eng := engine.New(engine.Options{Client: c, Cache: cacheManager, MaxAge: maxAge, SearchMaxAge: searchMaxAge})
+		addAlias(store, eng, args[1], args[2])
+	case "list":
+		listAliases(store)
+	case "remove", "rm":
+		if len(args) != 2 {
+			printAliasUsage()
+			os.Exit(1)
+		}
+		removeAlias(store, args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown alias command: %s\n\n", args[0])
+		printAliasUsage()
+		os.Exit(1)
+	}
+}
+
+func printAliasUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: ctx7 alias add <name> <library>")
+	fmt.Fprintln(os.Stderr, "       ctx7 alias list")
+	fmt.Fprintln(os.Stderr, "       ctx7 alias remove <name>")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "An alias fetches its library without a search, e.g. after")
+	fmt.Fprintln(os.Stderr, "ctx7 alias add rr /remix-run/react-router, ctx7 rr. Aliased")
+	fmt.Fprintln(os.Stderr, "libraries are listed first in the interactive selector.")
+}
+
+// addAlias resolves library and saves it as name
+func addAlias(store *alias.Store, eng *engine.Engine, name, library string) {
+	if err := alias.ValidateName(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lib, err := resolveLibrary(context.Background(), eng, library)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving library: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := store.Add(name, lib, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := store.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %s → %s (%s)\n", name, lib.ID, lib.Title)
+}
+
+// listAliases prints every alias with its library
+func listAliases(store *alias.Store) {
+	entries := store.Entries()
+	if len(entries) == 0 {
+		fmt.Println("No aliases yet (ctx7 alias add <name> <library>)")
+		return
+	}
+
+	printHeader("Aliases")
+	for _, e := range entries {
+		title := ""
+		if e.Title != "" && e.Title != e.LibraryID {
+			title = " (" + e.Title + ")"
+		}
+		fmt.Printf("%-16s → %s%s\n", e.Name, e.LibraryID, title)
+	}
+	fmt.Printf("\nTotal: %d aliases\n", len(entries))
+}
+
+// removeAlias deletes the alias name
+func removeAlias(store *alias.Store, name string) {
+	if !store.Remove(name) {
+		fmt.Fprintf(os.Stderr, "Error: no alias named %q\n", name)
+		os.Exit(1)
+	}
+	if err := store.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Removed %s\n", name)
+}
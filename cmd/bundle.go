@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/budget"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/content"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// bundleTargets maps each --target to the instructions file the assistant
+// reads
+var bundleTargets = map[string]string{
+	"claude":  "CLAUDE.md",
+	"cursor":  ".cursorrules",
+	"copilot": filepath.Join(".github", "copilot-instructions.md"),
+}
+
+// The markers delimit the generated part of an instructions file, so a
+// rerun replaces it and leaves the rest of the file alone
+const (
+	bundleBegin = "<!-- ctx7 bundle: begin -->"
+	bundleEnd   = "<!-- ctx7 bundle: end -->"
+)
+
+// bundleDoc is one library's documentation in a bundle
+type bundleDoc struct {
+	name    string
+	lib     client.Library
+	version string
+	content string
+	// tokens estimates the whole document, before the budget trimmed it
+	tokens int
+	err    error
+}
+
+// RunBundleCommand writes the docs of a project's libraries into the
+// instructions file of an AI assistant, inlined or referenced, within a
+// token budget shared by the libraries
+func RunBundleCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge, searchMaxAge time.Duration) {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	target := fs.String("target", "claude", "Assistant to write instructions for: claude, cursor, copilot")
+	outPath := fs.String("o", "", "Instructions file (default CLAUDE.md, .cursorrules or .github/copilot-instructions.md)")
+	tokens := fs.Int("budget", 50000, "Estimated tokens shared by the libraries (0 for no limit)")
+	reference := fs.Bool("reference", false, "Write each library's docs to --docs-dir and reference them instead of inlining")
+	docsDir := fs.String("docs-dir", filepath.Join(".ctx7", "docs"), "Directory for --reference documents")
+	jobs := fs.Int("jobs", 4, "Number of libraries to fetch at once")
+	fs.Parse(args)
+
+	path, ok := bundleTargets[*target]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown --target %q (want claude, cursor or copilot)\n", *target)
+		os.Exit(1)
+	}
+	if *outPath != "" {
+		path = *outPath
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		deps, manifests, err := projectDependencies(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(deps) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: no libraries given and no dependencies found in package.json, go.mod or requirements.txt")
+			fmt.Fprintln(os.Stderr, "Usage: ctx7 bundle [--target claude|cursor|copilot] [--budget N] [--reference] [<library>[@version]...]")
+			os.Exit(1)
+		}
+		fmt.Printf("Found %d dependencies in %s\n", len(deps), strings.Join(manifests, ", "))
+		names = deps
+	}
+
+	batch, tx := BeginBatch(cacheManager)
+	eng := engine.New(engine.Options{Client: c, Cache: batch, MaxAge: maxAge, SearchMaxAge: searchMaxAge})
+	docs := fetchAll(eng, names, *jobs)
+	CommitBatch(tx)
+	if len(docs) == 0 {
+		os.Exit(1)
+	}
+
+	if *tokens > 0 {
+		sizes := make([]int, len(docs))
+		for i, d := range docs {
+			sizes[i] = d.tokens
+		}
+		for i, share := range budget.Split(sizes, *tokens) {
+			// Token estimates are a quarter of the byte count
+			docs[i].content = content.Truncate(docs[i].content, share*4)
+		}
+	}
+
+	var refs []string
+	if *reference {
+		for _, d := range docs {
+			file := filepath.Join(*docsDir, docFileName(d))
+			if d.content != "" {
+				if err := writeFile(file, d.content); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			refs = append(refs, file)
+		}
+	}
+
+	block := renderBundle(*target, path, docs, refs)
+	if err := writeBundle(path, block); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	total, included := 0, 0
+	for _, d := range docs {
+		used := budget.EstimateTokens(d.content)
+		total += used
+		if d.content == "" {
+			fmt.Printf("- %s left out: its first snippet doesn't fit its share of the budget\n", d.lib.ID)
+			continue
+		}
+		included++
+		fmt.Printf("✓ %s ~%d of %d tokens\n", d.lib.ID, used, d.tokens)
+	}
+	fmt.Printf("\nWrote %s for %s (~%d tokens, %d of %d libraries)\n", path, *target, total, included, len(docs))
+}
+
+// fetchAll resolves and fetches every name, reporting failures as they
+// come. The documents are returned in the order of names, each library
+// once.
+func fetchAll(eng *engine.Engine, names []string, jobs int) []bundleDoc {
+	indices := make([]int, len(names))
+	for i := range indices {
+		indices[i] = i
+	}
+	fetched := make([]bundleDoc, len(names))
+	for d := range parallel(indices, jobs, func(i int) bundleDoc {
+		d := fetchBundleDoc(eng, names[i])
+		fetched[i] = d
+		return d
+	}) {
+		if d.err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", d.name, d.err)
+		}
+	}
+
+	var docs []bundleDoc
+	seen := make(map[string]bool)
+	for _, d := range fetched {
+		key := d.lib.ID + "@" + d.version
+		if d.err != nil || seen[key] {
+			continue
+		}
+		seen[key] = true
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+// fetchBundleDoc resolves a library[@version] and fetches its docs
+func fetchBundleDoc(eng *engine.Engine, arg string) bundleDoc {
+	ctx := context.Background()
+
+	name, version := client.SplitVersion(arg)
+	if version == "latest" {
+		version = ""
+	}
+	lib, err := resolveLibrary(ctx, eng, name)
+	if err != nil {
+		return bundleDoc{name: arg, err: err}
+	}
+	result, err := eng.Fetch(ctx, engine.Request{Library: lib, Version: version})
+	if err != nil {
+		return bundleDoc{name: arg, lib: lib, err: err}
+	}
+	return bundleDoc{
+		name:    arg,
+		lib:     lib,
+		version: version,
+		content: result.Content,
+		tokens:  budget.EstimateTokens(result.Content),
+	}
+}
+
+// renderBundle writes the generated block of the instructions file at
+// path: the documents inline, or with refs the files holding them
+func renderBundle(target, path string, docs []bundleDoc, refs []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n# Library documentation\n\n", bundleBegin)
+	b.WriteString("Up-to-date documentation for the libraries this project uses, from context7. Prefer it over what you remember when the two disagree.\n\n")
+
+	for i, d := range docs {
+		if d.content == "" {
+			continue
+		}
+		heading := d.heading()
+		if refs == nil {
+			fmt.Fprintf(&b, "## %s\n\n%s\n\n", heading, strings.TrimSpace(d.content))
+			continue
+		}
+		if target == "claude" {
+			// Claude Code imports @-mentioned files, relative to this one
+			ref, err := filepath.Rel(filepath.Dir(path), refs[i])
+			if err != nil {
+				ref = refs[i]
+			}
+			fmt.Fprintf(&b, "- %s: @%s\n", heading, filepath.ToSlash(ref))
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: read `%s` before working with it\n", heading, filepath.ToSlash(refs[i]))
+	}
+	if refs != nil {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "Generated by `ctx7 bundle`; run it again to update.\n%s\n", bundleEnd)
+	return b.String()
+}
+
+// heading names the library a document is about, with its version
+func (d bundleDoc) heading() string {
+	title := d.lib.Title
+	if title == "" || title == d.lib.ID {
+		// The search didn't list the library, so there is only its ID
+		title = "`" + d.lib.ID + "`"
+	} else {
+		title += " (`" + d.lib.ID + "`)"
+	}
+	if d.version != "" {
+		return title + ", " + d.version
+	}
+	return title
+}
+
+// writeBundle puts block into the file at path, replacing the block of an
+// earlier run or appending it to a file written by hand
+func writeBundle(path, block string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	doc := string(existing)
+	begin := strings.Index(doc, bundleBegin)
+	end := strings.Index(doc, bundleEnd)
+	switch {
+	case begin >= 0 && end > begin:
+		doc = doc[:begin] + block + strings.TrimPrefix(doc[end+len(bundleEnd):], "\n")
+	case doc != "":
+		doc = strings.TrimRight(doc, "\n") + "\n\n" + block
+	default:
+		doc = block
+	}
+	return writeFile(path, doc)
+}
+
+// docFileName names a library's --reference file, e.g.
+// vercel__next.js@v14.3.0.md
+func docFileName(d bundleDoc) string {
+	org, name, _ := strings.Cut(strings.Trim(d.lib.ID, "/"), "/")
+	version := d.version
+	if version == "" {
+		version = "latest"
+	}
+	name = strings.ReplaceAll(name, "/", "_")
+	return fmt.Sprintf("%s__%s@%s.md", org, name, strings.ReplaceAll(version, "/", "_"))
+}
+
+// writeFile writes data to path, creating its directory
+func writeFile(path, data string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
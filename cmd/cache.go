@@ -1,16 +1,28 @@
 package cmd
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/charmbracelet/x/term"
 	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
 )
 
-// RunCacheCommand handles all cache subcommands
-func RunCacheCommand(args []string, cacheManager *cache.Cache) {
+// RunCacheCommand handles all cache subcommands; maxAge and searchMaxAge
+// are the document and search TTLs (0 for the defaults)
+func RunCacheCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge, searchMaxAge time.Duration) {
+	if maxAge <= 0 {
+		maxAge = engine.DefaultMaxAge
+	}
+	if searchMaxAge <= 0 {
+		searchMaxAge = engine.DefaultSearchMaxAge
+	}
+
 	if len(args) == 0 {
 		printCacheUsage()
 		os.Exit(1)
@@ -20,17 +32,27 @@ func RunCacheCommand(args []string, cacheManager *cache.Cache) {
 
 	switch subcommand {
 	case "stats":
-		handleCacheStats(cacheManager, args[1:])
+		handleCacheStats(cacheManager, args[1:], searchMaxAge)
 	case "list":
-		handleCacheList(cacheManager, args[1:])
+		handleCacheList(cacheManager, args[1:], maxAge, searchMaxAge)
 	case "clear":
 		handleCacheClear(cacheManager, args[1:])
 	case "remove":
 		handleCacheRemove(cacheManager, args[1:])
+	case "info":
+		handleCacheInfo(cacheManager, args[1:], maxAge)
 	case "update":
-		handleCacheUpdate(cacheManager, args[1:])
+		handleCacheUpdate(cacheManager, c, maxAge, args[1:])
 	case "prune":
-		handleCachePrune(cacheManager, args[1:])
+		handleCachePrune(cacheManager, args[1:], searchMaxAge)
+	case "warm":
+		handleCacheWarm(cacheManager, c, maxAge, searchMaxAge, args[1:])
+	case "pin":
+		handleCachePin(cacheManager, args[1:], true)
+	case "unpin":
+		handleCachePin(cacheManager, args[1:], false)
+	case "reindex":
+		handleCacheReindex(cacheManager)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown cache command: %s\n\n", subcommand)
 		printCacheUsage()
@@ -43,35 +65,71 @@ func printCacheUsage() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  ctx7 cache stats              Show cache statistics")
-	fmt.Println("  ctx7 cache list               List all cached libraries")
+	fmt.Println("  ctx7 cache list               List all cached libraries (--searches, --variants)")
+	fmt.Println("  ctx7 cache info <library>     Show every cached version of a library in detail")
 	fmt.Println("  ctx7 cache clear              Clear entire cache")
-	fmt.Println("  ctx7 cache remove <library>   Remove specific library")
-	fmt.Println("  ctx7 cache update <library>   Force refresh specific library")
-	fmt.Println("  ctx7 cache prune --days N     Remove entries older than N days")
+	fmt.Println("  ctx7 cache remove <library>   Remove specific library (or org/* for a whole org)")
+	fmt.Println("  ctx7 cache update <library>   Force refresh specific library (or org/*, --all)")
+	fmt.Println("  ctx7 cache prune --days N     Remove entries older than N days (or --max-size)")
+	fmt.Println("  ctx7 cache prune --searches   Remove expired search results (--all for every one)")
+	fmt.Println("  ctx7 cache warm <library>...  Download docs for libraries ahead of time")
+	fmt.Println("  ctx7 cache pin <library>      Keep a library (or lib@version) when pruning")
+	fmt.Println("  ctx7 cache unpin <library>    Let prune remove a pinned library again")
+	fmt.Println("  ctx7 cache reindex            Rebuild the index that list and stats read")
 	fmt.Println()
 	fmt.Println("Flags:")
-	fmt.Println("  --json            Output in JSON format (stats, list)")
+	fmt.Println("  --json            Output in JSON format (stats, list, info)")
+	fmt.Println("  --schema          Print the JSON Schema of --json output (stats, list, info)")
+	fmt.Println("  --reset           Reset hit/miss statistics (stats)")
 	fmt.Println("  --force, -f       Skip confirmation prompts")
 	fmt.Println("  --dry-run         Preview changes without applying them")
 	fmt.Println("  --version <ver>   Target specific version (remove, update)")
 	fmt.Println("  --days <N>        Age threshold in days (prune)")
+	fmt.Println("  --max-size <S>    Size target, e.g. 200MB; oldest fetches go first (prune)")
 	fmt.Println("  --keep-latest     Keep latest version of each library (prune)")
+	fmt.Println("  --searches        Act on cached search results instead of libraries (list, prune)")
+	fmt.Println("  --from-file <f>   Read libraries from a file, one per line (warm)")
+	fmt.Println("  --refresh         Re-download libraries already cached (warm)")
+	fmt.Println("  --all             Update every cached library (update)")
+	fmt.Println("  --refetch         Re-download now instead of invalidating (update)")
+	fmt.Println("  --stale           Only re-download stale or expired entries (update --refetch)")
+	fmt.Println("  --on-regression   ask, keep, replace or both for downloads that look worse (update --refetch)")
+	fmt.Println("  --jobs <N>        Libraries to fetch at once (warm, update --refetch, default 4)")
 }
 
 // handleCacheStats shows cache statistics
-func handleCacheStats(c *cache.Cache, args []string) {
+func handleCacheStats(c *cache.Cache, args []string, searchMaxAge time.Duration) {
 	fs := flag.NewFlagSet("stats", flag.ExitOnError)
 	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	schema := fs.Bool("schema", false, "Print the JSON Schema of --json output")
+	reset := fs.Bool("reset", false, "Reset hit/miss statistics")
 	fs.Parse(args)
 
+	if *schema {
+		printSchema("cache-stats")
+		return
+	}
+
+	showScanProgress(c)
+
+	if *reset {
+		if err := c.ResetUsageStats(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error resetting usage stats: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✓ Hit/miss statistics reset")
+		return
+	}
+
 	stats, err := c.GetDetailedStats()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting cache stats: %v\n", err)
 		os.Exit(1)
 	}
+	expiredSearches := countExpiredSearches(c, searchMaxAge)
 
 	if *jsonOutput {
-		if err := printJSON(stats); err != nil {
+		if err := printJSON(newCacheStatsJSON(stats, expiredSearches, searchMaxAge)); err != nil {
 			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
 			os.Exit(1)
 		}
@@ -111,45 +169,70 @@ func handleCacheStats(c *cache.Cache, args []string) {
 	// Show search cache stats
 	if stats.SearchCacheEntries > 0 {
 		fmt.Println()
-		fmt.Printf("Search Cache:    %s (%d entries)\n",
-			formatSize(stats.SearchCacheSize), stats.SearchCacheEntries)
+		fmt.Printf("Search Cache:    %s (%d entries, %d expired, TTL %s)\n",
+			formatSize(stats.SearchCacheSize), stats.SearchCacheEntries, expiredSearches, searchMaxAge)
+	}
+
+	// Show hit/miss stats
+	usage := stats.Usage
+	if usage.Hits+usage.Misses > 0 {
+		fmt.Println()
+		fmt.Printf("Cache Usage (since %s):\n", formatDate(usage.Since))
+		fmt.Printf("  Hit Rate:      %.1f%% (%d hits, %d misses)\n",
+			usage.HitRate()*100, usage.Hits, usage.Misses)
+		fmt.Printf("  Bytes Saved:   %s\n", formatSize(usage.BytesServed))
+		if saved := usage.EstimatedTimeSaved(); saved > 0 {
+			fmt.Printf("  Time Saved:    ~%s (estimated)\n", formatDuration(saved))
+		}
 	}
 }
 
-// handleCacheList lists all cached libraries
-func handleCacheList(c *cache.Cache, args []string) {
+// handleCacheList lists all cached libraries, coloring ages by freshness
+func handleCacheList(c *cache.Cache, args []string, maxAge, searchMaxAge time.Duration) {
 	fs := flag.NewFlagSet("list", flag.ExitOnError)
 	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	schema := fs.Bool("schema", false, "Print the JSON Schema of --json output")
+	searches := fs.Bool("searches", false, "List cached search queries instead of libraries")
+	variants := fs.Bool("variants", false, "Include topic-scoped variants and kept copies of documents")
 	fs.Parse(args)
 
+	if *searches {
+		listCachedSearches(c, searchMaxAge, *jsonOutput, *schema)
+		return
+	}
+
+	if *schema {
+		printSchema("cache-list")
+		return
+	}
+
 	libraries, err := c.ListCachedLibraries()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error listing cache: %v\n", err)
 		os.Exit(1)
 	}
-
-	if len(libraries) == 0 {
-		fmt.Println("Cache is empty")
-		return
+	if !*variants {
+		libraries = withoutVariants(libraries)
 	}
 
 	if *jsonOutput {
-		data := map[string]interface{}{
-			"libraries":       libraries,
-			"total_libraries": len(libraries),
-		}
-		if err := printJSON(data); err != nil {
+		if err := printJSON(newCacheListJSON(libraries)); err != nil {
 			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
+	if len(libraries) == 0 {
+		fmt.Println("Cache is empty")
+		return
+	}
+
 	// Print human-readable list
 	printHeader("Cached Libraries")
 
 	var totalSize int64
-	var totalVersions int
+	var totalVersions, totalVariants int
 
 	for _, lib := range libraries {
 		fmt.Printf("%s\n", lib.LibraryID)
@@ -160,14 +243,55 @@ func handleCacheList(c *cache.Cache, args []string) {
 			if v.IsDefault {
 				defaultMarker = " (default)"
 			}
-			fmt.Printf("  └─ %-12s %10s    %s%s\n",
-				v.Version, formatSize(v.Size), formatDate(v.FetchedAt), defaultMarker)
+			if v.Metadata.Pinned {
+				defaultMarker += " 📌 pinned"
+			}
+			if v.Shared {
+				defaultMarker += " (shared)"
+			}
+			if v.IsVariant() {
+				totalVariants++
+				if v.Metadata.Superseded {
+					defaultMarker += " kept"
+				} else {
+					defaultMarker += fmt.Sprintf(" topic %q", v.Metadata.Topic)
+				}
+			}
+			freshness := formatFreshness(v.FetchedAt, maxAge)
+			if upstream := formatUpstream(v.Metadata); upstream != "" {
+				freshness += ", " + upstream
+			}
+			fmt.Printf("  └─ %-12s %10s    %s  %s%s\n",
+				v.Version, formatSize(v.Size), formatDate(v.FetchedAt), freshness, defaultMarker)
 		}
 		fmt.Println()
 	}
 
-	fmt.Printf("Total: %d libraries, %d versions, %s\n",
-		len(libraries), totalVersions, formatSize(totalSize))
+	variantNote := ""
+	if *variants {
+		variantNote = fmt.Sprintf(" (%d variants)", totalVariants)
+	}
+	fmt.Printf("Total: %d libraries, %d versions%s, %s\n",
+		len(libraries), totalVersions, variantNote, formatSize(totalSize))
+}
+
+// withoutVariants drops topic-scoped variants and kept copies from
+// libraries, and the libraries that only have those cached
+func withoutVariants(libraries []cache.CachedLibrary) []cache.CachedLibrary {
+	var out []cache.CachedLibrary
+	for _, lib := range libraries {
+		var versions []cache.VersionInfo
+		for _, v := range lib.Versions {
+			if !v.IsVariant() {
+				versions = append(versions, v)
+			}
+		}
+		if len(versions) > 0 {
+			lib.Versions = versions
+			out = append(out, lib)
+		}
+	}
+	return out
 }
 
 // handleCacheClear clears the entire cache
@@ -217,6 +341,49 @@ func handleCacheClear(c *cache.Cache, args []string) {
 	fmt.Println("Cache cleared successfully")
 }
 
+// handleCacheReindex rebuilds the cache index from the cached files
+func handleCacheReindex(c *cache.Cache) {
+	showScanProgress(c)
+	n, err := c.Reindex()
+	if errors.Is(err, cache.ErrNoIndex) {
+		fmt.Println("This cache backend keeps no index")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rebuilding cache index: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Indexed %d library versions\n", n)
+}
+
+// scanProgressInterval is how often showScanProgress redraws its line
+const scanProgressInterval = 100 * time.Millisecond
+
+// showScanProgress counts the library directories read on stderr while
+// the cache index is rebuilt, which can take a while on slow filesystems
+// such as NFS or WSL. Nothing is shown when stderr isn't a terminal or the
+// rebuild is over before the first redraw.
+func showScanProgress(c *cache.Cache) {
+	if !term.IsTerminal(os.Stderr.Fd()) {
+		return
+	}
+	start := time.Now()
+	var last time.Time
+	c.SetScanProgress(func(done, total int) {
+		if done == total {
+			if !last.IsZero() {
+				fmt.Fprint(os.Stderr, "\r\033[K")
+			}
+			return
+		}
+		if time.Since(start) < scanProgressInterval || time.Since(last) < scanProgressInterval {
+			return
+		}
+		last = time.Now()
+		fmt.Fprintf(os.Stderr, "\rIndexing cache... %d/%d libraries", done, total)
+	})
+}
+
 // handleCacheRemove removes a specific library or version
 func handleCacheRemove(c *cache.Cache, args []string) {
 	fs := flag.NewFlagSet("remove", flag.ExitOnError)
@@ -228,33 +395,35 @@ func handleCacheRemove(c *cache.Cache, args []string) {
 
 	if len(fs.Args()) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: library ID required")
-		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache remove <library-id> [--version <ver>]")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache remove <library-id|org/*> [--version <ver>]")
 		os.Exit(1)
 	}
 
 	libraryID := fs.Arg(0)
+	pattern, err := client.ParsePattern(libraryID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Get library info for confirmation
-	libraries, err := c.ListCachedLibraries()
+	libraries, err := c.MatchLibraries(pattern)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error listing cache: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Find the library
-	var targetLib *cache.CachedLibrary
-	for i := range libraries {
-		if libraries[i].LibraryID == libraryID || libraries[i].LibraryID == "/"+libraryID {
-			targetLib = &libraries[i]
-			break
-		}
-	}
-
-	if targetLib == nil {
+	if len(libraries) == 0 {
 		fmt.Fprintf(os.Stderr, "Error: library not found in cache: %s\n", libraryID)
 		os.Exit(1)
 	}
 
+	if pattern.IsWildcard() {
+		removeMatching(c, pattern, libraries, *version, *force, *dryRun)
+		return
+	}
+	targetLib := &libraries[0]
+
 	if *version != "" {
 		// Remove specific version
 		var targetVersion *cache.VersionInfo
@@ -333,19 +502,122 @@ func handleCacheRemove(c *cache.Cache, args []string) {
 	}
 }
 
+// removeMatching removes every library matched by a wildcard pattern, or
+// only the given version of each
+func removeMatching(c *cache.Cache, pattern client.Pattern, libraries []cache.CachedLibrary, version string, force, dryRun bool) {
+	var targets []cache.CachedLibrary
+	var totalSize int64
+
+	fmt.Printf("Found cached libraries matching %s:\n", pattern)
+	for _, lib := range libraries {
+		var size int64
+		versions := 0
+		for _, v := range lib.Versions {
+			if version == "" || v.Version == version {
+				size += v.Size
+				versions++
+			}
+		}
+		if versions == 0 {
+			continue
+		}
+
+		targets = append(targets, lib)
+		totalSize += size
+		if version != "" {
+			fmt.Printf("  └─ %s@%s (%s)\n", lib.LibraryID, version, formatSize(size))
+		} else {
+			fmt.Printf("  └─ %s (%d versions, %s)\n", lib.LibraryID, versions, formatSize(size))
+		}
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: version not found: %s@%s\n", pattern, version)
+		os.Exit(1)
+	}
+	fmt.Printf("\nTotal: %d libraries, %s\n\n", len(targets), formatSize(totalSize))
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would remove %d libraries\n", len(targets))
+		return
+	}
+
+	if !force {
+		if !confirmAction(fmt.Sprintf("Remove %d libraries?", len(targets))) {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	for _, lib := range targets {
+		if err := c.ForceUpdate(lib.LibraryID, version); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", lib.LibraryID, err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("\n✓ Removed %d libraries\n", len(targets))
+	fmt.Printf("✓ Freed %s of disk space\n", formatSize(totalSize))
+}
+
 // handleCacheUpdate forces a cache refresh for a library
-func handleCacheUpdate(c *cache.Cache, args []string) {
+func handleCacheUpdate(c *cache.Cache, api *client.Client, maxAge time.Duration, args []string) {
 	fs := flag.NewFlagSet("update", flag.ExitOnError)
 	version := fs.String("version", "", "Update only this version")
+	all := fs.Bool("all", false, "Update every cached library")
+	refetch := fs.Bool("refetch", false, "Re-download content now instead of invalidating it")
+	staleOnly := fs.Bool("stale", false, "With --refetch, only re-download stale or expired entries")
+	jobs := fs.Int("jobs", 4, "Number of libraries to re-download at once")
+	onRegression := fs.String("on-regression", "ask", "With --refetch, when a download is much smaller or older than the cached copy: ask, keep, replace or both")
 	fs.Parse(args)
 
-	if len(fs.Args()) == 0 {
+	if len(fs.Args()) == 0 && !*all {
 		fmt.Fprintln(os.Stderr, "Error: library ID required")
-		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache update <library-id> [--version <ver>]")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache update <library-id|org/*|--all> [--version <ver>] [--refetch [--stale]]")
 		os.Exit(1)
 	}
 
 	libraryID := fs.Arg(0)
+	if *all {
+		libraryID = "*/*"
+	}
+	pattern, err := client.ParsePattern(libraryID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *refetch {
+		policy, err := regressionPolicy(*onRegression)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --on-regression: %v\n", err)
+			os.Exit(1)
+		}
+		refetchMatching(c, api, maxAge, pattern, *version, *staleOnly, *jobs, policy)
+		return
+	}
+
+	if pattern.IsWildcard() {
+		libraries, err := c.MatchLibraries(pattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing cache: %v\n", err)
+			os.Exit(1)
+		}
+		if len(libraries) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no cached libraries match %s\n", pattern)
+			os.Exit(1)
+		}
+
+		for _, lib := range libraries {
+			if err := c.ForceUpdate(lib.LibraryID, *version); err != nil {
+				fmt.Fprintf(os.Stderr, "Error invalidating %s: %v\n", lib.LibraryID, err)
+				os.Exit(1)
+			}
+			fmt.Printf("✓ Cache invalidated for %s\n", lib.LibraryID)
+		}
+		fmt.Printf("\n✓ Next fetch of these %d libraries will retrieve fresh content from %s\n", len(libraries), api.Host())
+		return
+	}
 
 	fmt.Printf("Invalidating cache for: %s", libraryID)
 	if *version != "" {
@@ -363,34 +635,67 @@ func handleCacheUpdate(c *cache.Cache, args []string) {
 	} else {
 		fmt.Printf("\n✓ Cache invalidated for all versions\n")
 	}
-	fmt.Println("✓ Next fetch will retrieve fresh content from context7.com")
+	fmt.Printf("✓ Next fetch will retrieve fresh content from %s\n", api.Host())
 	fmt.Printf("\nTo fetch now, run: ctx7 %s\n", libraryID)
 }
 
 // handleCachePrune removes old cache entries
-func handleCachePrune(c *cache.Cache, args []string) {
+func handleCachePrune(c *cache.Cache, args []string, searchMaxAge time.Duration) {
 	fs := flag.NewFlagSet("prune", flag.ExitOnError)
 	days := fs.Int("days", 0, "Remove entries older than this many days")
+	maxSize := fs.String("max-size", "", "Remove least recently fetched entries until the cache fits, e.g. 200MB")
 	keepLatest := fs.Bool("keep-latest", false, "Keep latest version of each library")
+	searches := fs.Bool("searches", false, "Prune cached search results instead of libraries")
+	all := fs.Bool("all", false, "Remove every cached search (prune --searches)")
 	force := fs.Bool("force", false, "Skip confirmation")
 	fs.BoolVar(force, "f", false, "Skip confirmation (shorthand)")
 	dryRun := fs.Bool("dry-run", false, "Preview without deleting")
 	fs.Parse(args)
 
-	if *days <= 0 {
-		fmt.Fprintln(os.Stderr, "Error: --days flag is required and must be positive")
-		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache prune --days N [--keep-latest] [--force]")
+	if *searches {
+		maxAge := searchMaxAge
+		switch {
+		case *all:
+			maxAge = 0
+		case *days > 0:
+			maxAge = time.Duration(*days) * 24 * time.Hour
+		}
+		pruneSearches(c, maxAge, *force, *dryRun)
+		return
+	}
+
+	var targetSize int64
+	if *maxSize != "" {
+		size, err := parseSize(*maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		targetSize = size
+	}
+
+	if *days < 0 || (*days == 0 && targetSize == 0) {
+		fmt.Fprintln(os.Stderr, "Error: --days or --max-size is required and must be positive")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache prune [--days N] [--max-size SIZE] [--keep-latest] [--force]")
 		os.Exit(1)
 	}
 
 	maxAge := time.Duration(*days) * 24 * time.Hour
 
-	fmt.Printf("Analyzing cache entries older than %d days...\n\n", *days)
+	switch {
+	case *days > 0 && targetSize > 0:
+		fmt.Printf("Analyzing cache entries older than %d days or beyond %s...\n\n", *days, formatSize(targetSize))
+	case *days > 0:
+		fmt.Printf("Analyzing cache entries older than %d days...\n\n", *days)
+	default:
+		fmt.Printf("Analyzing cache entries beyond %s...\n\n", formatSize(targetSize))
+	}
 
 	result, err := c.Prune(cache.PruneOptions{
 		MaxAge:     maxAge,
 		DryRun:     true, // Always dry-run first to show what would be deleted
 		KeepLatest: *keepLatest,
+		TargetSize: targetSize,
 	})
 
 	if err != nil {
@@ -398,12 +703,16 @@ func handleCachePrune(c *cache.Cache, args []string) {
 		os.Exit(1)
 	}
 
+	if result.KeptPinned > 0 {
+		fmt.Printf("Keeping %d pinned entries\n\n", result.KeptPinned)
+	}
+
 	if result.RemovedCount == 0 {
-		fmt.Println("No stale entries found")
+		fmt.Println("Nothing to prune")
 		return
 	}
 
-	fmt.Printf("Found %d stale entries:\n", result.RemovedCount)
+	fmt.Printf("Found %d entries to prune:\n", result.RemovedCount)
 	for _, item := range result.RemovedItems {
 		fmt.Printf("  └─ %s\n", item)
 	}
@@ -428,6 +737,7 @@ func handleCachePrune(c *cache.Cache, args []string) {
 		MaxAge:     maxAge,
 		DryRun:     false,
 		KeepLatest: *keepLatest,
+		TargetSize: targetSize,
 	})
 
 	if err != nil {
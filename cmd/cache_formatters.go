@@ -4,9 +4,14 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/ui"
 )
 
 // formatSize converts bytes to human-readable format
@@ -25,6 +30,27 @@ func formatSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// parseSize parses a size such as "200MB", "1.5G" or "4096" into bytes,
+// using the same binary units as formatSize
+func parseSize(s string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "IB"), "B")
+
+	multiplier := int64(1)
+	if n := len(text); n > 0 {
+		if i := strings.IndexByte("KMGT", text[n-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			text = strings.TrimSpace(text[:n-1])
+		}
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size: %s (expected e.g. 200MB)", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
 // formatAge converts a time to a human-readable age string
 func formatAge(t time.Time) string {
 	now := time.Now()
@@ -35,18 +61,53 @@ func formatAge(t time.Time) string {
 	} else if diff < 48*time.Hour {
 		return "yesterday"
 	} else if diff < 7*24*time.Hour {
-		days := int(diff.Hours() / 24)
-		return fmt.Sprintf("%d days ago", days)
+		return ago(int(diff.Hours()/24), "day")
 	} else if diff < 30*24*time.Hour {
-		weeks := int(diff.Hours() / 24 / 7)
-		return fmt.Sprintf("%d weeks ago", weeks)
+		return ago(int(diff.Hours()/24/7), "week")
 	} else if diff < 365*24*time.Hour {
-		months := int(diff.Hours() / 24 / 30)
-		return fmt.Sprintf("%d months ago", months)
+		return ago(int(diff.Hours()/24/30), "month")
 	} else {
-		years := int(diff.Hours() / 24 / 365)
-		return fmt.Sprintf("%d years ago", years)
+		return ago(int(diff.Hours()/24/365), "year")
+	}
+}
+
+// ago renders n units in the past, e.g. "1 week ago" or "3 weeks ago"
+func ago(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s ago", unit)
+	}
+	return fmt.Sprintf("%d %ss ago", n, unit)
+}
+
+// formatFreshness renders a cache entry's age colored by its freshness
+// against maxAge, naming the state when it is not fresh since color may be
+// stripped (e.g. "3 days ago, expired")
+func formatFreshness(fetchedAt time.Time, maxAge time.Duration) string {
+	freshness := cache.FreshnessOf(fetchedAt, time.Now(), maxAge)
+	label := formatAge(fetchedAt)
+	if freshness != cache.Fresh {
+		label += ", " + freshness.String()
+	}
+	return ui.RenderFreshness(freshness, label)
+}
+
+// formatUpstream describes when context7 last updated a cached library, as
+// known when the entry was fetched (e.g. "upstream updated 3 weeks ago"),
+// or returns "" when the date is unknown
+func formatUpstream(meta cache.Metadata) string {
+	updated, err := time.Parse(time.RFC3339, meta.LastUpdateDate)
+	if err != nil {
+		return ""
 	}
+	return "upstream updated " + formatAge(updated)
+}
+
+// formatDuration rounds a duration for display (e.g. "1m12s", "850ms")
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
 }
 
 // formatDate converts a time to a short date string
@@ -63,7 +124,12 @@ func printHeader(title string) {
 
 // printJSON marshals data to JSON and prints it
 func printJSON(data interface{}) error {
-	encoder := json.NewEncoder(os.Stdout)
+	return writeJSON(os.Stdout, data)
+}
+
+// writeJSON marshals data to indented JSON and writes it to w
+func writeJSON(w io.Writer, data interface{}) error {
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(data)
 }
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatAge(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{time.Hour, "today"},
+		{30 * time.Hour, "yesterday"},
+		{3 * day, "3 days ago"},
+		{8 * day, "1 week ago"},
+		{15 * day, "2 weeks ago"},
+		{40 * day, "1 month ago"},
+		{100 * day, "3 months ago"},
+		{400 * day, "1 year ago"},
+		{800 * day, "2 years ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(time.Now().Add(-tt.age)); got != tt.want {
+			t.Errorf("formatAge(%v ago) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
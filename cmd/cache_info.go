@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/ui"
+)
+
+// handleCacheInfo prints everything the cache knows about one library
+func handleCacheInfo(c *cache.Cache, args []string, maxAge time.Duration) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	schema := fs.Bool("schema", false, "Print the JSON Schema of --json output")
+	fs.Parse(args)
+
+	if *schema {
+		printSchema("cache-info")
+		return
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: library ID required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache info [--json] <library-id>")
+		os.Exit(1)
+	}
+
+	lib, err := c.Library(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		if err := printJSON(newCacheInfoJSON(c, lib, maxAge, time.Now())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	printHeader(lib.LibraryID)
+
+	// Library-level details come from the most recently fetched version
+	latest := lib.Versions[0]
+	var totalSize int64
+	for _, v := range lib.Versions {
+		totalSize += v.Size
+		if v.FetchedAt.After(latest.FetchedAt) {
+			latest = v
+		}
+	}
+	meta := latest.Metadata
+	if meta.Title != "" {
+		fmt.Printf("Title:          %s\n", meta.Title)
+	}
+	fmt.Printf("Stars:          %d\n", meta.Stars)
+	fmt.Printf("Trust Score:    %.1f\n", meta.TrustScore)
+	if meta.LastUpdateDate != "" {
+		fmt.Printf("Last Updated:   %s (upstream, as of the last fetch)\n", formatUpstreamDate(meta.LastUpdateDate))
+	}
+	if len(meta.Versions) > 0 {
+		fmt.Printf("Upstream:       %s\n", strings.Join(meta.Versions, ", "))
+	}
+	fmt.Printf("Cached:         %d versions, %s\n", len(lib.Versions), formatSize(totalSize))
+	fmt.Printf("TTL:            %s\n", maxAge)
+
+	for _, v := range lib.Versions {
+		fmt.Println()
+		marker := ""
+		if v.IsDefault {
+			marker = " (default)"
+		}
+		if v.Metadata.Pinned {
+			marker += " 📌 pinned"
+		}
+		if v.Shared {
+			marker += " (shared)"
+		}
+		fmt.Printf("%s%s\n", v.Version, marker)
+
+		if v.Metadata.Branch != "" {
+			fmt.Printf("  Branch:       %s\n", v.Metadata.Branch)
+		}
+		if v.Metadata.Topic != "" {
+			fmt.Printf("  Topic:        %s\n", v.Metadata.Topic)
+		}
+		if v.Metadata.Superseded {
+			fmt.Printf("  Kept:         replaced by a refresh that looked like a regression\n")
+		}
+		fmt.Printf("  Size:         %s\n", formatSize(v.Size))
+		fmt.Printf("  Fetched:      %s %s (%s)\n", formatDate(v.FetchedAt), v.FetchedAt.Format("15:04"), formatFreshness(v.FetchedAt, maxAge))
+		if updated, err := time.Parse(time.RFC3339, v.Metadata.LastUpdateDate); err == nil {
+			fmt.Printf("  Updated:      %s upstream (%s)\n", formatDate(updated), formatAge(updated))
+		}
+		fmt.Printf("  Expires:      %s\n", formatExpiry(v.FetchedAt, maxAge))
+		if v.Metadata.EstimatedTokens > 0 {
+			fmt.Printf("  Tokens:       ~%d (upstream reports %d)\n", v.Metadata.EstimatedTokens, v.Metadata.TotalTokens)
+		} else {
+			fmt.Printf("  Tokens:       %d (upstream)\n", v.Metadata.TotalTokens)
+		}
+		fmt.Printf("  Snippets:     %d\n", v.Metadata.TotalSnippets)
+		if v.Metadata.SHA256 != "" {
+			fmt.Printf("  SHA-256:      %s\n", v.Metadata.SHA256)
+		}
+		if patches := v.Metadata.Patches; len(patches) > 0 {
+			last := patches[len(patches)-1]
+			var downloaded, size int64
+			for _, p := range patches {
+				downloaded += p.Bytes
+				size += p.Size
+			}
+			fmt.Printf("  Patched:      %d times since the last full download, %s of %s downloaded\n", len(patches), formatSize(downloaded), formatSize(size))
+			fmt.Printf("  Last patch:   %s, %d sections downloaded, %d reused, %d dropped\n", formatAge(last.PatchedAt), last.Fetched, last.Reused, last.Dropped)
+		}
+		if path := c.Location(lib.LibraryID, v.Version); path != "" {
+			fmt.Printf("  Path:         %s\n", path)
+		}
+	}
+}
+
+// formatExpiry says when an entry fetched at fetchedAt stops being served
+func formatExpiry(fetchedAt time.Time, maxAge time.Duration) string {
+	expires := fetchedAt.Add(maxAge)
+	freshness := cache.FreshnessOf(fetchedAt, time.Now(), maxAge)
+	if freshness == cache.Expired {
+		return ui.RenderFreshness(freshness, fmt.Sprintf("%s (refetched on next use)", formatDate(expires)))
+	}
+	remaining := strings.TrimSuffix(time.Until(expires).Round(time.Minute).String(), "0s")
+	return ui.RenderFreshness(freshness, fmt.Sprintf("%s (in %s)", formatDate(expires), remaining))
+}
+
+// formatUpstreamDate shortens context7's RFC3339 update date, leaving
+// other formats as they are
+func formatUpstreamDate(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return formatDate(t)
+	}
+	return s
+}
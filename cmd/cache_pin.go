@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+)
+
+// handleCachePin pins (or with pinned false, unpins) cached libraries so
+// that prune keeps them
+func handleCachePin(c *cache.Cache, args []string, pinned bool) {
+	name := "pin"
+	if !pinned {
+		name = "unpin"
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Error: library ID required")
+		fmt.Fprintf(os.Stderr, "Usage: ctx7 cache %s <library-id|org/*>[@version]...\n", name)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, arg := range fs.Args() {
+		if err := pinMatching(c, arg, pinned); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// pinMatching sets the pin on every cached library matching arg, which is
+// a library ID or pattern optionally followed by @version
+func pinMatching(c *cache.Cache, arg string, pinned bool) error {
+	id, version := client.SplitVersion(arg)
+
+	pattern, err := client.ParsePattern(id)
+	if err != nil {
+		return err
+	}
+	libraries, err := c.MatchLibraries(pattern)
+	if err != nil {
+		return fmt.Errorf("failed to list cache: %w", err)
+	}
+	if len(libraries) == 0 {
+		return fmt.Errorf("library not found in cache: %s", id)
+	}
+
+	action := "Pinned"
+	if !pinned {
+		action = "Unpinned"
+	}
+	for _, lib := range libraries {
+		versions, err := c.SetPinned(lib.LibraryID, version, pinned)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			fmt.Printf("✓ %s %s@%s\n", action, lib.LibraryID, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// refetchTarget is one cached version to re-download
+type refetchTarget struct {
+	libraryID string
+	version   cache.VersionInfo
+}
+
+// refetchResult is the outcome of re-downloading one cached version
+type refetchResult struct {
+	target  refetchTarget
+	changes *engine.ChangeSummary
+	// regression is set when the download looked worse than the cached
+	// copy; kept when that copy was kept instead
+	regression *engine.Regression
+	kept       bool
+	err        error
+}
+
+// label names the cached version, e.g. /vercel/next.js@v14
+func (t refetchTarget) label() string {
+	if t.version.IsDefault {
+		return t.libraryID
+	}
+	return t.libraryID + "@" + t.version.Version
+}
+
+// refetchMatching re-downloads every cached version of the libraries
+// matching pattern in parallel, reporting each as it finishes and a
+// changed/unchanged summary at the end. onRegression decides on downloads
+// that look worse than the cached copy.
+func refetchMatching(c *cache.Cache, api *client.Client, maxAge time.Duration, pattern client.Pattern, version string, staleOnly bool, jobs int, onRegression func(engine.Request, engine.Regression) engine.RegressionAction) {
+	libraries, err := c.MatchLibraries(pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	var targets []refetchTarget
+	for _, lib := range libraries {
+		for _, v := range lib.Versions {
+			// Kept copies are refetched with the document that replaced them
+			if version != "" && v.Version != version || v.Metadata.Superseded {
+				continue
+			}
+			if staleOnly && cache.FreshnessOf(v.FetchedAt, now, maxAge) == cache.Fresh {
+				continue
+			}
+			targets = append(targets, refetchTarget{libraryID: lib.LibraryID, version: v})
+		}
+	}
+
+	if len(targets) == 0 {
+		if staleOnly {
+			fmt.Println("No stale entries to refresh")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error: no cached libraries match %s\n", pattern)
+		os.Exit(1)
+	}
+
+	batch, tx := BeginBatch(c)
+	eng := engine.New(engine.Options{Client: api, Cache: batch, MaxAge: maxAge, OnRegression: onRegression})
+
+	fmt.Printf("Refreshing %d cached entries (%d at a time)...\n\n", len(targets), max(jobs, 1))
+
+	results := parallel(targets, jobs, func(t refetchTarget) refetchResult {
+		result, err := eng.Refresh(context.Background(), requestFor(t))
+		if err != nil {
+			return refetchResult{target: t, err: err}
+		}
+		return refetchResult{target: t, changes: result.Changes, regression: result.Regression, kept: result.FromCache}
+	})
+
+	var changed, unchanged, kept, failed, done int
+	for r := range results {
+		done++
+		progress := fmt.Sprintf("[%d/%d]", done, len(targets))
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Printf("%s ✗ %s: %v\n", progress, r.target.label(), r.err)
+		case r.kept:
+			kept++
+			fmt.Printf("%s ⚠ %s (kept the cached copy: %s)\n", progress, r.target.label(), r.regression)
+		case r.changes == nil || !r.changes.Unchanged():
+			changed++
+			fmt.Printf("%s ✓ %s (%s)\n", progress, r.target.label(), r.changeSummary())
+		default:
+			unchanged++
+			fmt.Printf("%s ✓ %s (unchanged)\n", progress, r.target.label())
+		}
+	}
+	CommitBatch(tx)
+
+	keptNote := ""
+	if kept > 0 {
+		keptNote = fmt.Sprintf(", %d kept", kept)
+	}
+	fmt.Printf("\nRefreshed %d entries: %d changed, %d unchanged%s, %d failed\n",
+		changed+unchanged, changed, unchanged, keptNote, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// changeSummary describes what a re-download changed in the cached copy
+func (r refetchResult) changeSummary() string {
+	summary := "changed"
+	if r.changes != nil {
+		summary = r.changes.String()
+	}
+	if r.regression != nil {
+		summary += "; looks like a regression: " + r.regression.String()
+	}
+	return summary
+}
+
+// requestFor rebuilds the fetch request for a cached version from its metadata
+func requestFor(t refetchTarget) engine.Request {
+	md := t.version.Metadata
+	return engine.Request{
+		Library: client.Library{
+			ID:             t.libraryID,
+			Title:          md.Title,
+			LastUpdateDate: md.LastUpdateDate,
+			TotalTokens:    md.TotalTokens,
+			TotalSnippets:  md.TotalSnippets,
+			Stars:          md.Stars,
+			TrustScore:     md.TrustScore,
+			Versions:       md.Versions,
+		},
+		Version: md.Version,
+		Branch:  md.Branch,
+		Topic:   md.Topic,
+	}
+}
+
+// regressionPolicy returns the OnRegression for --on-regression: "ask"
+// asks on the terminal, one download at a time, and keeps the cached copy
+// when there is no terminal to ask on
+func regressionPolicy(policy string) (func(engine.Request, engine.Regression) engine.RegressionAction, error) {
+	if policy != "ask" {
+		action, err := engine.ParseRegressionAction(policy)
+		if err != nil {
+			return nil, err
+		}
+		return func(engine.Request, engine.Regression) engine.RegressionAction { return action }, nil
+	}
+
+	var mu sync.Mutex
+	return func(req engine.Request, r engine.Regression) engine.RegressionAction {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return engine.RegressionKeep
+		}
+		mu.Lock()
+		defer mu.Unlock()
+
+		label := req.Library.ID
+		if key := req.CacheKey(); key != "" {
+			label += "@" + key
+		}
+		fmt.Printf("\n%s looks like a regression: %s\n", label, r)
+		fmt.Print("[k]eep cached, [r]eplace, keep [b]oth? (K/r/b): ")
+		response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(response)) {
+		case "r", "replace":
+			return engine.RegressionReplace
+		case "b", "both":
+			return engine.RegressionKeepBoth
+		}
+		return engine.RegressionKeep
+	}, nil
+}
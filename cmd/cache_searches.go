@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+)
+
+// listCachedSearches prints the cached search queries for `cache list --searches`
+func listCachedSearches(c *cache.Cache, maxAge time.Duration, jsonOutput, schema bool) {
+	if schema {
+		printSchema("cache-searches")
+		return
+	}
+
+	searches, err := c.ListCachedSearches()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing cached searches: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		if err := printJSON(newCacheSearchesJSON(searches, maxAge)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(searches) == 0 {
+		fmt.Println("No cached searches")
+		return
+	}
+
+	printHeader(fmt.Sprintf("Cached Searches (%d, TTL %s)", len(searches), maxAge))
+	var totalSize int64
+	for _, s := range searches {
+		fmt.Printf("%-40q %3d results  %s\n", s.Query, s.ResultCount, formatFreshness(s.CachedAt, maxAge))
+		totalSize += s.Size
+	}
+	fmt.Printf("\nTotal: %s\n", formatSize(totalSize))
+}
+
+// countExpiredSearches returns how many cached searches are older than maxAge
+func countExpiredSearches(c *cache.Cache, maxAge time.Duration) int {
+	searches, err := c.ListCachedSearches()
+	if err != nil {
+		return 0
+	}
+	var expired int
+	for _, s := range searches {
+		if time.Since(s.CachedAt) > maxAge {
+			expired++
+		}
+	}
+	return expired
+}
+
+// pruneSearches removes cached searches older than maxAge for
+// `cache prune --searches`; 0 removes them all
+func pruneSearches(c *cache.Cache, maxAge time.Duration, force, dryRun bool) {
+	if maxAge > 0 {
+		fmt.Printf("Analyzing cached searches older than %s...\n\n", maxAge)
+	} else {
+		fmt.Println("Analyzing all cached searches...")
+		fmt.Println()
+	}
+
+	result, err := c.PruneSearches(maxAge, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing search cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	if result.RemovedCount == 0 {
+		fmt.Println("Nothing to prune")
+		return
+	}
+
+	fmt.Printf("Found %d searches to prune:\n", result.RemovedCount)
+	for _, item := range result.RemovedItems {
+		fmt.Printf("  └─ %s\n", item)
+	}
+	fmt.Printf("\nTotal: %s to be freed\n\n", formatSize(result.FreedSpace))
+
+	if dryRun {
+		fmt.Println("[DRY RUN] Preview complete")
+		return
+	}
+
+	if !force {
+		if !confirmAction("Prune these searches?") {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	result, err = c.PruneSearches(maxAge, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pruning search cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n✓ Removed %d searches\n", result.RemovedCount)
+	fmt.Printf("✓ Freed %s of disk space\n", formatSize(result.FreedSpace))
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// warmResult is the outcome of warming one library
+type warmResult struct {
+	name      string
+	libraryID string
+	size      int
+	fromCache bool
+	err       error
+}
+
+// handleCacheWarm resolves and downloads docs for a list of libraries
+// concurrently so machines can be pre-populated
+func handleCacheWarm(c *cache.Cache, api *client.Client, maxAge, searchMaxAge time.Duration, args []string) {
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	fromFile := fs.String("from-file", "", "Read libraries from a file, one per line")
+	jobs := fs.Int("jobs", 4, "Number of libraries to fetch at once")
+	refresh := fs.Bool("refresh", false, "Re-download libraries that are already cached")
+	fs.Parse(args)
+
+	names := fs.Args()
+	if *fromFile != "" {
+		listed, err := readLibraryList(*fromFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		names = append(names, listed...)
+	}
+
+	// Fetching the same library twice at once would race on its cache entry
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: at least one library required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 cache warm [--from-file list.txt] [--jobs N] [<library>...]")
+		os.Exit(1)
+	}
+
+	batch, tx := BeginBatch(c)
+	eng := engine.New(engine.Options{Client: api, Cache: batch, MaxAge: maxAge, SearchMaxAge: searchMaxAge})
+
+	fmt.Printf("Warming %d libraries (%d at a time)...\n\n", len(names), max(*jobs, 1))
+
+	results := parallel(names, *jobs, func(name string) warmResult {
+		return warmLibrary(eng, name, *refresh)
+	})
+
+	var fetched, cached, failed int
+	for r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Printf("✗ %s: %v\n", r.name, r.err)
+		case r.fromCache:
+			cached++
+			fmt.Printf("✓ %s (already cached)\n", r.libraryID)
+		default:
+			fetched++
+			fmt.Printf("✓ %s (%s)\n", r.libraryID, formatSize(int64(r.size)))
+		}
+	}
+	CommitBatch(tx)
+
+	fmt.Printf("\nWarmed %d libraries: %d fetched, %d already cached, %d failed\n",
+		fetched+cached, fetched, cached, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// warmLibrary resolves name and makes sure its default docs are cached
+func warmLibrary(eng *engine.Engine, name string, refresh bool) warmResult {
+	ctx := context.Background()
+
+	lib, err := resolveLibrary(ctx, eng, name)
+	if err != nil {
+		return warmResult{name: name, err: err}
+	}
+
+	req := engine.Request{Library: lib}
+	var result *engine.Result
+	if refresh {
+		result, err = eng.Refresh(ctx, req)
+	} else {
+		result, err = eng.Fetch(ctx, req)
+	}
+	if err != nil {
+		return warmResult{name: name, libraryID: lib.ID, err: err}
+	}
+
+	return warmResult{
+		name:      name,
+		libraryID: lib.ID,
+		size:      len(result.Content),
+		fromCache: result.FromCache,
+	}
+}
+
+// resolveLibrary returns the top search result for a query. A library ID
+// (e.g. /vercel/next.js) is looked up by name so its metadata is cached
+// too, falling back to the bare ID when the search doesn't list it.
+func resolveLibrary(ctx context.Context, eng *engine.Engine, arg string) (client.Library, error) {
+	if !strings.HasPrefix(arg, "/") {
+		resolution, err := eng.Resolve(ctx, arg)
+		if err != nil {
+			return client.Library{}, err
+		}
+		return resolution.Library, nil
+	}
+
+	name := arg[strings.LastIndex(arg, "/")+1:]
+	if results, err := eng.Search(ctx, name); err == nil {
+		for _, lib := range results {
+			if lib.ID == arg {
+				return lib, nil
+			}
+		}
+	}
+	return client.Library{ID: arg, Title: arg}, nil
+}
+
+// readLibraryList reads library names from path, one per line, skipping
+// blank lines and # comments
+func readLibraryList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open library list: %w", err)
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read library list: %w", err)
+	}
+	return names, nil
+}
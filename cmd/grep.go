@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/ui"
+)
+
+// grepLine is a line of a document remembered for context output
+type grepLine struct {
+	number  int
+	text    string
+	section string
+}
+
+// RunGrepCommand searches the cached documentation of every library, or of
+// the libraries matching a pattern, for a regular expression
+func RunGrepCommand(args []string, cacheManager *cache.Cache) {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	ignoreCase := fs.Bool("i", false, "Ignore case when matching")
+	fixed := fs.Bool("F", false, "Treat the pattern as a literal string")
+	contextLines := fs.Int("C", 2, "Lines of context to show around each match")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fmt.Fprintln(os.Stderr, "Error: pattern required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 grep [-i] [-F] [-C N] <pattern> [library[@version]]")
+		os.Exit(1)
+	}
+
+	expr := fs.Arg(0)
+	if *fixed {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if *ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid pattern: %v\n", err)
+		os.Exit(1)
+	}
+
+	libraries, version, err := grepLibraries(cacheManager, fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var matches, documents int
+	for _, lib := range libraries {
+		for _, v := range lib.Versions {
+			// Topic variants repeat parts of the full document
+			if v.IsVariant() || version != "" && v.Version != version {
+				continue
+			}
+
+			label := lib.LibraryID + "@" + v.Version
+			r, err := cacheManager.OpenWithVersion(lib.LibraryID, v.Version)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", label, err)
+				continue
+			}
+			n, err := grepDocument(os.Stdout, label, r, re, max(*contextLines, 0), documents > 0)
+			r.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", label, err)
+			}
+			if n > 0 {
+				matches += n
+				documents++
+			}
+		}
+	}
+
+	// Like grep, no match is an exit status of 1 so scripts can test for it
+	if matches == 0 {
+		fmt.Fprintln(os.Stderr, "No matches")
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "\n%d matches in %d documents\n", matches, documents)
+}
+
+// grepLibraries returns the cached libraries to search: all of them, or
+// those matching arg (a library ID or pattern with an optional @version)
+func grepLibraries(c *cache.Cache, arg string) ([]cache.CachedLibrary, string, error) {
+	if arg == "" {
+		libraries, err := c.ListCachedLibraries()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list cache: %w", err)
+		}
+		return libraries, "", nil
+	}
+
+	id, version := client.SplitVersion(arg)
+
+	pattern, err := client.ParsePattern(id)
+	if err != nil {
+		return nil, "", err
+	}
+	libraries, err := c.MatchLibraries(pattern)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list cache: %w", err)
+	}
+	if len(libraries) == 0 {
+		return nil, "", fmt.Errorf("library not found in cache: %s", id)
+	}
+	return libraries, version, nil
+}
+
+// grepDocument prints the lines of r matching re with contextLines lines
+// around them, grouped under the document label and the section each group
+// starts in. It returns the number of matching lines.
+func grepDocument(w io.Writer, label string, r io.Reader, re *regexp.Regexp, contextLines int, separate bool) (int, error) {
+	var (
+		matches   int
+		section   string
+		before    []grepLine
+		after     int
+		lastShown int
+	)
+
+	show := func(l grepLine, match bool) {
+		if matches == 0 && lastShown == 0 {
+			if separate {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, label)
+		}
+		if lastShown == 0 || l.number > lastShown+1 {
+			if lastShown > 0 {
+				fmt.Fprintln(w, "  --")
+			}
+			if l.section != "" {
+				fmt.Fprintf(w, "  § %s\n", l.section)
+			}
+		}
+
+		sep, text := "-", l.text
+		if match {
+			sep, text = ":", highlightMatches(re, l.text)
+		}
+		fmt.Fprintf(w, "  %5d%s %s\n", l.number, sep, text)
+		lastShown = l.number
+	}
+
+	reader := bufio.NewReader(r)
+	for number := 1; ; number++ {
+		text, err := reader.ReadString('\n')
+		if text == "" && err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return matches, err
+		}
+		text = strings.TrimRight(text, "\r\n")
+
+		if title, ok := sectionTitle(text); ok {
+			section = title
+		}
+		line := grepLine{number: number, text: text, section: section}
+
+		switch {
+		case re.MatchString(text):
+			for _, l := range before {
+				show(l, false)
+			}
+			before = before[:0]
+			show(line, true)
+			matches++
+			after = contextLines
+		case after > 0:
+			show(line, false)
+			after--
+		case contextLines > 0:
+			if len(before) == contextLines {
+				before = append(before[:0], before[1:]...)
+			}
+			before = append(before, line)
+		}
+	}
+}
+
+// sectionTitle reports whether line starts a section and returns its title
+func sectionTitle(line string) (string, bool) {
+	for _, prefix := range []string{"TITLE: ", "# ", "## "} {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, prefix)), true
+		}
+	}
+	return "", false
+}
+
+// highlightMatches marks every match of re in line when stdout gets colors
+// (a terminal, unless $NO_COLOR or $CLICOLOR_FORCE say otherwise)
+func highlightMatches(re *regexp.Regexp, line string) string {
+	if !ui.ColorEnabled(os.Stdout) {
+		return line
+	}
+	return re.ReplaceAllStringFunc(line, func(s string) string {
+		return "\x1b[1;31m" + s + "\x1b[0m"
+	})
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hsbacot/ctx7/history"
+)
+
+// RunHistoryCommand lists or clears past fetches; `ctx7 history run` is
+// handled by main, which fetches the entry like any other query
+func RunHistoryCommand(args []string, h *history.History) {
+	if len(args) == 0 {
+		listHistory(h, nil)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		listHistory(h, args[1:])
+	case "clear":
+		clearHistory(h, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown history command: %s\n\n", args[0])
+		printHistoryUsage()
+		os.Exit(1)
+	}
+}
+
+func printHistoryUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: ctx7 history [list] [-n N]")
+	fmt.Fprintln(os.Stderr, "       ctx7 history run [OPTIONS] <N>")
+	fmt.Fprintln(os.Stderr, "       ctx7 history clear [--force]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "ctx7 history run N fetches the Nth most recent entry again, as")
+	fmt.Fprintln(os.Stderr, "numbered by ctx7 history; ctx7 --last is ctx7 history run 1.")
+}
+
+// listHistory prints the most recent fetches, numbered for history run
+func listHistory(h *history.History, args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	limit := fs.Int("n", 20, "Number of entries to show (0 for all)")
+	fs.Parse(args)
+
+	if len(h.Entries) == 0 {
+		fmt.Println("No history yet")
+		return
+	}
+
+	n := len(h.Entries)
+	if *limit > 0 {
+		n = min(n, *limit)
+	}
+	printHeader("History")
+	for i := 1; i <= n; i++ {
+		e, _ := h.Get(i)
+		fmt.Printf("%4d  %-10s %s\n", i, formatAge(e.Time), e)
+	}
+	if n < len(h.Entries) {
+		fmt.Printf("\n%d of %d entries (-n 0 shows all)\n", n, len(h.Entries))
+	}
+}
+
+// clearHistory forgets every fetch
+func clearHistory(h *history.History, args []string) {
+	fs := flag.NewFlagSet("clear", flag.ExitOnError)
+	force := fs.Bool("force", false, "Skip confirmation")
+	fs.BoolVar(force, "f", false, "Skip confirmation (shorthand)")
+	fs.Parse(args)
+
+	if !*force && !confirmAction("Forget all history?") {
+		fmt.Println("Cancelled")
+		return
+	}
+
+	h.Clear()
+	if err := h.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✓ History cleared")
+}
@@ -0,0 +1,661 @@
+package cmd
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hsbacot/ctx7/budget"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/content"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/tui"
+)
+
+// jsonSchemaVersion is bumped on any incompatible change to --json output.
+// Every document carries it as "schema_version"; the matching JSON Schemas
+// are printed by --schema.
+const jsonSchemaVersion = 1
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// printSchema prints the JSON Schema for a command's --json output
+func printSchema(name string) {
+	data, err := schemaFiles.ReadFile(fmt.Sprintf("schemas/%s.v%d.json", name, jsonSchemaVersion))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading schema: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(data)
+}
+
+// timestamp formats t as RFC3339 in UTC, or "" for the zero time
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// upstreamTimestamp normalizes an update date reported by context7 to UTC,
+// passing through dates in another format
+func upstreamTimestamp(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return timestamp(t)
+	}
+	return s
+}
+
+// cacheStatsJSON is the `cache stats --json` document
+type cacheStatsJSON struct {
+	SchemaVersion  int                `json:"schema_version"`
+	CacheDir       string             `json:"cache_dir"`
+	TotalLibraries int                `json:"total_libraries"`
+	TotalVersions  int                `json:"total_versions"`
+	TotalSizeBytes int64              `json:"total_size_bytes"`
+	OldestEntry    string             `json:"oldest_entry,omitempty"`
+	NewestEntry    string             `json:"newest_entry,omitempty"`
+	Libraries      []libraryStatsJSON `json:"libraries"`
+	SearchCache    searchCacheJSON    `json:"search_cache"`
+	Usage          usageJSON          `json:"usage"`
+}
+
+type libraryStatsJSON struct {
+	ID            string `json:"id"`
+	VersionCount  int    `json:"version_count"`
+	SizeBytes     int64  `json:"size_bytes"`
+	OldestVersion string `json:"oldest_version,omitempty"`
+	NewestVersion string `json:"newest_version,omitempty"`
+}
+
+type searchCacheJSON struct {
+	Entries    int   `json:"entries"`
+	Expired    int   `json:"expired"`
+	SizeBytes  int64 `json:"size_bytes"`
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+type usageJSON struct {
+	Hits            int64   `json:"hits"`
+	Misses          int64   `json:"misses"`
+	HitRate         float64 `json:"hit_rate"`
+	BytesServed     int64   `json:"bytes_served"`
+	Fetches         int64   `json:"fetches"`
+	BytesFetched    int64   `json:"bytes_fetched"`
+	FetchDurationMS int64   `json:"fetch_duration_ms"`
+	Since           string  `json:"since,omitempty"`
+}
+
+func newCacheStatsJSON(stats *cache.DetailedCacheStats, expiredSearches int, searchMaxAge time.Duration) cacheStatsJSON {
+	out := cacheStatsJSON{
+		SchemaVersion:  jsonSchemaVersion,
+		CacheDir:       stats.CacheDir,
+		TotalLibraries: len(stats.LibraryBreakdown),
+		TotalVersions:  stats.TotalEntries,
+		TotalSizeBytes: stats.TotalSize,
+		OldestEntry:    timestamp(stats.OldestEntry),
+		NewestEntry:    timestamp(stats.NewestEntry),
+		Libraries:      make([]libraryStatsJSON, 0, len(stats.LibraryBreakdown)),
+		SearchCache: searchCacheJSON{
+			Entries:    stats.SearchCacheEntries,
+			Expired:    expiredSearches,
+			SizeBytes:  stats.SearchCacheSize,
+			TTLSeconds: int64(searchMaxAge / time.Second),
+		},
+		Usage: usageJSON{
+			Hits:            stats.Usage.Hits,
+			Misses:          stats.Usage.Misses,
+			HitRate:         stats.Usage.HitRate(),
+			BytesServed:     stats.Usage.BytesServed,
+			Fetches:         stats.Usage.Fetches,
+			BytesFetched:    stats.Usage.BytesFetched,
+			FetchDurationMS: stats.Usage.FetchDuration.Milliseconds(),
+			Since:           timestamp(stats.Usage.Since),
+		},
+	}
+
+	for _, lib := range stats.LibraryBreakdown {
+		out.Libraries = append(out.Libraries, libraryStatsJSON{
+			ID:            lib.LibraryID,
+			VersionCount:  lib.VersionCount,
+			SizeBytes:     lib.TotalSize,
+			OldestVersion: timestamp(lib.OldestVersion),
+			NewestVersion: timestamp(lib.NewestVersion),
+		})
+	}
+	return out
+}
+
+// cacheListJSON is the `cache list --json` document; libraries are sorted
+// by ID and versions by name
+type cacheListJSON struct {
+	SchemaVersion  int                 `json:"schema_version"`
+	TotalLibraries int                 `json:"total_libraries"`
+	TotalVersions  int                 `json:"total_versions"`
+	TotalSizeBytes int64               `json:"total_size_bytes"`
+	Libraries      []cachedLibraryJSON `json:"libraries"`
+}
+
+type cachedLibraryJSON struct {
+	ID           string              `json:"id"`
+	Organization string              `json:"organization"`
+	Name         string              `json:"name"`
+	Versions     []cachedVersionJSON `json:"versions"`
+}
+
+type cachedVersionJSON struct {
+	Version         string `json:"version"`
+	IsDefault       bool   `json:"is_default"`
+	Branch          string `json:"branch,omitempty"`
+	SizeBytes       int64  `json:"size_bytes"`
+	FetchedAt       string `json:"fetched_at"`
+	LastUpdated     string `json:"last_updated,omitempty"`
+	SHA256          string `json:"sha256,omitempty"`
+	EstimatedTokens int    `json:"estimated_tokens,omitempty"`
+	Pinned          bool   `json:"pinned"`
+	Shared          bool   `json:"shared,omitempty"`
+	Topic           string `json:"topic,omitempty"`
+}
+
+func newCacheListJSON(libraries []cache.CachedLibrary) cacheListJSON {
+	out := cacheListJSON{
+		SchemaVersion: jsonSchemaVersion,
+		Libraries:     make([]cachedLibraryJSON, 0, len(libraries)),
+	}
+
+	for _, lib := range libraries {
+		entry := cachedLibraryJSON{
+			ID:           lib.LibraryID,
+			Organization: lib.Organization,
+			Name:         lib.Name,
+			Versions:     make([]cachedVersionJSON, 0, len(lib.Versions)),
+		}
+		for _, v := range lib.Versions {
+			entry.Versions = append(entry.Versions, cachedVersionJSON{
+				Version:         v.Version,
+				IsDefault:       v.IsDefault,
+				Branch:          v.Metadata.Branch,
+				SizeBytes:       v.Size,
+				FetchedAt:       timestamp(v.FetchedAt),
+				LastUpdated:     upstreamTimestamp(v.Metadata.LastUpdateDate),
+				SHA256:          v.Metadata.SHA256,
+				EstimatedTokens: v.Metadata.EstimatedTokens,
+				Pinned:          v.Metadata.Pinned,
+				Shared:          v.Shared,
+				Topic:           v.Metadata.Topic,
+			})
+			out.TotalVersions++
+			out.TotalSizeBytes += v.Size
+		}
+		sort.Slice(entry.Versions, func(i, j int) bool {
+			return entry.Versions[i].Version < entry.Versions[j].Version
+		})
+		out.Libraries = append(out.Libraries, entry)
+	}
+
+	sort.Slice(out.Libraries, func(i, j int) bool {
+		return out.Libraries[i].ID < out.Libraries[j].ID
+	})
+	out.TotalLibraries = len(out.Libraries)
+	return out
+}
+
+// cacheSearchesJSON is the `cache list --searches --json` document;
+// searches are newest first
+type cacheSearchesJSON struct {
+	SchemaVersion  int                `json:"schema_version"`
+	TotalSearches  int                `json:"total_searches"`
+	TotalSizeBytes int64              `json:"total_size_bytes"`
+	TTLSeconds     int64              `json:"ttl_seconds"`
+	Searches       []cachedSearchJSON `json:"searches"`
+}
+
+type cachedSearchJSON struct {
+	Query       string `json:"query"`
+	CachedAt    string `json:"cached_at"`
+	ResultCount int    `json:"result_count"`
+	SizeBytes   int64  `json:"size_bytes"`
+	Expired     bool   `json:"expired"`
+}
+
+func newCacheSearchesJSON(searches []cache.CachedSearch, maxAge time.Duration) cacheSearchesJSON {
+	out := cacheSearchesJSON{
+		SchemaVersion: jsonSchemaVersion,
+		TotalSearches: len(searches),
+		TTLSeconds:    int64(maxAge / time.Second),
+		Searches:      make([]cachedSearchJSON, 0, len(searches)),
+	}
+
+	now := time.Now()
+	for _, s := range searches {
+		out.Searches = append(out.Searches, cachedSearchJSON{
+			Query:       s.Query,
+			CachedAt:    timestamp(s.CachedAt),
+			ResultCount: s.ResultCount,
+			SizeBytes:   s.Size,
+			Expired:     now.Sub(s.CachedAt) > maxAge,
+		})
+		out.TotalSizeBytes += s.Size
+	}
+	return out
+}
+
+// cacheInfoJSON is the `cache info --json` document; versions keep the
+// cache's order (default first)
+type cacheInfoJSON struct {
+	SchemaVersion  int               `json:"schema_version"`
+	ID             string            `json:"id"`
+	Title          string            `json:"title"`
+	Stars          int               `json:"stars"`
+	TrustScore     float64           `json:"trust_score"`
+	LastUpdated    string            `json:"last_updated,omitempty"`
+	Upstream       []string          `json:"upstream_versions"`
+	TotalSizeBytes int64             `json:"total_size_bytes"`
+	TTLSeconds     int64             `json:"ttl_seconds"`
+	Versions       []versionInfoJSON `json:"versions"`
+}
+
+type versionInfoJSON struct {
+	Version         string      `json:"version"`
+	IsDefault       bool        `json:"is_default"`
+	Branch          string      `json:"branch,omitempty"`
+	SizeBytes       int64       `json:"size_bytes"`
+	FetchedAt       string      `json:"fetched_at"`
+	ExpiresAt       string      `json:"expires_at"`
+	LastUpdated     string      `json:"last_updated,omitempty"`
+	Freshness       string      `json:"freshness"`
+	TotalTokens     int         `json:"total_tokens"`
+	EstimatedTokens int         `json:"estimated_tokens,omitempty"`
+	TotalSnippets   int         `json:"total_snippets"`
+	SHA256          string      `json:"sha256,omitempty"`
+	Pinned          bool        `json:"pinned"`
+	Shared          bool        `json:"shared,omitempty"`
+	Topic           string      `json:"topic,omitempty"`
+	Path            string      `json:"path,omitempty"`
+	Patches         []patchJSON `json:"patches,omitempty"`
+}
+
+// patchJSON is a refresh that downloaded only the changed sections
+type patchJSON struct {
+	PatchedAt string `json:"patched_at"`
+	Reused    int    `json:"reused_sections"`
+	Fetched   int    `json:"fetched_sections"`
+	Dropped   int    `json:"dropped_sections"`
+	Bytes     int64  `json:"downloaded_bytes"`
+	Size      int64  `json:"size_bytes"`
+}
+
+func newPatchesJSON(patches []cache.Patch) []patchJSON {
+	var out []patchJSON
+	for _, p := range patches {
+		out = append(out, patchJSON{
+			PatchedAt: timestamp(p.PatchedAt),
+			Reused:    p.Reused,
+			Fetched:   p.Fetched,
+			Dropped:   p.Dropped,
+			Bytes:     p.Bytes,
+			Size:      p.Size,
+		})
+	}
+	return out
+}
+
+func newCacheInfoJSON(c *cache.Cache, lib *cache.CachedLibrary, maxAge time.Duration, now time.Time) cacheInfoJSON {
+	out := cacheInfoJSON{
+		SchemaVersion: jsonSchemaVersion,
+		ID:            lib.LibraryID,
+		Upstream:      []string{},
+		TTLSeconds:    int64(maxAge / time.Second),
+		Versions:      make([]versionInfoJSON, 0, len(lib.Versions)),
+	}
+
+	var latest time.Time
+	for _, v := range lib.Versions {
+		if v.FetchedAt.After(latest) {
+			// Library-level details come from the newest fetch
+			latest = v.FetchedAt
+			out.Title = v.Metadata.Title
+			out.Stars = v.Metadata.Stars
+			out.TrustScore = v.Metadata.TrustScore
+			out.LastUpdated = upstreamTimestamp(v.Metadata.LastUpdateDate)
+			if v.Metadata.Versions != nil {
+				out.Upstream = v.Metadata.Versions
+			}
+		}
+
+		out.Versions = append(out.Versions, versionInfoJSON{
+			Version:         v.Version,
+			IsDefault:       v.IsDefault,
+			Branch:          v.Metadata.Branch,
+			SizeBytes:       v.Size,
+			FetchedAt:       timestamp(v.FetchedAt),
+			ExpiresAt:       timestamp(v.FetchedAt.Add(maxAge)),
+			LastUpdated:     upstreamTimestamp(v.Metadata.LastUpdateDate),
+			Freshness:       cache.FreshnessOf(v.FetchedAt, now, maxAge).String(),
+			TotalTokens:     v.Metadata.TotalTokens,
+			EstimatedTokens: v.Metadata.EstimatedTokens,
+			TotalSnippets:   v.Metadata.TotalSnippets,
+			Topic:           v.Metadata.Topic,
+			SHA256:          v.Metadata.SHA256,
+			Pinned:          v.Metadata.Pinned,
+			Shared:          v.Shared,
+			Path:            c.Location(lib.LibraryID, v.Version),
+			Patches:         newPatchesJSON(v.Metadata.Patches),
+		})
+		out.TotalSizeBytes += v.Size
+	}
+	return out
+}
+
+// statsExportJSON is the `stats export --json` document. It deliberately
+// carries no paths, hostnames or queries so exports can be shared as is.
+type statsExportJSON struct {
+	SchemaVersion int                `json:"schema_version"`
+	ExportedAt    string             `json:"exported_at"`
+	Since         string             `json:"since,omitempty"`
+	CacheHits     int64              `json:"cache_hits"`
+	CacheMisses   int64              `json:"cache_misses"`
+	Fetches       int64              `json:"fetches"`
+	Libraries     []libraryUsageJSON `json:"libraries"`
+}
+
+type libraryUsageJSON struct {
+	ID        string `json:"id"`
+	Pulls     int64  `json:"pulls"`
+	CacheHits int64  `json:"cache_hits"`
+	Fetches   int64  `json:"fetches"`
+	LastUsed  string `json:"last_used"`
+}
+
+func newStatsExportJSON(usage cache.UsageStats, now time.Time) statsExportJSON {
+	out := statsExportJSON{
+		SchemaVersion: jsonSchemaVersion,
+		ExportedAt:    timestamp(now),
+		Since:         timestamp(usage.Since),
+		CacheHits:     usage.Hits,
+		CacheMisses:   usage.Misses,
+		Fetches:       usage.Fetches,
+		Libraries:     []libraryUsageJSON{},
+	}
+	for _, lib := range sortedLibraryUsage(usage) {
+		out.Libraries = append(out.Libraries, libraryUsageJSON{
+			ID:        lib.id,
+			Pulls:     lib.Pulls(),
+			CacheHits: lib.Hits,
+			Fetches:   lib.Fetches,
+			LastUsed:  lib.LastUsed,
+		})
+	}
+	return out
+}
+
+// searchJSON is the `search --json` document; results keep the API's ranking
+type searchJSON struct {
+	SchemaVersion int                `json:"schema_version"`
+	Query         string             `json:"query"`
+	Results       []searchResultJSON `json:"results"`
+}
+
+type searchResultJSON struct {
+	ID             string   `json:"id"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Branch         string   `json:"branch,omitempty"`
+	LastUpdated    string   `json:"last_updated,omitempty"`
+	Stars          int      `json:"stars"`
+	TrustScore     float64  `json:"trust_score"`
+	BenchmarkScore float64  `json:"benchmark_score"`
+	TotalTokens    int      `json:"total_tokens"`
+	TotalSnippets  int      `json:"total_snippets"`
+	Versions       []string `json:"versions"`
+}
+
+func newSearchJSON(query string, libs []client.Library) searchJSON {
+	out := searchJSON{
+		SchemaVersion: jsonSchemaVersion,
+		Query:         query,
+		Results:       make([]searchResultJSON, 0, len(libs)),
+	}
+
+	for _, lib := range libs {
+		versions := lib.Versions
+		if versions == nil {
+			versions = []string{}
+		}
+
+		out.Results = append(out.Results, searchResultJSON{
+			ID:             lib.ID,
+			Title:          lib.Title,
+			Description:    lib.Description,
+			Branch:         lib.Branch,
+			LastUpdated:    upstreamTimestamp(lib.LastUpdateDate),
+			Stars:          lib.Stars,
+			TrustScore:     lib.TrustScore,
+			BenchmarkScore: lib.BenchmarkScore,
+			TotalTokens:    lib.TotalTokens,
+			TotalSnippets:  lib.TotalSnippets,
+			Versions:       versions,
+		})
+	}
+	return out
+}
+
+// FetchResult is one document fetched by the main command, for
+// --format json
+type FetchResult struct {
+	Query     string
+	Library   client.Library
+	Version   string
+	Branch    string
+	Topic     string
+	FromCache bool
+	Duration  time.Duration
+	Content   string
+	// Explain holds the --explain trace, if one was recorded
+	Explain []engine.Step
+}
+
+// WriteFetchJSON writes results to w as the `--format json` document
+func WriteFetchJSON(w io.Writer, results []FetchResult) error {
+	return writeJSON(w, newFetchJSON(results))
+}
+
+// PrintFetchSchema prints the JSON Schema of `--format json` output
+func PrintFetchSchema() {
+	printSchema("fetch")
+}
+
+// WriteErrorJSON writes a failed lookup to w as one JSON line, for --ci.
+// code classifies the failure, e.g. "not_found" or "timeout"; errors from
+// the API also carry its status and error code.
+func WriteErrorJSON(w io.Writer, query, code string, err error) error {
+	detail := errorDetailJSON{Code: code, Message: err.Error(), Query: query}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		detail.Status = apiErr.StatusCode
+		detail.APICode = apiErr.Code
+	}
+	return json.NewEncoder(w).Encode(errorJSON{SchemaVersion: jsonSchemaVersion, Error: detail})
+}
+
+// ErrorCode classifies a failed lookup for JSON errors
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, tui.ErrCancelled), errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, engine.ErrNoDocumentation):
+		return "no_documentation"
+	case errors.Is(err, engine.ErrNoLibraries):
+		return "not_found"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		return "rate_limited"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return "network"
+	}
+	return "fetch_failed"
+}
+
+type errorJSON struct {
+	SchemaVersion int             `json:"schema_version"`
+	Error         errorDetailJSON `json:"error"`
+}
+
+type errorDetailJSON struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Query   string `json:"query,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	APICode string `json:"api_code,omitempty"`
+}
+
+// fetchJSON is the `--format json` document, one entry per library in the
+// order they were fetched
+type fetchJSON struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Documents     []fetchDocumentJSON `json:"documents"`
+}
+
+type fetchDocumentJSON struct {
+	Query      string        `json:"query"`
+	Library    fetchLibJSON  `json:"library"`
+	Version    string        `json:"version,omitempty"`
+	Branch     string        `json:"branch,omitempty"`
+	Topic      string        `json:"topic,omitempty"`
+	Source     string        `json:"source"`
+	DurationMS int64         `json:"duration_ms"`
+	Tokens     int           `json:"tokens"`
+	Snippets   []snippetJSON `json:"snippets"`
+	Explain    []engine.Step `json:"explain,omitempty"`
+}
+
+type fetchLibJSON struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Stars       int      `json:"stars"`
+	TrustScore  float64  `json:"trust_score"`
+	Versions    []string `json:"versions"`
+}
+
+type snippetJSON struct {
+	Title     string   `json:"title"`
+	Languages []string `json:"languages"`
+	Text      string   `json:"text"`
+}
+
+func newFetchJSON(results []FetchResult) fetchJSON {
+	out := fetchJSON{
+		SchemaVersion: jsonSchemaVersion,
+		Documents:     make([]fetchDocumentJSON, 0, len(results)),
+	}
+
+	for _, r := range results {
+		source := "network"
+		if r.FromCache {
+			source = "cache"
+		}
+		versions := r.Library.Versions
+		if versions == nil {
+			versions = []string{}
+		}
+
+		snippets := []snippetJSON{}
+		for _, s := range content.ParseSnippets(r.Content) {
+			langs := s.Languages()
+			if langs == nil {
+				langs = []string{}
+			}
+			snippets = append(snippets, snippetJSON{Title: s.Title, Languages: langs, Text: s.Text})
+		}
+
+		out.Documents = append(out.Documents, fetchDocumentJSON{
+			Query: r.Query,
+			Library: fetchLibJSON{
+				ID:          r.Library.ID,
+				Title:       r.Library.Title,
+				Description: r.Library.Description,
+				Stars:       r.Library.Stars,
+				TrustScore:  r.Library.TrustScore,
+				Versions:    versions,
+			},
+			Version:    r.Version,
+			Branch:     r.Branch,
+			Topic:      r.Topic,
+			Source:     source,
+			DurationMS: r.Duration.Milliseconds(),
+			Tokens:     budget.EstimateTokens(r.Content),
+			Snippets:   snippets,
+			Explain:    r.Explain,
+		})
+	}
+	return out
+}
+
+// versionsJSON is the `versions --json` document; versions keep the API's
+// order, newest first
+type versionsJSON struct {
+	SchemaVersion int              `json:"schema_version"`
+	Library       versionsLibJSON  `json:"library"`
+	Default       docVersionJSON   `json:"default"`
+	Versions      []docVersionJSON `json:"versions"`
+	Branch        *docVersionJSON  `json:"branch,omitempty"`
+}
+
+type versionsLibJSON struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type docVersionJSON struct {
+	Version   string `json:"version,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	Latest    bool   `json:"latest"`
+	Cached    bool   `json:"cached"`
+	FetchedAt string `json:"fetched_at,omitempty"`
+	Freshness string `json:"freshness,omitempty"`
+}
+
+func newVersionsJSON(lib client.Library, cached map[string]cache.VersionInfo, maxAge time.Duration, now time.Time) versionsJSON {
+	entry := func(version, branch string) docVersionJSON {
+		out := docVersionJSON{Version: version, Branch: branch}
+		if v, ok := cached[cache.RefKey(version, branch)]; ok {
+			out.Cached = true
+			out.FetchedAt = timestamp(v.FetchedAt)
+			out.Freshness = cache.FreshnessOf(v.FetchedAt, now, maxAge).String()
+		}
+		return out
+	}
+
+	out := versionsJSON{
+		SchemaVersion: jsonSchemaVersion,
+		Library:       versionsLibJSON{ID: lib.ID, Title: lib.Title},
+		Default:       entry("", ""),
+		Versions:      make([]docVersionJSON, 0, len(lib.Versions)),
+	}
+	for i, v := range lib.Versions {
+		e := entry(v, "")
+		e.Latest = i == 0
+		out.Versions = append(out.Versions, e)
+	}
+	if lib.Branch != "" {
+		b := entry("", lib.Branch)
+		out.Branch = &b
+	}
+	return out
+}
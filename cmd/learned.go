@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/learn"
+)
+
+// RunLearnedCommand inspects or resets the libraries learned for queries
+func RunLearnedCommand(args []string, store *learn.Store) {
+	if len(args) == 0 {
+		printLearnedUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		listLearned(store)
+	case "clear":
+		clearLearned(store, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown learned command: %s\n\n", args[0])
+		printLearnedUsage()
+		os.Exit(1)
+	}
+}
+
+func printLearnedUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: ctx7 learned list")
+	fmt.Fprintln(os.Stderr, "       ctx7 learned clear [--force]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "ctx7 remembers which library you fetch for a query and ranks it")
+	fmt.Fprintln(os.Stderr, "first the next time. Associations fade with a half-life of 30 days.")
+}
+
+// listLearned prints every learned query with its libraries and scores
+func listLearned(store *learn.Store) {
+	entries := store.Entries(time.Now())
+	if len(entries) == 0 {
+		fmt.Println("Nothing learned yet")
+		return
+	}
+
+	printHeader("Learned Queries")
+	for _, e := range entries {
+		libs := make([]string, len(e.Libraries))
+		for i, a := range e.Libraries {
+			libs[i] = fmt.Sprintf("%s (%.1f, %s)", a.LibraryID, a.Score, formatAge(a.Updated))
+		}
+		fmt.Printf("%-24s → %s\n", e.Query, strings.Join(libs, ", "))
+	}
+	fmt.Printf("\nTotal: %d queries\n", len(entries))
+}
+
+// clearLearned forgets every learned association
+func clearLearned(store *learn.Store, args []string) {
+	fs := flag.NewFlagSet("clear", flag.ExitOnError)
+	force := fs.Bool("force", false, "Skip confirmation")
+	fs.BoolVar(force, "f", false, "Skip confirmation (shorthand)")
+	fs.Parse(args)
+
+	if !*force && !confirmAction("Forget all learned queries?") {
+		fmt.Println("Cancelled")
+		return
+	}
+
+	store.Clear()
+	if err := store.Save(time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✓ Learned queries cleared")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hsbacot/ctx7/cache"
+)
+
+// parallel runs fn over items on up to jobs goroutines, delivering each
+// result as soon as it is ready; the channel closes once all are done
+func parallel[T, R any](items []T, jobs int, fn func(T) R) <-chan R {
+	queue := make(chan T)
+	results := make(chan R)
+
+	var wg sync.WaitGroup
+	for range max(jobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				results <- fn(item)
+			}
+		}()
+	}
+
+	go func() {
+		for _, item := range items {
+			queue <- item
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// BeginBatch starts a transaction for a command that caches many
+// libraries, so a crash caches all of them or none (see cache.Tx). The
+// command writes through the returned cache; without a cache there is
+// nothing to stage.
+func BeginBatch(c *cache.Cache) (*cache.Cache, *cache.Tx) {
+	if c == nil {
+		return nil, nil
+	}
+	tx, err := c.Begin()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; caching each library as it arrives\n", err)
+		return c, nil
+	}
+	return tx.Cache(), tx
+}
+
+// CommitBatch lands the libraries a batch cached
+func CommitBatch(tx *cache.Tx) {
+	if err := tx.Commit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
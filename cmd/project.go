@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// projectFiles are the dependency lists projectDependencies reads, in the
+// order they are tried
+var projectFiles = []struct {
+	name  string
+	parse func(data []byte) ([]string, error)
+}{
+	{"package.json", parsePackageJSON},
+	{"go.mod", parseGoMod},
+	{"requirements.txt", parseRequirements},
+}
+
+// projectDependencies returns the direct dependencies listed in the
+// project manifests of dir, as names to search for, and the manifests they
+// came from
+func projectDependencies(dir string) ([]string, []string, error) {
+	var names, found []string
+	for _, f := range projectFiles {
+		data, err := os.ReadFile(filepath.Join(dir, f.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", f.name, err)
+		}
+		deps, err := f.parse(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", f.name, err)
+		}
+		names = append(names, deps...)
+		found = append(found, f.name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), found, nil
+}
+
+// parsePackageJSON lists dependencies and devDependencies, leaving out
+// type-only @types packages
+func parsePackageJSON(data []byte) ([]string, error) {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		for name := range deps {
+			if !strings.HasPrefix(name, "@types/") {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// majorSuffix matches the /vN element of a Go module path
+var majorSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// parseGoMod lists the direct requirements by their last path element,
+// e.g. bubbletea for github.com/charmbracelet/bubbletea/v2
+func parseGoMod(data []byte) ([]string, error) {
+	var names []string
+	inBlock := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+		case !inBlock:
+			continue
+		}
+		if strings.Contains(line, "// indirect") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		parts := strings.Split(fields[0], "/")
+		name := parts[len(parts)-1]
+		if majorSuffix.MatchString(name) && len(parts) > 1 {
+			name = parts[len(parts)-2]
+		}
+		names = append(names, name)
+	}
+	return names, scanner.Err()
+}
+
+// requirementName matches the package name at the start of a requirement
+var requirementName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+
+// parseRequirements lists the packages of a pip requirements file,
+// skipping comments and options such as -r
+func parseRequirements(data []byte) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if name := requirementName.FindString(line); name != "" {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	return names, scanner.Err()
+}
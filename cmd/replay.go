@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/transcript"
+	"github.com/hsbacot/ctx7/tui"
+)
+
+// RunReplayCommand re-fetches the libraries chosen in a recorded session
+// without prompting, serving from the current cache where possible
+func RunReplayCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge time.Duration) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	noCache := fs.Bool("no-cache", false, "Skip cache, force fresh fetch")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: transcript file required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 replay <transcript.json> [--no-cache]")
+		os.Exit(1)
+	}
+
+	tr, err := transcript.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	eng := engine.New(engine.Options{
+		Client:  c,
+		Cache:   cacheManager,
+		NoCache: *noCache,
+		MaxAge:  maxAge,
+	})
+
+	var output strings.Builder
+	for _, session := range tr.Sessions {
+		if session.Library == nil || session.Error != "" {
+			fmt.Fprintf(os.Stderr, "Skipping '%s' (no library was fetched in the recorded run)\n", session.Query)
+			continue
+		}
+
+		result, err := eng.Fetch(context.Background(), engine.Request{
+			Library: *session.Library,
+			Version: session.Version,
+			Branch:  session.Branch,
+			Topic:   session.Topic,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching %s: %v\n", session.Library.ID, err)
+			os.Exit(1)
+		}
+
+		content := result.Content
+		if session.PickedTopic != "" {
+			content = tui.ExtractTopic(content, session.PickedTopic)
+		}
+
+		source := c.Host()
+		if result.FromCache {
+			source = "cache"
+		}
+		fmt.Fprintf(os.Stderr, "✓ %s from %s\n", session.Library.ID, source)
+
+		output.WriteString(content)
+	}
+
+	fmt.Print(output.String())
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// RunSearchCommand lists the libraries matching a query without fetching docs
+func RunSearchCommand(args []string, c *client.Client) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	schema := fs.Bool("schema", false, "Print the JSON Schema of --json output")
+	org := fs.String("org", "", "Only show libraries of this organization")
+	fs.Parse(args)
+
+	if *schema {
+		printSchema("search")
+		return
+	}
+
+	if fs.NArg() == 0 && *org == "" {
+		fmt.Fprintln(os.Stderr, "Error: query required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 search [--json] [--org <org>] <query>")
+		os.Exit(1)
+	}
+
+	// An org on its own searches for the org's name
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		query = *org
+	}
+	libraries, err := c.SearchLibraries(context.Background(), query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching libraries: %v\n", err)
+		os.Exit(1)
+	}
+	if *org != "" {
+		libraries = client.FilterLibraries(libraries, client.OrgPattern(*org))
+	}
+
+	if *jsonOutput {
+		if err := printJSON(newSearchJSON(query, libraries)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(libraries) == 0 {
+		fmt.Printf("No libraries found for '%s'\n", query)
+		return
+	}
+
+	printHeader(fmt.Sprintf("Libraries matching '%s'", query))
+	for i, lib := range libraries {
+		fmt.Printf("%2d. %-30s %s\n", i+1, lib.ID, lib.Title)
+		fmt.Printf("    ⭐ %d  🏆 %.1f  %d versions\n", lib.Stars, lib.TrustScore, len(lib.Versions))
+	}
+}
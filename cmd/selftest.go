@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/selftest"
+)
+
+// RunSelftestCommand checks the installation by running the whole pipeline
+// against a fake context7, without touching the network or the real cache
+func RunSelftestCommand(args []string) {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Show the pipeline's status lines")
+	fs.Parse(args)
+
+	opts := selftest.Options{Backend: cache.Backend(os.Getenv("CTX7_CACHE_BACKEND"))}
+	opts.CacheDir, _ = cache.DefaultDir()
+	if *verbose {
+		opts.Progress = os.Stderr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	printHeader("ctx7 self-test")
+	checks := selftest.Run(ctx, opts)
+	for _, c := range checks {
+		if c.Err != nil {
+			fmt.Printf("✗ %-16s %v\n", c.Name, c.Err)
+			continue
+		}
+		fmt.Printf("✓ %-16s %s\n", c.Name, c.Detail)
+	}
+
+	if selftest.Failed(checks) {
+		fmt.Println("\nSelf-test failed")
+		os.Exit(1)
+	}
+	fmt.Println("\nAll checks passed")
+}
@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/config"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// RunServeCommand serves the cache over HTTP so editor plugins, scripts
+// and teammates share one warm cache and one rate-limit budget. Cache
+// endpoints are read-only. Without a token or an allowlist in auth it only
+// listens on loopback addresses.
+func RunServeCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge, searchMaxAge time.Duration, auth ServeAuth) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("http", "localhost:7777", "Address to listen on (:7777 for every interface)")
+	shutdownFlag := fs.String("shutdown-timeout", "30s", "How long a stopping server waits for in-flight requests to finish")
+	fs.Parse(args)
+
+	shutdownTimeout, err := config.ParseDuration(*shutdownFlag)
+	if err != nil || shutdownTimeout <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --shutdown-timeout %q\n", *shutdownFlag)
+		os.Exit(1)
+	}
+
+	if cacheManager == nil {
+		fmt.Fprintln(os.Stderr, "Error: ctx7 serve needs a cache")
+		os.Exit(1)
+	}
+	allow, err := parseAllowlist(auth.Allow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: serve_allow: %v\n", err)
+		os.Exit(1)
+	}
+
+	metrics := &engine.Metrics{}
+	s := &server{
+		eng:          engine.New(engine.Options{Client: c, Cache: cacheManager, MaxAge: maxAge, SearchMaxAge: searchMaxAge, Metrics: metrics}),
+		metrics:      metrics,
+		cache:        cacheManager,
+		maxAge:       maxAge,
+		searchMaxAge: searchMaxAge,
+		flights:      make(map[string]*flight),
+		token:        auth.Token,
+		allow:        allow,
+		log:          os.Stderr,
+	}
+	srv := &http.Server{Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+
+	ln, err := s.listen(*addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Serve returns as soon as Shutdown starts; wait for the requests it
+	// lets finish before exiting
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		fmt.Fprintln(os.Stderr, "Shutting down...")
+		start := time.Now()
+		draining := s.inFlight.Load()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := srv.Shutdown(shutdownCtx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			// Cut off whatever is still running
+			srv.Close()
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		cutOff := s.inFlight.Load()
+		fmt.Fprintf(os.Stderr, "Stopped after serving %d requests: %d of %d in flight finished in %s, %d cut off\n",
+			s.served.Load(), draining-cutOff, draining, time.Since(start).Round(time.Millisecond), cutOff)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving the cache on http://%s\n", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	<-stopped
+}
+
+// server answers the HTTP API
+type server struct {
+	eng          *engine.Engine
+	metrics      *engine.Metrics
+	cache        *cache.Cache
+	maxAge       time.Duration
+	searchMaxAge time.Duration
+
+	// token and allow restrict who may use the API (see authorize)
+	token string
+	allow []netip.Prefix
+	// log receives the access log
+	log io.Writer
+	// inFlight and served count the requests being answered and answered
+	inFlight atomic.Int64
+	served   atomic.Int64
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// listen opens the server's listener on addr, refusing an address other
+// machines can reach unless a token or an allowlist guards the API
+func (s *server) listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.token == "" && len(s.allow) == 0 && !isLoopback(ln.Addr()) {
+		ln.Close()
+		return nil, fmt.Errorf("%s is reachable from the network; set serve_token (or $CTX7_SERVE_TOKEN) or serve_allow in the config first", ln.Addr())
+	}
+	return ln, nil
+}
+
+// flight is a download shared by every request for the same document
+type flight struct {
+	done   chan struct{}
+	result *engine.Result
+	err    error
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search", s.handleSearch)
+	mux.HandleFunc("GET /libs/{org}/{name}/llms.txt", s.handleDoc)
+	mux.HandleFunc("GET /libs/{org}/{name}/{version}/llms.txt", s.handleDoc)
+	mux.HandleFunc("GET /cache", s.handleCacheList)
+	mux.HandleFunc("GET /cache/stats", s.handleCacheStats)
+	mux.HandleFunc("GET /cache/libs/{org}/{name}", s.handleCacheInfo)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return s.track(s.logRequests(s.authorize(mux)))
+}
+
+// handleSearch lists the libraries matching ?q=, like ctx7 search --json
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "", "bad_request", errors.New("q is required"))
+		return
+	}
+
+	libraries, err := s.eng.Search(r.Context(), query)
+	if err != nil {
+		s.fail(w, query, err)
+		return
+	}
+	serveJSON(w, newSearchJSON(query, libraries))
+}
+
+// handleDoc serves a library's llms.txt from the cache, downloading it when
+// missing or expired. ?branch= and ?topic= select the document like the
+// CLI's flags; the response says whether it came from the cache in
+// X-Ctx7-Cache.
+func (s *server) handleDoc(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathLibraryID(w, r)
+	if !ok {
+		return
+	}
+	if v := r.PathValue("version"); v != "" && !validPathPart(v) {
+		writeError(w, http.StatusBadRequest, id, "bad_request", fmt.Errorf("invalid version %q", v))
+		return
+	}
+
+	params := r.URL.Query()
+	req := engine.Request{
+		Library: client.Library{ID: id},
+		Version: r.PathValue("version"),
+		Branch:  params.Get("branch"),
+		Topic:   params.Get("topic"),
+	}
+
+	result, ok := s.eng.Cached(req)
+	if !ok {
+		var err error
+		if result, err = s.fetch(r.Context(), req); err != nil {
+			s.fail(w, req.DocumentID(), err)
+			return
+		}
+	}
+
+	w.Header().Set("X-Ctx7-Cache", "miss")
+	if result.FromCache {
+		w.Header().Set("X-Ctx7-Cache", "hit")
+	}
+	http.ServeContent(w, r, "llms.txt", result.Metadata.FetchedAt, strings.NewReader(result.Content))
+}
+
+// fetch downloads a document once however many requests ask for it at the
+// same time. The download outlives the request that started it, so the
+// others still get it when that client goes away.
+func (s *server) fetch(ctx context.Context, req engine.Request) (*engine.Result, error) {
+	key := req.Library.ID + "@" + req.CacheKey()
+
+	s.mu.Lock()
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.result, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	if lib, err := resolveLibrary(ctx, s.eng, req.Library.ID); err == nil {
+		req.Library = lib
+	}
+	f.result, f.err = s.eng.Fetch(ctx, req)
+
+	s.mu.Lock()
+	delete(s.flights, key)
+	s.mu.Unlock()
+	close(f.done)
+	return f.result, f.err
+}
+
+// handleCacheList lists the cached libraries, like ctx7 cache list --json;
+// ?variants=true includes topic variants
+func (s *server) handleCacheList(w http.ResponseWriter, r *http.Request) {
+	libraries, err := s.cache.ListCachedLibraries()
+	if err != nil {
+		s.fail(w, "", err)
+		return
+	}
+	if r.URL.Query().Get("variants") != "true" {
+		libraries = withoutVariants(libraries)
+	}
+	serveJSON(w, newCacheListJSON(libraries))
+}
+
+// handleCacheStats reports cache statistics, like ctx7 cache stats --json
+func (s *server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.cache.GetDetailedStats()
+	if err != nil {
+		s.fail(w, "", err)
+		return
+	}
+	serveJSON(w, newCacheStatsJSON(stats, countExpiredSearches(s.cache, s.searchMaxAge), s.searchMaxAge))
+}
+
+// handleCacheInfo describes a cached library, like ctx7 cache info --json
+func (s *server) handleCacheInfo(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathLibraryID(w, r)
+	if !ok {
+		return
+	}
+	lib, err := s.cache.Library(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, id, "not_found", err)
+		return
+	}
+	serveJSON(w, newCacheInfoJSON(s.cache, lib, s.maxAge, time.Now()))
+}
+
+// pathLibraryID returns the library ID named by the {org} and {name} path
+// segments, answering 400 for segments that aren't one plain name each.
+// PathValue decodes %2F, so ..%2F.. would otherwise climb out of the cache.
+func pathLibraryID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	org, name := r.PathValue("org"), r.PathValue("name")
+	id := "/" + org + "/" + name
+	if !validPathPart(org) || !validPathPart(name) {
+		writeError(w, http.StatusBadRequest, id, "bad_request", fmt.Errorf("invalid library ID %q", id))
+		return "", false
+	}
+	return id, true
+}
+
+// validPathPart reports whether a decoded path segment is a plain name:
+// not empty or ".", without "..", and without slashes of either kind
+func validPathPart(part string) bool {
+	return part != "" && part != "." && !strings.Contains(part, "..") && !strings.ContainsAny(part, `/\`)
+}
+
+// handleMetrics exports the time and bytes of each stage since the server
+// started, for Prometheus
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.WritePrometheus(w)
+}
+
+// fail answers with err as a JSON error, with a status matching its code
+func (s *server) fail(w http.ResponseWriter, query string, err error) {
+	code := ErrorCode(err)
+	status := http.StatusBadGateway
+	var apiErr *client.APIError
+	switch {
+	case code == "not_found" || code == "no_documentation":
+		status = http.StatusNotFound
+	case code == "rate_limited":
+		status = http.StatusTooManyRequests
+	case code == "timeout":
+		status = http.StatusGatewayTimeout
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		status = http.StatusNotFound
+	}
+	writeError(w, status, query, code, err)
+}
+
+// serveJSON writes data as the JSON response
+func serveJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, data)
+}
+
+// writeError writes a JSON error document (schemas/error.v1.json)
+func writeError(w http.ResponseWriter, status int, query, code string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	WriteErrorJSON(w, query, code, err)
+}
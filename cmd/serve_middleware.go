@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ServeAuth restricts who may use ctx7 serve
+type ServeAuth struct {
+	// Token is the bearer token every request must carry, if set
+	Token string
+	// Allow lists the addresses and CIDR networks requests may come from;
+	// empty allows any
+	Allow []string
+}
+
+// authorize answers requests from outside the allowlist with 403 and
+// requests without the bearer token with 401. With both configured a
+// request has to pass both checks.
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.allow) > 0 && !s.allowed(r.RemoteAddr) {
+			writeError(w, http.StatusForbidden, "", "forbidden", errors.New("this address is not in serve_allow"))
+			return
+		}
+		if s.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ctx7"`)
+				writeError(w, http.StatusUnauthorized, "", "unauthorized", errors.New("missing or wrong bearer token"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowed reports whether remoteAddr, a request's host:port, is in the
+// allowlist
+func (s *server) allowed(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range s.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAllowlist parses the entries of serve_allow, addresses standing for
+// themselves alone
+func parseAllowlist(entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// isLoopback reports whether a listener's address only accepts connections
+// from this machine
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// track counts the requests in flight and answered, for the summary of a
+// shutdown
+func (s *server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer func() {
+			s.inFlight.Add(-1)
+			s.served.Add(1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequests writes a line to the access log for every request: when it
+// started, the client, method, path, status and how long it took
+func (s *server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		fmt.Fprintf(s.log, "%s %s %s %s %d %s\n", start.Format(time.DateTime), r.RemoteAddr, r.Method, r.URL.Path, sw.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// statusWriter remembers the status of a response for the access log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "Bearer nope", http.StatusUnauthorized},
+		{"prefix of the token", "Bearer s3c", http.StatusUnauthorized},
+		{"not bearer", "Basic s3cret", http.StatusUnauthorized},
+		{"bare token", "s3cret", http.StatusUnauthorized},
+		{"correct", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, t.TempDir(), ServeAuth{Token: "s3cret"})
+			req := httptest.NewRequest("GET", "/cache", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rec := serve(s, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d; body %q", rec.Code, tt.want, rec.Body)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if tt.want == http.StatusUnauthorized && challenge != `Bearer realm="ctx7"` {
+				t.Errorf("WWW-Authenticate = %q", challenge)
+			}
+			if tt.want == http.StatusUnauthorized && !strings.Contains(rec.Body.String(), `"unauthorized"`) {
+				t.Errorf("body %q, want the unauthorized code", rec.Body)
+			}
+		})
+	}
+}
+
+func TestServeAllowlist(t *testing.T) {
+	allow := []string{"10.0.0.0/24", "192.168.1.2", "fd00::/8"}
+	tests := []struct {
+		remote string
+		want   int
+	}{
+		{"10.0.0.5:4000", http.StatusOK},
+		{"10.0.1.5:4000", http.StatusForbidden},
+		{"192.168.1.2:4000", http.StatusOK},
+		{"192.168.1.3:4000", http.StatusForbidden},
+		{"[::ffff:10.0.0.9]:4000", http.StatusOK},
+		{"[fd00::1]:4000", http.StatusOK},
+		{"[fe80::1]:4000", http.StatusForbidden},
+		{"127.0.0.1:4000", http.StatusForbidden},
+		{"not an address", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			s, _ := newTestServer(t, t.TempDir(), ServeAuth{Allow: allow})
+			req := httptest.NewRequest("GET", "/cache", nil)
+			req.RemoteAddr = tt.remote
+
+			rec := serve(s, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d; body %q", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusForbidden && !strings.Contains(rec.Body.String(), `"forbidden"`) {
+				t.Errorf("body %q, want the forbidden code", rec.Body)
+			}
+		})
+	}
+}
+
+// With a token and an allowlist, a request has to pass both
+func TestServeTokenAndAllowlist(t *testing.T) {
+	tests := []struct {
+		name          string
+		remote, token string
+		want          int
+	}{
+		{"allowed with the token", "10.0.0.5:4000", "s3cret", http.StatusOK},
+		{"allowed without the token", "10.0.0.5:4000", "", http.StatusUnauthorized},
+		{"outside with the token", "10.0.1.5:4000", "s3cret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, t.TempDir(), ServeAuth{Token: "s3cret", Allow: []string{"10.0.0.0/24"}})
+			req := httptest.NewRequest("GET", "/cache", nil)
+			req.RemoteAddr = tt.remote
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if rec := serve(s, req); rec.Code != tt.want {
+				t.Errorf("status %d, want %d; body %q", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/33", "10.0.0", "example.com", "::1/200"} {
+		if _, err := parseAllowlist([]string{entry}); err == nil {
+			t.Errorf("parseAllowlist(%q) succeeded", entry)
+		}
+	}
+	prefixes, err := parseAllowlist([]string{" 10.0.0.7/24 ", "::ffff:192.168.1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefixes) != 2 || prefixes[0].String() != "10.0.0.0/24" || prefixes[1].String() != "192.168.1.2/32" {
+		t.Errorf("parseAllowlist = %v", prefixes)
+	}
+}
+
+// Without a token or an allowlist the API is only served on loopback
+func TestServeListen(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		auth ServeAuth
+		ok   bool
+	}{
+		{"loopback", "127.0.0.1:0", ServeAuth{}, true},
+		{"every interface", "0.0.0.0:0", ServeAuth{}, false},
+		{"every interface with a token", "0.0.0.0:0", ServeAuth{Token: "s3cret"}, true},
+		{"every interface with an allowlist", "0.0.0.0:0", ServeAuth{Allow: []string{"10.0.0.0/8"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, t.TempDir(), tt.auth)
+			ln, err := s.listen(tt.addr)
+			if ln != nil {
+				ln.Close()
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("listen(%q) = %v, want ok %v", tt.addr, err, tt.ok)
+			}
+			if err != nil && !strings.Contains(err.Error(), "serve_token") {
+				t.Errorf("error %q doesn't say how to allow the bind", err)
+			}
+		})
+	}
+}
+
+// Every request, refused ones included, is logged with its status
+func TestServeAccessLog(t *testing.T) {
+	s, _ := newTestServer(t, t.TempDir(), ServeAuth{Token: "s3cret"})
+	var log strings.Builder
+	s.log = &log
+
+	serve(s, httptest.NewRequest("GET", "/cache", nil))
+	req := httptest.NewRequest("GET", "/cache/stats", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	serve(s, req)
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("access log %q, want two lines", log.String())
+	}
+	for i, want := range []string{"GET /cache 401 ", "GET /cache/stats 200 "} {
+		if !strings.Contains(lines[i], " 192.0.2.1:1234 "+want) {
+			t.Errorf("line %d = %q, want %q from the client", i, lines[i], want)
+		}
+	}
+}
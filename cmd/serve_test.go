@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/tui/tuitest"
+)
+
+// newTestServer returns a server on a files cache in dir/cache, answering
+// from the tuitest fixtures, with the access log discarded
+func newTestServer(t *testing.T, dir string, auth ServeAuth) (*server, *tuitest.FakeClient) {
+	t.Helper()
+	c, err := cache.NewCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	allow, err := parseAllowlist(auth.Allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := tuitest.NewFixtureClient()
+	return &server{
+		eng:     engine.New(engine.Options{Client: fc, Cache: c, MaxAge: time.Hour}),
+		metrics: &engine.Metrics{},
+		cache:   c,
+		maxAge:  time.Hour,
+		flights: make(map[string]*flight),
+		token:   auth.Token,
+		allow:   allow,
+		log:     io.Discard,
+	}, fc
+}
+
+// serve answers req through the server's middleware and routes
+func serve(s *server, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeDoc(t *testing.T) {
+	s, fc := newTestServer(t, t.TempDir(), ServeAuth{})
+	rec := serve(s, httptest.NewRequest("GET", "/libs/facebook/react/llms.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != tuitest.Docs["/facebook/react"] {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("X-Ctx7-Cache"); got != "miss" {
+		t.Errorf("X-Ctx7-Cache = %q, want miss", got)
+	}
+
+	rec = serve(s, httptest.NewRequest("GET", "/libs/facebook/react/llms.txt", nil))
+	if got := rec.Header().Get("X-Ctx7-Cache"); rec.Code != http.StatusOK || got != "hit" {
+		t.Errorf("second request: status %d, X-Ctx7-Cache = %q, want 200 from the cache", rec.Code, got)
+	}
+	if len(fc.Fetches) != 1 {
+		t.Errorf("fetches = %q, want one", fc.Fetches)
+	}
+}
+
+// Path segments decode %2F, so IDs climbing out of the cache are refused
+// before anything is fetched or written
+func TestServeTraversal(t *testing.T) {
+	paths := []string{
+		"/libs/..%2F..%2F..%2Fx/y/llms.txt",
+		"/libs/x/..%2F..%2Fy/llms.txt",
+		"/libs/x/y/..%2F..%2F..%2Fz/llms.txt",
+		"/libs/..%5C..%5Cx/y/llms.txt",
+		"/libs/x/..%2E/llms.txt",
+		"/cache/libs/..%2F..%2Fx/y",
+		"/cache/libs/x/..%2F..",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			dir := t.TempDir()
+			s, fc := newTestServer(t, dir, ServeAuth{})
+			fc.Docs["/../../../x/y"] = "escaped"
+
+			rec := serve(s, httptest.NewRequest("GET", path, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want %d; body %q", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+			if len(fc.Fetches) != 0 || len(fc.Searches) != 0 {
+				t.Errorf("searches %q, fetches %q, want none", fc.Searches, fc.Fetches)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("wrote next to the cache: %v", entries)
+			}
+			if libs, _ := s.cache.ListCachedLibraries(); len(libs) != 0 {
+				t.Errorf("cached %v, want nothing", libs)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// RunSnippetsCommand searches code snippets within a single library
+func RunSnippetsCommand(args []string, cacheManager *cache.Cache, c *client.Client) {
+	fs := flag.NewFlagSet("snippets", flag.ExitOnError)
+	noCache := fs.Bool("no-cache", false, "Skip cache, force fresh fetch")
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "Error: library and query required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 snippets <library> <query> [--no-cache]")
+		os.Exit(1)
+	}
+
+	query := strings.Join(fs.Args()[1:], " ")
+
+	libraryID, err := resolveLibraryID(c, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving library: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Serve from cache when possible
+	if cacheManager != nil && !*noCache {
+		if content, err := cacheManager.GetCachedSnippets(libraryID, query, 24*time.Hour); err == nil {
+			fmt.Print(content)
+			return
+		}
+	}
+
+	content, err := c.SearchSnippets(context.Background(), libraryID, query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching snippets: %v\n", err)
+		os.Exit(1)
+	}
+
+	if strings.TrimSpace(content) == "" {
+		fmt.Fprintf(os.Stderr, "No snippets found for '%s' in %s\n", query, libraryID)
+		os.Exit(1)
+	}
+
+	if cacheManager != nil && !*noCache {
+		_ = cacheManager.CacheSnippets(libraryID, query, content)
+	}
+
+	fmt.Print(content)
+}
+
+// resolveLibraryID returns arg unchanged if it is already a library ID
+// (e.g. /vercel/next.js), otherwise the ID of the top search result
+func resolveLibraryID(c *client.Client, arg string) (string, error) {
+	if strings.HasPrefix(arg, "/") {
+		return arg, nil
+	}
+
+	resolution, err := engine.New(engine.Options{Client: c}).Resolve(context.Background(), arg)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(os.Stderr, "Using %s (%s)\n", resolution.Library.Title, resolution.Library.ID)
+	return resolution.Library.ID, nil
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/hsbacot/ctx7/budget"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/content"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/stack"
+	"github.com/hsbacot/ctx7/tui"
+)
+
+// RunStackCommand composes the docs of a stack's libraries into one
+// context file, splitting the token budget by the libraries' weights
+func RunStackCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge, searchMaxAge time.Duration) {
+	if len(args) == 0 {
+		printStackUsage()
+		os.Exit(1)
+	}
+
+	dir, err := stack.UserDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	stacks, err := stack.Load(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		listStacks(stacks, dir)
+		return
+	case "new":
+		newStack(args[1:], stacks, dir, cacheManager, c, maxAge, searchMaxAge)
+		return
+	}
+
+	fs := flag.NewFlagSet("stack", flag.ExitOnError)
+	outPath := fs.String("o", "", "Write the composed file here instead of to stdout")
+	tokens := fs.Int("budget", -1, "Estimated tokens shared by the libraries (default the stack's budget, 0 for no limit)")
+	jobs := fs.Int("jobs", 4, "Number of libraries to fetch at once")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		printStackUsage()
+		os.Exit(1)
+	}
+	s, ok := stacks[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown stack %q (ctx7 stack list shows them)\n", fs.Arg(0))
+		os.Exit(1)
+	}
+	if *tokens < 0 {
+		*tokens = s.Budget
+	}
+
+	batch, tx := BeginBatch(cacheManager)
+	eng := engine.New(engine.Options{Client: c, Cache: batch, MaxAge: maxAge, SearchMaxAge: searchMaxAge})
+	writeStack(eng, tx, s, *tokens, *jobs, *outPath)
+}
+
+// newStack builds a stack in the TUI, saves it when it was given a name
+// and writes it composed
+func newStack(args []string, stacks map[string]stack.Stack, dir string, cacheManager *cache.Cache, c *client.Client, maxAge, searchMaxAge time.Duration) {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	outPath := fs.String("o", "", "Write the composed file here instead of to stdout")
+	tokens := fs.Int("budget", 50000, "Estimated tokens to start the budget at (0 for no limit)")
+	jobs := fs.Int("jobs", 4, "Number of libraries to fetch at once")
+	fs.Parse(args)
+
+	if !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stderr.Fd()) {
+		fmt.Fprintln(os.Stderr, "Error: ctx7 stack new needs a terminal; write a stack file instead (ctx7 stack list shows where)")
+		os.Exit(1)
+	}
+
+	batch, tx := BeginBatch(cacheManager)
+	defer tx.Rollback()
+	eng := engine.New(engine.Options{Client: c, Cache: batch, MaxAge: maxAge, SearchMaxAge: searchMaxAge})
+	s, ok, err := tui.RunStackBuilder(context.Background(), eng.Search, *tokens, stack.Names(stacks),
+		tea.WithInput(os.Stdin), tea.WithOutput(os.Stderr))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Cancelled")
+		return
+	}
+
+	if s.Name != "" && dir == "" {
+		fmt.Fprintf(os.Stderr, "Warning: not saving %s: there is no config directory\n", s.Name)
+		s.Name = ""
+	}
+	if s.Name != "" {
+		path, err := stack.Save(dir, s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Saved %s; ctx7 stack %s composes it again\n", path, s.Name)
+	} else {
+		s.Name = "custom"
+	}
+	writeStack(eng, tx, s, s.Budget, *jobs, *outPath)
+}
+
+// writeStack fetches the libraries of s through the batch tx and writes
+// them composed, within tokens, to outPath or stdout
+func writeStack(eng *engine.Engine, tx *cache.Tx, s stack.Stack, tokens, jobs int, outPath string) {
+	names := make([]string, len(s.Libraries))
+	weights := make(map[string]float64)
+	for i, lib := range s.Libraries {
+		names[i] = lib.Name
+		weights[lib.Name] = lib.Weight
+	}
+
+	docs := fetchAll(eng, names, jobs)
+	CommitBatch(tx)
+	if len(docs) == 0 {
+		os.Exit(1)
+	}
+
+	if tokens > 0 {
+		sizes := make([]int, len(docs))
+		docWeights := make([]float64, len(docs))
+		for i, d := range docs {
+			sizes[i] = d.tokens
+			docWeights[i] = weights[d.name]
+		}
+		for i, share := range budget.SplitWeighted(sizes, docWeights, tokens) {
+			docs[i].content = content.Truncate(docs[i].content, share*4)
+		}
+	}
+
+	composed := composeStack(s, docs)
+	if outPath != "" {
+		if err := writeFile(outPath, composed); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Print(composed)
+	}
+
+	for _, d := range docs {
+		if d.content == "" {
+			fmt.Fprintf(os.Stderr, "- %s left out: its first snippet doesn't fit its share of the budget\n", d.lib.ID)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "✓ %s ~%d of %d tokens\n", d.lib.ID, budget.EstimateTokens(d.content), d.tokens)
+	}
+	if outPath != "" {
+		fmt.Fprintf(os.Stderr, "\nWrote the %s stack to %s (~%d tokens)\n", s.Name, outPath, budget.EstimateTokens(composed))
+	}
+}
+
+func printStackUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: ctx7 stack [-o <file>] [--budget N] <stack>")
+	fmt.Fprintln(os.Stderr, "       ctx7 stack new [-o <file>] [--budget N]")
+	fmt.Fprintln(os.Stderr, "       ctx7 stack list")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "A stack is a set of libraries fetched into one context file, e.g.")
+	fmt.Fprintln(os.Stderr, "nextjs for Next.js, React, Tailwind CSS and TypeScript.")
+}
+
+// listStacks prints every stack with its libraries and weights
+func listStacks(stacks map[string]stack.Stack, dir string) {
+	printHeader("Stacks")
+	for _, name := range stack.Names(stacks) {
+		s := stacks[name]
+		fmt.Printf("%-12s %s\n", name, s.Description)
+
+		libs := make([]string, len(s.Libraries))
+		for i, lib := range s.Libraries {
+			weight := lib.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			libs[i] = fmt.Sprintf("%s (%g)", lib.Name, weight)
+		}
+		fmt.Printf("%-12s %s\n", "", strings.Join(libs, ", "))
+		if s.Path != "" {
+			fmt.Printf("%-12s from %s\n", "", s.Path)
+		}
+		fmt.Println()
+	}
+	if dir != "" {
+		fmt.Printf("Add stacks, or override these by name, in %s\n", filepath.Join(dir, "<name>.toml"))
+	}
+}
+
+// composeStack joins the documents of a stack into one file
+func composeStack(s stack.Stack, docs []bundleDoc) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s stack\n\n", s.Name)
+	if s.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", s.Description)
+	}
+	for _, d := range docs {
+		if d.content != "" {
+			fmt.Fprintf(&b, "## %s\n\n%s\n\n", d.heading(), strings.TrimSpace(d.content))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+)
+
+// RunStatsCommand reports the local usage analytics. They are kept in the
+// cache directory only and never sent anywhere.
+func RunStatsCommand(args []string, cacheManager *cache.Cache) {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 stats export [--json] [--schema]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Prints how often docs were pulled per library (counts only, no")
+		fmt.Fprintln(os.Stderr, "queries or content). Nothing is ever sent anywhere; share the")
+		fmt.Fprintln(os.Stderr, "--json export yourself to aggregate usage across a team.")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("stats export", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	schema := fs.Bool("schema", false, "Print the JSON Schema of --json output")
+	fs.Parse(args[1:])
+
+	if *schema {
+		printSchema("stats-export")
+		return
+	}
+
+	usage, err := cacheManager.GetUsageStats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading usage stats: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		if err := printJSON(newStatsExportJSON(usage, time.Now())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	libraries := sortedLibraryUsage(usage)
+	if len(libraries) == 0 {
+		fmt.Println("No library usage recorded yet")
+		return
+	}
+
+	printHeader(fmt.Sprintf("Library Usage since %s", formatDate(usage.Since)))
+	fmt.Printf("%-40s %7s %7s %8s  %s\n", "Library", "Pulls", "Cached", "Fetched", "Last used")
+	for _, lib := range libraries {
+		fmt.Printf("%-40s %7d %7d %8d  %s\n", lib.id, lib.Pulls(), lib.Hits, lib.Fetches, lib.LastUsed)
+	}
+}
+
+// libraryUsage is a library's counters with its ID
+type libraryUsage struct {
+	id string
+	cache.LibraryUsage
+}
+
+// sortedLibraryUsage returns the per-library counters, most pulled first
+func sortedLibraryUsage(usage cache.UsageStats) []libraryUsage {
+	libraries := make([]libraryUsage, 0, len(usage.Libraries))
+	for id, lib := range usage.Libraries {
+		libraries = append(libraries, libraryUsage{id: id, LibraryUsage: *lib})
+	}
+	sort.Slice(libraries, func(i, j int) bool {
+		if libraries[i].Pulls() != libraries[j].Pulls() {
+			return libraries[i].Pulls() > libraries[j].Pulls()
+		}
+		return libraries[i].id < libraries[j].id
+	})
+	return libraries
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hsbacot/ctx7/budget"
+	"github.com/hsbacot/ctx7/content"
+)
+
+// tokenTitleWidth is the width of the section column of a token report
+const tokenTitleWidth = 44
+
+// WriteTokenReport writes the approximate token counts of doc per model
+// family (see budget.Families), for the whole document and for each of its
+// sections, so a reader can tell how much of it fits in a prompt. label
+// names the document, e.g. /facebook/react@v18.3.1.
+func WriteTokenReport(w io.Writer, label, doc string) {
+	snippets := content.ParseSnippets(doc)
+
+	fmt.Fprintf(w, "%s: %d sections, %s\n\n", label, len(snippets), formatSize(int64(len(doc))))
+
+	fmt.Fprintf(w, "%-*s", tokenTitleWidth, "")
+	for _, f := range budget.Families {
+		fmt.Fprintf(w, " %9s", f.Name)
+	}
+	fmt.Fprintln(w)
+
+	writeTokenRow(w, "Whole document", doc)
+	for _, s := range snippets {
+		title := s.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		if s.Level > 1 {
+			title = "  " + title
+		}
+		writeTokenRow(w, "  "+title, s.Text)
+	}
+
+	fmt.Fprintln(w)
+	for _, f := range budget.Families {
+		fmt.Fprintf(w, "%-8s %s\n", f.Name, f.Models)
+	}
+	fmt.Fprintf(w, "Counts are approximations; --budget estimates ~%d tokens for the whole document.\n", budget.EstimateTokens(doc))
+}
+
+// writeTokenRow writes one line of a token report, shortening title to fit
+func writeTokenRow(w io.Writer, title, text string) {
+	title = ansi.Truncate(title, tokenTitleWidth, "…")
+	fmt.Fprint(w, title+strings.Repeat(" ", tokenTitleWidth-ansi.StringWidth(title)))
+	for _, f := range budget.Families {
+		fmt.Fprintf(w, " %9d", f.Count(text))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// RunVersionsCommand resolves a library and lists the versions context7
+// has documentation for, marking the latest and the ones already cached.
+// maxAge is the document TTL (0 for the default).
+func RunVersionsCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge time.Duration) {
+	if maxAge <= 0 {
+		maxAge = engine.DefaultMaxAge
+	}
+
+	fs := flag.NewFlagSet("versions", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	schema := fs.Bool("schema", false, "Print the JSON Schema of --json output")
+	fs.Parse(args)
+
+	if *schema {
+		printSchema("versions")
+		return
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: library required")
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 versions [--json] <library>")
+		os.Exit(1)
+	}
+
+	eng := engine.New(engine.Options{Client: c, Cache: cacheManager})
+	lib, err := resolveLibrary(context.Background(), eng, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving library: %v\n", err)
+		os.Exit(1)
+	}
+
+	var cached map[string]cache.VersionInfo
+	if cacheManager != nil {
+		cached = cachedRefs(cacheManager, lib.ID)
+	}
+	now := time.Now()
+
+	if *jsonOutput {
+		if err := printJSON(newVersionsJSON(lib, cached, maxAge, now)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	printHeader(fmt.Sprintf("Versions of %s (%s)", lib.Title, lib.ID))
+
+	row := func(label, note, ref string) {
+		status := "-"
+		if v, ok := cached[ref]; ok {
+			status = "cached " + formatFreshness(v.FetchedAt, maxAge)
+		}
+		fmt.Printf("  %-24s %-8s %s\n", label, note, status)
+	}
+
+	row("(default)", "", cache.RefKey("", ""))
+	for i, v := range lib.Versions {
+		note := ""
+		if i == 0 {
+			note = "latest"
+		}
+		row(v, note, cache.RefKey(v, ""))
+	}
+	if lib.Branch != "" {
+		row("branch "+lib.Branch, "", cache.RefKey("", lib.Branch))
+	}
+
+	if len(lib.Versions) == 0 {
+		fmt.Println("\nNo versioned documentation; only the default document is available.")
+	}
+}
+
+// cachedRefs returns the cached full documents of a library keyed by their
+// ref key ("" for the default document); topic variants are left out
+func cachedRefs(c *cache.Cache, libraryID string) map[string]cache.VersionInfo {
+	lib, err := c.Library(libraryID)
+	if err != nil {
+		return nil
+	}
+
+	refs := make(map[string]cache.VersionInfo, len(lib.Versions))
+	for _, v := range lib.Versions {
+		if v.IsVariant() {
+			continue
+		}
+		ref := v.Version
+		if v.IsDefault {
+			ref = ""
+		}
+		refs[ref] = v
+	}
+	return refs
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+)
+
+// RunWatchCommand keeps the cache fresh until interrupted: every interval
+// it asks context7 when each cached library was last updated and
+// re-downloads the versions fetched before then, logging a summary of
+// each pass. Meant to run in the background, e.g. in a dev container.
+func RunWatchCommand(args []string, cacheManager *cache.Cache, c *client.Client, maxAge time.Duration) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Hour, "Time between checks")
+	once := fs.Bool("once", false, "Check once and exit")
+	jobs := fs.Int("jobs", 4, "Number of libraries to check at once")
+	onRegression := fs.String("on-regression", "keep", "keep, replace or both for downloads that look worse than the cached copy")
+	fs.Parse(args)
+
+	if cacheManager == nil {
+		fmt.Fprintln(os.Stderr, "Error: ctx7 watch needs a cache")
+		os.Exit(1)
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --interval must be positive")
+		os.Exit(1)
+	}
+	policy, err := regressionPolicy(*onRegression)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --on-regression: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := &watcher{
+		cache: cacheManager,
+		api:   c,
+		eng:   engine.New(engine.Options{Client: c, Cache: cacheManager, MaxAge: maxAge, OnRegression: policy}),
+		jobs:  *jobs,
+	}
+
+	if *once {
+		if w.pass(ctx) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Checking the cache for updated libraries every %s (Ctrl-C to stop)\n", *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		w.pass(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watcher runs the passes of ctx7 watch
+type watcher struct {
+	cache *cache.Cache
+	api   *client.Client
+	eng   *engine.Engine
+	jobs  int
+}
+
+// watchResult is what a pass found for one cached library
+type watchResult struct {
+	libraryID string
+	err       error // Checking context7 failed
+	// listed is set when context7 lists the library with an update date
+	listed    bool
+	refetched []refetchResult
+}
+
+// pass checks every cached library once, re-downloading the versions
+// context7 has updated since they were fetched, and returns the number of
+// failures
+func (w *watcher) pass(ctx context.Context) int {
+	libraries, err := w.cache.ListCachedLibraries()
+	if err != nil {
+		fmt.Printf("%s ✗ Error listing cache: %v\n", watchTime(), err)
+		return 1
+	}
+
+	var updated, refreshed, unlisted, failed int
+	for r := range parallel(libraries, w.jobs, func(lib cache.CachedLibrary) watchResult {
+		return w.check(ctx, lib)
+	}) {
+		if ctx.Err() != nil {
+			continue
+		}
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Printf("%s ✗ %s: %v\n", watchTime(), r.libraryID, r.err)
+		case !r.listed:
+			unlisted++
+		}
+		for _, o := range r.refetched {
+			updated++
+			switch {
+			case o.err != nil:
+				failed++
+				fmt.Printf("%s ✗ %s: %v\n", watchTime(), o.target.label(), o.err)
+			case o.kept:
+				fmt.Printf("%s ⚠ %s (kept the cached copy: %s)\n", watchTime(), o.target.label(), o.regression)
+			case o.changes != nil && o.changes.Unchanged():
+				refreshed++
+				fmt.Printf("%s ✓ %s (unchanged)\n", watchTime(), o.target.label())
+			default:
+				refreshed++
+				fmt.Printf("%s ✓ %s (%s)\n", watchTime(), o.target.label(), o.changeSummary())
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return failed
+	}
+
+	unlistedNote := ""
+	if unlisted > 0 {
+		unlistedNote = fmt.Sprintf(", %d without an update date on context7", unlisted)
+	}
+	fmt.Printf("%s Checked %d libraries: %d entries out of date, %d refreshed, %d failed%s\n",
+		watchTime(), len(libraries), updated, refreshed, failed, unlistedNote)
+	return failed
+}
+
+// check compares a cached library's versions with its update date on
+// context7 and re-downloads those fetched before it. Shared versions are
+// read-only, kept copies go with the document that replaced them, and
+// versions downloaded from another instance aren't this one's to refresh.
+func (w *watcher) check(ctx context.Context, lib cache.CachedLibrary) watchResult {
+	r := watchResult{libraryID: lib.LibraryID}
+	if ctx.Err() != nil {
+		r.err = ctx.Err()
+		return r
+	}
+
+	upstream, ok, err := w.upstream(ctx, lib.LibraryID)
+	if err != nil {
+		r.err = fmt.Errorf("failed to check for updates: %w", err)
+		return r
+	}
+	updatedAt, err := time.Parse(time.RFC3339, upstream.LastUpdateDate)
+	if !ok || err != nil {
+		return r
+	}
+	r.listed = true
+
+	for _, v := range lib.Versions {
+		if v.Shared || v.Metadata.Superseded || !w.eng.SameOrigin(v.Metadata) || !updatedAt.After(v.FetchedAt) {
+			continue
+		}
+		t := refetchTarget{libraryID: lib.LibraryID, version: v}
+		req := requestFor(t)
+		req.Library = upstream
+
+		o := refetchResult{target: t}
+		if result, err := w.eng.Refresh(ctx, req); err != nil {
+			o.err = err
+		} else {
+			o.changes, o.regression, o.kept = result.Changes, result.Regression, result.FromCache
+		}
+		r.refetched = append(r.refetched, o)
+	}
+	return r
+}
+
+// upstream returns the library as context7 lists it now, searching by its
+// name without going through the search cache
+func (w *watcher) upstream(ctx context.Context, libraryID string) (client.Library, bool, error) {
+	name := libraryID[strings.LastIndex(libraryID, "/")+1:]
+	results, err := w.api.SearchLibraries(ctx, name)
+	if err != nil {
+		return client.Library{}, false, err
+	}
+	for _, lib := range results {
+		if lib.ID == libraryID {
+			return lib, true, nil
+		}
+	}
+	return client.Library{}, false, nil
+}
+
+// watchTime stamps the lines ctx7 watch logs
+func watchTime() string {
+	return time.Now().Format(time.DateTime)
+}
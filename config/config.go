@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config holds user settings from the config file, overridden by environment variables
+type Config struct {
+	// BaseURL points ctx7 at a mirror or self-hosted context7 instance ($CTX7_BASE_URL)
+	BaseURL string `toml:"base_url"`
+	// CABundle is a PEM file of extra trusted certificates ($CTX7_CA_BUNDLE)
+	CABundle string `toml:"ca_bundle"`
+	// InsecureSkipVerify disables TLS certificate verification
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	// CacheTTL is how long cached documents are served before refetching,
+	// e.g. "72h" (0 uses the default of 24h)
+	CacheTTL time.Duration `toml:"cache_ttl"`
+	// SearchCacheTTL is how long search results are reused, e.g. "6h"
+	// (0 uses the default of 1h)
+	SearchCacheTTL time.Duration `toml:"search_cache_ttl"`
+	// SearchTimeout bounds library searches, e.g. "5s" (--search-timeout)
+	SearchTimeout time.Duration `toml:"search_timeout"`
+	// FetchTimeout bounds documentation downloads, e.g. "5m" (--fetch-timeout)
+	FetchTimeout time.Duration `toml:"fetch_timeout"`
+	// Lang is the language of search queries, e.g. "ja", passed to the
+	// search API as a hint (--lang)
+	Lang string `toml:"lang"`
+	// ManifestURL is a SHA-256 manifest (URL or path) that mirrored documents
+	// must match before they are cached (--insecure skips the check)
+	ManifestURL string `toml:"manifest_url"`
+	// ManifestKey is the minisign public key the manifest is signed with
+	ManifestKey string `toml:"manifest_key"`
+	// CardHeight is the number of lines each library takes in the
+	// interactive picker (0 uses the default of 5)
+	CardHeight int `toml:"card_height"`
+	// RankProfile orders search results by a ranking profile, built in
+	// ("popular", "quality", "fresh") or from RankProfiles; empty keeps
+	// context7's order with learned libraries first
+	RankProfile string `toml:"rank_profile"`
+	// RankProfiles defines ranking profiles by name, e.g. [rank_profiles.mine]
+	RankProfiles map[string]RankWeights `toml:"rank_profiles"`
+	// RankCommand scores search results with an external command instead
+	// of a profile (see engine.CommandRanker)
+	RankCommand string `toml:"rank_command"`
+	// ServeToken is the bearer token ctx7 serve requires of every request
+	// ($CTX7_SERVE_TOKEN)
+	ServeToken string `toml:"serve_token"`
+	// ServeAllow lists the addresses and networks, e.g. "10.0.0.0/8",
+	// ctx7 serve accepts requests from
+	ServeAllow []string `toml:"serve_allow"`
+	// Theme picks the colors of the interactive picker, the spinner and the
+	// cache commands' output, from a [theme] table
+	Theme Theme `toml:"theme"`
+	// Defaults holds default flags per command, from tables such as
+	// [defaults.fetch] for ctx7 <library> or [defaults.cache.prune]
+	Defaults map[string]any `toml:"defaults"`
+}
+
+// RankWeights are the weights of a ranking profile, each feature scaled to
+// 0–1 across the results (see engine.Weights)
+type RankWeights struct {
+	Relevance float64 `toml:"relevance"`
+	Stars     float64 `toml:"stars"`
+	Trust     float64 `toml:"trust"`
+	Benchmark float64 `toml:"benchmark"`
+	Recency   float64 `toml:"recency"`
+	Learned   float64 `toml:"learned"`
+}
+
+// Theme is a built-in color preset with optional per-color overrides.
+// Colors are ANSI 256 numbers like "205" or hex like "#ff5fd7".
+type Theme struct {
+	// Preset is "dark" (the default), "light" or "monochrome"
+	Preset  string `toml:"preset"`
+	Accent  string `toml:"accent"`
+	Success string `toml:"success"`
+	Warning string `toml:"warning"`
+	Error   string `toml:"error"`
+	Info    string `toml:"info"`
+	Muted   string `toml:"muted"`
+}
+
+// fetchDefaults is the [defaults] table of the command without a name,
+// ctx7 <library>
+const fetchDefaults = "fetch"
+
+// FetchDefaults returns the default flags of ctx7 <library>, from
+// [defaults.fetch]
+func (c *Config) FetchDefaults() []string {
+	table, _ := c.Defaults[fetchDefaults].(map[string]any)
+	return flagsOf(table)
+}
+
+// CommandDefaults returns the default flags of the subcommand named by the
+// leading words of args, e.g. [defaults.cache.prune] for "cache prune
+// --dry-run", and how many words name it
+func (c *Config) CommandDefaults(args []string) (n int, flags []string) {
+	table := c.Defaults
+	for n < len(args) && !(n == 0 && args[n] == fetchDefaults) {
+		sub, ok := table[args[n]].(map[string]any)
+		if !ok {
+			break
+		}
+		table = sub
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, flagsOf(table)
+}
+
+// flagsOf turns the values of a [defaults] table into --name=value flags in
+// name order; a list repeats its flag for each value. Tables within it are
+// the defaults of subcommands, not flags.
+func flagsOf(table map[string]any) []string {
+	var flags []string
+	for _, name := range slices.Sorted(maps.Keys(table)) {
+		values, ok := table[name].([]any)
+		if !ok {
+			values = []any{table[name]}
+		}
+		for _, v := range values {
+			if _, isTable := v.(map[string]any); isTable {
+				continue
+			}
+			flags = append(flags, fmt.Sprintf("--%s=%v", name, v))
+		}
+	}
+	return flags
+}
+
+// DefaultPath returns the config file location: $CTX7_CONFIG, or
+// ~/.config/ctx7/config.toml
+func DefaultPath() (string, error) {
+	if path := os.Getenv("CTX7_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "ctx7", "config.toml"), nil
+}
+
+// Load reads the config file at path and applies environment overrides;
+// a missing file yields the defaults
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if _, err := toml.DecodeFile(path, cfg); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if baseURL := os.Getenv("CTX7_BASE_URL"); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if caBundle := os.Getenv("CTX7_CA_BUNDLE"); caBundle != "" {
+		cfg.CABundle = caBundle
+	}
+	if token := os.Getenv("CTX7_SERVE_TOKEN"); token != "" {
+		cfg.ServeToken = token
+	}
+
+	return cfg, nil
+}
+
+// LoadDefault loads the config from DefaultPath
+func LoadDefault() (*Config, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// ParseDuration parses a duration like time.ParseDuration, also accepting
+// whole days such as "30d"
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,119 @@
+package content
+
+import (
+	"crypto/sha256"
+	"hash/fnv"
+	"strings"
+)
+
+// shingleWords is how many consecutive words make up a shingle when
+// snippets are compared for similarity
+const shingleWords = 3
+
+// Duplicates counts the snippets Dedupe dropped
+type Duplicates struct {
+	Snippets int // Snippets in the document
+	Exact    int // Dropped as copies of an earlier snippet
+	Similar  int // Dropped as near-copies of an earlier snippet
+}
+
+// Dropped returns the number of snippets dropped
+func (d Duplicates) Dropped() int {
+	return d.Exact + d.Similar
+}
+
+// Dedupe drops the snippets of doc that repeat an earlier one, keeping the
+// first of each. Snippets are copies when they differ only in whitespace.
+// With a similarity above 0, snippets whose words overlap an earlier
+// one's by at least that share (Jaccard similarity of 3-word shingles, 0
+// to 1) are dropped too. doc is returned as it is when nothing is dropped.
+func Dedupe(doc string, similarity float64) (string, Duplicates) {
+	snippets := ParseSnippets(doc)
+	d := Duplicates{Snippets: len(snippets)}
+
+	seen := make(map[[sha256.Size]byte]bool)
+	var kept []string
+	var similar shingleIndex
+	for _, s := range snippets {
+		words := strings.Fields(s.Text)
+		sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+		if seen[sum] {
+			d.Exact++
+			continue
+		}
+		seen[sum] = true
+
+		if similarity > 0 {
+			sh := shingles(words)
+			if similar.matches(sh, similarity) {
+				d.Similar++
+				continue
+			}
+			similar.add(sh)
+		}
+		kept = append(kept, strings.TrimSpace(s.Text))
+	}
+
+	if d.Dropped() == 0 {
+		return doc, d
+	}
+	return joinSnippets(doc, kept), d
+}
+
+// shingles returns the hashes of the runs of shingleWords words in words,
+// ignoring case. Snippets shorter than that are one shingle.
+func shingles(words []string) map[uint64]struct{} {
+	set := make(map[uint64]struct{})
+	for i := 0; i == 0 || i+shingleWords <= len(words); i++ {
+		h := fnv.New64a()
+		for _, w := range words[i:min(i+shingleWords, len(words))] {
+			h.Write([]byte(strings.ToLower(w)))
+			h.Write([]byte{0})
+		}
+		set[h.Sum64()] = struct{}{}
+	}
+	return set
+}
+
+// shingleIndex holds the shingles of the snippets kept so far, so a
+// snippet is only compared with those it shares shingles with
+type shingleIndex struct {
+	sizes      []int            // Shingle count of each snippet
+	containing map[uint64][]int // Snippets by shingle
+	shared     []int            // Scratch counts for matches, by snippet
+}
+
+func (x *shingleIndex) add(sh map[uint64]struct{}) {
+	if x.containing == nil {
+		x.containing = make(map[uint64][]int)
+	}
+	for h := range sh {
+		x.containing[h] = append(x.containing[h], len(x.sizes))
+	}
+	x.sizes = append(x.sizes, len(sh))
+	x.shared = append(x.shared, 0)
+}
+
+// matches reports whether the Jaccard similarity of sh and any snippet in
+// the index is at least threshold
+func (x *shingleIndex) matches(sh map[uint64]struct{}, threshold float64) bool {
+	var candidates []int
+	for h := range sh {
+		for _, i := range x.containing[h] {
+			if x.shared[i] == 0 {
+				candidates = append(candidates, i)
+			}
+			x.shared[i]++
+		}
+	}
+
+	found := false
+	for _, i := range candidates {
+		n := x.shared[i]
+		x.shared[i] = 0
+		if float64(n) >= threshold*float64(len(sh)+x.sizes[i]-n) {
+			found = true
+		}
+	}
+	return found
+}
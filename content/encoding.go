@@ -0,0 +1,50 @@
+package content
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// utf8BOM is the byte order mark some upstream docs start with
+const utf8BOM = "\uFEFF"
+
+// StripBOM removes a leading UTF-8 byte order mark
+func StripBOM(s string) string {
+	return strings.TrimPrefix(s, utf8BOM)
+}
+
+// SkipBOM returns a reader over r without its leading UTF-8 byte order mark
+func SkipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && string(prefix) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
+
+// LineEnding selects how line breaks are written
+type LineEnding string
+
+const (
+	// LineEndingKeep leaves line breaks as fetched
+	LineEndingKeep LineEnding = ""
+	// LineEndingLF converts all line breaks to \n
+	LineEndingLF LineEnding = "lf"
+	// LineEndingCRLF converts all line breaks to \r\n
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// NormalizeLineEndings rewrites mixed \r\n, \r and \n line breaks to le
+func NormalizeLineEndings(s string, le LineEnding) string {
+	if le == LineEndingKeep {
+		return s
+	}
+
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	if le == LineEndingCRLF {
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+	}
+	return s
+}
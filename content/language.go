@@ -0,0 +1,85 @@
+package content
+
+import (
+	"slices"
+	"strings"
+)
+
+// languageAliases maps the names code blocks are tagged with to one
+// canonical name per language
+var languageAliases = map[string]string{
+	"golang":      "go",
+	"ts":          "typescript",
+	"tsx":         "typescript",
+	"js":          "javascript",
+	"jsx":         "javascript",
+	"mjs":         "javascript",
+	"py":          "python",
+	"python3":     "python",
+	"rb":          "ruby",
+	"rs":          "rust",
+	"sh":          "shell",
+	"bash":        "shell",
+	"zsh":         "shell",
+	"console":     "shell",
+	"shellscript": "shell",
+	"yml":         "yaml",
+	"c++":         "cpp",
+	"cs":          "csharp",
+	"c#":          "csharp",
+	"kt":          "kotlin",
+}
+
+// NormalizeLanguage returns the canonical name of a code block language,
+// e.g. "typescript" for "ts"
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if canonical, ok := languageAliases[lang]; ok {
+		return canonical
+	}
+	return lang
+}
+
+// Languages returns the canonical languages of the snippet's code, from
+// its LANGUAGE: line and the info strings of its fenced code blocks
+func (s Snippet) Languages() []string {
+	var langs []string
+	add := func(lang string) {
+		if lang = NormalizeLanguage(lang); lang != "" && !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+
+	inCode := false
+	for _, line := range strings.Split(s.Text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if lang, ok := strings.CutPrefix(trimmed, "LANGUAGE: "); ok && !inCode {
+			add(lang)
+			continue
+		}
+		if info, ok := strings.CutPrefix(trimmed, "```"); ok {
+			if !inCode {
+				// The info string may carry attributes after the language
+				if fields := strings.Fields(info); len(fields) > 0 {
+					add(fields[0])
+				}
+			}
+			inCode = !inCode
+		}
+	}
+	return langs
+}
+
+// SelectLanguage returns the snippets of doc with code in lang, e.g. "go"
+// or "ts". The result is empty when no snippet has code in lang.
+func SelectLanguage(doc, lang string) string {
+	lang = NormalizeLanguage(lang)
+
+	var selected []string
+	for _, s := range ParseSnippets(doc) {
+		if slices.Contains(s.Languages(), lang) {
+			selected = append(selected, strings.TrimSpace(s.Text))
+		}
+	}
+	return joinSnippets(doc, selected)
+}
@@ -0,0 +1,65 @@
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SplitSections splits doc into the parts a document is patched by: each
+// TITLE: snippet up to and including the separator line after it, or the
+// text from one # or ## heading to the next. Unlike ParseSnippets nothing
+// is trimmed, so the sections join back into doc exactly.
+func SplitSections(doc string) []string {
+	if IsTitled(doc) {
+		return splitTitled(doc)
+	}
+	return splitHeadings(doc)
+}
+
+func splitTitled(doc string) []string {
+	var sections []string
+	for doc != "" {
+		i := strings.Index(doc, SnippetSeparator)
+		if i < 0 {
+			sections = append(sections, doc)
+			break
+		}
+		end := i + len(SnippetSeparator)
+		if strings.HasPrefix(doc[end:], "\n") {
+			end++
+		}
+		sections = append(sections, doc[:end])
+		doc = doc[end:]
+	}
+	return sections
+}
+
+func splitHeadings(doc string) []string {
+	var sections []string
+	start := 0
+	for offset := 0; offset < len(doc); {
+		end := strings.IndexByte(doc[offset:], '\n')
+		if end < 0 {
+			end = len(doc)
+		} else {
+			end += offset + 1
+		}
+		if headingLevel(doc[offset:end]) > 0 && offset > start {
+			sections = append(sections, doc[start:offset])
+			start = offset
+		}
+		offset = end
+	}
+	if start < len(doc) {
+		sections = append(sections, doc[start:])
+	}
+	return sections
+}
+
+// SectionDigest returns the hex SHA-256 of a section, the name servers
+// index it by
+func SectionDigest(section string) string {
+	sum := sha256.Sum256([]byte(section))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+package content
+
+import (
+	"strings"
+)
+
+// SnippetSeparator divides titled snippets in context7 llms.txt documents
+const SnippetSeparator = "----------------------------------------"
+
+// Snippet is one titled part of a document: a TITLE: snippet, or the text
+// under a markdown heading up to the next heading
+type Snippet struct {
+	Title string
+	// Level is the heading level (1 for #, 2 for ##), 0 for TITLE: snippets
+	Level int
+	Text  string
+}
+
+// Field returns the value of a snippet's "NAME: value" line outside its
+// code, such as SOURCE or DESCRIPTION, or "" when it has none
+func (s Snippet) Field(name string) string {
+	inCode := false
+	for _, line := range strings.Split(s.Text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, name+": "); ok && !inCode {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// IsTitled reports whether doc is made of TITLE: snippets rather than
+// markdown sections
+func IsTitled(doc string) bool {
+	return strings.HasPrefix(doc, "TITLE: ") || strings.Contains(doc, "\nTITLE: ")
+}
+
+// ParseSnippets splits doc into its snippets. Text before the first title
+// or heading becomes a snippet without a title.
+func ParseSnippets(doc string) []Snippet {
+	if IsTitled(doc) {
+		return parseTitled(doc)
+	}
+	return parseHeadings(doc)
+}
+
+func parseTitled(doc string) []Snippet {
+	var snippets []Snippet
+	for _, block := range strings.Split(doc, SnippetSeparator) {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		s := Snippet{Text: block}
+		for _, line := range strings.Split(block, "\n") {
+			if title, ok := strings.CutPrefix(line, "TITLE: "); ok {
+				s.Title = strings.TrimSpace(title)
+				break
+			}
+		}
+		snippets = append(snippets, s)
+	}
+	return snippets
+}
+
+func parseHeadings(doc string) []Snippet {
+	var snippets []Snippet
+	var current *Snippet
+	var lines []string
+
+	flush := func() {
+		if current != nil {
+			current.Text = strings.Join(lines, "\n")
+			snippets = append(snippets, *current)
+		}
+	}
+
+	for _, line := range strings.Split(doc, "\n") {
+		if level := headingLevel(line); level > 0 {
+			flush()
+			current = &Snippet{Title: strings.TrimSpace(line[level:]), Level: level}
+			lines = nil
+		} else if current == nil {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			current = &Snippet{}
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return snippets
+}
+
+// headingLevel returns 1 for "# " and 2 for "## " lines, otherwise 0
+func headingLevel(line string) int {
+	switch {
+	case strings.HasPrefix(line, "# "):
+		return 1
+	case strings.HasPrefix(line, "## "):
+		return 2
+	}
+	return 0
+}
+
+// SelectSections returns the parts of doc whose titles satisfy match. A
+// matching markdown section keeps its subsections. The result is empty
+// when nothing matches.
+func SelectSections(doc string, match func(title string) bool) string {
+	snippets := ParseSnippets(doc)
+
+	var selected []string
+	for i := 0; i < len(snippets); i++ {
+		s := snippets[i]
+		if s.Title == "" || !match(s.Title) {
+			continue
+		}
+		parts := []string{s.Text}
+		for s.Level > 0 && i+1 < len(snippets) && snippets[i+1].Level > s.Level {
+			i++
+			parts = append(parts, snippets[i].Text)
+		}
+		selected = append(selected, strings.TrimSpace(strings.Join(parts, "\n")))
+	}
+	return joinSnippets(doc, selected)
+}
+
+// Truncate keeps the leading snippets of doc that fit in maxBytes, so a
+// shortened document never ends mid-snippet. It returns "" when not even
+// the first snippet fits.
+func Truncate(doc string, maxBytes int) string {
+	if len(doc) <= maxBytes {
+		return doc
+	}
+
+	sep := len("\n\n")
+	if IsTitled(doc) {
+		sep = len("\n\n" + SnippetSeparator + "\n\n")
+	}
+	var kept []string
+	size := 0
+	for _, s := range ParseSnippets(doc) {
+		text := strings.TrimSpace(s.Text)
+		if size+len(text)+sep > maxBytes {
+			break
+		}
+		size += len(text) + sep
+		kept = append(kept, text)
+	}
+	return joinSnippets(doc, kept)
+}
+
+// joinSnippets reassembles selected parts of doc with the separator doc
+// uses between snippets
+func joinSnippets(doc string, selected []string) string {
+	if len(selected) == 0 {
+		return ""
+	}
+
+	sep := "\n\n"
+	if IsTitled(doc) {
+		sep = "\n\n" + SnippetSeparator + "\n\n"
+	}
+	return strings.Join(selected, sep) + "\n"
+}
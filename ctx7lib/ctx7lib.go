@@ -0,0 +1,211 @@
+// Package ctx7lib is the stable Go API for embedding ctx7: library
+// resolution against context7.com plus cached llms.txt fetching, without
+// shelling out to the CLI.
+package ctx7lib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/resolver"
+)
+
+// Library is a library search result from context7.com
+type Library = client.Library
+
+// Options configures a Client
+type Options struct {
+	// CacheDir is the cache location; defaults to the CLI's cache (cache.DefaultDir)
+	// so embedders share documents already fetched by ctx7
+	CacheDir string
+	// CacheBackend selects the storage backend ("files" or "sqlite")
+	CacheBackend string
+	// SharedCacheDirs are read-only caches searched beneath CacheDir, such as
+	// a system cache seeded in a container image; nothing is written to them
+	SharedCacheDirs []string
+	// DisableCache turns off all cache reads and writes
+	DisableCache bool
+	// MaxAge is how long cached documents are considered fresh (default 24h)
+	MaxAge time.Duration
+	// SearchMaxAge is how long search results are reused (default 1h)
+	SearchMaxAge time.Duration
+	// MaxStaleness refetches cached documents younger than MaxAge once they
+	// are this old, unless the library is known unchanged upstream
+	MaxStaleness time.Duration
+	// BaseURL points at a mirror or self-hosted context7 instance
+	BaseURL string
+	// CABundle is a PEM file of extra trusted certificates
+	CABundle string
+	// InsecureSkipVerify disables TLS certificate verification
+	InsecureSkipVerify bool
+	// ManifestURL and ManifestKey verify documents against a mirror's
+	// SHA-256 manifest and its minisign signature (see client.Options)
+	ManifestURL string
+	ManifestKey string
+	// SearchTimeout and FetchTimeout bound each search and download
+	// (default 5s and 5m)
+	SearchTimeout time.Duration
+	FetchTimeout  time.Duration
+	// Language hints the language of search queries, e.g. "ja"
+	Language string
+}
+
+// FetchOptions selects which document of a library to fetch
+type FetchOptions struct {
+	Version string
+	Branch  string
+	// Refresh bypasses the cache and always downloads
+	Refresh bool
+}
+
+// Document is fetched llms.txt content with its provenance
+type Document struct {
+	Library   Library
+	Version   string
+	Branch    string
+	Content   string
+	FromCache bool
+	FetchedAt time.Time
+}
+
+// Client resolves libraries and fetches their documentation
+type Client struct {
+	engine *engine.Engine
+	cache  *cache.Cache
+}
+
+// New creates a Client
+func New(opts Options) (*Client, error) {
+	var c *cache.Cache
+	if !opts.DisableCache {
+		dir := opts.CacheDir
+		if dir == "" {
+			defaultDir, err := cache.DefaultDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to locate cache directory: %w", err)
+			}
+			dir = defaultDir
+		}
+
+		var err error
+		c, err = cache.NewCacheWithShared(dir, cache.Backend(opts.CacheBackend), opts.SharedCacheDirs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	api, err := client.NewClientWithOptions(client.Options{
+		BaseURL:            opts.BaseURL,
+		CABundle:           opts.CABundle,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		ManifestURL:        opts.ManifestURL,
+		ManifestKey:        opts.ManifestKey,
+		SearchTimeout:      opts.SearchTimeout,
+		FetchTimeout:       opts.FetchTimeout,
+		Language:           opts.Language,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		engine: engine.New(engine.Options{
+			Client:       api,
+			Cache:        c,
+			NoCache:      opts.DisableCache,
+			MaxAge:       opts.MaxAge,
+			SearchMaxAge: opts.SearchMaxAge,
+			MaxStaleness: opts.MaxStaleness,
+		}),
+		cache: c,
+	}, nil
+}
+
+// Search returns every library matching query
+func (c *Client) Search(query string) ([]Library, error) {
+	return c.SearchContext(context.Background(), query)
+}
+
+// SearchContext is Search with a context that can abort the request
+func (c *Client) SearchContext(ctx context.Context, query string) ([]Library, error) {
+	return c.engine.Search(ctx, query)
+}
+
+// Resolve returns the best-matching library for query
+func (c *Client) Resolve(query string) (Library, error) {
+	return c.ResolveContext(context.Background(), query)
+}
+
+// ResolveContext is Resolve with a context that can abort the request
+func (c *Client) ResolveContext(ctx context.Context, query string) (Library, error) {
+	resolution, err := c.engine.Resolve(ctx, query)
+	if err != nil {
+		return Library{}, err
+	}
+	return resolution.Library, nil
+}
+
+// Fetch resolves query and fetches the matching library's documentation.
+// The query may name a version, e.g. react-router@6.4.0, when
+// opts.Version is empty.
+func (c *Client) Fetch(query string, opts FetchOptions) (*Document, error) {
+	return c.FetchContext(context.Background(), query, opts)
+}
+
+// FetchContext is Fetch with a context that can abort the requests
+func (c *Client) FetchContext(ctx context.Context, query string, opts FetchOptions) (*Document, error) {
+	r, err := resolver.Resolve(ctx, query, resolver.Options{Finder: c.engine, Version: opts.Version})
+	if err != nil {
+		return nil, err
+	}
+	opts.Version = r.Version
+	return c.FetchLibraryContext(ctx, r.Library, opts)
+}
+
+// FetchLibrary fetches documentation for an already-resolved library. The
+// version must be one the library lists, with or without a leading "v";
+// "latest" is the default document.
+func (c *Client) FetchLibrary(lib Library, opts FetchOptions) (*Document, error) {
+	return c.FetchLibraryContext(context.Background(), lib, opts)
+}
+
+// FetchLibraryContext is FetchLibrary with a context that can abort the request
+func (c *Client) FetchLibraryContext(ctx context.Context, lib Library, opts FetchOptions) (*Document, error) {
+	if opts.Version == "latest" {
+		opts.Version = ""
+	}
+	version, err := resolver.MatchVersion(lib, opts.Version)
+	if err != nil {
+		return nil, err
+	}
+	opts.Version = version
+	req := engine.Request{Library: lib, Version: opts.Version, Branch: opts.Branch}
+
+	var result *engine.Result
+	if opts.Refresh {
+		result, err = c.engine.Refresh(ctx, req)
+	} else {
+		result, err = c.engine.Fetch(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		Library:   lib,
+		Version:   opts.Version,
+		Branch:    opts.Branch,
+		Content:   result.Content,
+		FromCache: result.FromCache,
+		FetchedAt: result.Metadata.FetchedAt,
+	}, nil
+}
+
+// Cache returns the underlying cache, or nil when caching is disabled
+func (c *Client) Cache() *cache.Cache {
+	return c.cache
+}
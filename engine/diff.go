@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeSummary describes how a refreshed document differs from the cached copy it replaced
+type ChangeSummary struct {
+	AddedSections   int
+	RemovedSections int
+	// ChangedPercent is the share of lines that were added or removed (0-100)
+	ChangedPercent float64
+}
+
+// Unchanged reports whether the new document is identical to the old one
+func (c ChangeSummary) Unchanged() bool {
+	return c.AddedSections == 0 && c.RemovedSections == 0 && c.ChangedPercent == 0
+}
+
+// String renders the summary, e.g. "+12 sections, -3 sections, content changed 8%"
+func (c ChangeSummary) String() string {
+	if c.Unchanged() {
+		return "unchanged"
+	}
+
+	var parts []string
+	if c.AddedSections > 0 {
+		parts = append(parts, fmt.Sprintf("+%d sections", c.AddedSections))
+	}
+	if c.RemovedSections > 0 {
+		parts = append(parts, fmt.Sprintf("-%d sections", c.RemovedSections))
+	}
+	if c.ChangedPercent > 0 && c.ChangedPercent < 1 {
+		parts = append(parts, "content changed <1%")
+	} else {
+		parts = append(parts, fmt.Sprintf("content changed %.0f%%", c.ChangedPercent))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Diff compares two versions of a document by section titles and lines
+func Diff(oldContent, newContent string) ChangeSummary {
+	var summary ChangeSummary
+
+	oldSections := countTitles(oldContent)
+	newSections := countTitles(newContent)
+	for title, n := range newSections {
+		if extra := n - oldSections[title]; extra > 0 {
+			summary.AddedSections += extra
+		}
+	}
+	for title, n := range oldSections {
+		if missing := n - newSections[title]; missing > 0 {
+			summary.RemovedSections += missing
+		}
+	}
+
+	// Line multiset difference: lines present in one version but not the other
+	oldLines := strings.Split(oldContent, "\n")
+	newLines := strings.Split(newContent, "\n")
+	counts := make(map[string]int, len(oldLines))
+	for _, line := range oldLines {
+		counts[line]++
+	}
+	for _, line := range newLines {
+		counts[line]--
+	}
+
+	changed := 0
+	for _, n := range counts {
+		if n < 0 {
+			n = -n
+		}
+		changed += n
+	}
+	if total := len(oldLines) + len(newLines); total > 0 {
+		summary.ChangedPercent = float64(changed) / float64(total) * 100
+	}
+
+	return summary
+}
+
+// countTitles counts llms.txt snippet titles and markdown headings
+func countTitles(content string) map[string]int {
+	titles := make(map[string]int)
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "TITLE: ") || strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "## ") {
+			titles[line]++
+		}
+	}
+	return titles
+}
@@ -0,0 +1,652 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hsbacot/ctx7/alias"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/learn"
+)
+
+// DefaultMaxAge is how long cached documents are served without refetching
+const DefaultMaxAge = 24 * time.Hour
+
+// DefaultSearchMaxAge is how long cached search results are reused; new
+// libraries and versions show up in searches well before documents change
+const DefaultSearchMaxAge = time.Hour
+
+// ErrNoLibraries is returned when a search matches no library
+var ErrNoLibraries = errors.New("no libraries found")
+
+// Client is the subset of the context7 API client the engine depends on.
+// *client.Client satisfies it; tests can substitute a fake.
+type Client interface {
+	client.Searcher
+	client.Fetcher
+}
+
+// Streamer is implemented by clients that can write a document as it
+// downloads; other clients are buffered by RefreshTo
+type Streamer interface {
+	StreamLLMsTxt(ctx context.Context, libraryID string, opts client.FetchOptions, w io.Writer) (int64, error)
+}
+
+// Options configures an Engine
+type Options struct {
+	// Client overrides the context7 API client (defaults to client.NewClient())
+	Client Client
+	// Searcher and Fetcher, if set, replace the client for searches or for
+	// downloads only, e.g. to search a fixture set but download for real.
+	// Streaming and section patching are used when the Fetcher supports them.
+	Searcher client.Searcher
+	Fetcher  client.Fetcher
+	// Cache is optional; without it every fetch goes to the network
+	Cache *cache.Cache
+	// NoCache skips cache reads and writes entirely
+	NoCache bool
+	// MaxAge is the cache TTL (defaults to DefaultMaxAge)
+	MaxAge time.Duration
+	// SearchMaxAge is the TTL of cached search results (defaults to DefaultSearchMaxAge)
+	SearchMaxAge time.Duration
+	// StaleOK serves an expired cached document when refreshing it fails,
+	// e.g. while offline
+	StaleOK bool
+	// MaxStaleness, if set, refetches a cached document within the TTL once
+	// it is older than this, unless the library is known not to have
+	// changed upstream since (see cache.Staleness)
+	MaxStaleness time.Duration
+	// Learned, if set, ranks the libraries picked for a query before first
+	Learned *learn.Store
+	// Aliases, if set, resolves the user's names for libraries without a
+	// search, before the registry
+	Aliases *alias.Store
+	// Registry, if set, resolves well-known library names without a search
+	Registry func(name string) (client.Library, bool)
+	// Filter drops search results below a minimum quality; the cached
+	// results are kept whole
+	Filter Filter
+	// Ranker, if set, orders search results in place of the learned
+	// libraries first
+	Ranker Ranker
+	// Trace, if set, records each decision for --explain
+	Trace *Trace
+	// Metrics, if set, adds up the time and bytes of each stage
+	Metrics *Metrics
+	// OnRegression, if set, decides what a refresh does with a document
+	// much smaller than the cached copy, or older (see Regression); without
+	// it the cached copy is always replaced
+	OnRegression func(req Request, r Regression) RegressionAction
+	// Clock overrides the time source used for cache metadata (defaults to time.Now)
+	Clock func() time.Time
+}
+
+// Engine runs the search → select → cache → fetch pipeline synchronously.
+// The TUI drives it from tea commands; headless modes call it directly.
+// Network calls take a context so callers can abort them mid-flight.
+type Engine struct {
+	searcher     client.Searcher
+	fetcher      client.Fetcher
+	searchScope  cache.SearchScope
+	baseURL      string // Of the fetcher, recorded with the documents it downloads
+	cache        *cache.Cache
+	noCache      bool
+	maxAge       time.Duration
+	searchMaxAge time.Duration
+	staleOK      bool
+	maxStaleness time.Duration
+	learned      *learn.Store
+	aliases      *alias.Store
+	registry     func(name string) (client.Library, bool)
+	minimum      Filter
+	ranker       Ranker
+	trace        *Trace
+	metrics      *Metrics
+	onRegression func(req Request, r Regression) RegressionAction
+	now          func() time.Time
+}
+
+// Request identifies a single document: a library plus optional version or branch
+type Request struct {
+	Library client.Library
+	Version string
+	Branch  string
+	// Topic narrows the document server-side. Topic-scoped documents are
+	// cached as variants of the full document so they never mask it.
+	Topic string
+	// Progress, if set, is called as the document downloads
+	Progress client.ProgressFunc
+}
+
+// DocumentID returns the path used to fetch the document from context7
+func (r Request) DocumentID() string {
+	if r.Version != "" && r.Branch == "" {
+		return fmt.Sprintf("%s/%s", r.Library.ID, r.Version)
+	}
+	return r.Library.ID
+}
+
+// fetchOptions returns the client options for the request
+func (r Request) fetchOptions() client.FetchOptions {
+	return client.FetchOptions{
+		Branch:   r.Branch,
+		Topic:    r.Topic,
+		Progress: r.Progress,
+	}
+}
+
+// metadata describes the document as cached at fetchedAt
+func (r Request) metadata(fetchedAt time.Time) cache.Metadata {
+	return cache.Metadata{
+		LibraryID:      r.Library.ID,
+		Title:          r.Library.Title,
+		Version:        r.Version,
+		Branch:         r.Branch,
+		FetchedAt:      fetchedAt,
+		LastUpdateDate: r.Library.LastUpdateDate,
+		TotalTokens:    r.Library.TotalTokens,
+		TotalSnippets:  r.Library.TotalSnippets,
+		Stars:          r.Library.Stars,
+		TrustScore:     r.Library.TrustScore,
+		Versions:       r.Library.Versions,
+		Topic:          r.Topic,
+	}
+}
+
+// CacheKey returns the cache version key for the request
+func (r Request) CacheKey() string {
+	return cache.VariantKey(cache.RefKey(r.Version, r.Branch), r.Topic)
+}
+
+// label names the document in traces, e.g. "/vercel/next.js@v14 (topic routing)"
+func (r Request) label() string {
+	label := r.Library.ID + "@latest"
+	switch {
+	case r.Branch != "":
+		label = fmt.Sprintf("%s (branch %s)", r.Library.ID, r.Branch)
+	case r.Version != "":
+		label = r.Library.ID + "@" + r.Version
+	}
+	if r.Topic != "" {
+		label += fmt.Sprintf(" (topic %s)", r.Topic)
+	}
+	return label
+}
+
+// Result is a fetched document
+type Result struct {
+	Content   string
+	FromCache bool
+	Metadata  cache.Metadata
+	// Changes compares a refreshed document with the cached copy it
+	// replaced; nil when nothing was cached before
+	Changes *ChangeSummary
+	// Stale is the refresh error a cached copy was served in place of
+	// (see Options.StaleOK); nil otherwise
+	Stale error
+	// Regression is set when the refreshed document looked worse than the
+	// cached copy; FromCache tells whether that copy was kept
+	Regression *Regression
+}
+
+// Resolution is the outcome of resolving a query to a library
+type Resolution struct {
+	// Library is the best match (the first search result)
+	Library client.Library
+	// Results holds every candidate, in API order
+	Results []client.Library
+}
+
+// New creates an engine
+func New(opts Options) *Engine {
+	var c Client = client.NewClient()
+	if opts.Client != nil {
+		c = opts.Client
+	}
+	var searcher client.Searcher = c
+	if opts.Searcher != nil {
+		searcher = opts.Searcher
+	}
+	var fetcher client.Fetcher = c
+	if opts.Fetcher != nil {
+		fetcher = opts.Fetcher
+	}
+
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	searchMaxAge := opts.SearchMaxAge
+	if searchMaxAge <= 0 {
+		searchMaxAge = DefaultSearchMaxAge
+	}
+
+	now := time.Now
+	if opts.Clock != nil {
+		now = opts.Clock
+	}
+
+	var scope cache.SearchScope
+	if o, ok := searcher.(Origin); ok {
+		scope = cache.SearchScope{BaseURL: o.BaseURL(), Lang: o.Language()}
+	}
+	baseURL := client.DefaultBaseURL
+	if o, ok := fetcher.(Origin); ok {
+		baseURL = o.BaseURL()
+	}
+
+	return &Engine{
+		searcher:     searcher,
+		fetcher:      fetcher,
+		searchScope:  scope,
+		baseURL:      baseURL,
+		cache:        opts.Cache,
+		noCache:      opts.NoCache,
+		maxAge:       maxAge,
+		searchMaxAge: searchMaxAge,
+		staleOK:      opts.StaleOK,
+		maxStaleness: opts.MaxStaleness,
+		learned:      opts.Learned,
+		aliases:      opts.Aliases,
+		registry:     opts.Registry,
+		minimum:      opts.Filter,
+		ranker:       opts.Ranker,
+		trace:        opts.Trace,
+		metrics:      opts.Metrics,
+		onRegression: opts.OnRegression,
+		now:          now,
+	}
+}
+
+// Search returns every library matching the query that passes the Filter,
+// reusing cached results younger than the search TTL. Libraries learned
+// for the query come first.
+func (e *Engine) Search(ctx context.Context, query string) ([]client.Library, error) {
+	useCache := e.cache != nil && !e.noCache
+	if useCache {
+		var cached []client.Library
+		start := e.now()
+		err := e.cache.GetCachedSearchResults(query, e.searchScope, e.searchMaxAge, &cached)
+		e.metrics.Observe(StageCacheRead, e.now().Sub(start), 0)
+		if err == nil && len(cached) > 0 {
+			e.trace.Add("search", "%d results for %q from the search cache (TTL %s)", len(cached), query, shortDuration(e.searchMaxAge))
+			return e.rank(query, e.filter(cached)), nil
+		}
+	}
+
+	start := e.now()
+	results, err := e.searcher.SearchLibraries(ctx, query)
+	e.metrics.Observe(StageSearch, e.now().Sub(start), 0)
+	if err != nil {
+		return nil, err
+	}
+	e.trace.Add("search", "%d results for %q from the search API", len(results), query)
+	if useCache && len(results) > 0 {
+		start := e.now()
+		_ = e.cache.CacheSearchResults(query, e.searchScope, results)
+		e.metrics.Observe(StageCacheWrite, e.now().Sub(start), 0)
+	}
+	return e.rank(query, e.filter(results)), nil
+}
+
+// rank orders results by the Ranker, or moves the libraries learned for
+// query to the front
+func (e *Engine) rank(query string, results []client.Library) []client.Library {
+	now := e.now()
+	ranked, scores := e.learned.Boost(query, results, now), []float64(nil)
+	detail := "search API order"
+	if e.ranker != nil && len(results) > 0 {
+		var ok bool
+		if ranked, scores, ok = e.rankBy(query, results, now); ok {
+			detail = fmt.Sprintf("ranked by %v", e.ranker)
+		}
+	}
+	if e.trace == nil || len(ranked) == 0 {
+		return ranked
+	}
+
+	apiRank := make(map[string]int, len(results))
+	for i, lib := range results {
+		apiRank[lib.ID] = i + 1
+	}
+	step := Step{Stage: "rank", Detail: detail}
+	for i, lib := range ranked {
+		c := Candidate{
+			ID:             lib.ID,
+			Title:          lib.Title,
+			APIRank:        apiRank[lib.ID],
+			Learned:        e.learned.Score(query, lib.ID, now),
+			TrustScore:     lib.TrustScore,
+			BenchmarkScore: lib.BenchmarkScore,
+			Stars:          lib.Stars,
+		}
+		if scores != nil {
+			c.Score = scores[i]
+		} else if c.APIRank != i+1 {
+			step.Detail = "libraries learned for the query first, then search API order"
+		}
+		step.Candidates = append(step.Candidates, c)
+	}
+	e.trace.add(step)
+	return ranked
+}
+
+// Known returns the library an alias or the registry resolves query to.
+// An alias always wins; over the registry, a library learned for the
+// query takes precedence, so Known fails when it differs.
+func (e *Engine) Known(query string) (client.Library, bool) {
+	if lib, ok := e.aliases.Lookup(query); ok {
+		e.trace.Add("alias", "%q is your alias for %s; no search needed", learn.Normalize(query), lib.ID)
+		return lib, true
+	}
+	if e.registry == nil {
+		e.trace.Add("registry", "not used (--no-registry or a custom base URL)")
+		return client.Library{}, false
+	}
+	lib, ok := e.registry(query)
+	if !ok {
+		e.trace.Add("registry", "no bundled library is named %q", learn.Normalize(query))
+		return client.Library{}, false
+	}
+	if top, learned := e.learned.Top(query, e.now()); learned && top != lib.ID {
+		e.trace.Add("registry", "%q is bundled as %s, but %s was learned for it", learn.Normalize(query), lib.ID, top)
+		return client.Library{}, false
+	}
+	e.trace.Add("registry", "%q is bundled as %s; no search needed", learn.Normalize(query), lib.ID)
+	return lib, true
+}
+
+// Resolve picks the best match for query, from the registry when it knows
+// the name and otherwise by searching
+func (e *Engine) Resolve(ctx context.Context, query string) (*Resolution, error) {
+	if lib, ok := e.Known(query); ok {
+		return &Resolution{Library: lib, Results: []client.Library{lib}}, nil
+	}
+
+	results, err := e.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, ErrNoLibraries
+	}
+
+	return &Resolution{Library: results[0], Results: results}, nil
+}
+
+// Cached returns the cached document for req if it exists and is still valid
+func (e *Engine) Cached(req Request) (*Result, bool) {
+	if e.cache == nil || e.noCache {
+		e.trace.Add("cache", "not used (--no-cache)")
+		return nil, false
+	}
+
+	start := e.now()
+	entry, err := e.entry(req)
+	if err != nil {
+		e.metrics.Observe(StageCacheRead, e.now().Sub(start), 0)
+		e.cache.RecordMiss()
+		var other *otherOriginError
+		if errors.As(err, &other) {
+			e.trace.Add("cache", "miss: %s is cached from %s, not %s", req.label(), client.HostOf(other.baseURL), client.HostOf(e.baseURL))
+		} else {
+			e.trace.Add("cache", "miss: %s is not cached", req.label())
+		}
+		return nil, false
+	}
+	e.metrics.Observe(StageCacheRead, e.now().Sub(start), int64(len(entry.Content)))
+	if entry.Expired {
+		e.cache.RecordMiss()
+		e.trace.Add("cache", "stale: %s fetched %s ago, past the %s TTL", req.label(), shortDuration(e.now().Sub(entry.Metadata.FetchedAt).Round(time.Second)), shortDuration(e.maxAge))
+		return nil, false
+	}
+	if e.maxStaleness > 0 {
+		upstream, _ := time.Parse(time.RFC3339, req.Library.LastUpdateDate)
+		if behind := cache.Staleness(entry.Metadata.FetchedAt, upstream, e.now()); behind > e.maxStaleness {
+			why := "no upstream update date to compare"
+			if !upstream.IsZero() {
+				why = fmt.Sprintf("context7 updated it %s after the fetch", shortDuration(upstream.Sub(entry.Metadata.FetchedAt).Round(time.Second)))
+			}
+			e.cache.RecordMiss()
+			e.trace.Add("cache", "stale: %s fetched %s ago, past the %s staleness limit (%s)", req.label(), shortDuration(behind.Round(time.Second)), shortDuration(e.maxStaleness), why)
+			return nil, false
+		}
+	}
+	e.cache.RecordHit(req.Library.ID, int64(len(entry.Content)))
+	e.trace.Add("cache", "hit: %s fetched %s ago, within the %s TTL", req.label(), shortDuration(e.now().Sub(entry.Metadata.FetchedAt).Round(time.Second)), shortDuration(e.maxAge))
+
+	return &Result{Content: entry.Content, FromCache: true, Metadata: entry.Metadata}, true
+}
+
+// Fetch returns the cached document when valid, otherwise downloads and caches it
+func (e *Engine) Fetch(ctx context.Context, req Request) (*Result, error) {
+	if result, ok := e.Cached(req); ok {
+		return result, nil
+	}
+	return e.Refresh(ctx, req)
+}
+
+// Prefetch downloads and caches req's document ahead of it being asked
+// for, unless a valid copy is cached already. Its cache lookup counts as
+// neither a hit nor a miss; without a cache there is nowhere to keep the
+// document, so nothing is downloaded.
+func (e *Engine) Prefetch(ctx context.Context, req Request) error {
+	if e.cache == nil || e.noCache {
+		return nil
+	}
+	if entry, err := e.entry(req); err == nil && !entry.Expired {
+		return nil
+	}
+	e.trace.Add("fetch", "prefetching %s", req.label())
+	_, err := e.Refresh(ctx, req)
+	return err
+}
+
+// Refresh always downloads the document and replaces any cached copy
+func (e *Engine) Refresh(ctx context.Context, req Request) (*Result, error) {
+	start := e.now()
+	if doc, previous, p, ok := e.patch(ctx, req); ok {
+		return e.patched(req, doc, previous, p, e.now().Sub(start)), nil
+	}
+
+	content, err := e.fetcher.FetchLLMsTxt(ctx, req.DocumentID(), req.fetchOptions())
+	if err == nil && isPlaceholder(content) {
+		err = ErrNoDocumentation
+	}
+	if err != nil {
+		if result, ok := e.staleFallback(ctx, req, err); ok {
+			return result, nil
+		}
+		return nil, err
+	}
+	elapsed := e.now().Sub(start)
+	e.metrics.Observe(StageDownload, elapsed, int64(len(content)))
+	e.trace.Add("fetch", "downloaded %s (%d bytes) in %s", req.label(), len(content), elapsed.Round(time.Millisecond))
+
+	metadata := e.metadata(req, e.now())
+
+	result := &Result{Content: content, Metadata: metadata}
+
+	// Cache the result
+	if e.cache != nil && !e.noCache {
+		if previous, err := e.lookup(req); err == nil {
+			r, keep := e.checkRegression(req, previous, content)
+			if keep {
+				e.cache.RecordFetch(req.Library.ID, int64(len(content)), elapsed)
+				return kept(previous, r), nil
+			}
+			result.Regression = r
+			changes := Diff(previous.Content, content)
+			result.Changes = &changes
+		}
+		start := e.now()
+		_ = e.cache.SetWithVersion(req.Library.ID, req.CacheKey(), content, metadata)
+		e.metrics.Observe(StageCacheWrite, e.now().Sub(start), int64(len(content)))
+		e.cache.RecordFetch(req.Library.ID, int64(len(content)), elapsed)
+	}
+
+	return result, nil
+}
+
+// RefreshTo downloads the document like Refresh but writes it to w instead
+// of returning it: the body is streamed into the cache and then copied to
+// w, so it is never held in memory whole. Result.Content is left empty and
+// no change summary is computed. Nothing reaches w until the whole body
+// has arrived, so a cancelled or failed download never leaves partial
+// content behind. A document patched from the cached copy is the
+// exception, and so is a document that has to be compared with the cached
+// copy for OnRegression: both are assembled in memory.
+func (e *Engine) RefreshTo(ctx context.Context, req Request, w io.Writer) (*Result, error) {
+	if e.comparesWithCached(req) {
+		result, err := e.Refresh(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, result.Content); err != nil {
+			return nil, fmt.Errorf("failed to write document: %w", err)
+		}
+		result.Content = ""
+		return result, nil
+	}
+
+	start := e.now()
+	if doc, previous, p, ok := e.patch(ctx, req); ok {
+		result := e.patched(req, doc, previous, p, e.now().Sub(start))
+		if _, err := io.WriteString(w, result.Content); err != nil {
+			return nil, fmt.Errorf("failed to write document: %w", err)
+		}
+		result.Content = ""
+		return result, nil
+	}
+	metadata := e.metadata(req, start)
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := e.stream(ctx, req, pw)
+		pw.CloseWithError(err)
+	}()
+
+	body, err := checkStream(pr)
+	if err != nil {
+		return e.staleTo(ctx, req, err, w)
+	}
+
+	if e.cache == nil || e.noCache {
+		// Nothing to write through; spool to a temp file instead
+		if err := spool(body, w); err != nil {
+			return nil, err
+		}
+		e.metrics.Observe(StageDownload, e.now().Sub(start), 0)
+		e.trace.Add("fetch", "streamed %s in %s", req.label(), e.now().Sub(start).Round(time.Millisecond))
+		return &Result{Metadata: metadata}, nil
+	}
+
+	size, err := e.cache.SetWithVersionFrom(req.Library.ID, req.CacheKey(), body, metadata)
+	if err != nil {
+		// A failed write leaves the previous copy in place
+		return e.staleTo(ctx, req, err, w)
+	}
+	// The body is written to the cache as it arrives, so the write is
+	// part of the download
+	e.metrics.Observe(StageDownload, e.now().Sub(start), size)
+	e.trace.Add("fetch", "streamed %s (%d bytes) in %s", req.label(), size, e.now().Sub(start).Round(time.Millisecond))
+	e.cache.RecordFetch(req.Library.ID, size, e.now().Sub(start))
+
+	readStart := e.now()
+	cached, err := e.cache.OpenWithVersion(req.Library.ID, req.CacheKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read back cached document: %w", err)
+	}
+	defer cached.Close()
+
+	if _, err := io.Copy(w, cached); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+	e.metrics.Observe(StageCacheRead, e.now().Sub(readStart), size)
+	return &Result{Metadata: metadata}, nil
+}
+
+// staleFallback returns the cached copy of req, however old, in place of a
+// refresh that failed with err, when StaleOK allows it. A cancelled request
+// is never answered from the cache.
+func (e *Engine) staleFallback(ctx context.Context, req Request, err error) (*Result, bool) {
+	if !e.staleOK || ctx.Err() != nil {
+		return nil, false
+	}
+	result, ok := e.Stale(req, err)
+	if ok {
+		e.trace.Add("cache", "refresh failed (%v); serving %s fetched %s ago", err, req.label(), shortDuration(e.now().Sub(result.Metadata.FetchedAt).Round(time.Second)))
+	}
+	return result, ok
+}
+
+// Stale returns the cached copy of req however old, to serve in place of a
+// refresh that failed with err; it reports false when nothing is cached
+func (e *Engine) Stale(req Request, err error) (*Result, bool) {
+	if e.cache == nil || e.noCache {
+		return nil, false
+	}
+	entry, lookupErr := e.entry(req)
+	if lookupErr != nil {
+		return nil, false
+	}
+	return &Result{Content: entry.Content, FromCache: true, Stale: err, Metadata: entry.Metadata}, true
+}
+
+// staleTo is staleFallback for RefreshTo: the cached copy is written to w,
+// or err returned when there is none to serve
+func (e *Engine) staleTo(ctx context.Context, req Request, err error, w io.Writer) (*Result, error) {
+	result, ok := e.staleFallback(ctx, req, err)
+	if !ok {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, result.Content); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+	result.Content = ""
+	return result, nil
+}
+
+// spool copies r to w through a temp file once r is read to the end
+func spool(r io.Reader, w io.Writer) error {
+	f, err := os.CreateTemp("", "ctx7-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to write document: %w", err)
+	}
+	return nil
+}
+
+// stream writes req's document to w, buffering it first when the client
+// can't stream
+func (e *Engine) stream(ctx context.Context, req Request, w io.Writer) (int64, error) {
+	if s, ok := e.fetcher.(Streamer); ok {
+		return s.StreamLLMsTxt(ctx, req.DocumentID(), req.fetchOptions(), w)
+	}
+
+	content, err := e.fetcher.FetchLLMsTxt(ctx, req.DocumentID(), req.fetchOptions())
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(w, content)
+	return int64(n), err
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Filter drops search results below a minimum quality, such as forks and
+// mirrors of popular libraries. The zero Filter keeps everything.
+type Filter struct {
+	// MinStars drops libraries with fewer GitHub stars
+	MinStars int
+	// MinTrust drops libraries with a lower trust score
+	MinTrust float64
+	// ExcludeDeprecated drops libraries context7 marks deprecated
+	ExcludeDeprecated bool
+}
+
+// reject returns why lib falls short of the filter, or "" to keep it
+func (f Filter) reject(lib client.Library) string {
+	switch {
+	case lib.Stars < f.MinStars:
+		return fmt.Sprintf("%d stars, under %d", lib.Stars, f.MinStars)
+	case lib.TrustScore < f.MinTrust:
+		return fmt.Sprintf("trust %.1f, under %.1f", lib.TrustScore, f.MinTrust)
+	case f.ExcludeDeprecated && lib.Deprecated():
+		return "deprecated"
+	}
+	return ""
+}
+
+// filter drops the results that fall short of the engine's Filter
+func (e *Engine) filter(results []client.Library) []client.Library {
+	if e.minimum == (Filter{}) {
+		return results
+	}
+	kept := make([]client.Library, 0, len(results))
+	for _, lib := range results {
+		if why := e.minimum.reject(lib); why != "" {
+			e.trace.Add("search", "dropped %s (%s)", lib.ID, why)
+			continue
+		}
+		kept = append(kept, lib)
+	}
+	return kept
+}
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/resolver"
+)
+
+// Lookup is the outcome of the whole pipeline for one query
+type Lookup struct {
+	Resolution
+	// Request is the document fetched: the best match at the version the
+	// query named
+	Request Request
+	Result  *Result
+}
+
+// Get runs the search → select → cache → fetch pipeline for query without
+// anyone to ask: the query is resolved (see resolver.Resolve) and the
+// document served from the cache when valid, downloaded otherwise. The
+// query may name a version, e.g. react-router@6.4.0.
+func (e *Engine) Get(ctx context.Context, query string) (*Lookup, error) {
+	r, err := resolver.Resolve(ctx, query, resolver.Options{Finder: e})
+	if err != nil {
+		return nil, err
+	}
+
+	req := Request{Library: r.Library, Version: r.Version}
+	result, err := e.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Lookup{Resolution: Resolution{Library: r.Library, Results: r.Results}, Request: req, Result: result}, nil
+}
+
+// Find is Resolve as a resolver.Finder
+func (e *Engine) Find(ctx context.Context, name string) (client.Library, []client.Library, error) {
+	resolution, err := e.Resolve(ctx, name)
+	if err != nil {
+		return client.Library{}, nil, err
+	}
+	return resolution.Library, resolution.Results, nil
+}
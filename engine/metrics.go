@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages of the pipeline timed by Metrics
+const (
+	StageSearch     = "search"
+	StageDownload   = "download"
+	StageCacheRead  = "cache_read"
+	StageCacheWrite = "cache_write"
+)
+
+// stages lists the stages in the order they are reported
+var stages = []string{StageSearch, StageDownload, StageCacheRead, StageCacheWrite}
+
+// Metrics adds up how long each stage of the pipeline took and how many
+// bytes it moved, across every lookup of an engine: the verbose summary of
+// a run and the /metrics endpoint of ctx7 serve read it. A nil *Metrics
+// records nothing.
+type Metrics struct {
+	mu     sync.Mutex
+	totals map[string]StageTotal
+}
+
+// StageTotal is what one stage added up to
+type StageTotal struct {
+	Stage    string
+	Calls    int
+	Duration time.Duration
+	Bytes    int64
+}
+
+// Observe records one call of stage that took d and moved n bytes
+func (m *Metrics) Observe(stage string, d time.Duration, n int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.totals == nil {
+		m.totals = make(map[string]StageTotal)
+	}
+	t := m.totals[stage]
+	t.Calls++
+	t.Duration += d
+	t.Bytes += n
+	m.totals[stage] = t
+}
+
+// Totals returns the totals of every stage, in pipeline order, including
+// stages never observed
+func (m *Metrics) Totals() []StageTotal {
+	totals := make([]StageTotal, len(stages))
+	if m != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	for i, stage := range stages {
+		if m != nil {
+			totals[i] = m.totals[stage]
+		}
+		totals[i].Stage = stage
+	}
+	return totals
+}
+
+// WriteSummary writes the totals as a one-line footer ending with the
+// run's total time, leaving out the stages that never ran
+func (m *Metrics) WriteSummary(w io.Writer, total time.Duration) {
+	var parts []string
+	for _, t := range m.Totals() {
+		if t.Calls == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s %dms", strings.ReplaceAll(t.Stage, "_", " "), t.Duration.Milliseconds())
+		if t.Bytes > 0 {
+			part += fmt.Sprintf(" (%d bytes)", t.Bytes)
+		}
+		if t.Calls > 1 {
+			part += fmt.Sprintf(" ×%d", t.Calls)
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, fmt.Sprintf("total %dms", total.Milliseconds()))
+	fmt.Fprintf(w, "metrics: %s\n", strings.Join(parts, " • "))
+}
+
+// WritePrometheus writes the totals in the Prometheus text exposition
+// format
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	totals := m.Totals()
+	metric := func(name, help string, value func(StageTotal) string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, t := range totals {
+			fmt.Fprintf(w, "%s{stage=%q} %s\n", name, t.Stage, value(t))
+		}
+	}
+	metric("ctx7_stage_calls_total", "Calls of each stage of the pipeline.", func(t StageTotal) string {
+		return fmt.Sprint(t.Calls)
+	})
+	metric("ctx7_stage_seconds_total", "Time spent in each stage of the pipeline.", func(t StageTotal) string {
+		return fmt.Sprint(t.Duration.Seconds())
+	})
+	metric("ctx7_stage_bytes_total", "Bytes moved by each stage of the pipeline.", func(t StageTotal) string {
+		return fmt.Sprint(t.Bytes)
+	})
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Origin is implemented by clients that can tell which context7 instance
+// they query and the language hint they search with, so what one instance
+// or language answered is not served for another
+type Origin interface {
+	BaseURL() string
+	Language() string
+}
+
+// otherOriginError is returned for a cached document downloaded from
+// another context7 instance than the engine's
+type otherOriginError struct {
+	baseURL string
+}
+
+func (e *otherOriginError) Error() string {
+	return fmt.Sprintf("cached from %s", e.baseURL)
+}
+
+// Host returns the host name of the instance documents are downloaded
+// from, e.g. "context7.com", for messages
+func (e *Engine) Host() string {
+	return client.HostOf(e.baseURL)
+}
+
+// SameOrigin reports whether a cached document was downloaded from the
+// engine's instance
+func (e *Engine) SameOrigin(metadata cache.Metadata) bool {
+	return e.checkOrigin(metadata) == nil
+}
+
+// entry reads req's cached document, treating a copy from another
+// instance as missing
+func (e *Engine) entry(req Request) (*cache.CacheEntry, error) {
+	entry, err := e.cache.GetEntry(req.Library.ID, req.CacheKey(), e.maxAge)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkOrigin(entry.Metadata); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// lookup is entry for cache.Lookup, whatever the entry's age
+func (e *Engine) lookup(req Request) (*cache.CacheEntry, error) {
+	entry, err := e.cache.Lookup(req.Library.ID, req.CacheKey())
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkOrigin(entry.Metadata); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// checkOrigin returns an otherOriginError unless metadata describes a
+// document downloaded from the engine's instance
+func (e *Engine) checkOrigin(metadata cache.Metadata) error {
+	if from := metadataOrigin(metadata); from != e.baseURL {
+		return &otherOriginError{baseURL: from}
+	}
+	return nil
+}
+
+// metadataOrigin returns the instance a cached document came from
+func metadataOrigin(metadata cache.Metadata) string {
+	if metadata.BaseURL == "" {
+		return client.DefaultBaseURL
+	}
+	return metadata.BaseURL
+}
+
+// metadata describes req's document as downloaded from the engine's
+// instance at fetchedAt
+func (e *Engine) metadata(req Request, fetchedAt time.Time) cache.Metadata {
+	metadata := req.metadata(fetchedAt)
+	metadata.BaseURL = e.baseURL
+	return metadata
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/content"
+)
+
+// SectionFetcher is implemented by clients that can download the sections
+// of a document one by one, from servers that index them. Refreshes then
+// download only the sections that changed since the cached copy.
+type SectionFetcher interface {
+	FetchSectionIndex(ctx context.Context, libraryID string, opts client.FetchOptions) ([]string, error)
+	FetchSection(ctx context.Context, libraryID, digest string, opts client.FetchOptions) (string, error)
+	VerifyLLMsTxt(ctx context.Context, libraryID string, opts client.FetchOptions, doc string) error
+}
+
+// patch rebuilds req's document from its cached copy and the sections that
+// changed since, when the server indexes sections. ok is false when the
+// whole document has to be downloaded instead; previous is the cached copy
+// patched.
+func (e *Engine) patch(ctx context.Context, req Request) (doc string, previous *cache.CacheEntry, p cache.Patch, ok bool) {
+	fetcher, isFetcher := e.fetcher.(SectionFetcher)
+	// Topic results are generated per request, so they have no stable sections
+	if !isFetcher || e.cache == nil || e.noCache || req.Topic != "" {
+		return "", nil, p, false
+	}
+	start := e.now()
+	previous, err := e.lookup(req)
+	if err != nil {
+		return "", nil, p, false
+	}
+	e.metrics.Observe(StageCacheRead, e.now().Sub(start), int64(len(previous.Content)))
+
+	start = e.now()
+	defer func() {
+		// Servers without a section index weren't downloaded from
+		if ok || p.Fetched > 0 {
+			e.metrics.Observe(StageDownload, e.now().Sub(start), p.Bytes)
+		}
+	}()
+	index, err := fetcher.FetchSectionIndex(ctx, req.DocumentID(), req.fetchOptions())
+	if err != nil {
+		if !errors.Is(err, client.ErrNoSections) {
+			e.trace.Add("patch", "no section index (%v); downloading the whole document", err)
+		}
+		return "", nil, p, false
+	}
+
+	cached := make(map[string]string)
+	for _, section := range content.SplitSections(previous.Content) {
+		cached[content.SectionDigest(section)] = section
+	}
+
+	var b strings.Builder
+	used := make(map[string]bool)
+	for _, digest := range index {
+		digest = strings.ToLower(digest)
+		section, have := cached[digest]
+		if have {
+			p.Reused++
+		} else {
+			section, err = fetcher.FetchSection(ctx, req.DocumentID(), digest, req.fetchOptions())
+			if err != nil {
+				e.trace.Add("patch", "failed (%v); downloading the whole document", err)
+				return "", nil, p, false
+			}
+			p.Fetched++
+			p.Bytes += int64(len(section))
+		}
+		used[digest] = true
+		b.WriteString(section)
+	}
+	for digest := range cached {
+		if !used[digest] {
+			p.Dropped++
+		}
+	}
+
+	doc = b.String()
+	if err := fetcher.VerifyLLMsTxt(ctx, req.DocumentID(), req.fetchOptions(), doc); err != nil {
+		e.trace.Add("patch", "patched document failed verification (%v); downloading the whole document", err)
+		return "", nil, p, false
+	}
+	p.PatchedAt = e.now()
+	p.Size = int64(len(doc))
+	e.trace.Add("patch", "%s: reused %d of %d sections, downloaded %d (%d of %d bytes)", req.label(), p.Reused, len(index), p.Fetched, p.Bytes, p.Size)
+	return doc, previous, p, true
+}
+
+// patched caches a document rebuilt by patch in elapsed, adding p to the
+// patch history of the copy it replaces
+func (e *Engine) patched(req Request, doc string, previous *cache.CacheEntry, p cache.Patch, elapsed time.Duration) *Result {
+	r, keep := e.checkRegression(req, previous, doc)
+	if keep {
+		e.cache.RecordFetch(req.Library.ID, p.Bytes, elapsed)
+		return kept(previous, r)
+	}
+
+	metadata := e.metadata(req, e.now())
+	metadata.Patches = slices.Clone(previous.Metadata.Patches)
+	metadata.AddPatch(p)
+
+	changes := Diff(previous.Content, doc)
+	start := e.now()
+	_ = e.cache.SetWithVersion(req.Library.ID, req.CacheKey(), doc, metadata)
+	e.metrics.Observe(StageCacheWrite, e.now().Sub(start), int64(len(doc)))
+	e.cache.RecordFetch(req.Library.ID, p.Bytes, elapsed)
+	return &Result{Content: doc, Metadata: metadata, Changes: &changes, Regression: r}
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNoDocumentation is returned when context7 serves an empty document or
+// a placeholder, e.g. while a library is still being indexed. Such
+// documents are never cached.
+var ErrNoDocumentation = errors.New("no documentation available yet")
+
+// placeholderMaxSize bounds what can be a placeholder; real documents are
+// far larger
+const placeholderMaxSize = 512
+
+// placeholderPhrases appear in the stand-in text context7 serves for
+// libraries without usable docs
+var placeholderPhrases = []string{
+	"being indexed",
+	"being processed",
+	"no content available",
+	"no documentation available",
+}
+
+// isPlaceholder reports whether doc is empty or only a placeholder message
+func isPlaceholder(doc string) bool {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return true
+	}
+	if len(doc) > placeholderMaxSize {
+		return false
+	}
+
+	doc = strings.ToLower(doc)
+	for _, phrase := range placeholderPhrases {
+		if strings.Contains(doc, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStream peeks at the start of a streamed document and fails with
+// ErrNoDocumentation if it is a placeholder; otherwise the returned reader
+// yields the whole document
+func checkStream(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, placeholderMaxSize+1)
+	head, err := br.Peek(placeholderMaxSize + 1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(head) <= placeholderMaxSize && isPlaceholder(string(head)) {
+		return nil, ErrNoDocumentation
+	}
+	return br, nil
+}
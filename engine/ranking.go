@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Ranker scores search results for a query; the engine orders them by
+// score, highest first, keeping the search API's order between equal
+// scores. Without one, libraries learned for the query come first.
+type Ranker interface {
+	Scores(query string, results []RankInput) ([]float64, error)
+}
+
+// RankInput is what a Ranker knows about one search result
+type RankInput struct {
+	Library client.Library
+	// APIRank is the result's 1-based position in the search API's order
+	APIRank int
+	// Learned is the decayed weight of past picks for the query, 0 for none
+	Learned float64
+	// Age is how long ago context7 last updated the library, -1 when unknown
+	Age time.Duration
+}
+
+// Weights is a ranking profile: each result scores the weighted sum of its
+// features, each scaled to 0–1 across the results
+type Weights struct {
+	// Relevance is the search API's score, or its order when it has none
+	Relevance float64
+	// Stars are the GitHub stars, on a log scale
+	Stars float64
+	// Trust is context7's trust score
+	Trust float64
+	// Benchmark is context7's benchmark score
+	Benchmark float64
+	// Recency favors libraries context7 updated recently
+	Recency float64
+	// Learned is the weight of past picks for the query
+	Learned float64
+}
+
+// RankProfiles are the built-in ranking profiles; config rank_profiles
+// adds to them and overrides them
+var RankProfiles = map[string]Weights{
+	"popular": {Relevance: 0.5, Stars: 1, Learned: 1},
+	"quality": {Relevance: 0.5, Trust: 1, Benchmark: 1, Learned: 1},
+	"fresh":   {Relevance: 0.5, Recency: 1, Learned: 1},
+}
+
+// recencyHalfLife is the age at which a library's recency is halved
+const recencyHalfLife = 30 * 24 * time.Hour
+
+// Scores scores each result by the profile
+func (w Weights) Scores(query string, results []RankInput) ([]float64, error) {
+	var maxRelevance, maxBenchmark, maxLearned float64
+	var maxStars int
+	for _, r := range results {
+		maxRelevance = max(maxRelevance, r.Library.Score)
+		maxBenchmark = max(maxBenchmark, r.Library.BenchmarkScore)
+		maxLearned = max(maxLearned, r.Learned)
+		maxStars = max(maxStars, r.Library.Stars)
+	}
+	scale := func(v, top float64) float64 {
+		if top <= 0 {
+			return 0
+		}
+		return v / top
+	}
+
+	scores := make([]float64, len(results))
+	for i, r := range results {
+		relevance := 1 - float64(r.APIRank-1)/float64(len(results))
+		if maxRelevance > 0 {
+			relevance = scale(r.Library.Score, maxRelevance)
+		}
+		var recency float64
+		if r.Age >= 0 {
+			recency = 1 / (1 + float64(r.Age)/float64(recencyHalfLife))
+		}
+		scores[i] = w.Relevance*relevance +
+			w.Stars*scale(math.Log1p(float64(r.Library.Stars)), math.Log1p(float64(maxStars))) +
+			w.Trust*r.Library.TrustScore/10 +
+			w.Benchmark*scale(r.Library.BenchmarkScore, maxBenchmark) +
+			w.Recency*recency +
+			w.Learned*scale(r.Learned, maxLearned)
+	}
+	return scores, nil
+}
+
+// String names the weighted features, e.g. "stars 1, relevance 0.5"
+func (w Weights) String() string {
+	var parts []string
+	for _, f := range []struct {
+		name   string
+		weight float64
+	}{
+		{"relevance", w.Relevance}, {"stars", w.Stars}, {"trust", w.Trust},
+		{"benchmark", w.Benchmark}, {"recency", w.Recency}, {"learned", w.Learned},
+	} {
+		if f.weight != 0 {
+			parts = append(parts, fmt.Sprintf("%s %g", f.name, f.weight))
+		}
+	}
+	if len(parts) == 0 {
+		return "no weights"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// commandTimeout bounds a ranking command
+const commandTimeout = 5 * time.Second
+
+// CommandRanker scores results with an external command, run by sh. The
+// command reads the query and results as JSON on stdin and prints a JSON
+// array of scores, one per result in the order given.
+type CommandRanker struct {
+	Command string
+}
+
+// rankCommandInput is the document a ranking command reads
+type rankCommandInput struct {
+	Query   string             `json:"query"`
+	Results []rankCommandEntry `json:"results"`
+}
+
+type rankCommandEntry struct {
+	ID             string   `json:"id"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	LastUpdated    string   `json:"last_updated,omitempty"`
+	State          string   `json:"state,omitempty"`
+	Stars          int      `json:"stars"`
+	TrustScore     float64  `json:"trust_score"`
+	BenchmarkScore float64  `json:"benchmark_score"`
+	Relevance      float64  `json:"relevance"`
+	TotalTokens    int      `json:"total_tokens"`
+	TotalSnippets  int      `json:"total_snippets"`
+	Versions       []string `json:"versions"`
+	VIP            bool     `json:"vip,omitempty"`
+	APIRank        int      `json:"api_rank"`
+	Learned        float64  `json:"learned_score"`
+}
+
+// Scores runs the command on the results
+func (c CommandRanker) Scores(query string, results []RankInput) ([]float64, error) {
+	in := rankCommandInput{Query: query, Results: make([]rankCommandEntry, len(results))}
+	for i, r := range results {
+		versions := r.Library.Versions
+		if versions == nil {
+			versions = []string{}
+		}
+		in.Results[i] = rankCommandEntry{
+			ID:             r.Library.ID,
+			Title:          r.Library.Title,
+			Description:    r.Library.Description,
+			LastUpdated:    r.Library.LastUpdateDate,
+			State:          r.Library.State,
+			Stars:          r.Library.Stars,
+			TrustScore:     r.Library.TrustScore,
+			BenchmarkScore: r.Library.BenchmarkScore,
+			Relevance:      r.Library.Score,
+			TotalTokens:    r.Library.TotalTokens,
+			TotalSnippets:  r.Library.TotalSnippets,
+			Versions:       versions,
+			VIP:            r.Library.VIP,
+			APIRank:        r.APIRank,
+			Learned:        r.Learned,
+		}
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode results: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to run ranking command: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("failed to run ranking command: %w", err)
+	}
+
+	var scores []float64
+	if err := json.Unmarshal(out, &scores); err != nil {
+		return nil, fmt.Errorf("failed to parse ranking command output: %w", err)
+	}
+	if len(scores) != len(results) {
+		return nil, fmt.Errorf("ranking command returned %d scores for %d results", len(scores), len(results))
+	}
+	return scores, nil
+}
+
+// String names the command
+func (c CommandRanker) String() string {
+	return fmt.Sprintf("command %q", c.Command)
+}
+
+// rankBy orders results by the ranker's scores, falling back to the API
+// order with learned libraries first when it fails
+func (e *Engine) rankBy(query string, results []client.Library, now time.Time) ([]client.Library, []float64, bool) {
+	inputs := make([]RankInput, len(results))
+	for i, lib := range results {
+		inputs[i] = RankInput{Library: lib, APIRank: i + 1, Learned: e.learned.Score(query, lib.ID, now), Age: -1}
+		if updated, err := time.Parse(time.RFC3339, lib.LastUpdateDate); err == nil {
+			inputs[i].Age = max(now.Sub(updated), 0)
+		}
+	}
+	scores, err := e.ranker.Scores(query, inputs)
+	if err != nil {
+		e.trace.Add("rank", "ranking by %v failed (%v); using the default order", e.ranker, err)
+		return e.learned.Boost(query, results, now), nil, false
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	ranked := make([]client.Library, len(results))
+	sorted := make([]float64, len(results))
+	for i, k := range order {
+		ranked[i], sorted[i] = results[k], scores[k]
+	}
+	return ranked, sorted, true
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+)
+
+// regressionShrink is the share of the cached copy's size below which a
+// refreshed document is taken for a possible upstream regression
+const regressionShrink = 0.5
+
+// RegressionAction is what a refresh does with a document that looks worse
+// than the cached copy it would replace
+type RegressionAction int
+
+const (
+	// RegressionReplace caches the download as usual
+	RegressionReplace RegressionAction = iota
+	// RegressionKeep discards the download and serves the cached copy
+	RegressionKeep
+	// RegressionKeepBoth caches the download and keeps the cached copy
+	// beside it (see cache.KeptKey)
+	RegressionKeepBoth
+)
+
+// ParseRegressionAction parses "replace", "keep" or "both"
+func ParseRegressionAction(s string) (RegressionAction, error) {
+	switch s {
+	case "replace":
+		return RegressionReplace, nil
+	case "keep":
+		return RegressionKeep, nil
+	case "both":
+		return RegressionKeepBoth, nil
+	}
+	return 0, fmt.Errorf("unknown regression policy %q (want keep, replace or both)", s)
+}
+
+// Regression describes a refreshed document that is much smaller than the
+// cached copy, or that context7 dates before it
+type Regression struct {
+	CachedSize     int64
+	DownloadedSize int64
+	// CachedUpdated and DownloadedUpdated are context7's update dates of
+	// the two documents, zero when unknown
+	CachedUpdated     time.Time
+	DownloadedUpdated time.Time
+}
+
+// String explains why the download looks like a regression
+func (r Regression) String() string {
+	var reasons []string
+	if r.shrunk() {
+		reasons = append(reasons, fmt.Sprintf("the download is %d%% the size of the cached copy (%d of %d bytes)",
+			r.DownloadedSize*100/r.CachedSize, r.DownloadedSize, r.CachedSize))
+	}
+	if r.older() {
+		reasons = append(reasons, fmt.Sprintf("context7 dates it %s, before the cached copy's %s",
+			r.DownloadedUpdated.Format("2006-01-02"), r.CachedUpdated.Format("2006-01-02")))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func (r Regression) shrunk() bool {
+	return float64(r.DownloadedSize) < float64(r.CachedSize)*regressionShrink
+}
+
+func (r Regression) older() bool {
+	return !r.CachedUpdated.IsZero() && !r.DownloadedUpdated.IsZero() && r.DownloadedUpdated.Before(r.CachedUpdated)
+}
+
+// regression compares a refreshed document with the cached copy it would
+// replace
+func regression(req Request, previous *cache.CacheEntry, doc string) (Regression, bool) {
+	r := Regression{CachedSize: int64(len(previous.Content)), DownloadedSize: int64(len(doc))}
+	r.CachedUpdated, _ = time.Parse(time.RFC3339, previous.Metadata.LastUpdateDate)
+	r.DownloadedUpdated, _ = time.Parse(time.RFC3339, req.Library.LastUpdateDate)
+	return r, r.shrunk() || r.older()
+}
+
+// checkRegression asks OnRegression what to do when doc looks worse than
+// previous. keep is set when the cached copy is to be served instead; a
+// copy to keep beside the new document is saved before it is replaced.
+func (e *Engine) checkRegression(req Request, previous *cache.CacheEntry, doc string) (r *Regression, keep bool) {
+	if e.onRegression == nil {
+		return nil, false
+	}
+	found, ok := regression(req, previous, doc)
+	if !ok {
+		return nil, false
+	}
+
+	switch e.onRegression(req, found) {
+	case RegressionKeep:
+		e.trace.Add("cache", "%s looks like a regression (%s); keeping the cached copy", req.label(), found)
+		return &found, true
+	case RegressionKeepBoth:
+		metadata := previous.Metadata
+		metadata.Superseded = true
+		_ = e.cache.SetWithVersion(req.Library.ID, cache.KeptKey(req.CacheKey()), previous.Content, metadata)
+		e.trace.Add("cache", "%s looks like a regression (%s); replacing the cached copy and keeping it as %s", req.label(), found, cache.KeptKey(req.CacheKey()))
+	default:
+		e.trace.Add("cache", "%s looks like a regression (%s); replacing the cached copy", req.label(), found)
+	}
+	return &found, false
+}
+
+// kept answers a refresh with the cached copy it would have replaced
+func kept(previous *cache.CacheEntry, r *Regression) *Result {
+	return &Result{Content: previous.Content, FromCache: true, Metadata: previous.Metadata, Regression: r}
+}
+
+// comparesWithCached reports whether a refresh of req has to be compared
+// with a cached copy before replacing it
+func (e *Engine) comparesWithCached(req Request) bool {
+	if e.onRegression == nil || e.cache == nil || e.noCache {
+		return false
+	}
+	_, err := e.lookup(req)
+	return err == nil
+}
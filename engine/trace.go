@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace records the decisions behind one lookup for --explain: how the
+// query was matched, how the candidates ranked, where the document came
+// from and what was filtered out. A nil *Trace records nothing.
+type Trace struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// Step is one recorded decision. Stage names the part of the pipeline
+// ("query", "registry", "search", "rank", "select", "cache", "fetch",
+// "filter").
+type Step struct {
+	Stage      string      `json:"stage"`
+	Detail     string      `json:"detail"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// Candidate is a search result in the order it was ranked
+type Candidate struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// APIRank is the result's 1-based position in the search API's order
+	APIRank int `json:"api_rank"`
+	// Learned is the decayed weight of past picks for the query, 0 for none
+	Learned        float64 `json:"learned_score,omitempty"`
+	TrustScore     float64 `json:"trust_score,omitempty"`
+	BenchmarkScore float64 `json:"benchmark_score,omitempty"`
+	Stars          int     `json:"stars,omitempty"`
+	// Score is what the configured Ranker scored the result, 0 without one
+	Score float64 `json:"rank_score,omitempty"`
+}
+
+// Add records a step
+func (t *Trace) Add(stage, format string, args ...any) {
+	t.add(Step{Stage: stage, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (t *Trace) add(step Step) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps = append(t.steps, step)
+}
+
+// Steps returns the recorded steps in order
+func (t *Trace) Steps() []Step {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Step(nil), t.steps...)
+}
+
+// WriteText writes the steps as an indented list under a heading naming
+// the query
+func (t *Trace) WriteText(w io.Writer, query string) {
+	fmt.Fprintf(w, "explain: %s\n", query)
+	for _, s := range t.Steps() {
+		fmt.Fprintf(w, "  %-9s %s\n", s.Stage, s.Detail)
+		for i, c := range s.Candidates {
+			fmt.Fprintf(w, "  %-9s %3d. %s\n", "", i+1, c.describe())
+		}
+	}
+}
+
+// describe summarizes a candidate's ranking inputs on one line
+func (c Candidate) describe() string {
+	parts := []string{c.ID, fmt.Sprintf("(%s)", c.Title), fmt.Sprintf("api #%d", c.APIRank)}
+	if c.Learned > 0 {
+		parts = append(parts, fmt.Sprintf("learned %.2f", c.Learned))
+	}
+	if c.TrustScore > 0 {
+		parts = append(parts, fmt.Sprintf("trust %.1f", c.TrustScore))
+	}
+	if c.BenchmarkScore > 0 {
+		parts = append(parts, fmt.Sprintf("benchmark %.1f", c.BenchmarkScore))
+	}
+	if c.Stars > 0 {
+		parts = append(parts, fmt.Sprintf("%d stars", c.Stars))
+	}
+	if c.Score != 0 {
+		parts = append(parts, fmt.Sprintf("score %.2f", c.Score))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// shortDuration formats d without trailing zero units, e.g. "24h" rather
+// than "24h0m0s"
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,173 @@
+// Package history records the libraries ctx7 fetched, so past fetches can
+// be listed and run again.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxEntries bounds how many fetches are remembered; the oldest are
+// dropped first
+const MaxEntries = 1000
+
+// Entry is one fetch: what was asked for and what it resolved to
+type Entry struct {
+	Query     string    `json:"query"`
+	LibraryID string    `json:"library_id"`
+	Title     string    `json:"title,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Branch    string    `json:"branch,omitempty"`
+	Topic     string    `json:"topic,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Args returns the ctx7 arguments that fetch e again: the library it
+// resolved to, so a changed search ranking can't pick another one
+func (e Entry) Args() []string {
+	var args []string
+	if e.Branch != "" {
+		args = append(args, "--branch", e.Branch)
+	}
+	if e.Topic != "" {
+		args = append(args, "--topic", e.Topic)
+	}
+	id := e.LibraryID
+	if e.Version != "" {
+		id += "@" + e.Version
+	}
+	return append(args, id)
+}
+
+// String summarizes e: the query, and the library it resolved to when
+// that wasn't named directly, e.g. "next.js → /vercel/next.js@v14.3.0"
+func (e Entry) String() string {
+	target := e.LibraryID
+	if e.Version != "" {
+		target += "@" + e.Version
+	}
+	s := target
+	if e.Query != e.LibraryID {
+		s = e.Query + " → " + target
+	}
+
+	var details []string
+	if e.Branch != "" {
+		details = append(details, "branch "+e.Branch)
+	}
+	if e.Topic != "" {
+		details = append(details, "topic "+e.Topic)
+	}
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+// same reports whether e and other fetched the same document
+func (e Entry) same(other Entry) bool {
+	return e.LibraryID == other.LibraryID && e.Version == other.Version &&
+		e.Branch == other.Branch && e.Topic == other.Topic
+}
+
+// History is the list of past fetches, oldest first. The zero value is not
+// usable; Load one from a file.
+type History struct {
+	Entries []Entry `json:"entries"`
+
+	path string
+}
+
+// DefaultPath returns the history location (~/.config/ctx7/history.json)
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "ctx7", "history.json"), nil
+}
+
+// Load reads the history from path; a missing file yields an empty history
+func Load(path string) (*History, error) {
+	h := &History{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	if err := json.Unmarshal(data, h); err != nil {
+		return h, fmt.Errorf("failed to parse history: %w", err)
+	}
+	return h, nil
+}
+
+// Save writes the history back to its file
+func (h *History) Save() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	if err := os.WriteFile(h.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Add records a fetch
+func (h *History) Add(e Entry) {
+	if h == nil || e.LibraryID == "" {
+		return
+	}
+	h.Entries = append(h.Entries, e)
+	if len(h.Entries) > MaxEntries {
+		h.Entries = h.Entries[len(h.Entries)-MaxEntries:]
+	}
+}
+
+// Clear forgets every fetch
+func (h *History) Clear() {
+	h.Entries = nil
+}
+
+// Get returns the nth most recent fetch, counting from 1
+func (h *History) Get(n int) (Entry, bool) {
+	if h == nil || n < 1 || n > len(h.Entries) {
+		return Entry{}, false
+	}
+	return h.Entries[len(h.Entries)-n], true
+}
+
+// Recent returns up to n fetches, most recent first, each document once
+func (h *History) Recent(n int) []Entry {
+	if h == nil {
+		return nil
+	}
+	var recent []Entry
+	for i := len(h.Entries) - 1; i >= 0 && len(recent) < n; i-- {
+		e := h.Entries[i]
+		seen := false
+		for _, r := range recent {
+			if r.same(e) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			recent = append(recent, e)
+		}
+	}
+	return recent
+}
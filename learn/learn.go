@@ -0,0 +1,253 @@
+package learn
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+const (
+	// HalfLife is how long it takes a selection to count half as much
+	HalfLife = 30 * 24 * time.Hour
+	// MaxQueries bounds how many queries are remembered; the weakest are
+	// dropped first
+	MaxQueries = 500
+	// MaxLibrariesPerQuery bounds the libraries remembered for one query
+	MaxLibrariesPerQuery = 5
+	// minScore is the decayed score below which an association is
+	// forgotten, about 100 days after a single selection
+	minScore = 0.1
+)
+
+// Association is a library picked for a query, weighted by how often and
+// how recently it was picked
+type Association struct {
+	LibraryID string    `json:"library_id"`
+	Score     float64   `json:"score"`
+	Updated   time.Time `json:"updated"`
+}
+
+// decayed returns the score as of now
+func (a Association) decayed(now time.Time) float64 {
+	age := now.Sub(a.Updated)
+	if age <= 0 {
+		return a.Score
+	}
+	return a.Score * math.Pow(0.5, float64(age)/float64(HalfLife))
+}
+
+// Entry is one learned query and its libraries, strongest first, for listing
+type Entry struct {
+	Query     string
+	Libraries []Association
+}
+
+// Store holds learned query→library associations. The zero value is not
+// usable; Load one from a file.
+type Store struct {
+	Queries map[string][]Association `json:"queries"`
+
+	path string
+}
+
+// DefaultPath returns the learned data location (~/.config/ctx7/learned.json)
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "ctx7", "learned.json"), nil
+}
+
+// Load reads the store from path; a missing file yields an empty store
+func Load(path string) (*Store, error) {
+	s := &Store{Queries: map[string][]Association{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read learned data: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return s, fmt.Errorf("failed to parse learned data: %w", err)
+	}
+	if s.Queries == nil {
+		s.Queries = map[string][]Association{}
+	}
+	return s, nil
+}
+
+// Save collects decayed associations and writes the store back to its file
+func (s *Store) Save(now time.Time) error {
+	s.collect(now)
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create learned data directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal learned data: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write learned data: %w", err)
+	}
+	return nil
+}
+
+// Clear forgets everything learned
+func (s *Store) Clear() {
+	s.Queries = map[string][]Association{}
+}
+
+// Normalize folds queries that differ only in case or spacing together
+func Normalize(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// Record strengthens the association of query with libraryID
+func (s *Store) Record(query, libraryID string, now time.Time) {
+	query = Normalize(query)
+	if s == nil || query == "" || libraryID == "" {
+		return
+	}
+
+	libs := s.Queries[query]
+	i := slices.IndexFunc(libs, func(a Association) bool { return a.LibraryID == libraryID })
+	if i < 0 {
+		libs = append(libs, Association{LibraryID: libraryID})
+		i = len(libs) - 1
+	}
+	libs[i].Score = libs[i].decayed(now) + 1
+	libs[i].Updated = now
+
+	sortByScore(libs, now)
+	if len(libs) > MaxLibrariesPerQuery {
+		libs = libs[:MaxLibrariesPerQuery]
+	}
+	s.Queries[query] = libs
+}
+
+// Boost returns results with the libraries learned for query moved to the
+// front, strongest first; the rest keep their order. results is not modified.
+func (s *Store) Boost(query string, results []client.Library, now time.Time) []client.Library {
+	if s == nil {
+		return results
+	}
+	libs := s.Queries[Normalize(query)]
+	if len(libs) == 0 {
+		return results
+	}
+
+	rank := make(map[string]float64, len(libs))
+	for _, a := range libs {
+		if score := a.decayed(now); score >= minScore {
+			rank[a.LibraryID] = score
+		}
+	}
+
+	boosted := slices.Clone(results)
+	sort.SliceStable(boosted, func(i, j int) bool {
+		return rank[boosted[i].ID] > rank[boosted[j].ID]
+	})
+	return boosted
+}
+
+// Top returns the library most strongly associated with query
+func (s *Store) Top(query string, now time.Time) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	for _, a := range s.Queries[Normalize(query)] {
+		if a.decayed(now) >= minScore {
+			return a.LibraryID, true
+		}
+	}
+	return "", false
+}
+
+// Score returns the decayed weight of libraryID for query, 0 if none
+func (s *Store) Score(query, libraryID string, now time.Time) float64 {
+	if s == nil {
+		return 0
+	}
+	for _, a := range s.Queries[Normalize(query)] {
+		if a.LibraryID == libraryID {
+			if score := a.decayed(now); score >= minScore {
+				return score
+			}
+		}
+	}
+	return 0
+}
+
+// Entries returns every learned query with its decayed scores, strongest
+// queries first
+func (s *Store) Entries(now time.Time) []Entry {
+	s.collect(now)
+
+	entries := make([]Entry, 0, len(s.Queries))
+	for query, libs := range s.Queries {
+		decayed := make([]Association, len(libs))
+		for i, a := range libs {
+			decayed[i] = Association{LibraryID: a.LibraryID, Score: a.decayed(now), Updated: a.Updated}
+		}
+		sortByScore(decayed, now)
+		entries = append(entries, Entry{Query: query, Libraries: decayed})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if a, b := entries[i].Libraries[0].Score, entries[j].Libraries[0].Score; a != b {
+			return a > b
+		}
+		return entries[i].Query < entries[j].Query
+	})
+	return entries
+}
+
+// collect drops associations that have decayed away and, past MaxQueries,
+// the queries with the weakest associations
+func (s *Store) collect(now time.Time) {
+	strongest := make(map[string]float64, len(s.Queries))
+	for query, libs := range s.Queries {
+		libs = slices.DeleteFunc(libs, func(a Association) bool { return a.decayed(now) < minScore })
+		if len(libs) == 0 {
+			delete(s.Queries, query)
+			continue
+		}
+		sortByScore(libs, now)
+		s.Queries[query] = libs
+		strongest[query] = libs[0].decayed(now)
+	}
+
+	if len(s.Queries) <= MaxQueries {
+		return
+	}
+	queries := make([]string, 0, len(s.Queries))
+	for query := range s.Queries {
+		queries = append(queries, query)
+	}
+	sort.Slice(queries, func(i, j int) bool { return strongest[queries[i]] < strongest[queries[j]] })
+	for _, query := range queries[:len(queries)-MaxQueries] {
+		delete(s.Queries, query)
+	}
+}
+
+// sortByScore orders associations by decayed score, strongest first
+func sortByScore(libs []Association, now time.Time) {
+	sort.SliceStable(libs, func(i, j int) bool {
+		return libs[i].decayed(now) > libs[j].decayed(now)
+	})
+}
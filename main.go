@@ -1,28 +1,177 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/x/term"
+	"github.com/hsbacot/ctx7/alias"
+	"github.com/hsbacot/ctx7/budget"
 	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
 	"github.com/hsbacot/ctx7/cmd"
+	"github.com/hsbacot/ctx7/config"
+	"github.com/hsbacot/ctx7/content"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/history"
+	"github.com/hsbacot/ctx7/learn"
+	"github.com/hsbacot/ctx7/prefs"
+	"github.com/hsbacot/ctx7/registry"
+	"github.com/hsbacot/ctx7/transcript"
 	"github.com/hsbacot/ctx7/tui"
 	"github.com/hsbacot/ctx7/ui"
 )
 
 func main() {
-	// Check for cache subcommand before parsing flags
-	if len(os.Args) > 1 && os.Args[1] == "cache" {
-		cacheManager, err := initCache()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
-			os.Exit(1)
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	theme, err := ui.ThemeFromConfig(cfg.Theme)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	tui.SetTheme(theme)
+
+	// --cache-dir and --base-url apply to the subcommands too, so take them
+	// out before they are dispatched
+	var cacheDir, baseURLFlag string
+	cacheDir, os.Args = extractOption(os.Args, "cache-dir")
+	if cacheDir != "" {
+		os.Setenv("CTX7_CACHE_DIR", cacheDir)
+	}
+	baseURLFlag, os.Args = extractOption(os.Args, "base-url")
+	if baseURLFlag != "" {
+		cfg.BaseURL = baseURLFlag
+	}
+
+	// Flags from [defaults.<command>] go right after the command's name, so
+	// those given on the command line override them
+	if n, flags := cfg.CommandDefaults(os.Args[1:]); len(flags) > 0 {
+		os.Args = slices.Concat(os.Args[:1+n], flags, os.Args[1+n:])
+	}
+
+	// Check for subcommands before parsing flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			cacheManager, err := initCache()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
+				os.Exit(1)
+			}
+			cmd.RunCacheCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL, cfg.SearchCacheTTL)
+			return
+		case "snippets":
+			cacheManager, _ := initCache()
+			cmd.RunSnippetsCommand(os.Args[2:], cacheManager, newClient(cfg, ""))
+			return
+		case "search":
+			cmd.RunSearchCommand(os.Args[2:], newClient(cfg, ""))
+			return
+		case "versions":
+			cacheManager, _ := initCache()
+			cmd.RunVersionsCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL)
+			return
+		case "stats":
+			cacheManager, err := initCache()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
+				os.Exit(1)
+			}
+			cmd.RunStatsCommand(os.Args[2:], cacheManager)
+			return
+		case "grep":
+			cacheManager, err := initCache()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
+				os.Exit(1)
+			}
+			cmd.RunGrepCommand(os.Args[2:], cacheManager)
+			return
+		case "learned":
+			path, err := learn.DefaultPath()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			store, err := learn.Load(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			cmd.RunLearnedCommand(os.Args[2:], store)
+			return
+		case "alias":
+			path, err := alias.DefaultPath()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			store, err := alias.Load(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			cacheManager, _ := initCache()
+			cmd.RunAliasCommand(os.Args[2:], store, cacheManager, newClient(cfg, ""), cfg.CacheTTL, cfg.SearchCacheTTL)
+			return
+		case "serve":
+			cacheManager, err := initCache()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
+				os.Exit(1)
+			}
+			cmd.RunServeCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL, cfg.SearchCacheTTL, cmd.ServeAuth{Token: cfg.ServeToken, Allow: cfg.ServeAllow})
+			return
+		case "watch":
+			cacheManager, err := initCache()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
+				os.Exit(1)
+			}
+			cmd.RunWatchCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL)
+			return
+		case "bundle":
+			cacheManager, _ := initCache()
+			cmd.RunBundleCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL, cfg.SearchCacheTTL)
+			return
+		case "stack":
+			cacheManager, _ := initCache()
+			cmd.RunStackCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL, cfg.SearchCacheTTL)
+			return
+		case "history":
+			// history run fetches an entry again, through the normal flags
+			if len(os.Args) > 2 && os.Args[2] == "run" {
+				os.Args = historyRunArgs(os.Args)
+				break
+			}
+			cmd.RunHistoryCommand(os.Args[2:], loadHistory(nil))
+			return
+		case "selftest":
+			cmd.RunSelftestCommand(os.Args[2:])
+			return
+		case "replay":
+			cacheManager, _ := initCache()
+			cmd.RunReplayCommand(os.Args[2:], cacheManager, newClient(cfg, ""), cfg.CacheTTL)
+			return
 		}
-		cmd.RunCacheCommand(os.Args[2:], cacheManager)
-		return
 	}
 
 	// Parse command-line flags
@@ -32,14 +181,113 @@ func main() {
 	verbose := flag.Bool("v", false, "verbose mode - show detailed logs")
 	flag.BoolVar(verbose, "verbose", false, "verbose mode - show detailed logs")
 
+	baseURL := flag.String("base-url", "", "context7 instance to query (default $CTX7_BASE_URL, config base_url, or https://context7.com)")
+
+	searchTimeout := flag.Duration("search-timeout", 0, "give up on a library search after this long (default config search_timeout or 5s)")
+	fetchTimeout := flag.Duration("fetch-timeout", 0, "give up on a documentation download after this long (default config fetch_timeout or 5m)")
+
+	lang := flag.String("lang", "", "language of the query, e.g. ja, passed to the search API as a hint (default config lang)")
+	insecure := flag.Bool("insecure", false, "accept documents that fail verification against config manifest_url")
+
 	noCache := flag.Bool("no-cache", false, "skip cache, force fresh fetch")
+	staleOK := flag.Bool("stale-ok", false, "if a download fails, use the cached copy however old it is")
+	noPrefetch := flag.Bool("no-prefetch", false, "don't download the top results in the background while the picker is open")
+	maxStaleness := flag.String("max-staleness", "", "refetch cached docs older than this, e.g. 30d or 12h, unless context7 reports no update since")
+	onRegression := flag.String("on-regression", "keep", "when a refetched doc is much smaller or older than the cached copy: keep the cached copy, replace it, or keep both")
+	minStars := flag.Int("min-stars", 0, "leave libraries with fewer GitHub stars out of the search results")
+	minTrust := flag.Float64("min-trust", 0, "leave libraries with a lower trust score out of the search results")
+	excludeDeprecated := flag.Bool("exclude-deprecated", false, "leave libraries context7 marks deprecated out of the search results")
+	noRegistry := flag.Bool("no-registry", false, "search for every query, even names of bundled popular libraries")
 	clearCache := flag.Bool("clear-cache", false, "clear all cached content")
 
 	showVersions := flag.Bool("versions", false, "show and select version")
 	flag.BoolVar(showVersions, "select-version", false, "show and select version")
 
+	branch := flag.String("branch", "", "fetch documentation for a specific branch")
+
+	topic := flag.String("topic", "", "fetch only documentation about a topic")
+	pickTopic := flag.Bool("topics", false, "pick a topic from the document's table of contents")
+	section := flag.String("section", "", "output only the snippets whose titles contain this text (case-insensitive)")
+	grepSection := flag.String("grep-section", "", "output only the snippets whose titles match this regular expression")
+	codeLang := flag.String("code-lang", "", "output only the snippets with code in this language, e.g. go, ts, python")
+	dedupe := flag.Bool("dedupe", false, "drop snippets that repeat an earlier one and report the tokens saved")
+	dedupeSimilarity := flag.Float64("dedupe-similarity", 0, "with --dedupe, also drop snippets at least this similar to an earlier one, from 0 to 1, e.g. 0.9")
+
+	noSpinner := flag.Bool("no-spinner", false, "show static status lines instead of an animated spinner")
+	flag.BoolVar(noSpinner, "reduced-motion", false, "show static status lines instead of an animated spinner")
+
+	crlf := flag.Bool("crlf", false, "write output with CRLF line endings")
+	lf := flag.Bool("lf", false, "write output with LF line endings")
+
+	format := flag.String("format", "text", "output format: text, json with metadata and parsed snippets, html to read in a browser, or pdf (needs wkhtmltopdf)")
+	schema := flag.Bool("schema", false, "print the JSON Schema of --format json output")
+	explain := flag.Bool("explain", false, "report how each query was resolved and where its document came from (on stderr, or in --format json)")
+
+	plain := flag.Bool("plain", false, "no TUI: report progress as plain lines on stderr (default when stderr is not a terminal)")
+	quiet := flag.Bool("q", false, "no TUI and no progress lines, only errors")
+	flag.BoolVar(quiet, "quiet", false, "no TUI and no progress lines, only errors")
+	ci := flag.Bool("ci", false, "one-shot mode for CI and containers: quiet, no prompts, pager, color or learned ranking, bounded timeouts and JSON errors")
+
+	noPager := flag.Bool("no-pager", false, "print content directly instead of opening the pager on a terminal")
+	render := flag.Bool("render", false, "render markdown with glamour (default when stdout is a terminal)")
+	noRender := flag.Bool("no-render", false, "never render markdown, output plain text")
+
+	last := flag.Bool("last", false, "fetch the most recent library from history again (ctx7 history run 1)")
+
+	transcriptPath := flag.String("transcript", "", "record queries, shown results and selections to this JSON file")
+
+	outputDir := flag.String("output-dir", "", "same as -o <dir>/")
+	outPath := flag.String("o", "", "write the output to this file, or with a trailing / each library to <dir>/<org>__<name>@<version>.md")
+	force := flag.Bool("force", false, "overwrite existing files written by -o")
+	templateName := flag.String("template", "", "combine the libraries through a template: claude-xml, markdown-toc, or a text/template file")
+	splitOut := flag.String("split-out", "", "write each snippet to its own file, with front matter, in <dir>/<org>__<name>@<version>/")
+
+	tokenBudget := flag.Int("budget", 0, "maximum estimated tokens to emit across all libraries")
+	countTokens := flag.Bool("count-tokens", false, "report approximate token counts per model family, for the whole document and each section, instead of printing it")
+	budgetMode := flag.String("budget-mode", "stop", "what to do once the budget is reached: stop, summary")
+
+	// [defaults.fetch] is parsed first, so the command line overrides it
+	flag.CommandLine.Parse(cfg.FetchDefaults())
 	flag.Parse()
 
+	if *last {
+		if flag.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --last fetches from history and takes no libraries")
+			os.Exit(1)
+		}
+		flag.CommandLine.Parse(historyEntry(1).Args())
+	}
+
+	if *searchTimeout > 0 {
+		cfg.SearchTimeout = *searchTimeout
+	}
+	if *fetchTimeout > 0 {
+		cfg.FetchTimeout = *fetchTimeout
+	}
+	if *ci {
+		// Never wait on the built-in five-minute download timeout in a build
+		if cfg.SearchTimeout == 0 {
+			cfg.SearchTimeout = ciSearchTimeout
+		}
+		if cfg.FetchTimeout == 0 {
+			cfg.FetchTimeout = ciFetchTimeout
+		}
+		os.Setenv("NO_COLOR", "1")
+		tui.SetTheme(theme)
+	}
+	if *lang != "" {
+		cfg.Lang = *lang
+	}
+	if *insecure && cfg.ManifestURL != "" {
+		fmt.Fprintln(os.Stderr, "Warning: manifest verification is disabled (--insecure)")
+		cfg.ManifestURL, cfg.ManifestKey = "", ""
+	}
+
+	if *schema {
+		cmd.PrintFetchSchema()
+		return
+	}
+
 	// Handle clear-cache command
 	if *clearCache {
 		c, err := initCache()
@@ -55,14 +303,154 @@ func main() {
 		return
 	}
 
-	// Require query argument
+	filter := engine.Filter{MinStars: *minStars, MinTrust: *minTrust, ExcludeDeprecated: *excludeDeprecated}
+	rank, err := ranker(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Without a query, browse for one on a terminal
 	args := flag.Args()
+	var browsed []client.Library
+	if len(args) == 0 && !*plain && !*quiet && !*ci && term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stderr.Fd()) {
+		args, browsed = browse(cfg, *baseURL, filter, rank)
+	}
 	if len(args) == 0 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	query := args[0]
+	if *crlf && *lf {
+		fmt.Fprintln(os.Stderr, "Error: --crlf and --lf are mutually exclusive")
+		os.Exit(1)
+	}
+	lineEnding := content.LineEndingKeep
+	if *crlf {
+		lineEnding = content.LineEndingCRLF
+	} else if *lf {
+		lineEnding = content.LineEndingLF
+	}
+
+	if *format != "text" && *format != "json" && *format != "html" && *format != "pdf" {
+		fmt.Fprintf(os.Stderr, "Error: unknown --format %q (want text, json, html or pdf)\n", *format)
+		os.Exit(1)
+	}
+	jsonOutput := *format == "json"
+	pdfOutput := *format == "pdf"
+	// PDFs are printed from the HTML page
+	htmlOutput := *format == "html" || pdfOutput
+	if *outPath != "" && *outputDir != "" {
+		fmt.Fprintln(os.Stderr, "Error: -o and --output-dir are mutually exclusive")
+		os.Exit(1)
+	}
+	if *splitOut != "" && (*outPath != "" || *outputDir != "") {
+		fmt.Fprintln(os.Stderr, "Error: --split-out writes its own files; drop -o and --output-dir")
+		os.Exit(1)
+	}
+	// -o dir/ (or an existing directory) writes one file per library
+	outDir := ""
+	if *outPath != "" && (strings.HasSuffix(*outPath, "/") || isDir(*outPath)) {
+		outDir = *outPath
+	}
+	if *outputDir != "" {
+		outDir = *outputDir
+	}
+	if *countTokens && (jsonOutput || htmlOutput || outDir != "" || *splitOut != "") {
+		fmt.Fprintln(os.Stderr, "Error: --count-tokens prints a report instead of the docs; drop --format, --output-dir, -o <dir>/ and --split-out")
+		os.Exit(1)
+	}
+	if *templateName != "" && (jsonOutput || htmlOutput || outDir != "" || *splitOut != "" || *countTokens) {
+		fmt.Fprintln(os.Stderr, "Error: --template combines the docs into one document; drop --format, --output-dir, -o <dir>/, --split-out and --count-tokens")
+		os.Exit(1)
+	}
+	if (jsonOutput || htmlOutput) && (outDir != "" || *splitOut != "") {
+		fmt.Fprintf(os.Stderr, "Error: --format %s writes a single document and can't be split into a directory\n", *format)
+		os.Exit(1)
+	}
+	if htmlOutput && *outPath == "" && term.IsTerminal(os.Stdout.Fd()) {
+		if pdfOutput {
+			fmt.Fprintln(os.Stderr, "Error: --format pdf writes a binary file; use -o <file>.pdf or redirect the output")
+		} else {
+			fmt.Fprintln(os.Stderr, "Error: --format html writes a page to open in a browser; use -o <file>.html or redirect the output")
+		}
+		os.Exit(1)
+	}
+	if pdfOutput {
+		if _, err := ui.PDFConverter(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var tmpl *template.Template
+	if *templateName != "" {
+		if tmpl, err = ui.LoadTemplate(*templateName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Refuse before fetching anything rather than after
+	if *outPath != "" && outDir == "" && !*force {
+		if _, err := os.Stat(*outPath); err == nil {
+			fmt.Fprintf(os.Stderr, "Error: %s already exists (use --force to overwrite)\n", *outPath)
+			os.Exit(1)
+		}
+	}
+
+	var staleness time.Duration
+	if *maxStaleness != "" {
+		if staleness, err = config.ParseDuration(*maxStaleness); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --max-staleness: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	regressionAction, err := engine.ParseRegressionAction(*onRegression)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --on-regression: %v\n", err)
+		os.Exit(1)
+	}
+
+	matchSection, err := sectionMatcher(*section, *grepSection)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *dedupeSimilarity < 0 || *dedupeSimilarity > 1 {
+		fmt.Fprintln(os.Stderr, "Error: --dedupe-similarity must be between 0 and 1")
+		os.Exit(1)
+	}
+	if *dedupeSimilarity > 0 && !*dedupe {
+		fmt.Fprintln(os.Stderr, "Error: --dedupe-similarity needs --dedupe")
+		os.Exit(1)
+	}
+
+	mode, err := budget.ParseMode(*budgetMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tracker := budget.NewTracker(*tokenBudget, mode)
+
+	// The pickers need the TUI. Without a terminal on stderr its redraws
+	// would garble whatever captures the output, so plain lines are used.
+	pickers := *interactive || *showVersions || *pickTopic
+	if (*plain || *quiet || *ci) && pickers {
+		fmt.Fprintln(os.Stderr, "Error: --plain, --quiet and --ci can't be combined with -i, --versions or --topics")
+		os.Exit(1)
+	}
+	if *ci && *staleOK {
+		fmt.Fprintln(os.Stderr, "Error: --ci never serves expired documents; drop --stale-ok")
+		os.Exit(1)
+	}
+	var status io.Writer
+	switch {
+	case *quiet || *ci:
+		status = io.Discard
+	case *plain || !pickers && !term.IsTerminal(os.Stderr.Fd()):
+		status = os.Stderr
+	}
 
 	// Initialize cache
 	cacheManager, err := initCache()
@@ -73,74 +461,1076 @@ func main() {
 	// Initialize logger
 	logger := ui.InitLogger(*verbose)
 
-	// Create and run Bubble Tea model
+	// Verbose runs end with what each stage took
+	var metrics *engine.Metrics
+	if *verbose {
+		metrics = &engine.Metrics{}
+	}
+	runStart := time.Now()
+
+	// Interrupts and SIGTERM cancel the run through the model so an
+	// in-flight download is cleaned up before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Create Bubble Tea model options
 	opts := tui.Options{
+		Context:      ctx,
 		Interactive:  *interactive,
 		Verbose:      *verbose,
 		NoCache:      *noCache,
 		ShowVersions: *showVersions,
-		Logger:       logger,
-		Cache:        cacheManager,
+		// Dumb terminals render spinner frames as a stream of redrawn lines
+		ReducedMotion: *noSpinner || os.Getenv("TERM") == "dumb",
+		Branch:        *branch,
+		Topic:         *topic,
+		PickTopic:     *pickTopic,
+		Logger:        logger,
+		Cache:         cacheManager,
+		MaxAge:        cfg.CacheTTL,
+		SearchMaxAge:  cfg.SearchCacheTTL,
+		StaleOK:       *staleOK,
+		MaxStaleness:  staleness,
+		CardHeight:    cfg.CardHeight,
+		Metrics:       metrics,
+		Client:        newClient(cfg, *baseURL),
+	}
+	opts.Filter = filter
+	opts.Ranker = rank
+	opts.NoPrefetch = *noPrefetch
+	opts.OnRegression = func(engine.Request, engine.Regression) engine.RegressionAction {
+		return regressionAction
+	}
+	if *interactive {
+		opts.Prefs = loadPrefs(logger)
+	}
+	// Past picks would make CI results depend on whoever ran ctx7 before
+	var learned *learn.Store
+	if !*ci {
+		learned = loadLearned(logger)
+	}
+	opts.Learned = learned
+	// Aliases are the user's own names, so they hold in CI too
+	opts.Aliases = loadAliases(logger)
+	var hist *history.History
+	if !*ci {
+		hist = loadHistory(logger)
+	}
+	// Bundled IDs are context7.com's; another instance may not have them
+	if !*noRegistry && resolveBaseURL(cfg, *baseURL) == client.DefaultBaseURL {
+		opts.Registry = registry.Lookup
 	}
 
-	m := tui.NewModel(query, opts)
+	isTTY := term.IsTerminal(os.Stdout.Fd())
+	shouldRender := (*render || isTTY) && !*noRender && !*ci
+
+	// When piped output is printed unchanged, stream downloads through the
+	// cache straight to stdout instead of buffering every document. On a
+	// terminal the writes would tear through the status line.
+	stream := !isTTY && !shouldRender && !jsonOutput && !htmlOutput && *outPath == "" && !tracker.Enabled() &&
+		outDir == "" && *splitOut == "" && *templateName == "" && !*countTokens && !*pickTopic && matchSection == nil && *codeLang == "" && !*dedupe && lineEnding == content.LineEndingKeep
+	if stream {
+		opts.Output = os.Stdout
+	}
+
+	var tr *transcript.Transcript
+	if *transcriptPath != "" {
+		tr = transcript.New(*transcriptPath, os.Args[1:], time.Now())
+	}
+
+	// Fetch each requested library in turn (batch mode when several are
+	// given, or several were marked in the interactive picker)
+	jobs := make([]job, len(args))
+	for i, query := range args {
+		// name@latest is the same as no version: the default document
+		name, version := client.SplitVersion(query)
+		if version == "latest" {
+			version = ""
+		}
+		if version != "" && (*showVersions || *branch != "") {
+			fmt.Fprintf(os.Stderr, "Error: %s names a version; drop it to use --versions or --branch\n", query)
+			os.Exit(1)
+		}
+		jobs[i] = job{name: name, version: version}
+		// Libraries picked while browsing need no search
+		if i < len(browsed) {
+			jobs[i].lib = &browsed[i]
+		}
+	}
+
+	// Like cache warm, a batch caches all of its libraries or none of them
+	batch, tx := cmd.BeginBatch(opts.Cache)
+	opts.Cache = batch
+
+	var output strings.Builder
+	var results []cmd.FetchResult
+	var pages []ui.HTMLDoc
+	var templateDocs []ui.TemplateDoc
+	title := ""
+	for i := 0; i < len(jobs); i++ {
+		j := jobs[i]
+		if tracker.Exhausted() {
+			tracker.Skip(j.name)
+			continue
+		}
+
+		runOpts := opts
+		runOpts.Library = j.lib
+		runOpts.Version = j.version
+		if *explain {
+			runOpts.Trace = &engine.Trace{}
+			if normalized := learn.Normalize(j.name); normalized != j.name {
+				runOpts.Trace.Add("query", "%q, matched as %q", j.name, normalized)
+			} else {
+				runOpts.Trace.Add("query", "%q", j.name)
+			}
+			if j.version != "" {
+				runOpts.Trace.Add("query", "version %s requested", j.version)
+			}
+		}
+		start := time.Now()
+		final := runQuery(j.name, runOpts, logger, tr, status, *ci)
+		elapsed := time.Since(start)
+		if err := final.StaleError(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: using an expired cached copy of %s: %v\n", final.Library().ID, err)
+		}
+		if r := final.Regression(); r != nil {
+			if final.WasFromCache() {
+				fmt.Fprintf(os.Stderr, "Warning: kept the cached copy of %s, as the refetched one looks like a regression: %s (--on-regression replace or both to take it)\n", final.Library().ID, r)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: the refetched copy of %s looks like a regression: %s\n", final.Library().ID, r)
+			}
+		}
+		// Remember which library a search query led to; IDs need no learning
+		if lib := final.Library(); lib != nil && j.lib == nil && !strings.HasPrefix(j.name, "/") {
+			learned.Record(j.name, lib.ID, time.Now())
+		}
+		if lib := final.Library(); lib != nil && title == "" {
+			title = lib.Title
+		}
+		hist.Add(historyEntryOf(j.name, runOpts, final))
+
+		// Fetch the other marked libraries right after this one
+		var more []job
+		for _, lib := range final.AdditionalLibraries() {
+			more = append(more, job{name: lib.ID, lib: &lib})
+		}
+		jobs = slices.Insert(jobs, i+1, more...)
+
+		if stream {
+			// Downloads were already written; cached documents still need printing
+			fmt.Print(final.Content())
+			explainQuery(runOpts.Trace, j.name)
+			continue
+		}
+
+		doc := final.Content()
+		if matchSection != nil {
+			before := len(content.ParseSnippets(doc))
+			doc = content.SelectSections(doc, matchSection)
+			if doc == "" {
+				fmt.Fprintf(os.Stderr, "Warning: no sections of %s match\n", final.Library().ID)
+			}
+			filter := fmt.Sprintf("--section %q", *section)
+			if *grepSection != "" {
+				filter = fmt.Sprintf("--grep-section %q", *grepSection)
+			}
+			runOpts.Trace.Add("filter", "%s: kept %d of %d snippets", filter, len(content.ParseSnippets(doc)), before)
+		}
+		if *codeLang != "" && doc != "" {
+			before := len(content.ParseSnippets(doc))
+			doc = content.SelectLanguage(doc, *codeLang)
+			if doc == "" {
+				fmt.Fprintf(os.Stderr, "Warning: %s has no %s snippets\n", final.Library().ID, *codeLang)
+			}
+			runOpts.Trace.Add("filter", "--code-lang %s: kept %d of %d snippets", *codeLang, len(content.ParseSnippets(doc)), before)
+		}
+		if *dedupe && doc != "" {
+			deduped, dups := content.Dedupe(doc, *dedupeSimilarity)
+			saved := budget.EstimateTokens(doc) - budget.EstimateTokens(deduped)
+			if dups.Dropped() > 0 && !*quiet && !*ci {
+				fmt.Fprintf(os.Stderr, "Deduplicated %s: dropped %d of %d snippets (%d exact, %d similar), ~%d tokens saved\n",
+					final.Library().ID, dups.Dropped(), dups.Snippets, dups.Exact, dups.Similar, saved)
+			}
+			runOpts.Trace.Add("filter", "--dedupe: dropped %d of %d snippets, ~%d tokens", dups.Dropped(), dups.Snippets, saved)
+			doc = deduped
+		}
+
+		if *countTokens {
+			explainQuery(runOpts.Trace, j.name)
+			label := final.Library().ID
+			if v := final.Version(); v != "" {
+				label += "@" + v
+			}
+			if output.Len() > 0 {
+				output.WriteString("\n")
+			}
+			cmd.WriteTokenReport(&output, label, doc)
+			continue
+		}
+
+		admitted := tracker.Admit(j.name, doc, summarize(final))
+		if admitted != doc {
+			runOpts.Trace.Add("filter", "token budget: kept %d of %d tokens", budget.EstimateTokens(admitted), budget.EstimateTokens(doc))
+		}
+		if !jsonOutput {
+			explainQuery(runOpts.Trace, j.name)
+		}
+		if jsonOutput {
+			results = append(results, fetchResult(j.name, runOpts, final, elapsed, admitted))
+			continue
+		}
+		if htmlOutput {
+			lib := final.Library()
+			pages = append(pages, ui.HTMLDoc{Title: lib.Title, LibraryID: lib.ID, Version: final.Version(), Content: admitted})
+			continue
+		}
+		if outDir != "" {
+			if err := writeDocFile(filepath.Join(outDir, outputFileName(final)), admitted, lineEnding, *force); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			continue
+		}
+		if *splitOut != "" {
+			if err := writeSnippetFiles(*splitOut, final, admitted, lineEnding, *force); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			continue
+		}
+		if tmpl != nil {
+			// Documents left out by the budget get no entry
+			if admitted != "" {
+				templateDocs = append(templateDocs, templateDoc(j.name, runOpts, final, admitted))
+			}
+			continue
+		}
+		output.WriteString(admitted)
+	}
+	cmd.CommitBatch(tx)
+
+	saveTranscript(tr, logger)
+	if learned != nil {
+		if err := learned.Save(time.Now()); err != nil {
+			logger.Warn("Failed to save learned queries", "error", err)
+		}
+	}
+	if hist != nil {
+		if err := hist.Save(); err != nil {
+			logger.Warn("Failed to save history", "error", err)
+		}
+	}
+
+	if report := tracker.Report(); report != "" {
+		fmt.Fprint(os.Stderr, report)
+	}
+	if metrics != nil {
+		metrics.WriteSummary(os.Stderr, time.Since(runStart))
+	}
+
+	if jsonOutput {
+		var doc strings.Builder
+		if err := cmd.WriteFetchJSON(&doc, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			os.Exit(1)
+		}
+		if *outPath == "" {
+			fmt.Print(doc.String())
+			return
+		}
+		output = doc
+		lineEnding = content.LineEndingKeep
+	}
+	if htmlOutput {
+		pageTitle := title
+		if len(pages) > 1 {
+			pageTitle = fmt.Sprintf("%d libraries", len(pages))
+		}
+		var doc strings.Builder
+		render := ui.RenderHTML
+		if pdfOutput {
+			render = func(w io.Writer, title string, docs []ui.HTMLDoc) error {
+				return ui.RenderPDF(ctx, w, title, docs)
+			}
+		}
+		if err := render(&doc, pageTitle, pages); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *outPath == "" {
+			fmt.Print(doc.String())
+			return
+		}
+		output = doc
+		lineEnding = content.LineEndingKeep
+	}
+	if tmpl != nil {
+		var doc strings.Builder
+		if err := ui.RenderTemplate(&doc, tmpl, templateDocs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		output = doc
+	}
+	if outDir != "" || *splitOut != "" {
+		return
+	}
+	if *outPath != "" {
+		if err := writeDocFile(*outPath, output.String(), lineEnding, *force); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(jobs) > 1 {
+		title = fmt.Sprintf("%d libraries", len(jobs))
+	}
+	// Token reports and templated documents are printed as they are
+	writeOutput(output.String(), title, shouldRender && !*countTokens && tmpl == nil, *noPager || !isTTY || status != nil || *countTokens, lineEnding, logger)
+}
+
+// fetchResult describes a finished lookup for --format json
+func fetchResult(query string, opts tui.Options, m tui.Model, elapsed time.Duration, doc string) cmd.FetchResult {
+	topic := m.Topic()
+	if topic == "" {
+		topic = opts.Topic
+	}
+	return cmd.FetchResult{
+		Query:     query,
+		Library:   *m.Library(),
+		Version:   m.Version(),
+		Branch:    m.Branch(),
+		Topic:     topic,
+		FromCache: m.WasFromCache(),
+		Duration:  elapsed,
+		Content:   doc,
+		Explain:   opts.Trace.Steps(),
+	}
+}
 
-	// Create program with appropriate options
-	// Output TUI to stderr so stdout only contains the final content (for piping)
-	var p *tea.Program
-	p = tea.NewProgram(m, tea.WithInput(os.Stdin), tea.WithOutput(os.Stderr))
+// templateDoc describes a finished lookup for --template
+func templateDoc(query string, opts tui.Options, m tui.Model, doc string) ui.TemplateDoc {
+	r := fetchResult(query, opts, m, 0, doc)
+	return ui.TemplateDoc{
+		Query:     query,
+		Title:     r.Library.Title,
+		LibraryID: r.Library.ID,
+		Version:   r.Version,
+		Branch:    r.Branch,
+		Topic:     r.Topic,
+		FetchedAt: m.FetchedAt(),
+		FromCache: r.FromCache,
+		Content:   doc,
+	}
+}
+
+// explainQuery prints the --explain trace of a query to stderr, if one was
+// recorded
+func explainQuery(trace *engine.Trace, query string) {
+	if trace == nil {
+		return
+	}
+	trace.WriteText(os.Stderr, query)
+}
+
+// sectionMatcher builds the title filter of --section (a case-insensitive
+// substring) or --grep-section (a regular expression), or nil for neither
+func sectionMatcher(section, pattern string) (func(string) bool, error) {
+	switch {
+	case section != "" && pattern != "":
+		return nil, errors.New("--section and --grep-section are mutually exclusive")
+	case section != "":
+		section = strings.ToLower(section)
+		return func(title string) bool {
+			return strings.Contains(strings.ToLower(title), section)
+		}, nil
+	case pattern != "":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep-section pattern: %w", err)
+		}
+		return re.MatchString, nil
+	}
+	return nil, nil
+}
+
+// job is one library to fetch: a search query, or a library already picked
+type job struct {
+	name    string
+	version string
+	lib     *client.Library
+}
+
+// outputFileName names a library's file in an -o directory:
+// <org>__<name>@<version>.md, with "latest" for the default version
+func outputFileName(m tui.Model) string {
+	org, name, _ := strings.Cut(strings.Trim(m.Library().ID, "/"), "/")
+	version := m.Version()
+	if version == "" {
+		version = "latest"
+	}
+	name = strings.ReplaceAll(name, "/", "_")
+	version = strings.ReplaceAll(version, "/", "_")
+	return fmt.Sprintf("%s__%s@%s.md", org, name, version)
+}
+
+// writeSnippetFiles writes each snippet of a library's doc to its own
+// file in a directory of dir named like outputFileName, for tools that
+// ingest documentation chunk by chunk: 0001-<title>.md and so on, in
+// document order, each headed by YAML front matter naming the library,
+// version, title and source. With force, the snippet files of an earlier
+// split are replaced, and those it had beyond the new count removed.
+func writeSnippetFiles(dir string, m tui.Model, doc string, le content.LineEnding, force bool) error {
+	dir = filepath.Join(dir, strings.TrimSuffix(outputFileName(m), ".md"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if force {
+		old, _ := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9]-*.md"))
+		for _, path := range old {
+			os.Remove(path)
+		}
+	}
+
+	version := m.Version()
+	if version == "" {
+		version = "latest"
+	}
+	snippets := content.ParseSnippets(doc)
+	for i, s := range snippets {
+		var b strings.Builder
+		b.WriteString("---\n")
+		writeFrontMatter(&b, "title", s.Title)
+		writeFrontMatter(&b, "library", m.Library().ID)
+		writeFrontMatter(&b, "version", version)
+		writeFrontMatter(&b, "source", s.Field("SOURCE"))
+		fmt.Fprintf(&b, "snippet: %d\n", i+1)
+		b.WriteString("---\n\n")
+		b.WriteString(strings.TrimSpace(s.Text) + "\n")
+
+		path := filepath.Join(dir, fmt.Sprintf("%04d-%s.md", i+1, slug(s.Title)))
+		if err := createDocFile(path, b.String(), le, force); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %d snippets to %s\n", len(snippets), dir)
+	return nil
+}
+
+// writeFrontMatter writes a YAML front matter field, quoted as JSON (which
+// YAML reads as is), unless value is empty
+func writeFrontMatter(b *strings.Builder, key, value string) {
+	if value == "" {
+		return
+	}
+	quoted, _ := json.Marshal(value)
+	fmt.Fprintf(b, "%s: %s\n", key, quoted)
+}
+
+// slugMaxLen bounds the title part of a snippet's file name
+const slugMaxLen = 60
+
+// slug turns a snippet title into a file name part safe on every
+// platform: lowercase letters and digits separated by single dashes,
+// "snippet" for a title that has none
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if b.Len() >= slugMaxLen {
+			break
+		}
+		if 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return "snippet"
+	}
+	return b.String()
+}
+
+// writeDocFile writes doc to path with le line endings, creating its
+// directory. An existing file is only replaced when force is set.
+func writeDocFile(path, doc string, le content.LineEnding, force bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := createDocFile(path, doc, le, force); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
 
-	finalModel, err := p.Run()
+// createDocFile writes doc to path in an existing directory like
+// writeDocFile, without reporting it
+func createDocFile(path, doc string, le content.LineEnding, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
 	if err != nil {
-		logger.Error("Application error", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if _, err := f.WriteString(content.NormalizeLineEndings(doc, le)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// writeOutput prints doc to stdout with le line endings, or opens the pager
+// unless noPager is set. With shouldRender the markdown is rendered first;
+// the pager renders it progressively so huge documents open immediately.
+func writeOutput(doc, title string, shouldRender, noPager bool, le content.LineEnding, logger *log.Logger) {
+	emit := func(s string) {
+		fmt.Print(content.NormalizeLineEndings(s, le))
+	}
+
+	var render tui.Renderer
+	if shouldRender && doc != "" {
+		r, err := ui.MarkdownRenderer(0)
+		if err != nil {
+			logger.Warn("Markdown rendering failed, showing plain text", "error", err)
+		} else {
+			render = r
+		}
+	}
+
+	if noPager || doc == "" {
+		emit(renderAll(doc, render, logger))
+		return
+	}
+
+	p := loadPrefs(logger)
+	result, err := tui.RunRenderingPager(title, doc, render, p, tea.WithInput(os.Stdin), tea.WithOutput(os.Stderr))
+	if err != nil {
+		logger.Error("Pager failed", "error", err)
+		emit(renderAll(doc, render, logger))
+		return
+	}
+	if p != nil {
+		if err := p.Save(); err != nil {
+			logger.Warn("Failed to save preferences", "error", err)
+		}
+	}
+
+	if result.Print {
+		emit(renderAll(doc, render, logger))
+	}
+}
+
+// renderAll renders doc in one pass, falling back to the plain text
+func renderAll(doc string, render tui.Renderer, logger *log.Logger) string {
+	if render == nil {
+		return doc
+	}
+	rendered, err := render(doc)
+	if err != nil {
+		logger.Warn("Markdown rendering failed, showing plain text", "error", err)
+		return doc
+	}
+	return rendered
+}
+
+// exitNoDocumentation is the exit status when a library has no usable docs
+// yet, so scripts can retry later instead of treating it as a failure
+const exitNoDocumentation = 3
+
+// exitCancelled is the exit status of a run cancelled with ctrl+c, esc or
+// a signal, following the shell's 128+SIGINT convention
+const exitCancelled = 130
+
+// Timeouts of --ci when neither the config nor a flag sets them
+const (
+	ciSearchTimeout = 10 * time.Second
+	ciFetchTimeout  = 2 * time.Minute
+)
+
+// runQuery runs the Bubble Tea program for a single query and exits on
+// failure. With a plain writer the model runs without Bubble Tea and
+// reports progress to it as lines. The lookup is recorded in tr when a
+// transcript was requested. With jsonErrors a failure is reported as a
+// JSON line on stderr instead of a log message.
+func runQuery(query string, opts tui.Options, logger *log.Logger, tr *transcript.Transcript, plain io.Writer, jsonErrors bool) tui.Model {
+	m := tui.NewModel(query, opts)
+
+	var finalModel tea.Model = m
+	if plain != nil {
+		finalModel = tui.RunPlain(m, plain)
+	} else {
+		// Create program with appropriate options
+		// Output TUI to stderr so stdout only contains the final content (for piping)
+		var p *tea.Program
+		// Signals are handled through opts.Context instead of Bubble Tea, which
+		// would quit without letting a download clean up
+		p = tea.NewProgram(m, tea.WithInput(os.Stdin), tea.WithOutput(os.Stderr), tea.WithoutSignalHandler())
+
+		var err error
+		finalModel, err = p.Run()
+		if err != nil {
+			logger.Error("Application error", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Remember selector choices even if the user cancelled
+	if opts.Prefs != nil {
+		if err := opts.Prefs.Save(); err != nil {
+			logger.Warn("Failed to save preferences", "error", err)
+		}
 	}
 
 	// Extract final state
 	final := finalModel.(tui.Model)
 
+	if tr != nil {
+		tr.Add(sessionOf(query, opts, final))
+	}
+
+	// Cancellations are reported too, so a CI log shows why the step stopped
+	if err := final.Err(); err != nil && jsonErrors {
+		explainQuery(opts.Trace, query)
+		cmd.WriteErrorJSON(os.Stderr, query, cmd.ErrorCode(err), err)
+	}
+	if errors.Is(final.Err(), tui.ErrCancelled) {
+		saveTranscript(tr, logger)
+		os.Exit(exitCancelled)
+	}
 	if final.Err() != nil {
-		logger.Error("Fetch failed", "error", final.Err())
+		if !jsonErrors {
+			explainQuery(opts.Trace, query)
+			logger.Error("Fetch failed", "error", final.Err())
+		}
+		saveTranscript(tr, logger)
+		if errors.Is(final.Err(), engine.ErrNoDocumentation) {
+			os.Exit(exitNoDocumentation)
+		}
+		os.Exit(1)
+	}
+
+	return final
+}
+
+// sessionOf records a finished lookup for the transcript
+func sessionOf(query string, opts tui.Options, m tui.Model) transcript.Session {
+	session := transcript.Session{
+		Query:       query,
+		Results:     transcript.Results(m.SearchResults()),
+		Library:     m.Library(),
+		Version:     m.Version(),
+		Branch:      m.Branch(),
+		Topic:       opts.Topic,
+		PickedTopic: m.Topic(),
+		FromCache:   m.WasFromCache(),
+	}
+	for _, lib := range m.AdditionalLibraries() {
+		session.AlsoSelected = append(session.AlsoSelected, lib.ID)
+	}
+	if m.Err() != nil {
+		session.Error = m.Err().Error()
+	}
+	return session
+}
+
+// saveTranscript writes tr if a transcript was requested
+func saveTranscript(tr *transcript.Transcript, logger *log.Logger) {
+	if tr == nil {
+		return
+	}
+	if err := tr.Save(); err != nil {
+		logger.Error("Failed to save transcript", "error", err)
+	}
+}
+
+// summarize builds the short stand-in emitted when a document exceeds the budget
+func summarize(m tui.Model) string {
+	lib := m.Library()
+	if lib == nil {
+		return ""
+	}
+	summary := fmt.Sprintf("# %s (%s)\n\n", lib.Title, lib.ID)
+	if lib.Description != "" {
+		summary += lib.Description + "\n\n"
+	}
+	summary += fmt.Sprintf("[Omitted: ~%d tokens over budget. Fetch with: ctx7 %s]\n\n",
+		budget.EstimateTokens(m.Content()), lib.ID)
+	return summary
+}
+
+// loadPrefs reads the remembered UI preferences, or returns nil if they
+// cannot be located
+func loadPrefs(logger *log.Logger) *prefs.Prefs {
+	path, err := prefs.DefaultPath()
+	if err != nil {
+		logger.Warn("Preferences unavailable", "error", err)
+		return nil
+	}
+
+	p, err := prefs.Load(path)
+	if err != nil {
+		// Start from empty prefs; the next save replaces the broken file
+		logger.Warn("Failed to load preferences", "error", err)
+	}
+	return p
+}
+
+// loadLearned reads the libraries learned for queries, or returns nil if
+// they cannot be located
+func loadLearned(logger *log.Logger) *learn.Store {
+	path, err := learn.DefaultPath()
+	if err != nil {
+		logger.Warn("Learned queries unavailable", "error", err)
+		return nil
+	}
+
+	s, err := learn.Load(path)
+	if err != nil {
+		// Start over; the next save replaces the broken file
+		logger.Warn("Failed to load learned queries", "error", err)
+	}
+	return s
+}
+
+// loadAliases reads the user's library aliases, or returns nil if they
+// cannot be located
+func loadAliases(logger *log.Logger) *alias.Store {
+	path, err := alias.DefaultPath()
+	if err != nil {
+		logger.Warn("Aliases unavailable", "error", err)
+		return nil
+	}
+
+	s, err := alias.Load(path)
+	if err != nil {
+		// Start over; the next save replaces the broken file
+		logger.Warn("Failed to load aliases", "error", err)
+	}
+	return s
+}
+
+// loadHistory reads the past fetches, or returns nil if they cannot be
+// located. Without a logger, problems are reported on stderr.
+func loadHistory(logger *log.Logger) *history.History {
+	warn := func(msg string, err error) {
+		if logger == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", msg, err)
+			return
+		}
+		logger.Warn(msg, "error", err)
+	}
+
+	path, err := history.DefaultPath()
+	if err != nil {
+		warn("History unavailable", err)
+		return nil
+	}
+
+	h, err := history.Load(path)
+	if err != nil {
+		// Start over; the next save replaces the broken file
+		warn("Failed to load history", err)
+	}
+	return h
+}
+
+// historyEntry returns the nth most recent fetch, exiting when there is
+// none
+func historyEntry(n int) history.Entry {
+	e, ok := loadHistory(nil).Get(n)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: no history entry %d (ctx7 history lists them)\n", n)
+		os.Exit(1)
+	}
+	return e
+}
+
+// historyRunArgs turns ctx7 history run [OPTIONS] <N> into the arguments
+// that fetch entry N again with those options
+func historyRunArgs(args []string) []string {
+	rest := args[3:]
+	if len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: ctx7 history run [OPTIONS] <N>")
+		os.Exit(1)
+	}
+	n, err := strconv.Atoi(rest[len(rest)-1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %q is not a history entry number\n", rest[len(rest)-1])
+		os.Exit(1)
+	}
+
+	runArgs := append([]string{args[0]}, rest[:len(rest)-1]...)
+	return append(runArgs, historyEntry(n).Args()...)
+}
+
+// browse lets the user search as they type, or pick a recent fetch. It
+// returns the arguments of what to fetch and, for libraries picked from
+// the results, the libraries in the same order.
+func browse(cfg *config.Config, baseURL string, filter engine.Filter, rank engine.Ranker) ([]string, []client.Library) {
+	cacheManager, _ := initCache()
+	logger := ui.InitLogger(false)
+	aliases := loadAliases(logger)
+	eng := engine.New(engine.Options{
+		Client:       newClient(cfg, baseURL),
+		Cache:        cacheManager,
+		MaxAge:       cfg.CacheTTL,
+		SearchMaxAge: cfg.SearchCacheTTL,
+		Learned:      loadLearned(logger),
+		Aliases:      aliases,
+		Filter:       filter,
+		Ranker:       rank,
+	})
+
+	choice, ok, err := tui.RunBrowse(context.Background(), eng.Search, tui.BrowseOptions{
+		Recent:     loadHistory(logger).Recent(20),
+		Cache:      cacheManager,
+		MaxAge:     cfg.CacheTTL,
+		Aliases:    aliases,
+		CardHeight: cfg.CardHeight,
+	}, tea.WithInput(os.Stdin), tea.WithOutput(os.Stderr))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if !ok {
+		os.Exit(exitCancelled)
+	}
+
+	if choice.Recent != nil {
+		flag.CommandLine.Parse(choice.Recent.Args())
+		return flag.Args(), nil
+	}
+	args := make([]string, len(choice.Libraries))
+	for i, lib := range choice.Libraries {
+		args[i] = lib.ID
+	}
+	return args, choice.Libraries
+}
 
-	// Output content to stdout
-	fmt.Print(final.Content())
+// historyEntryOf records a finished lookup for the history
+func historyEntryOf(query string, opts tui.Options, m tui.Model) history.Entry {
+	e := history.Entry{Query: query, Version: m.Version(), Branch: m.Branch(), Topic: m.Topic(), Time: time.Now()}
+	if e.Topic == "" {
+		e.Topic = opts.Topic
+	}
+	if lib := m.Library(); lib != nil {
+		e.LibraryID, e.Title = lib.ID, lib.Title
+	}
+	return e
+}
+
+// ranker returns the search ranking chosen by config rank_command or
+// rank_profile, or nil for context7's order with learned libraries first
+func ranker(cfg *config.Config) (engine.Ranker, error) {
+	if cfg.RankCommand != "" {
+		return engine.CommandRanker{Command: cfg.RankCommand}, nil
+	}
+	if cfg.RankProfile == "" {
+		return nil, nil
+	}
+	if w, ok := cfg.RankProfiles[cfg.RankProfile]; ok {
+		return engine.Weights(w), nil
+	}
+	if w, ok := engine.RankProfiles[cfg.RankProfile]; ok {
+		return w, nil
+	}
+	return nil, fmt.Errorf("unknown rank_profile %q", cfg.RankProfile)
+}
+
+// newClient creates the API client and exits if its TLS settings are
+// unusable; a --base-url flag value overrides the config
+func newClient(cfg *config.Config, baseURL string) *client.Client {
+	baseURL = resolveBaseURL(cfg, baseURL)
+	if cfg.InsecureSkipVerify {
+		fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled (insecure_skip_verify)")
+	}
+
+	c, err := client.NewClientWithOptions(client.Options{
+		BaseURL:            baseURL,
+		CABundle:           cfg.CABundle,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		SearchTimeout:      cfg.SearchTimeout,
+		FetchTimeout:       cfg.FetchTimeout,
+		Language:           cfg.Lang,
+		ManifestURL:        cfg.ManifestURL,
+		ManifestKey:        cfg.ManifestKey,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return c
+}
+
+// resolveBaseURL returns the context7 instance to query: the --base-url
+// flag value, the config (or $CTX7_BASE_URL), or the public instance
+func resolveBaseURL(cfg *config.Config, baseURL string) string {
+	if baseURL == "" {
+		baseURL = cfg.BaseURL
+	}
+	if baseURL == "" {
+		return client.DefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/")
 }
 
 func initCache() (*cache.Cache, error) {
-	homeDir, err := os.UserHomeDir()
+	cacheDir, err := cache.DefaultDir()
 	if err != nil {
 		return nil, err
 	}
 
-	cacheDir := filepath.Join(homeDir, ".cache", "ctx7")
-	return cache.NewCache(cacheDir)
+	backend := cache.Backend(os.Getenv("CTX7_CACHE_BACKEND"))
+	return cache.NewCacheWithShared(cacheDir, backend, cache.SharedDirs())
+}
+
+// extractOption removes a --<name> <value> (or --<name>=<value>) option,
+// such as --cache-dir, from args, returning its value and the remaining
+// arguments
+func extractOption(args []string, name string) (string, []string) {
+	var dir string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		option, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || option != name {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		dir = value
+	}
+	return dir, rest
 }
 
 func printUsage() {
-	fmt.Fprintln(os.Stderr, "Usage: ctx7 [OPTIONS] <library-name>")
+	fmt.Fprintln(os.Stderr, "Usage: ctx7 [OPTIONS] <library-name>[@version] [<library-name>[@version]...]")
+	fmt.Fprintln(os.Stderr, "       ctx7 [OPTIONS]                (on a terminal: search as you type)")
 	fmt.Fprintln(os.Stderr, "       ctx7 cache <command> [OPTIONS]")
+	fmt.Fprintln(os.Stderr, "       ctx7 search [--json] [--org <org>] <query>")
+	fmt.Fprintln(os.Stderr, "       ctx7 versions [--json] <library>")
+	fmt.Fprintln(os.Stderr, "       ctx7 snippets <library> <query>")
+	fmt.Fprintln(os.Stderr, "       ctx7 replay <transcript.json>")
+	fmt.Fprintln(os.Stderr, "       ctx7 grep [-i] [-F] [-C N] <pattern> [library[@version]]")
+	fmt.Fprintln(os.Stderr, "       ctx7 stats export [--json]")
+	fmt.Fprintln(os.Stderr, "       ctx7 learned list|clear")
+	fmt.Fprintln(os.Stderr, "       ctx7 alias add <name> <library> | list | remove <name>")
+	fmt.Fprintln(os.Stderr, "       ctx7 history [list|run <N>|clear]")
+	fmt.Fprintln(os.Stderr, "       ctx7 serve [--http <addr>] [--shutdown-timeout 30s]")
+	fmt.Fprintln(os.Stderr, "       ctx7 watch [--interval 1h] [--once]")
+	fmt.Fprintln(os.Stderr, "       ctx7 stack [-o <file>] [--budget N] <stack> | list")
+	fmt.Fprintln(os.Stderr, "       ctx7 bundle [--target claude|cursor|copilot] [--budget N] [--reference] [<library>[@version]...]")
+	fmt.Fprintln(os.Stderr, "       ctx7 selftest [-v]")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Options:")
-	fmt.Fprintln(os.Stderr, "  -i, --interactive       Show selection menu for multiple matches")
+	fmt.Fprintln(os.Stderr, "  -i, --interactive       Show selection menu for multiple matches (space marks several)")
 	fmt.Fprintln(os.Stderr, "  -v, --verbose           Show detailed logs")
 	fmt.Fprintln(os.Stderr, "  --versions              Show version selection menu")
+	fmt.Fprintln(os.Stderr, "  --branch <name>         Fetch docs for a specific branch")
+	fmt.Fprintln(os.Stderr, "  --topic <topic>         Fetch only docs about a topic")
+	fmt.Fprintln(os.Stderr, "  --topics                Pick a topic from the document's table of contents")
+	fmt.Fprintln(os.Stderr, "  --section <text>        Output only snippets whose titles contain <text>")
+	fmt.Fprintln(os.Stderr, "  --grep-section <re>     Output only snippets whose titles match a regular expression")
+	fmt.Fprintln(os.Stderr, "  --code-lang <lang>      Output only snippets with code in <lang>, e.g. go, ts, python")
+	fmt.Fprintln(os.Stderr, "  --dedupe                Drop repeated snippets and report the tokens saved")
+	fmt.Fprintln(os.Stderr, "  --dedupe-similarity <s> With --dedupe, also drop snippets <s> (0-1) similar to an earlier one")
+	fmt.Fprintln(os.Stderr, "  --budget <tokens>       Token budget across all fetched libraries")
+	fmt.Fprintln(os.Stderr, "  --budget-mode <mode>    When over budget: stop (skip) or summary")
+	fmt.Fprintln(os.Stderr, "  --template <name>       Combine the docs through claude-xml, markdown-toc or a template file")
+	fmt.Fprintln(os.Stderr, "  --count-tokens          Report approximate tokens per model family and section instead of the docs")
+	fmt.Fprintln(os.Stderr, "  --base-url <url>        Query a mirror or self-hosted context7 instance")
+	fmt.Fprintln(os.Stderr, "  --search-timeout <d>    Give up on a search after <d> (default 5s)")
+	fmt.Fprintln(os.Stderr, "  --fetch-timeout <d>     Give up on a download after <d> (default 5m)")
+	fmt.Fprintln(os.Stderr, "  --lang <code>           Hint the query's language to the search API, e.g. ja")
+	fmt.Fprintln(os.Stderr, "  --last                  Fetch the most recent library from ctx7 history again")
 	fmt.Fprintln(os.Stderr, "  --no-cache              Skip cache, force fresh fetch")
+	fmt.Fprintln(os.Stderr, "  --no-prefetch           Don't download the top results while the picker is open")
+	fmt.Fprintln(os.Stderr, "  --max-staleness <age>   Refetch cached docs older than <age> (30d, 12h) unless unchanged upstream")
+	fmt.Fprintln(os.Stderr, "  --min-stars <n>         Leave libraries with fewer stars out of the search results")
+	fmt.Fprintln(os.Stderr, "  --min-trust <score>     Leave libraries with a lower trust score out of the search results")
+	fmt.Fprintln(os.Stderr, "  --exclude-deprecated    Leave deprecated libraries out of the search results")
+	fmt.Fprintln(os.Stderr, "  --on-regression <p>     When a refetch is much smaller or older than the cache: keep, replace, both")
+	fmt.Fprintln(os.Stderr, "  --insecure              Don't verify documents against the configured manifest")
+	fmt.Fprintln(os.Stderr, "  -o <file>               Write the output to <file> instead of stdout")
+	fmt.Fprintln(os.Stderr, "  -o <dir>/               Write each library to <dir>/<org>__<name>@<version>.md")
+	fmt.Fprintln(os.Stderr, "  --force                 Let -o overwrite existing files")
+	fmt.Fprintln(os.Stderr, "  --split-out <dir>       Write each snippet to its own file with front matter, for RAG ingestion")
+	fmt.Fprintln(os.Stderr, "  --output-dir <dir>      Same as -o <dir>/")
+	fmt.Fprintln(os.Stderr, "  --transcript <file>     Record results and interactive selections as JSON")
+	fmt.Fprintln(os.Stderr, "  --crlf / --lf           Normalize line endings of the output")
+	fmt.Fprintln(os.Stderr, "  --format <fmt>          text (default), json (metadata, timing, parsed snippets), html or pdf")
+	fmt.Fprintln(os.Stderr, "  --no-pager              Print content instead of opening the pager")
+	fmt.Fprintln(os.Stderr, "  --plain                 No TUI, plain progress lines (default when stderr isn't a terminal)")
+	fmt.Fprintln(os.Stderr, "  -q, --quiet             No TUI and no progress lines, only errors")
+	fmt.Fprintln(os.Stderr, "  --ci                    Quiet and non-interactive, no color, 10s/2m timeouts, JSON errors")
+	fmt.Fprintln(os.Stderr, "  --no-spinner            Static status lines instead of animation (alias --reduced-motion)")
+	fmt.Fprintln(os.Stderr, "  --render / --no-render  Force or disable markdown rendering (default: on for terminals)")
 	fmt.Fprintln(os.Stderr, "  --clear-cache           Clear all cached content")
+	fmt.Fprintln(os.Stderr, "  --cache-dir <dir>       Cache location, also for cache commands (default $CTX7_CACHE_DIR)")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Cache Commands:")
 	fmt.Fprintln(os.Stderr, "  ctx7 cache stats        Show cache statistics")
 	fmt.Fprintln(os.Stderr, "  ctx7 cache list         List all cached libraries")
+	fmt.Fprintln(os.Stderr, "  ctx7 cache info <lib>   Show a cached library's versions, metadata and paths")
 	fmt.Fprintln(os.Stderr, "  ctx7 cache clear        Clear entire cache")
-	fmt.Fprintln(os.Stderr, "  ctx7 cache remove <lib> Remove specific library")
-	fmt.Fprintln(os.Stderr, "  ctx7 cache update <lib> Force refresh specific library")
+	fmt.Fprintln(os.Stderr, "  ctx7 cache remove <lib> Remove specific library (org/* for a whole org)")
+	fmt.Fprintln(os.Stderr, "  ctx7 cache update <lib> Force refresh specific library (--all --refetch for everything)")
 	fmt.Fprintln(os.Stderr, "  ctx7 cache prune        Remove old cache entries")
+	fmt.Fprintln(os.Stderr, "  ctx7 cache warm <lib>.. Download docs ahead of time (CI images)")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Environment:")
+	fmt.Fprintln(os.Stderr, "  CTX7_CACHE_DIR          Cache location (default $XDG_CACHE_HOME/ctx7 or the platform cache dir)")
+	fmt.Fprintln(os.Stderr, "  CTX7_TMP_DIR            Staging directory for cache writes (default <cache dir>/tmp)")
+	fmt.Fprintln(os.Stderr, "  CTX7_CACHE_BACKEND      Cache storage backend: files (default), sqlite")
+	fmt.Fprintln(os.Stderr, "  CTX7_SHARED_CACHE       Read-only caches beneath the user cache, e.g. /var/cache/ctx7 (separated like PATH)")
+	fmt.Fprintln(os.Stderr, "  CTX7_BASE_URL           context7 instance to query (default https://context7.com)")
+	fmt.Fprintln(os.Stderr, "  CTX7_CA_BUNDLE          Extra trusted CA certificates (PEM) for TLS-intercepting proxies")
+	fmt.Fprintln(os.Stderr, "  HTTPS_PROXY, NO_PROXY   Proxy settings for requests to context7")
+	fmt.Fprintln(os.Stderr, "  CTX7_CONFIG             Config file (default ~/.config/ctx7/config.toml)")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Examples:")
 	fmt.Fprintln(os.Stderr, "  ctx7 react-router")
 	fmt.Fprintln(os.Stderr, "  ctx7 -i react")
+	fmt.Fprintln(os.Stderr, "  ctx7 react-router@6.4.0")
 	fmt.Fprintln(os.Stderr, "  ctx7 --versions react-router")
+	fmt.Fprintln(os.Stderr, "  ctx7 --branch dev react-router")
+	fmt.Fprintln(os.Stderr, "  ctx7 --topics next.js")
+	fmt.Fprintln(os.Stderr, "  ctx7 --section middleware next.js")
+	fmt.Fprintln(os.Stderr, "  ctx7 --budget 100000 react next.js tailwind")
+	fmt.Fprintln(os.Stderr, "  ctx7 -o docs/ react next.js")
+	fmt.Fprintln(os.Stderr, "  ctx7 snippets next.js middleware redirect")
+	fmt.Fprintln(os.Stderr, "  ctx7 -i --transcript session.json react && ctx7 replay session.json")
 	fmt.Fprintln(os.Stderr, "  ctx7 cache stats")
 	fmt.Fprintln(os.Stderr, "  ctx7 cache prune --days 30")
+	fmt.Fprintln(os.Stderr, "  ctx7 cache warm --from-file libs.txt")
 }
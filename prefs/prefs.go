@@ -0,0 +1,73 @@
+package prefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Prefs holds interactive choices remembered between runs. It is UI state,
+// kept apart from the user's config file.
+type Prefs struct {
+	// LibrarySort is the library selector's last sort mode (e.g. "trust")
+	LibrarySort string `json:"library_sort,omitempty"`
+	// LibrarySortAscending reverses LibrarySort, smallest or oldest first
+	LibrarySortAscending bool `json:"library_sort_ascending,omitempty"`
+	// LibraryFilter is the library selector's last filter text
+	LibraryFilter string `json:"library_filter,omitempty"`
+	// LibraryView is the library selector's layout: "compact" for one line
+	// per library, or empty for cards
+	LibraryView string `json:"library_view,omitempty"`
+	// HelpExpanded keeps the selector's full key help open
+	HelpExpanded bool `json:"help_expanded,omitempty"`
+	// PagerInline shows the pager in the normal screen instead of the
+	// alternate screen, so the document stays in the scrollback
+	PagerInline bool `json:"pager_inline,omitempty"`
+
+	path string
+}
+
+// DefaultPath returns the state file location (~/.config/ctx7/state.json)
+func DefaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "ctx7", "state.json"), nil
+}
+
+// Load reads prefs from path; a missing file yields empty prefs
+func Load(path string) (*Prefs, error) {
+	p := &Prefs{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return p, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return p, nil
+}
+
+// Save writes prefs back to the file they were loaded from
+func (p *Prefs) Save() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.WriteFile(p.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+//go:build ignore
+
+// gen builds libraries.json.gz from popular.txt. Each ID is looked up with
+// the search API to record its current title; IDs the API no longer knows
+// are dropped with a warning. With -offline the titles are taken from the
+// IDs instead, for building without network access.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// entry mirrors registry.Entry; gen can't import the package it builds
+type entry struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	Names []string `json:"names"`
+}
+
+func main() {
+	offline := flag.Bool("offline", false, "skip checking IDs against the search API")
+	flag.Parse()
+
+	entries, err := readSeeds("popular.txt")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !*offline {
+		c := client.NewClient()
+		kept := entries[:0]
+		for _, e := range entries {
+			title, err := lookupTitle(c, e)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: dropping %s: %v\n", e.ID, err)
+				continue
+			}
+			e.Title = title
+			kept = append(kept, e)
+		}
+		entries = kept
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal registry: %v\n", err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+
+	if err := os.WriteFile("libraries.json.gz", buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write registry: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d libraries (%d bytes)\n", len(entries), buf.Len())
+}
+
+// readSeeds parses lines of "<id> <name>..." skipping blanks and # comments
+func readSeeds(file string) ([]entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open seed list: %w", err)
+	}
+	defer f.Close()
+
+	var entries []entry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/") {
+			return nil, fmt.Errorf("%s:%d: want \"/<org>/<name> <name>...\"", file, n)
+		}
+		entries = append(entries, entry{
+			ID:    fields[0],
+			Title: path.Base(fields[0]),
+			Names: fields[1:],
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// lookupTitle searches for the entry's first name and returns the title of
+// the result with the entry's ID
+func lookupTitle(c *client.Client, e entry) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results, err := c.SearchLibraries(ctx, e.Names[0])
+	if err != nil {
+		return "", err
+	}
+	for _, lib := range results {
+		if lib.ID == e.ID {
+			return lib.Title, nil
+		}
+	}
+	return "", fmt.Errorf("not in the search results for %q", e.Names[0])
+}
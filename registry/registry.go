@@ -0,0 +1,73 @@
+// Package registry resolves the names of popular libraries to their
+// context7 IDs without a search, from a list bundled into the binary.
+package registry
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Entry is a bundled library and the names that resolve to it
+type Entry struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	Names []string `json:"names"`
+}
+
+//go:embed libraries.json.gz
+var bundled []byte
+
+var (
+	loadOnce sync.Once
+	byName   map[string]Entry
+)
+
+// load decompresses the bundled list on first use. A damaged list leaves
+// the registry empty, so every query falls back to search.
+func load() {
+	byName = map[string]Entry{}
+
+	zr, err := gzip.NewReader(bytes.NewReader(bundled))
+	if err != nil {
+		return
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+	for _, e := range entries {
+		for _, name := range e.Names {
+			byName[normalize(name)] = e
+		}
+	}
+}
+
+// normalize folds queries that differ only in case or surrounding space
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Lookup returns the bundled library named exactly name, ignoring case
+func Lookup(name string) (client.Library, bool) {
+	loadOnce.Do(load)
+
+	e, ok := byName[normalize(name)]
+	if !ok {
+		return client.Library{}, false
+	}
+	return client.Library{ID: e.ID, Title: e.Title}, true
+}
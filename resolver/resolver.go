@@ -0,0 +1,79 @@
+// Package resolver turns a query such as react-router@6.4.0 into the
+// library and version to fetch. It doesn't search itself: a Finder, such
+// as the engine with its aliases, registry and search cache, does that, so
+// the CLI, the TUI, the servers and Go programs resolve queries alike.
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Finder finds the libraries a name may refer to
+type Finder interface {
+	// Find returns the best match for name and every candidate, in the
+	// order they were found
+	Find(ctx context.Context, name string) (client.Library, []client.Library, error)
+}
+
+// Options configures Resolve
+type Options struct {
+	// Finder searches for the library the query names
+	Finder Finder
+	// Version, when set, is resolved instead of any version the query
+	// names
+	Version string
+}
+
+// Result is what a query resolved to
+type Result struct {
+	// Library is the best match
+	Library client.Library
+	// Results holds every candidate
+	Results []client.Library
+	// Version is a version Library lists, or "" for the default document
+	Version string
+}
+
+// Resolve finds the library query names and matches the version it names
+// against the library's, taking the best match without anyone to ask.
+// "latest" is the default document.
+func Resolve(ctx context.Context, query string, opts Options) (Result, error) {
+	name, version := client.SplitVersion(query)
+	if opts.Version != "" {
+		version = opts.Version
+	}
+	if version == "latest" {
+		version = ""
+	}
+
+	lib, results, err := opts.Finder.Find(ctx, name)
+	if err != nil {
+		return Result{}, err
+	}
+	version, err = MatchVersion(lib, version)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Library: lib, Results: results, Version: version}, nil
+}
+
+// MatchVersion returns the version of lib that version names, with or
+// without a leading "v" (6.4.0 for v6.4.0). A library that lists no
+// versions accepts any; "" is always the default document.
+func MatchVersion(lib client.Library, version string) (string, error) {
+	if version == "" || len(lib.Versions) == 0 {
+		return version, nil
+	}
+
+	want := strings.TrimPrefix(version, "v")
+	for _, v := range lib.Versions {
+		if v == version || strings.TrimPrefix(v, "v") == want {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no version %s (available: %s)", lib.ID, version, strings.Join(lib.Versions, ", "))
+}
@@ -0,0 +1,100 @@
+package selftest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Query is what the self-test searches for
+const Query = "selftest"
+
+// Libraries are the search results the fake server returns for every
+// query, best match first
+var Libraries = []client.Library{
+	{
+		ID:             "/ctx7/selftest",
+		Title:          "ctx7 self-test",
+		Description:    "Canned documentation served by ctx7 selftest",
+		Branch:         "main",
+		LastUpdateDate: "2025-01-01T00:00:00Z",
+		State:          "finalized",
+		TotalTokens:    60,
+		TotalSnippets:  2,
+		Stars:          1000,
+		TrustScore:     9,
+		BenchmarkScore: 80,
+		Versions:       []string{"v1"},
+	},
+	{
+		ID:             "/ctx7/selftest-fork",
+		Title:          "ctx7 self-test (fork)",
+		Description:    "A fork that the self-test must not pick",
+		LastUpdateDate: "2024-01-01T00:00:00Z",
+		State:          "finalized",
+		Stars:          3,
+		TrustScore:     2,
+	},
+}
+
+// Docs maps the library IDs of Libraries to their llms.txt
+var Docs = map[string]string{
+	"/ctx7/selftest": `TITLE: Searching
+DESCRIPTION: ctx7 searches context7 for the library.
+SOURCE: selftest
+
+LANGUAGE: bash
+CODE:
+` + "```" + `
+ctx7 selftest
+` + "```" + `
+
+----------------------------------------
+
+TITLE: Caching
+DESCRIPTION: The fetched document is cached and served from the cache next time.
+SOURCE: selftest
+`,
+	"/ctx7/selftest-fork": "TITLE: Fork\nDESCRIPTION: Not this one.\n",
+}
+
+// Server is a fake context7 serving Libraries and Docs
+type Server struct {
+	*httptest.Server
+	searches atomic.Int64
+	fetches  atomic.Int64
+}
+
+// NewServer starts a fake context7 on a local port; Close stops it
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Searches and Fetches count the requests served so far
+func (s *Server) Searches() int64 { return s.searches.Load() }
+func (s *Server) Fetches() int64  { return s.fetches.Load() }
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v2/libs/search" {
+		s.searches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(client.SearchResponse{Results: Libraries})
+		return
+	}
+
+	id, ok := strings.CutSuffix(r.URL.Path, "/llms.txt")
+	doc, found := Docs[id]
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	s.fetches.Add(1)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(doc))
+}
@@ -0,0 +1,205 @@
+// Package selftest checks that an installation of ctx7 works: it runs the
+// search → select → fetch → cache pipeline against an in-process fake
+// context7 serving canned fixtures, and reports on the binary, the cache
+// directory and the terminal. Tests can use the fake server on its own.
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/tui"
+)
+
+// Options configures a self-test run
+type Options struct {
+	// CacheDir is the cache directory to check; the pipeline itself runs
+	// on a throwaway cache
+	CacheDir string
+	// Backend is the cache storage backend to run the pipeline on
+	Backend cache.Backend
+	// Progress, if set, receives the pipeline's status lines
+	Progress io.Writer
+}
+
+// Check is the outcome of one step of the self-test
+type Check struct {
+	Name   string
+	Detail string
+	Err    error
+}
+
+// Failed reports whether any check failed
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs every check, in order. The pipeline checks are skipped once one
+// of them fails.
+func Run(ctx context.Context, opts Options) []Check {
+	checks := []Check{checkBinary(), checkCacheDir(opts.CacheDir), checkTerminal()}
+	return append(checks, checkPipeline(ctx, opts)...)
+}
+
+// checkBinary reports where the running binary is and how it was built
+func checkBinary() Check {
+	c := Check{Name: "binary"}
+	path, err := os.Executable()
+	if err != nil {
+		c.Err = fmt.Errorf("failed to locate the executable: %w", err)
+		return c
+	}
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	c.Detail = fmt.Sprintf("%s %s, %s %s/%s", path, version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return c
+}
+
+// checkCacheDir makes sure files can be written to the cache directory
+func checkCacheDir(dir string) Check {
+	c := Check{Name: "cache directory", Detail: dir}
+	if dir == "" {
+		c.Err = errors.New("no cache directory could be located")
+		return c
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.Err = fmt.Errorf("failed to create cache directory: %w", err)
+		return c
+	}
+	f, err := os.CreateTemp(dir, ".selftest-*")
+	if err != nil {
+		c.Err = fmt.Errorf("cache directory is not writable: %w", err)
+		return c
+	}
+	_, err = f.WriteString(Query)
+	f.Close()
+	os.Remove(f.Name())
+	if err != nil {
+		c.Err = fmt.Errorf("failed to write to cache directory: %w", err)
+	}
+	return c
+}
+
+// checkTerminal describes what the terminal supports; it never fails, as
+// ctx7 works without a terminal too
+func checkTerminal() Check {
+	var parts []string
+	for _, f := range []struct {
+		name string
+		file *os.File
+	}{{"stdin", os.Stdin}, {"stdout", os.Stdout}, {"stderr", os.Stderr}} {
+		if term.IsTerminal(f.file.Fd()) {
+			parts = append(parts, f.name+" is a terminal")
+		} else {
+			parts = append(parts, f.name+" is not a terminal")
+		}
+	}
+	if width, height, err := term.GetSize(os.Stderr.Fd()); err == nil {
+		parts = append(parts, fmt.Sprintf("%dx%d", width, height))
+	}
+	termName := os.Getenv("TERM")
+	if termName == "" {
+		termName = "unset"
+	}
+	parts = append(parts, "TERM "+termName, "colors "+lipgloss.ColorProfile().Name())
+	return Check{Name: "terminal", Detail: strings.Join(parts, ", ")}
+}
+
+// checkPipeline fetches the fixture library twice through the same model
+// the CLI runs: first streamed from the fake server into a throwaway
+// cache, then from that cache
+func checkPipeline(ctx context.Context, opts Options) []Check {
+	srv := NewServer()
+	defer srv.Close()
+
+	dir, err := os.MkdirTemp("", "ctx7-selftest-")
+	if err != nil {
+		return []Check{{Name: "pipeline", Err: fmt.Errorf("failed to create temp dir: %w", err)}}
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := cache.NewCacheWithBackend(filepath.Join(dir, "cache"), opts.Backend)
+	if err != nil {
+		return []Check{{Name: "pipeline", Err: fmt.Errorf("failed to open cache: %w", err)}}
+	}
+	api, err := client.NewClientWithOptions(client.Options{BaseURL: srv.URL})
+	if err != nil {
+		return []Check{{Name: "pipeline", Err: err}}
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = io.Discard
+	}
+	want := Libraries[0]
+	doc := Docs[want.ID]
+
+	var streamed bytes.Buffer
+	first := tui.RunPlain(tui.NewModel(Query, tui.Options{Context: ctx, Client: api, Cache: c, Output: &streamed}), progress)
+
+	checks := []Check{{Name: "search", Detail: fmt.Sprintf("%d results from %s", len(Libraries), srv.URL)}}
+	if srv.Searches() == 0 {
+		checks[0].Err = fmt.Errorf("the fake server was never searched: %v", first.Err())
+		return checks
+	}
+
+	selected := Check{Name: "select", Detail: want.ID}
+	if lib := first.Library(); lib == nil {
+		selected.Err = fmt.Errorf("nothing picked: %v", first.Err())
+	} else if lib.ID != want.ID {
+		selected.Err = fmt.Errorf("picked %s instead of %s", lib.ID, want.ID)
+	}
+	checks = append(checks, selected)
+	if selected.Err != nil {
+		return checks
+	}
+
+	fetched := Check{Name: "fetch", Detail: fmt.Sprintf("%d bytes streamed", streamed.Len())}
+	switch {
+	case first.Err() != nil:
+		fetched.Err = first.Err()
+	case first.WasFromCache():
+		fetched.Err = errors.New("served from an empty cache")
+	case streamed.String() != doc:
+		fetched.Err = fmt.Errorf("got %d bytes, want the %d of the fixture", streamed.Len(), len(doc))
+	}
+	checks = append(checks, fetched)
+	if fetched.Err != nil {
+		return checks
+	}
+
+	fetches := srv.Fetches()
+	second := tui.RunPlain(tui.NewModel(Query, tui.Options{Context: ctx, Client: api, Cache: c}), progress)
+	backend := opts.Backend
+	if backend == "" {
+		backend = cache.BackendFiles
+	}
+	cached := Check{Name: "cache", Detail: fmt.Sprintf("%d bytes read back from the %s store", len(second.Content()), backend)}
+	switch {
+	case second.Err() != nil:
+		cached.Err = second.Err()
+	case !second.WasFromCache() || srv.Fetches() != fetches:
+		cached.Err = errors.New("downloaded again instead of served from the cache")
+	case second.Content() != doc:
+		cached.Err = fmt.Errorf("got %d bytes, want the %d cached", len(second.Content()), len(doc))
+	}
+	return append(checks, cached)
+}
@@ -0,0 +1,159 @@
+// Package stack holds curated sets of libraries that are fetched together
+// into one context file, such as the docs a Next.js project needs. Presets
+// are bundled into the binary; stacks in the user's stacks directory add
+// to them or replace them by name.
+package stack
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hsbacot/ctx7/config"
+)
+
+//go:embed presets/*.toml
+var presets embed.FS
+
+// Stack is a named set of libraries and how a token budget is split
+// between them
+type Stack struct {
+	Name        string `toml:"-"`
+	Description string `toml:"description"`
+	// Budget is the token budget of the composed file (0 for no limit)
+	Budget    int       `toml:"budget"`
+	Libraries []Library `toml:"library"`
+	// Path is the user file the stack was read from, "" for a preset
+	Path string `toml:"-"`
+}
+
+// Library is a member of a stack
+type Library struct {
+	// Name is a search query or context7 ID, optionally with @version
+	Name string `toml:"name"`
+	// Weight is the library's relative share of the budget (default 1)
+	Weight float64 `toml:"weight"`
+}
+
+// UserDir returns the directory of user stacks, next to the config file
+func UserDir() (string, error) {
+	path, err := config.DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "stacks"), nil
+}
+
+// Load returns the presets together with the stacks in dir, one
+// <name>.toml file each; a missing dir holds no stacks
+func Load(dir string) (map[string]Stack, error) {
+	stacks := make(map[string]Stack)
+
+	files, err := presets.ReadDir("presets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presets: %w", err)
+	}
+	for _, f := range files {
+		data, err := presets.ReadFile("presets/" + f.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read preset %s: %w", f.Name(), err)
+		}
+		s, err := parse(f.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+		stacks[s.Name] = s
+	}
+
+	if dir == "" {
+		return stacks, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stacks: %w", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stack: %w", err)
+		}
+		s, err := parse(filepath.Base(path), data)
+		if err != nil {
+			return nil, err
+		}
+		s.Path = path
+		stacks[s.Name] = s
+	}
+	return stacks, nil
+}
+
+// parse decodes the stack in file, named after it
+func parse(file string, data []byte) (Stack, error) {
+	var s Stack
+	if err := toml.Unmarshal(data, &s); err != nil {
+		return Stack{}, fmt.Errorf("failed to parse stack %s: %w", file, err)
+	}
+	s.Name = strings.TrimSuffix(file, ".toml")
+	if len(s.Libraries) == 0 {
+		return Stack{}, fmt.Errorf("stack %s lists no libraries", s.Name)
+	}
+	return s, nil
+}
+
+// validName matches the names a stack can be saved under
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName checks that name can be used as a stack's file name
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid stack name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// Save writes s to dir as <name>.toml, replacing a stack of that name,
+// and returns the file's path
+func Save(dir string, s Stack) (string, error) {
+	if err := ValidateName(s.Name); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create stacks directory: %w", err)
+	}
+
+	var b strings.Builder
+	enc := toml.NewEncoder(&b)
+	enc.Indent = ""
+	if err := enc.Encode(s); err != nil {
+		return "", fmt.Errorf("failed to encode stack: %w", err)
+	}
+	path := filepath.Join(dir, s.Name+".toml")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write stack: %w", err)
+	}
+	return path, nil
+}
+
+// Names returns the names of stacks in order
+func Names(stacks map[string]Stack) []string {
+	names := make([]string, 0, len(stacks))
+	for name := range stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Weights returns the weight of each library in order
+func (s Stack) Weights() []float64 {
+	weights := make([]float64, len(s.Libraries))
+	for i, lib := range s.Libraries {
+		weights[i] = lib.Weight
+	}
+	return weights
+}
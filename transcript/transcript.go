@@ -0,0 +1,106 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// FormatVersion is the transcript file format written by this build
+const FormatVersion = 1
+
+// Transcript records the interactive choices of one ctx7 run
+type Transcript struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Args are the command-line arguments of the recorded run
+	Args     []string  `json:"args"`
+	Sessions []Session `json:"sessions"`
+
+	path string
+}
+
+// Session is one library lookup: the query, what was shown, and what was chosen
+type Session struct {
+	Query string `json:"query"`
+	// Results are the search results shown, in API order
+	Results []Result `json:"results,omitempty"`
+	// Library is the library finally fetched
+	Library *client.Library `json:"library,omitempty"`
+	// AlsoSelected lists further library IDs marked in the multi-select
+	// picker; each is recorded as its own session
+	AlsoSelected []string `json:"also_selected,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	Branch       string   `json:"branch,omitempty"`
+	// Topic is the server-side --topic; PickedTopic the one chosen in the topic picker
+	Topic       string `json:"topic,omitempty"`
+	PickedTopic string `json:"picked_topic,omitempty"`
+	FromCache   bool   `json:"from_cache"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Result is a search result as shown to the user
+type Result struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	Stars      int     `json:"stars"`
+	TrustScore float64 `json:"trust_score"`
+}
+
+// New starts an empty transcript that Save writes to path
+func New(path string, args []string, now time.Time) *Transcript {
+	return &Transcript{
+		Version:   FormatVersion,
+		CreatedAt: now,
+		Args:      args,
+		Sessions:  []Session{},
+		path:      path,
+	}
+}
+
+// Load reads a transcript written by Save
+func Load(path string) (*Transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	t := &Transcript{path: path}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("failed to parse transcript: %w", err)
+	}
+	if t.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported transcript version %d (expected %d)", t.Version, FormatVersion)
+	}
+	return t, nil
+}
+
+// Add appends a session
+func (t *Transcript) Add(s Session) {
+	t.Sessions = append(t.Sessions, s)
+}
+
+// Save writes the transcript as JSON
+func (t *Transcript) Save() error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %w", err)
+	}
+
+	if err := os.WriteFile(t.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
+
+// Results converts search results to their recorded form
+func Results(libs []client.Library) []Result {
+	results := make([]Result, len(libs))
+	for i, lib := range libs {
+		results[i] = Result{ID: lib.ID, Title: lib.Title, Stars: lib.Stars, TrustScore: lib.TrustScore}
+	}
+	return results
+}
@@ -0,0 +1,440 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hsbacot/ctx7/alias"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/history"
+)
+
+const (
+	// searchDebounce is how long typing has to pause before a search
+	searchDebounce = 300 * time.Millisecond
+	// minSearchQuery is the shortest query searched while typing
+	minSearchQuery = 2
+	// browseRecent bounds the recent fetches offered before typing, and
+	// browseSuggestions those that match what is being typed
+	browseRecent      = 10
+	browseSuggestions = 3
+)
+
+// BrowseOptions configures RunBrowse
+type BrowseOptions struct {
+	// Recent are the past fetches to suggest, most recent first
+	Recent []history.Entry
+	// Cache, if set, marks the cached libraries, colored against MaxAge
+	Cache  *cache.Cache
+	MaxAge time.Duration
+	// Aliases lists the aliased libraries first (nil for none)
+	Aliases *alias.Store
+	// CardHeight is the lines per library (DefaultCardHeight if zero)
+	CardHeight int
+}
+
+// browseFocus is the part of the browser keys go to
+type browseFocus int
+
+const (
+	browseFocusInput browseFocus = iota
+	browseFocusRecent
+	browseFocusResults
+)
+
+// searchDebounceMsg fires once typing paused; seq tells whether more
+// was typed since
+type searchDebounceMsg struct {
+	seq   int
+	query string
+}
+
+// browseSearchMsg carries the results of a browser search
+type browseSearchMsg struct {
+	query   string
+	results []client.Library
+	err     error
+}
+
+type browseModel struct {
+	ctx    context.Context
+	search SearchFunc
+	opts   BrowseOptions
+	cached cachedVersions
+
+	focus        browseFocus
+	input        textinput.Model
+	seq          int                // Bumped on every edit of the query
+	pending      string             // Query being searched, "" for none
+	cancelSearch context.CancelFunc // Aborts the pending search
+	query        string             // Query the results are for
+	results      []client.Library
+	err          error
+
+	recentCursor int
+	selector     librarySelectorModel
+
+	width, height int
+	choice        *BrowseChoice
+}
+
+// BrowseChoice is what was picked in RunBrowse: a recent fetch to repeat,
+// or the libraries marked in the results of Query
+type BrowseChoice struct {
+	Recent    *history.Entry
+	Query     string
+	Libraries []client.Library
+}
+
+// RunBrowse lets the user type a query, searched as they type, and pick
+// libraries from the results or one of the recent fetches. ok is false
+// when the user quit without picking.
+func RunBrowse(ctx context.Context, search SearchFunc, opts BrowseOptions, programOpts ...tea.ProgramOption) (choice BrowseChoice, ok bool, err error) {
+	final, err := tea.NewProgram(newBrowse(ctx, search, opts), programOpts...).Run()
+	if err != nil {
+		return BrowseChoice{}, false, err
+	}
+	m := final.(browseModel)
+	if m.choice == nil {
+		return BrowseChoice{}, false, nil
+	}
+	return *m.choice, true, nil
+}
+
+func newBrowse(ctx context.Context, search SearchFunc, opts BrowseOptions) browseModel {
+	input := textinput.New()
+	input.Placeholder = "search for a library, e.g. next.js"
+	input.Prompt = "🔍 "
+	// Blink messages are not routed back to the input
+	input.Cursor.SetMode(cursor.CursorStatic)
+	input.Focus()
+
+	return browseModel{
+		ctx:    ctx,
+		search: search,
+		opts:   opts,
+		cached: loadCachedVersions(opts.Cache),
+		input:  input,
+	}
+}
+
+func (m browseModel) Init() tea.Cmd {
+	return nil
+}
+
+// typed returns the query as typed, without surrounding space
+func (m browseModel) typed() string {
+	return strings.TrimSpace(m.input.Value())
+}
+
+// suggestions returns the recent fetches matching what is typed, all of
+// them before anything is
+func (m browseModel) suggestions() []history.Entry {
+	query := foldText(m.typed())
+	if query == "" {
+		return m.opts.Recent[:min(len(m.opts.Recent), browseRecent)]
+	}
+
+	var matches []history.Entry
+	for _, e := range m.opts.Recent {
+		if strings.Contains(foldText(e.Query+" "+e.Title+" "+e.LibraryID), query) {
+			matches = append(matches, e)
+			if len(matches) == browseSuggestions {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// hasResults reports whether the selector has libraries to pick from
+func (m browseModel) hasResults() bool {
+	return len(m.results) > 0
+}
+
+// startSearch searches for query, abandoning the pending search
+func (m browseModel) startSearch(query string) (browseModel, tea.Cmd) {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.pending, m.cancelSearch = query, cancel
+
+	search := m.search
+	return m, func() tea.Msg {
+		results, err := search(ctx, query)
+		return browseSearchMsg{query: query, results: results, err: err}
+	}
+}
+
+func (m browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	if m.hasResults() && m.width > 0 {
+		m.selector = m.selector.resize(m.width, m.height-m.headerLines())
+	}
+	return m, cmd
+}
+
+func (m browseModel) update(msg tea.Msg) (browseModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case searchDebounceMsg:
+		// Still typing, or already searched
+		if msg.seq != m.seq || msg.query == m.query {
+			return m, nil
+		}
+		return m.startSearch(msg.query)
+
+	case browseSearchMsg:
+		// Results of a query that has been edited since are dropped
+		if msg.query != m.pending {
+			return m, nil
+		}
+		m.pending, m.cancelSearch = "", nil
+		m.query, m.err = msg.query, msg.err
+		if msg.err != nil {
+			m.results = nil
+			return m, nil
+		}
+		m.results = msg.results
+		m.selector = newLibrarySelector(m.results, sortByStars, "", m.cached, time.Now(), m.opts.MaxAge, m.opts.CardHeight, m.opts.Aliases.Favorite)
+		if m.focus == browseFocusResults && !m.hasResults() {
+			m.setFocus(browseFocusInput)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		switch m.focus {
+		case browseFocusInput:
+			return m.updateInput(msg)
+		case browseFocusRecent:
+			return m.updateRecent(msg)
+		case browseFocusResults:
+			return m.updateResults(msg)
+		}
+	}
+	return m, nil
+}
+
+// setFocus moves the keys to f
+func (m *browseModel) setFocus(f browseFocus) {
+	m.focus = f
+	if f == browseFocusInput {
+		m.input.Focus()
+	} else {
+		m.input.Blur()
+	}
+	m.recentCursor = 0
+}
+
+// below moves the focus to the first section below the input
+func (m browseModel) below() browseModel {
+	switch {
+	case len(m.suggestions()) > 0:
+		m.setFocus(browseFocusRecent)
+	case m.hasResults():
+		m.setFocus(browseFocusResults)
+	}
+	return m
+}
+
+func (m browseModel) updateInput(msg tea.KeyMsg) (browseModel, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		query := m.typed()
+		if query == "" {
+			return m.below(), nil
+		}
+		if query == m.query && m.pending == "" {
+			if m.hasResults() {
+				m.setFocus(browseFocusResults)
+			}
+			return m, nil
+		}
+		// Search now rather than after the pause
+		if query == m.pending {
+			return m, nil
+		}
+		m.seq++
+		return m.startSearch(query)
+	case "down", "tab":
+		return m.below(), nil
+	case "esc":
+		if m.input.Value() != "" {
+			m.input.SetValue("")
+			return m.edited(), nil
+		}
+		return m, tea.Quit
+	}
+
+	before := m.input.Value()
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() == before {
+		return m, cmd
+	}
+
+	m = m.edited()
+	query := m.typed()
+	if len([]rune(query)) < minSearchQuery {
+		return m, cmd
+	}
+	seq := m.seq
+	return m, tea.Batch(cmd, tea.Tick(searchDebounce, func(time.Time) tea.Msg {
+		return searchDebounceMsg{seq: seq, query: query}
+	}))
+}
+
+// edited drops what the previous query led to once it was changed
+func (m browseModel) edited() browseModel {
+	m.seq++
+	// The pending search is for what was typed before
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	m.pending, m.cancelSearch = "", nil
+	if len([]rune(m.typed())) < minSearchQuery {
+		m.query, m.results, m.err = "", nil, nil
+	}
+	return m
+}
+
+func (m browseModel) updateRecent(msg tea.KeyMsg) (browseModel, tea.Cmd) {
+	suggestions := m.suggestions()
+	switch msg.String() {
+	case "up", "k":
+		if m.recentCursor == 0 {
+			m.setFocus(browseFocusInput)
+			return m, nil
+		}
+		m.recentCursor--
+	case "down", "j":
+		if m.recentCursor < len(suggestions)-1 {
+			m.recentCursor++
+		} else if m.hasResults() {
+			m.setFocus(browseFocusResults)
+		}
+	case "enter":
+		m.choice = &BrowseChoice{Recent: &suggestions[m.recentCursor]}
+		return m, tea.Quit
+	case "tab":
+		if m.hasResults() {
+			m.setFocus(browseFocusResults)
+		} else {
+			m.setFocus(browseFocusInput)
+		}
+	case "esc", "/":
+		m.setFocus(browseFocusInput)
+	default:
+		// Typing goes back to the query
+		if msg.Type == tea.KeyRunes {
+			m.setFocus(browseFocusInput)
+			return m.updateInput(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m browseModel) updateResults(msg tea.KeyMsg) (browseModel, tea.Cmd) {
+	if !m.selector.filter.Focused() && m.selector.detail == nil && msg.String() == "tab" {
+		m.setFocus(browseFocusInput)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.selector, cmd = m.selector.Update(msg)
+	if !m.selector.done {
+		return m, cmd
+	}
+
+	picked := m.selector.choices
+	if len(picked) == 0 && m.selector.choice != nil {
+		picked = []client.Library{*m.selector.choice}
+	}
+	if len(picked) == 0 {
+		// Left the results: back to the query
+		m.selector.done = false
+		m.setFocus(browseFocusInput)
+		return m, nil
+	}
+	m.choice = &BrowseChoice{Query: m.query, Libraries: picked}
+	return m, tea.Quit
+}
+
+// headerLines is the height of what is drawn above the selector
+func (m browseModel) headerLines() int {
+	// Blank line, title, blank line, input, status
+	lines := 5
+	if n := len(m.suggestions()); n > 0 {
+		lines += n + 2
+	}
+	return lines
+}
+
+func (m browseModel) View() string {
+	var b strings.Builder
+	b.WriteString("\n" + builderTitleStyle.Render("  Browse library docs") + "\n\n")
+	b.WriteString("  " + m.input.View() + "\n")
+
+	query := m.typed()
+	switch {
+	case m.pending != "":
+		b.WriteString(hintStyle.Render("  Searching...") + "\n")
+	case m.err != nil:
+		b.WriteString(errorStyle.Render("  "+m.err.Error()) + "\n")
+	case m.query != "" && !m.hasResults():
+		b.WriteString(hintStyle.Render(fmt.Sprintf("  No libraries match '%s'", m.query)) + "\n")
+	case len([]rune(query)) < minSearchQuery && query != "":
+		b.WriteString(hintStyle.Render("  Keep typing to search") + "\n")
+	default:
+		b.WriteString(hintStyle.Render("  "+m.help()) + "\n")
+	}
+
+	if suggestions := m.suggestions(); len(suggestions) > 0 {
+		b.WriteString("\n" + builderTitleStyle.Render("  Recent") + "\n")
+		for i, e := range suggestions {
+			line := e.String()
+			if m.focus == browseFocusRecent && i == m.recentCursor {
+				line = builderCursorStyle.Render("> ") + line
+			} else {
+				line = "  " + line
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if m.hasResults() {
+		b.WriteString(m.selector.View() + "\n")
+	}
+	return b.String()
+}
+
+// help lists the keys of the focused section
+func (m browseModel) help() string {
+	switch m.focus {
+	case browseFocusRecent:
+		return "↑/↓ move • enter fetch again • type to search • ctrl+c quit"
+	case browseFocusResults:
+		return "enter fetch • tab back to the search • ctrl+c quit"
+	}
+	keys := "type to search"
+	if len(m.suggestions()) > 0 || m.hasResults() {
+		keys += " • ↓ pick"
+	}
+	if m.input.Value() != "" {
+		return keys + " • esc clear"
+	}
+	return keys + " • esc quit"
+}
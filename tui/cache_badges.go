@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/ui"
+)
+
+// cachedVersions maps library IDs to when each cached version (by cache key) was fetched
+type cachedVersions map[string]map[string]time.Time
+
+// loadCachedVersions indexes the cache so selectors can mark cached items;
+// it returns nil when there is no cache or it cannot be listed
+func loadCachedVersions(c *cache.Cache) cachedVersions {
+	if c == nil {
+		return nil
+	}
+
+	libraries, err := c.ListCachedLibraries()
+	if err != nil {
+		return nil
+	}
+
+	index := make(cachedVersions, len(libraries))
+	for _, lib := range libraries {
+		versions := make(map[string]time.Time, len(lib.Versions))
+		for _, v := range lib.Versions {
+			// A topic variant doesn't make the full document cached
+			if v.IsVariant() {
+				continue
+			}
+			versions[v.Version] = v.FetchedAt
+		}
+		index[lib.LibraryID] = versions
+	}
+	return index
+}
+
+// newest returns when any version of libraryID was last fetched
+func (cv cachedVersions) newest(libraryID string) (time.Time, bool) {
+	var newest time.Time
+	for _, fetchedAt := range cv[libraryID] {
+		if fetchedAt.After(newest) {
+			newest = fetchedAt
+		}
+	}
+	return newest, !newest.IsZero()
+}
+
+// fetched returns when the version of libraryID stored under key was fetched
+func (cv cachedVersions) fetched(libraryID, key string) (time.Time, bool) {
+	fetchedAt, ok := cv[libraryID][key]
+	return fetchedAt, ok
+}
+
+// cacheBadge renders a cached marker with the entry's age, e.g. "💾 cached 2d ago",
+// colored by its freshness against maxAge
+func cacheBadge(fetchedAt, now time.Time, maxAge time.Duration) string {
+	return ui.RenderFreshness(cache.FreshnessOf(fetchedAt, now, maxAge), "💾 cached "+cacheAge(now.Sub(fetchedAt)))
+}
+
+// upstreamBadge marks a cached library that context7 has updated since it
+// was fetched, e.g. "↻ updated upstream 3d ago"
+func upstreamBadge(updated, now time.Time) string {
+	return ui.RenderFreshness(cache.Stale, "↻ updated upstream "+cacheAge(now.Sub(updated)))
+}
+
+// cacheAge formats the age of a cache entry, e.g. "2d ago"
+func cacheAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
@@ -0,0 +1,103 @@
+package tui_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/tui"
+	"github.com/hsbacot/ctx7/tui/tuitest"
+)
+
+// Searches and fetches go to the Searcher and Fetcher given in place of a
+// client, never to context7.com
+func TestSearcherAndFetcher(t *testing.T) {
+	searcher, fetcher := tuitest.NewFixtureClient(), tuitest.NewFixtureClient()
+	m := tuitest.Start(tui.NewModel("react", tui.Options{
+		Searcher:      searcher,
+		Fetcher:       fetcher,
+		Cache:         tuitest.NewCache(t, tuitest.FixedClock(now)),
+		Clock:         tuitest.FixedClock(now),
+		ReducedMotion: true,
+	}))
+
+	if m.State() != "success" || m.Content() != tuitest.Docs["/facebook/react"] {
+		t.Fatalf("state = %q, content = %q, want success with the fixture doc (err: %v)", m.State(), m.Content(), m.Err())
+	}
+	if !slices.Equal(searcher.Searches, []string{"react"}) || len(searcher.Fetches) != 0 {
+		t.Errorf("searcher got searches %q, fetches %q, want one search", searcher.Searches, searcher.Fetches)
+	}
+	if !slices.Equal(fetcher.Fetches, []string{"/facebook/react"}) || len(fetcher.Searches) != 0 {
+		t.Errorf("fetcher got searches %q, fetches %q, want one fetch", fetcher.Searches, fetcher.Fetches)
+	}
+}
+
+// A document fetched once is served from the cache until it expires
+func TestCachedDocument(t *testing.T) {
+	clock := now
+	tick := func() time.Time { return clock }
+	c := tuitest.NewCache(t, tick)
+	react := &tuitest.Libraries[0]
+
+	tests := []struct {
+		name      string
+		after     time.Duration // Since the first fetch
+		opts      tui.Options
+		fromCache bool
+	}{
+		{name: "first run", fromCache: false},
+		{name: "fresh", after: time.Hour, fromCache: true},
+		{name: "no cache", after: time.Hour, opts: tui.Options{NoCache: true}, fromCache: false},
+		{name: "expired", after: 48 * time.Hour, fromCache: false},
+		{name: "refetched", after: 49 * time.Hour, fromCache: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock = now.Add(tt.after)
+			fc := tuitest.NewFixtureClient()
+			opts := tt.opts
+			opts.Client, opts.Cache, opts.Clock = fc, c, tick
+			opts.Library, opts.MaxAge = react, 24*time.Hour
+			opts.ReducedMotion = true
+
+			m := tuitest.Start(tui.NewModel(react.ID, opts))
+			if m.State() != "success" || m.Content() != tuitest.Docs[react.ID] {
+				t.Fatalf("state = %q, content = %q, want success with the fixture doc (err: %v)", m.State(), m.Content(), m.Err())
+			}
+			if m.WasFromCache() != tt.fromCache {
+				t.Errorf("from cache = %v, want %v", m.WasFromCache(), tt.fromCache)
+			}
+			if fetched := len(fc.Fetches) > 0; fetched == tt.fromCache {
+				t.Errorf("fetches = %q, from cache = %v", fc.Fetches, tt.fromCache)
+			}
+			if len(fc.Searches) != 0 {
+				t.Errorf("searches = %q, want none for a library given up front", fc.Searches)
+			}
+		})
+	}
+}
+
+// A library without documentation fails with the API's error and leaves
+// nothing in the cache
+func TestMissingDocument(t *testing.T) {
+	fork := &tuitest.Libraries[2]
+	c := tuitest.NewCache(t, tuitest.FixedClock(now))
+	m := tuitest.Start(tui.NewModel(fork.ID, tui.Options{
+		Client:        tuitest.NewFixtureClient(),
+		Cache:         c,
+		Clock:         tuitest.FixedClock(now),
+		Library:       fork,
+		ReducedMotion: true,
+	}))
+
+	var apiErr *client.APIError
+	if m.State() != "error" || !errors.As(m.Err(), &apiErr) || apiErr.StatusCode != 404 {
+		t.Fatalf("state = %q, err = %v, want error with a 404", m.State(), m.Err())
+	}
+	if entry, err := c.Lookup(fork.ID, ""); err == nil {
+		t.Errorf("cached %q, want nothing cached", entry.Content)
+	}
+}
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hsbacot/ctx7/cache"
+)
+
+// updateDetail handles keys while the detail view of a library is open
+func (m librarySelectorModel) updateDetail(msg tea.KeyMsg) (librarySelectorModel, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.choice, m.detail, m.done = m.detail, nil, true
+	case "d", "esc", "q":
+		m.detail = nil
+	case "ctrl+c":
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// detailView shows everything the search returned for the library, where
+// the cards cut the description and versions short
+func (m librarySelectorModel) detailView() string {
+	lib := m.detail
+	width := 80
+	if m.width > 0 {
+		width = m.width
+	}
+	// Leave room for the border and padding
+	inner := max(width-6, 20)
+
+	var b strings.Builder
+	b.WriteString(builderTitleStyle.Render(lib.Title) + "  " + hintStyle.Render(lib.ID) + "\n")
+	if lib.Description != "" {
+		b.WriteString("\n" + ansi.Wrap(lib.Description, inner, "") + "\n")
+	}
+	b.WriteString("\n")
+
+	row := func(label, value string) {
+		if value != "" {
+			b.WriteString(detailLabelStyle.Render(label) + value + "\n")
+		}
+	}
+	nonZero := func(format string, v float64) string {
+		if v == 0 {
+			return ""
+		}
+		return fmt.Sprintf(format, v)
+	}
+	row("Stars", formatNumber(lib.Stars))
+	row("Trust", nonZero("%.1f", lib.TrustScore))
+	row("Benchmark", nonZero("%.1f", lib.BenchmarkScore))
+	row("Relevance", nonZero("%.2f", lib.Score))
+	row("State", lib.State)
+	row("Branch", lib.Branch)
+	row("Updated", formatDate(lib.LastUpdateDate))
+	row("Tokens", formatTokens(lib.TotalTokens))
+	row("Snippets", formatNumber(lib.TotalSnippets))
+	if lib.VIP {
+		row("VIP", "yes")
+	}
+	if fetchedAt, ok := m.cached.fetched(lib.ID, cache.RefKey("", "")); ok {
+		row("Cached", cacheBadge(fetchedAt, m.now, m.maxAge))
+	}
+
+	if len(lib.Versions) > 0 {
+		versions := make([]string, len(lib.Versions))
+		for i, v := range lib.Versions {
+			versions[i] = v
+			if _, ok := m.cached.fetched(lib.ID, cache.RefKey(v, "")); ok {
+				versions[i] += " 💾"
+			}
+		}
+		b.WriteString(fmt.Sprintf("\n%d versions\n", len(versions)))
+		b.WriteString(ansi.Wrap(strings.Join(versions, ", "), inner, "") + "\n")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	// Keep the box and the hint on screen however many versions there are
+	if m.height > 0 && len(lines) > m.height-4 {
+		lines = append(lines[:max(m.height-5, 1)], hintStyle.Render("…"))
+	}
+
+	return "\n" + detailBoxStyle.Width(width-2).Render(strings.Join(lines, "\n")) + "\n" +
+		hintStyle.Render("enter fetch • d/esc back")
+}
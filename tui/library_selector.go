@@ -1,32 +1,57 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/hsbacot/ctx7/client"
+	"golang.org/x/text/unicode/norm"
 )
 
 type libraryItem struct {
-	lib client.Library
+	lib      client.Library
+	selected bool   // Marked with space for multi-select
+	favorite bool   // Has an alias, listed first
+	badge    string // Cache marker, e.g. "💾 cached 2d ago"
 }
 
 func (i libraryItem) Title() string {
-	// Primary line: Title + Stars + Trust Score
+	// Primary line: Title + Stars + Trust Score + Benchmark Score
 	stars := formatNumber(i.lib.Stars)
 	trust := fmt.Sprintf("%.1f", i.lib.TrustScore)
+	benchmark := ""
+	if i.lib.BenchmarkScore > 0 {
+		benchmark = fmt.Sprintf("  📊 %.1f", i.lib.BenchmarkScore)
+	}
 	vip := ""
 	if i.lib.VIP {
 		vip = " ✨"
 	}
 
-	return fmt.Sprintf("%s  ⭐ %s  🏆 %s%s",
-		i.lib.Title, stars, trust, vip)
+	mark := ""
+	if i.selected {
+		mark = "✓ "
+	}
+	if i.favorite {
+		mark += "★ "
+	}
+
+	badge := ""
+	if i.badge != "" {
+		badge = "  " + i.badge
+	}
+
+	return fmt.Sprintf("%s%s  ⭐ %s  🏆 %s%s%s%s",
+		mark, i.lib.Title, stars, trust, benchmark, vip, badge)
 }
 
 func (i libraryItem) Description() string {
@@ -63,160 +88,516 @@ const (
 	sortByUpdated
 	sortByTokens
 	sortByRelevance
+	sortByBenchmark
 )
 
+var sortModeNames = []string{"stars", "trust", "updated", "tokens", "relevance", "benchmark"}
+
+func (s sortMode) String() string {
+	return sortModeNames[s]
+}
+
+// parseSortMode returns the sort mode named name, defaulting to stars
+func parseSortMode(name string) sortMode {
+	for i, n := range sortModeNames {
+		if n == name {
+			return sortMode(i)
+		}
+	}
+	return sortByStars
+}
+
 type librarySelectorModel struct {
 	list           list.Model
 	libraries      []client.Library
 	allLibraries   []client.Library // Keep original for filtering
 	choice         *client.Library
+	choices        []client.Library // Set instead of choice when several were marked
+	selected       map[string]bool  // IDs marked with space
+	cached         cachedVersions   // For cache badges
+	now            time.Time
+	maxAge         time.Duration    // Cache TTL the badges are colored against
 	done           bool
+	detail         *client.Library // Library shown in full with d, nil for the list
 	sortMode       sortMode
-	filterActive   bool
-	filterInput    string
+	ascending      bool // Reverses the sort, smallest or oldest first
+	filter         textinput.Model
+	// Searching context7 from the filter: search is nil when only the
+	// results can be filtered, remote set while the filter searches
+	search         SearchFunc
+	searchCtx      context.Context
+	remote         bool
+	searchSeq      int                // Bumped on every edit of a remote query
+	searching      string             // Query being searched, "" for none
+	cancelSearch   context.CancelFunc // Aborts the pending search
+	searchErr      error
+	favorite       func(libraryID string) bool // Libraries listed first; nil for none
+	cardHeight     int  // Lines per result card
+	compact        bool // One line per library instead of cards
+	width, height  int // Terminal size, zero until known
 }
 
-func newLibrarySelector(libraries []client.Library) librarySelectorModel {
-	// Sort by stars by default
-	sortedLibs := make([]client.Library, len(libraries))
-	copy(sortedLibs, libraries)
-	sortLibraries(sortedLibs, sortByStars)
+// DefaultCardHeight is the number of lines each library card takes
+const DefaultCardHeight = 5
+
+// libraryViewCompact is the remembered name of the one-line view
+const libraryViewCompact = "compact"
 
-	items := make([]list.Item, len(sortedLibs))
-	for i, lib := range sortedLibs {
-		items[i] = libraryItem{lib: lib}
+// newLibrarySelector creates the selector starting with the given sort mode
+// and filter, typically remembered from the previous run. Libraries found
+// in cached are marked with their cache age as of now, colored against maxAge.
+// Each card is cardHeight lines tall (DefaultCardHeight if zero). Libraries
+// for which favorite is true come first, whatever the sort (nil for none).
+func newLibrarySelector(libraries []client.Library, mode sortMode, filter string, cached cachedVersions, now time.Time, maxAge time.Duration, cardHeight int, favorite func(libraryID string) bool) librarySelectorModel {
+	if cardHeight <= 0 {
+		cardHeight = DefaultCardHeight
 	}
 
-	delegate := list.NewDefaultDelegate()
-	delegate.SetSpacing(1)
-	delegate.SetHeight(5) // Each result takes ~5 lines
+	sortedLibs := make([]client.Library, len(libraries))
+	copy(sortedLibs, libraries)
+	sortLibraries(sortedLibs, mode, false)
+	favoritesFirst(sortedLibs, favorite)
 
-	// Show up to 5 libraries at once
-	itemCount := len(items)
-	if itemCount > 5 {
-		itemCount = 5
+	m := librarySelectorModel{
+		libraries:    sortedLibs,
+		allLibraries: sortedLibs,
+		sortMode:     mode,
+		filter:       newFilterInput(),
+		selected:     make(map[string]bool),
+		cached:       cached,
+		now:          now,
+		maxAge:       maxAge,
+		favorite:     favorite,
+		cardHeight:   cardHeight,
 	}
-	listHeight := itemCount*6 + 4
+	items := m.items(sortedLibs)
 
-	l := list.New(items, delegate, 80, listHeight)
+	l := list.New(items, m.delegate(), 80, m.listHeight())
 	l.Title = fmt.Sprintf("🔍 Library Search (%d results)", len(libraries))
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false) // We'll handle filtering ourselves
 	l.SetShowHelp(true)
-	l.Styles.Title = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
-		Bold(true).
-		MarginLeft(2)
+	themeList(&l)
 
-	return librarySelectorModel{
-		list:         l,
-		libraries:    sortedLibs,
-		allLibraries: sortedLibs,
-		sortMode:     sortByStars,
+	m.list = l
+
+	// Restore the filter only if it still matches something; a filter left
+	// over from another search would otherwise hide every result
+	if filter != "" {
+		filtered := m
+		filtered.filter.SetValue(filter)
+		if filtered = filtered.applyFilter(); len(filtered.libraries) > 0 {
+			m = filtered
+		}
+	}
+
+	return m
+}
+
+// selectorDebounceMsg fires once typing a remote query paused; seq tells
+// whether more was typed since
+type selectorDebounceMsg struct {
+	seq   int
+	query string
+}
+
+// selectorSearchMsg carries the results of a search from the filter
+type selectorSearchMsg struct {
+	query   string
+	results []client.Library
+	err     error
+}
+
+// withSearch lets tab switch the filter to searching context7 with search,
+// for libraries the first query missed
+func (m librarySelectorModel) withSearch(ctx context.Context, search SearchFunc) librarySelectorModel {
+	m.searchCtx, m.search = ctx, search
+	return m
+}
+
+// setRemote switches the filter between the results and context7
+func (m librarySelectorModel) setRemote(remote bool) (librarySelectorModel, tea.Cmd) {
+	m.remote = remote
+	m.filter.Prompt = "Filter: "
+	if remote {
+		m.filter.Prompt = "Search context7: "
+	}
+	m = m.stopSearch()
+	if !remote {
+		return m.applyFilter(), nil
+	}
+	return m.searchNow()
+}
+
+// stopSearch abandons the pending search
+func (m librarySelectorModel) stopSearch() librarySelectorModel {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	m.searching, m.cancelSearch, m.searchErr = "", nil, nil
+	return m
+}
+
+// searchNow searches context7 for the filter text, or shows every result
+// again when it is too short to search
+func (m librarySelectorModel) searchNow() (librarySelectorModel, tea.Cmd) {
+	query := strings.TrimSpace(m.filter.Value())
+	if len([]rune(query)) < minSearchQuery {
+		m.libraries = m.allLibraries
+		return m.resort(), nil
+	}
+
+	ctx, cancel := context.WithCancel(m.searchCtx)
+	m.searching, m.cancelSearch = query, cancel
+	search := m.search
+	return m, func() tea.Msg {
+		results, err := search(ctx, query)
+		return selectorSearchMsg{query: query, results: results, err: err}
+	}
+}
+
+// searched shows the libraries context7 found for the filter, keeping
+// them for when the filter goes back to the results
+func (m librarySelectorModel) searched(msg selectorSearchMsg) librarySelectorModel {
+	// Results of a query that has been edited since are dropped
+	if !m.remote || msg.query != m.searching {
+		return m
+	}
+	m.searching, m.cancelSearch = "", nil
+	if msg.err != nil {
+		m.searchErr = msg.err
+		return m
+	}
+
+	known := make(map[string]bool, len(m.allLibraries))
+	for _, lib := range m.allLibraries {
+		known[lib.ID] = true
+	}
+	for _, lib := range msg.results {
+		if !known[lib.ID] {
+			m.allLibraries = append(m.allLibraries, lib)
+		}
 	}
+
+	m.libraries = msg.results
+	m = m.resort()
+	m.list.Title = fmt.Sprintf("🔍 Library Search (%d results for '%s')", len(msg.results), msg.query)
+	return m
+}
+
+// newFilterInput creates the filter line editor: paste, word deletion,
+// cursor movement and unicode input come from textinput
+func newFilterInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Filter: "
+	ti.PromptStyle = promptStyle
+	ti.TextStyle = ti.PromptStyle
+	// Blink messages are not routed back to the selector
+	ti.Cursor.SetMode(cursor.CursorStatic)
+	return ti
 }
 
 func (m librarySelectorModel) Update(msg tea.Msg) (librarySelectorModel, tea.Cmd) {
 	switch msg := msg.(type) {
+	case selectorDebounceMsg:
+		if !m.remote || msg.seq != m.searchSeq {
+			return m, nil
+		}
+		return m.searchNow()
+	case selectorSearchMsg:
+		return m.searched(msg), nil
 	case tea.KeyMsg:
+		if m.detail != nil {
+			return m.updateDetail(msg)
+		}
+		if m.filter.Focused() {
+			return m.updateFilter(msg)
+		}
+
 		switch msg.String() {
 		case "enter":
+			return m.choose(), nil
+		case " ":
+			// Toggle the highlighted library for multi-select
 			if item, ok := m.list.SelectedItem().(libraryItem); ok {
-				m.choice = &item.lib
-				m.done = true
-				return m, nil
+				if m.selected[item.lib.ID] {
+					delete(m.selected, item.lib.ID)
+				} else {
+					m.selected[item.lib.ID] = true
+				}
+				item.selected = m.selected[item.lib.ID]
+				m.list.SetItem(m.list.Index(), item)
 			}
+			return m, nil
 		case "/":
-			// Toggle filter mode
-			m.filterActive = !m.filterActive
-			if !m.filterActive {
-				// Reset filter when exiting
-				m.filterInput = ""
-				m = m.applyFilter()
+			// Enter filter mode (a restored filter can be edited in place)
+			m.filter.Focus()
+			m.filter.CursorEnd()
+			return m, nil
+		case "d":
+			// Show everything known about the highlighted library
+			if item, ok := m.list.SelectedItem().(libraryItem); ok {
+				lib := item.lib
+				m.detail = &lib
 			}
 			return m, nil
+		case "v":
+			// Switch between cards and the compact view
+			return m.setCompact(!m.compact), nil
 		case "s":
 			// Cycle through sort modes
-			m.sortMode = (m.sortMode + 1) % 5
+			m.sortMode = (m.sortMode + 1) % sortMode(len(sortModeNames))
 			m = m.resort()
 			return m, nil
+		case "S":
+			// Flip the direction of the current sort
+			return m.setAscending(!m.ascending), nil
 		case "q", "esc":
 			m.done = true
 			return m, nil
 		case "ctrl+c":
 			m.done = true
 			return m, tea.Quit
-		default:
-			// Handle filter input
-			if m.filterActive {
-				if msg.String() == "backspace" {
-					if len(m.filterInput) > 0 {
-						m.filterInput = m.filterInput[:len(m.filterInput)-1]
-						m = m.applyFilter()
-					}
-				} else if len(msg.String()) == 1 {
-					m.filterInput += msg.String()
-					m = m.applyFilter()
-				}
-				return m, nil
-			}
 		}
 	case tea.WindowSizeMsg:
-		m.list.SetSize(msg.Width, msg.Height-2)
+		m = m.resize(msg.Width, msg.Height)
 	}
 
+	fullHelp := m.list.Help.ShowAll
 	var cmd tea.Cmd
 	m.list, cmd = m.list.Update(msg)
+	if m.list.Help.ShowAll != fullHelp {
+		// "?" toggled the full help; make room for it
+		m.list.SetHeight(m.listHeight())
+	}
 	return m, cmd
 }
 
+// selectorChrome is the number of lines the selector draws around the
+// list: the blank line above it, the filter and the status line
+const selectorChrome = 3
+
+// resize fits the list to a terminal of the given size
+func (m librarySelectorModel) resize(width, height int) librarySelectorModel {
+	m.width, m.height = width, height
+	m.list.SetSize(width, m.listHeight())
+	return m
+}
+
+// setCompact switches between cards and one line per library
+func (m librarySelectorModel) setCompact(compact bool) librarySelectorModel {
+	m.compact = compact
+	m.list.SetDelegate(m.delegate())
+	m.list.SetHeight(m.listHeight())
+	return m
+}
+
+// setAscending sorts smallest or oldest first, or back to largest first
+func (m librarySelectorModel) setAscending(ascending bool) librarySelectorModel {
+	m.ascending = ascending
+	return m.resort()
+}
+
+// setFullHelp expands or collapses the key help below the list
+func (m librarySelectorModel) setFullHelp(full bool) librarySelectorModel {
+	m.list.Help.ShowAll = full
+	m.list.SetHeight(m.listHeight())
+	return m
+}
+
+// delegate renders libraries as cards, or as single lines when compact
+func (m librarySelectorModel) delegate() list.DefaultDelegate {
+	delegate := newDelegate()
+	if m.compact {
+		delegate.ShowDescription = false
+		delegate.SetSpacing(0)
+		delegate.SetHeight(1)
+		return delegate
+	}
+	delegate.SetSpacing(1)
+	delegate.SetHeight(m.cardHeight)
+	return delegate
+}
+
+// itemLines returns the lines each library takes, including spacing
+func (m librarySelectorModel) itemLines() int {
+	if m.compact {
+		return 1
+	}
+	return m.cardHeight + 1
+}
+
+// listHeight returns the height that shows every visible library, capped
+// to what fits in the terminal, or to 5 cards while its size is unknown
+func (m librarySelectorModel) listHeight() int {
+	// Title and pagination above the cards, help below
+	listChrome := 6
+	if m.list.Help.ShowAll {
+		// The full help takes a few more lines
+		listChrome += 3
+	}
+	items := len(m.libraries)
+	if m.height <= 0 {
+		// Without the terminal size, take about as many lines as 5 cards
+		items = min(items, 5*(m.cardHeight+1)/m.itemLines())
+	}
+	height := items*m.itemLines() + listChrome
+	if m.height > 0 {
+		height = min(height, max(m.height-selectorChrome, m.itemLines()+listChrome))
+	}
+	return height
+}
+
+// updateFilter handles keys while the filter is being edited
+func (m librarySelectorModel) updateFilter(msg tea.KeyMsg) (librarySelectorModel, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		return m.choose(), nil
+	case "esc":
+		// Leave filter mode and reset the filter
+		m.filter.Blur()
+		m.filter.SetValue("")
+		return m.setRemote(false)
+	case "tab":
+		if m.search != nil {
+			return m.setRemote(!m.remote)
+		}
+	case "ctrl+c":
+		m.done = true
+		return m, tea.Quit
+	case "up", "down":
+		var cmd tea.Cmd
+		m.list, cmd = m.list.Update(msg)
+		return m, cmd
+	}
+
+	before := m.filter.Value()
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	if m.filter.Value() == before {
+		return m, cmd
+	}
+	if !m.remote {
+		return m.applyFilter(), cmd
+	}
+
+	// Search once typing pauses
+	m = m.stopSearch()
+	m.searchSeq++
+	seq, query := m.searchSeq, strings.TrimSpace(m.filter.Value())
+	if len([]rune(query)) < minSearchQuery {
+		m.libraries = m.allLibraries
+		return m.resort(), cmd
+	}
+	return m, tea.Batch(cmd, tea.Tick(searchDebounce, func(time.Time) tea.Msg {
+		return selectorDebounceMsg{seq: seq, query: query}
+	}))
+}
+
 func (m librarySelectorModel) View() string {
+	if m.detail != nil {
+		return m.detailView()
+	}
 	view := m.list.View()
 
-	// Show filter input if active
-	if m.filterActive {
-		filterStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("205")).
-			Bold(true)
-		view += "\n" + filterStyle.Render(fmt.Sprintf("Filter: %s_", m.filterInput))
+	// Show filter input if active, or the filter still applied
+	if m.filter.Focused() || m.filter.Value() != "" {
+		view += "\n" + m.filter.View()
+		switch {
+		case m.searching != "":
+			view += hintStyle.Render("  searching...")
+		case m.searchErr != nil:
+			view += errorStyle.Render("  " + m.searchErr.Error())
+		case m.filter.Focused() && m.search != nil && m.remote:
+			view += hintStyle.Render("  tab filters the results")
+		case m.filter.Focused() && m.search != nil:
+			view += hintStyle.Render("  tab searches context7")
+		}
 	}
 
 	// Show current sort mode
-	sortLabel := []string{"Stars", "Trust", "Updated", "Tokens", "Relevance"}[m.sortMode]
-	sortStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	view += "\n" + sortStyle.Render(fmt.Sprintf("Sort: %s ▼", sortLabel))
+	sortLabel := []string{"Stars", "Trust", "Updated", "Tokens", "Relevance", "Benchmark"}[m.sortMode]
+	direction := "▼"
+	if m.ascending {
+		direction = "▲"
+	}
+	sortStyle := hintStyle
+	status := fmt.Sprintf("Sort: %s %s (S flips) • space select • d details • v view", sortLabel, direction)
+	if len(m.selected) > 0 {
+		status = fmt.Sprintf("Sort: %s %s • %d selected, enter fetches all", sortLabel, direction, len(m.selected))
+	}
+	// Where the highlighted library is among the results, as only a page
+	// of them fits
+	if n := len(m.list.Items()); n > 0 {
+		status = fmt.Sprintf("%d/%d • %s", m.list.Index()+1, n, status)
+	}
+	view += "\n" + sortStyle.Render(status)
 
 	return "\n" + view
 }
 
 // Helper methods
 
+// choose finishes with the marked libraries, or the highlighted one if none are marked
+func (m librarySelectorModel) choose() librarySelectorModel {
+	if len(m.selected) > 0 {
+		// Keep the original result order, including marks hidden by the filter
+		for _, lib := range m.allLibraries {
+			if m.selected[lib.ID] {
+				m.choices = append(m.choices, lib)
+			}
+		}
+		m.done = true
+		return m
+	}
+
+	if item, ok := m.list.SelectedItem().(libraryItem); ok {
+		m.choice = &item.lib
+		m.done = true
+	}
+	return m
+}
+
+// items builds list items for libs, keeping multi-select marks and cache badges
+func (m librarySelectorModel) items(libs []client.Library) []list.Item {
+	items := make([]list.Item, len(libs))
+	for i, lib := range libs {
+		item := libraryItem{lib: lib, selected: m.selected[lib.ID], favorite: m.favorite != nil && m.favorite(lib.ID)}
+		if fetchedAt, ok := m.cached.newest(lib.ID); ok {
+			item.badge = cacheBadge(fetchedAt, m.now, m.maxAge)
+			if updated, err := time.Parse(time.RFC3339, lib.LastUpdateDate); err == nil && updated.After(fetchedAt) {
+				item.badge += " " + upstreamBadge(updated, m.now)
+			}
+		}
+		items[i] = item
+	}
+	return items
+}
+
 func (m librarySelectorModel) resort() librarySelectorModel {
 	sorted := make([]client.Library, len(m.libraries))
 	copy(sorted, m.libraries)
-	sortLibraries(sorted, m.sortMode)
+	sortLibraries(sorted, m.sortMode, m.ascending)
+	favoritesFirst(sorted, m.favorite)
 
-	items := make([]list.Item, len(sorted))
-	for i, lib := range sorted {
-		items[i] = libraryItem{lib: lib}
-	}
-
-	m.list.SetItems(items)
+	m.list.SetItems(m.items(sorted))
+	m.list.SetHeight(m.listHeight())
 	m.libraries = sorted
 	return m
 }
 
 func (m librarySelectorModel) applyFilter() librarySelectorModel {
-	if m.filterInput == "" {
+	if m.filter.Value() == "" {
 		// Reset to all libraries
 		m.libraries = m.allLibraries
 		return m.resort()
 	}
 
+	query := foldText(m.filter.Value())
 	filtered := []client.Library{}
 	for _, lib := range m.allLibraries {
-		searchText := strings.ToLower(lib.Title + " " + lib.Description + " " + lib.ID)
-		if strings.Contains(searchText, strings.ToLower(m.filterInput)) {
+		searchText := foldText(lib.Title + " " + lib.Description + " " + lib.ID)
+		if strings.Contains(searchText, query) {
 			filtered = append(filtered, lib)
 		}
 	}
@@ -224,21 +605,25 @@ func (m librarySelectorModel) applyFilter() librarySelectorModel {
 	m.libraries = filtered
 
 	// Resort filtered results
-	sortLibraries(filtered, m.sortMode)
+	sortLibraries(filtered, m.sortMode, m.ascending)
+	favoritesFirst(filtered, m.favorite)
 
-	items := make([]list.Item, len(filtered))
-	for i, lib := range filtered {
-		items[i] = libraryItem{lib: lib}
-	}
-
-	m.list.SetItems(items)
+	m.list.SetItems(m.items(filtered))
+	m.list.SetHeight(m.listHeight())
 	m.list.Title = fmt.Sprintf("🔍 Library Search (%d results)", len(filtered))
 	return m
 }
 
 // Sorting functions
 
-func sortLibraries(libs []client.Library, mode sortMode) {
+// sortLibraries sorts libs largest or newest first by mode, or the other
+// way round when ascending
+func sortLibraries(libs []client.Library, mode sortMode, ascending bool) {
+	defer func() {
+		if ascending {
+			slices.Reverse(libs)
+		}
+	}()
 	switch mode {
 	case sortByStars:
 		sort.Slice(libs, func(i, j int) bool {
@@ -262,7 +647,22 @@ func sortLibraries(libs []client.Library, mode sortMode) {
 		sort.Slice(libs, func(i, j int) bool {
 			return libs[i].Score > libs[j].Score
 		})
+	case sortByBenchmark:
+		sort.Slice(libs, func(i, j int) bool {
+			return libs[i].BenchmarkScore > libs[j].BenchmarkScore
+		})
+	}
+}
+
+// favoritesFirst moves the favorite libraries ahead of the rest, keeping
+// the order within each
+func favoritesFirst(libs []client.Library, favorite func(libraryID string) bool) {
+	if favorite == nil {
+		return
 	}
+	sort.SliceStable(libs, func(i, j int) bool {
+		return favorite(libs[i].ID) && !favorite(libs[j].ID)
+	})
 }
 
 // Formatting helper functions
@@ -321,32 +721,20 @@ func extractOrg(id string) string {
 	return ""
 }
 
+// foldText normalizes text for filtering: full-width and composed forms
+// compare equal to their plain equivalents, and case is ignored
+func foldText(text string) string {
+	return strings.ToLower(norm.NFKC.String(text))
+}
+
+// wrapText wraps text to width terminal cells, limited to 2 lines. Words
+// wider than a line, such as CJK text without spaces, are broken anywhere.
 func wrapText(text string, width int) string {
-	if len(text) <= width {
+	if ansi.StringWidth(text) <= width {
 		return text
 	}
 
-	// Simple word wrap
-	words := strings.Fields(text)
-	var lines []string
-	var currentLine string
-
-	for _, word := range words {
-		if len(currentLine)+len(word)+1 <= width {
-			if currentLine != "" {
-				currentLine += " "
-			}
-			currentLine += word
-		} else {
-			if currentLine != "" {
-				lines = append(lines, currentLine)
-			}
-			currentLine = word
-		}
-	}
-	if currentLine != "" {
-		lines = append(lines, currentLine)
-	}
+	lines := strings.Split(ansi.Wrap(strings.Join(strings.Fields(text), " "), width, ""), "\n")
 
 	// Limit to 2 lines
 	if len(lines) > 2 {
@@ -3,6 +3,7 @@ package tui
 import (
 	"github.com/hsbacot/ctx7/cache"
 	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
 )
 
 // Message types for Bubble Tea state transitions
@@ -14,14 +15,33 @@ type searchCompleteMsg struct {
 
 type fetchCompleteMsg struct {
 	content string
-	err     error
+	changes *engine.ChangeSummary
+	// stale is the cached copy served because the download failed, or
+	// because it looked like a regression
+	stale *engine.Result
+	// regression is set when the download looked worse than the cached copy
+	regression *engine.Regression
+	err        error
+}
+
+// downloadProgressMsg reports the bytes received by an in-flight fetch;
+// ch delivers the next report
+type downloadProgressMsg struct {
+	read  int64
+	total int64 // -1 when unknown
+	ch    <-chan downloadProgressMsg
 }
 
 type cacheCheckCompleteMsg struct {
-	entry *cache.CacheEntry
-	found bool
+	entry      *cache.CacheEntry
+	found      bool
+	prefetched bool // Downloaded into the cache by this run's prefetch
 }
 
 type errorMsg struct {
 	err error
 }
+
+// cancelledMsg reports that the model's context was cancelled, by the
+// cancel keys or by the caller (e.g. on SIGTERM)
+type cancelledMsg struct{}
@@ -1,15 +1,26 @@
 package tui
 
 import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
+	"github.com/hsbacot/ctx7/alias"
 	"github.com/hsbacot/ctx7/cache"
 	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/learn"
+	"github.com/hsbacot/ctx7/prefs"
 )
 
 type state int
 
+// Client is the subset of the context7 API client the model depends on
+type Client = engine.Client
+
 const (
 	stateInitializing state = iota
 	stateCheckingCache
@@ -17,18 +28,105 @@ const (
 	stateSelectingLibrary
 	stateSelectingVersion
 	stateFetching
+	stateSelectingTopic
+	stateFailed
 	stateSuccess
 	stateError
 )
 
+var stateNames = map[state]string{
+	stateInitializing:     "initializing",
+	stateCheckingCache:    "checking-cache",
+	stateSearching:        "searching",
+	stateSelectingLibrary: "selecting-library",
+	stateSelectingVersion: "selecting-version",
+	stateFetching:         "fetching",
+	stateSelectingTopic:   "selecting-topic",
+	stateFailed:           "failed",
+	stateSuccess:          "success",
+	stateError:            "error",
+}
+
+func (s state) String() string {
+	return stateNames[s]
+}
+
 // Options contains configuration for the Model
 type Options struct {
 	Interactive  bool
 	Verbose      bool
 	NoCache      bool
 	ShowVersions bool
-	Logger       *log.Logger
-	Cache        *cache.Cache
+	// ReducedMotion replaces the animated spinner with static status lines
+	ReducedMotion bool
+	Branch        string
+	// Topic narrows the fetched documentation to a topic (server-side)
+	Topic string
+	// PickTopic offers a table of contents of the fetched document to pick from
+	PickTopic bool
+	Logger    *log.Logger
+	Cache     *cache.Cache
+	// Library skips the search and fetches this library directly
+	Library *client.Library
+	// Version fetches a specific version of the library (e.g. from
+	// react-router@6.4.0), checked against the versions the library lists
+	Version string
+	// Prefs restores and records the library selector's sort mode, filter,
+	// view and help state; the caller saves it after the run (nil disables)
+	Prefs *prefs.Prefs
+	// Learned ranks the libraries previously fetched for the query first (nil disables)
+	Learned *learn.Store
+	// Registry resolves well-known library names without a search (nil always searches)
+	Registry func(name string) (client.Library, bool)
+	// Filter drops search results below a minimum quality
+	Filter engine.Filter
+	// Ranker, if set, orders the search results (see engine.Options.Ranker)
+	Ranker engine.Ranker
+	// Aliases resolves the user's short names without a search and lists
+	// the aliased libraries first in the selector (nil for none)
+	Aliases *alias.Store
+	// Trace, if set, records each decision for --explain
+	Trace *engine.Trace
+	// Metrics, if set, adds up the time and bytes of each stage for the
+	// verbose summary
+	Metrics *engine.Metrics
+
+	// Output receives downloaded documents as they stream in through the
+	// cache instead of them being kept for Content; cached documents are
+	// still returned by Content. Incompatible with PickTopic.
+	Output io.Writer
+
+	// Context bounds every network request; ctrl+c and the cancel key
+	// abort in-flight requests through a child of it (defaults to context.Background()).
+	// Cancelling it ends the run with ErrCancelled once any in-flight
+	// request has wound down.
+	Context context.Context
+
+	// Client overrides the context7 API client (defaults to client.NewClient())
+	Client Client
+	// Searcher and Fetcher, if set, replace Client for searches or for
+	// downloads only (see engine.Options.Searcher)
+	Searcher client.Searcher
+	Fetcher  client.Fetcher
+	// MaxAge is the cache TTL, also used to color cache ages (defaults to engine.DefaultMaxAge)
+	MaxAge time.Duration
+	// SearchMaxAge is the TTL of cached search results (defaults to engine.DefaultSearchMaxAge)
+	SearchMaxAge time.Duration
+	// StaleOK serves an expired cached document when the download fails
+	StaleOK bool
+	// MaxStaleness refetches cached documents that may have fallen this far
+	// behind context7 (see engine.Options.MaxStaleness)
+	MaxStaleness time.Duration
+	// OnRegression decides what a refresh does with a document that looks
+	// worse than the cached copy (see engine.Options.OnRegression)
+	OnRegression func(req engine.Request, r engine.Regression) engine.RegressionAction
+	// NoPrefetch stops the picker from downloading the documents of the
+	// top results in the background while the user picks
+	NoPrefetch bool
+	// CardHeight is the number of lines per library in the picker (defaults to DefaultCardHeight)
+	CardHeight int
+	// Clock overrides the time source used for cache metadata and ages (defaults to time.Now)
+	Clock func() time.Time
 }
 
 // Model is the Bubble Tea model for ctx7
@@ -39,50 +137,157 @@ type Model struct {
 	verbose      bool
 	noCache      bool
 	showVersions bool
+	noMotion     bool
+	topic        string
+	pickTopic    bool
+	output       io.Writer
+	cardHeight   int
 
 	// State
-	state state
-	err   error
+	state      state
+	err        error
+	notice     string // Shown above a selector, e.g. why the last pick failed
+	cancelling bool   // Cancelled while busy; quits once the request returns
+
+	// Recovery from a failed search or fetch (stateFailed)
+	failed   state          // The request to retry
+	fallback *engine.Result // Cached copy to use instead, if any
 
 	// Data
-	searchResults []client.Library
-	selectedLib   *client.Library
-	selectedVer   string
-	content       string
-	cacheEntry    *cache.CacheEntry
+	searchResults  []client.Library
+	selectedLib    *client.Library
+	additionalLibs []client.Library // Further libraries marked in the multi-select picker
+	selectedVer    string
+	selectedBranch string // Branch chosen via --branch or the version selector
+	selectedTopic  string // Topic chosen in the topic selector
+	content        string
+	changes        *engine.ChangeSummary // Set when a fetch replaced a cached copy
+	cacheEntry     *cache.CacheEntry
+	fetchedAt      time.Time // When content was downloaded, if not from the cache
+	downloaded     int64     // Bytes received by the in-flight fetch
+	downloadTotal  int64     // Expected size of the fetch, -1 when unknown
 
 	// UI Components
 	spinner         spinner.Model
+	progress        progress.Model
 	versionSelector versionSelectorModel
 	librarySelector librarySelectorModel
+	topicSelector   topicSelectorModel
 	logger          *log.Logger
+	prefs           *prefs.Prefs
+	width, height   int // Terminal size from the last WindowSizeMsg
 
 	// Services
-	client *client.Client
-	cache  *cache.Cache
+	ctx     context.Context
+	cancel  context.CancelFunc // Aborts in-flight searches and fetches
+	engine  *engine.Engine
+	trace   *engine.Trace
+	cache   *cache.Cache
+	aliases *alias.Store
+	maxAge  time.Duration
+	now     func() time.Time
+
+	// Background downloads of the top results while picking; nil with NoPrefetch
+	prefetch *prefetcher
 
 	// Flags
 	wasFromCache bool
+	staleErr     error              // Download error an expired cached copy was served in place of
+	regression   *engine.Regression // Why the download looked worse than the cached copy
 }
 
 // NewModel creates a new Bubble Tea model
 func NewModel(query string, opts Options) Model {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-
-	return Model{
-		query:        query,
-		interactive:  opts.Interactive,
-		verbose:      opts.Verbose,
-		noCache:      opts.NoCache,
-		showVersions: opts.ShowVersions,
-		state:        stateInitializing,
-		spinner:      s,
-		logger:       opts.Logger,
-		client:       client.NewClient(),
-		cache:        opts.Cache,
+	s.Style = spinnerStyle
+
+	now := opts.Clock
+	if now == nil {
+		now = time.Now
+	}
+
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = engine.DefaultMaxAge
+	}
+
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	m := Model{
+		query:          query,
+		interactive:    opts.Interactive,
+		verbose:        opts.Verbose,
+		noCache:        opts.NoCache,
+		showVersions:   opts.ShowVersions,
+		noMotion:       opts.ReducedMotion,
+		topic:          opts.Topic,
+		pickTopic:      opts.PickTopic,
+		output:         opts.Output,
+		cardHeight:     opts.CardHeight,
+		selectedBranch: opts.Branch,
+		selectedVer:    opts.Version,
+		selectedLib:    opts.Library,
+		trace:          opts.Trace,
+		state:          stateInitializing,
+		spinner:        s,
+		progress:       progress.New(progress.WithSolidFill("205"), progress.WithWidth(40)),
+		logger:         opts.Logger,
+		prefs:          opts.Prefs,
+		cache:          opts.Cache,
+		aliases:        opts.Aliases,
+		maxAge:         maxAge,
+		now:            now,
+		ctx:            ctx,
+		cancel:         cancel,
+		engine: engine.New(engine.Options{
+			Client:       opts.Client,
+			Searcher:     opts.Searcher,
+			Fetcher:      opts.Fetcher,
+			Cache:        opts.Cache,
+			NoCache:      opts.NoCache,
+			MaxAge:       maxAge,
+			SearchMaxAge: opts.SearchMaxAge,
+			StaleOK:      opts.StaleOK,
+			MaxStaleness: opts.MaxStaleness,
+			Learned:      opts.Learned,
+			Registry:     opts.Registry,
+			Filter:       opts.Filter,
+			Ranker:       opts.Ranker,
+			Aliases:      opts.Aliases,
+			Trace:        opts.Trace,
+			Metrics:      opts.Metrics,
+			OnRegression: opts.OnRegression,
+			Clock:        opts.Clock,
+		}),
+	}
+
+	if !opts.NoPrefetch {
+		m.prefetch = &prefetcher{}
+	}
+
+	// A well-known name needs no search, unless the results will be shown
+	switch {
+	case m.selectedLib != nil:
+		m.trace.Add("select", "%s was given; no search needed", m.selectedLib.ID)
+	case m.interactive || m.showVersions:
+		m.trace.Add("registry", "skipped: the search results are needed for picking")
+		if lib, ok := opts.Aliases.Lookup(query); ok {
+			// Search for what the alias names, so the picker has it to offer
+			m.query = lib.Title
+			m.trace.Add("alias", "%q is your alias for %s; searching %q", query, lib.ID, lib.Title)
+		}
+	default:
+		if lib, ok := m.engine.Known(query); ok {
+			m.selectedLib = &lib
+			m.trace.Add("select", "%s by name", lib.ID)
+		}
 	}
+	return m
 }
 
 // Err returns the error if one occurred
@@ -95,7 +300,64 @@ func (m Model) Content() string {
 	return m.content
 }
 
+// State returns the name of the current state (e.g. "searching", "success")
+func (m Model) State() string {
+	return m.state.String()
+}
+
+// Library returns the selected library, or nil if none was selected
+func (m Model) Library() *client.Library {
+	return m.selectedLib
+}
+
+// SearchResults returns the libraries found by the search, in API order
+func (m Model) SearchResults() []client.Library {
+	return m.searchResults
+}
+
+// Version returns the selected version ("" for the default)
+func (m Model) Version() string {
+	return m.selectedVer
+}
+
+// Branch returns the selected branch, if any
+func (m Model) Branch() string {
+	return m.selectedBranch
+}
+
+// Topic returns the topic picked in the topic selector, if any
+func (m Model) Topic() string {
+	return m.selectedTopic
+}
+
+// AdditionalLibraries returns the libraries marked alongside Library in the
+// multi-select picker; the caller fetches them with Options.Library
+func (m Model) AdditionalLibraries() []client.Library {
+	return m.additionalLibs
+}
+
 // WasFromCache returns true if content was loaded from cache
 func (m Model) WasFromCache() bool {
 	return m.wasFromCache
 }
+
+// FetchedAt returns when the content was downloaded from context7: when
+// the cached copy was, or during this run
+func (m Model) FetchedAt() time.Time {
+	if m.wasFromCache && m.cacheEntry != nil {
+		return m.cacheEntry.Metadata.FetchedAt
+	}
+	return m.fetchedAt
+}
+
+// Regression returns why the download looked worse than the cached copy,
+// or nil; WasFromCache tells whether the cached copy was kept
+func (m Model) Regression() *engine.Regression {
+	return m.regression
+}
+
+// StaleError returns the download error an expired cached copy was served
+// in place of (see Options.StaleOK), or nil
+func (m Model) StaleError() error {
+	return m.staleErr
+}
@@ -0,0 +1,281 @@
+package tui_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/exp/teatest"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/tui"
+	"github.com/hsbacot/ctx7/tui/tuitest"
+)
+
+var now = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+var errOffline = errors.New("offline")
+
+// newModel returns a model for query served by fc, with an empty cache
+// and without prefetching, so the fetches a test sees are its own
+func newModel(t *testing.T, query string, fc *tuitest.FakeClient, opts tui.Options) tui.Model {
+	t.Helper()
+	opts.Client = fc
+	opts.Cache = tuitest.NewCache(t, tuitest.FixedClock(now))
+	opts.Clock = tuitest.FixedClock(now)
+	opts.ReducedMotion = true
+	opts.NoPrefetch = true
+	return tui.NewModel(query, opts)
+}
+
+func TestPipeline(t *testing.T) {
+	react := tuitest.Docs["/facebook/react"]
+
+	tests := []struct {
+		name   string
+		query  string
+		opts   tui.Options
+		script func(fc *tuitest.FakeClient)
+		keys   []string
+
+		state    string
+		err      error
+		library  string
+		version  string
+		branch   string
+		content  string
+		searches []string
+		fetches  []string
+	}{
+		{
+			name:     "first result without a picker",
+			query:    "react",
+			state:    "success",
+			library:  "/facebook/react",
+			content:  react,
+			searches: []string{"react"},
+			fetches:  []string{"/facebook/react"},
+		},
+		{
+			name:    "only result",
+			query:   "react-router",
+			script:  func(fc *tuitest.FakeClient) { fc.Results = fc.Results[1:2] },
+			state:   "success",
+			library: "/remix-run/react-router",
+			content: tuitest.Docs["/remix-run/react-router"],
+			fetches: []string{"/remix-run/react-router"},
+		},
+		{
+			name:    "version in the query",
+			query:   "react",
+			opts:    tui.Options{Version: "18.3.1"},
+			state:   "success",
+			library: "/facebook/react",
+			version: "v18.3.1",
+			content: tuitest.Docs["/facebook/react/v18.3.1"],
+			fetches: []string{"/facebook/react/v18.3.1"},
+		},
+		{
+			name:    "picked from the library picker",
+			query:   "react",
+			opts:    tui.Options{Interactive: true},
+			keys:    []string{"down", "enter"},
+			state:   "success",
+			library: "/remix-run/react-router",
+			content: tuitest.Docs["/remix-run/react-router"],
+			fetches: []string{"/remix-run/react-router"},
+		},
+		{
+			name:    "picked version",
+			query:   "react",
+			opts:    tui.Options{Interactive: true, ShowVersions: true},
+			keys:    []string{"enter", "down", "enter"},
+			state:   "success",
+			library: "/facebook/react",
+			version: "v18.3.1",
+			content: tuitest.Docs["/facebook/react/v18.3.1"],
+			fetches: []string{"/facebook/react/v18.3.1"},
+		},
+		{
+			name:    "picked branch",
+			query:   "react",
+			opts:    tui.Options{Interactive: true, ShowVersions: true},
+			keys:    []string{"enter", "down", "down", "enter"},
+			state:   "success",
+			library: "/facebook/react",
+			branch:  "main",
+			content: react,
+			fetches: []string{"/facebook/react@main"},
+		},
+		{
+			name:     "no results",
+			query:    "nothing",
+			script:   func(fc *tuitest.FakeClient) { fc.Results = nil },
+			state:    "error",
+			err:      engine.ErrNoLibraries,
+			searches: []string{"nothing"},
+		},
+		{
+			name:   "search fails",
+			query:  "react",
+			script: func(fc *tuitest.FakeClient) { fc.SearchErr = errOffline },
+			state:  "error",
+			err:    errOffline,
+		},
+		{
+			name:    "fetch fails",
+			query:   "react",
+			script:  func(fc *tuitest.FakeClient) { fc.FetchErr = errOffline },
+			state:   "error",
+			err:     errOffline,
+			library: "/facebook/react",
+			fetches: []string{"/facebook/react"},
+		},
+		{
+			name:    "unlisted version",
+			query:   "react",
+			opts:    tui.Options{Version: "v1.0.0"},
+			state:   "error",
+			library: "/facebook/react",
+			version: "v1.0.0",
+		},
+		{
+			name:  "library picker cancelled",
+			query: "react",
+			opts:  tui.Options{Interactive: true},
+			keys:  []string{"esc"},
+			state: "error",
+			err:   tui.ErrCancelled,
+		},
+		{
+			name:    "version picker cancelled",
+			query:   "react",
+			opts:    tui.Options{Interactive: true, ShowVersions: true},
+			keys:    []string{"enter", "esc"},
+			state:   "error",
+			err:     tui.ErrCancelled,
+			library: "/facebook/react",
+		},
+		{
+			name:  "interrupted in the picker",
+			query: "react",
+			opts:  tui.Options{Interactive: true},
+			keys:  []string{"ctrl+c"},
+			state: "error",
+			err:   tui.ErrCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := tuitest.NewFixtureClient()
+			if tt.script != nil {
+				tt.script(fc)
+			}
+			m := tuitest.Start(newModel(t, tt.query, fc, tt.opts))
+			for _, key := range tt.keys {
+				m = tuitest.Send(m, tuitest.Key(key))
+			}
+
+			if m.State() != tt.state {
+				t.Fatalf("state = %q, want %q (err: %v)", m.State(), tt.state, m.Err())
+			}
+			if tt.err != nil && !errors.Is(m.Err(), tt.err) {
+				t.Errorf("err = %v, want %v", m.Err(), tt.err)
+			}
+			if tt.state == "error" && m.Err() == nil {
+				t.Error("err = nil, want an error")
+			}
+			if got := libraryID(m.Library()); got != tt.library {
+				t.Errorf("library = %q, want %q", got, tt.library)
+			}
+			if m.Version() != tt.version || m.Branch() != tt.branch {
+				t.Errorf("version, branch = %q, %q, want %q, %q", m.Version(), m.Branch(), tt.version, tt.branch)
+			}
+			if m.Content() != tt.content {
+				t.Errorf("content = %q, want %q", m.Content(), tt.content)
+			}
+			if tt.searches != nil && !slices.Equal(fc.Searches, tt.searches) {
+				t.Errorf("searches = %q, want %q", fc.Searches, tt.searches)
+			}
+			if !slices.Equal(fc.Fetches, tt.fetches) {
+				t.Errorf("fetches = %q, want %q", fc.Fetches, tt.fetches)
+			}
+		})
+	}
+}
+
+// A failed fetch from the picker waits on the error screen; retrying
+// after the network is back fetches the same library again
+func TestFetchRetry(t *testing.T) {
+	fc := tuitest.NewFixtureClient()
+	fc.FetchErr = errOffline
+	m := tuitest.Start(newModel(t, "react", fc, tui.Options{Interactive: true}))
+	m = tuitest.Send(m, tuitest.Key("enter"))
+	if m.State() != "failed" || !errors.Is(m.Err(), errOffline) {
+		t.Fatalf("state = %q, err = %v, want failed with %v", m.State(), m.Err(), errOffline)
+	}
+
+	fc.FetchErr = nil
+	m = tuitest.Send(m, tuitest.Key("r"))
+	if m.State() != "success" || m.Err() != nil {
+		t.Fatalf("state = %q, err = %v, want success", m.State(), m.Err())
+	}
+	if want := []string{"/facebook/react", "/facebook/react"}; !slices.Equal(fc.Fetches, want) {
+		t.Errorf("fetches = %q, want %q", fc.Fetches, want)
+	}
+}
+
+// Going back from a failed fetch reopens the library picker
+func TestFetchFailedBack(t *testing.T) {
+	fc := tuitest.NewFixtureClient()
+	fc.FetchErr = errOffline
+	m := tuitest.Start(newModel(t, "react", fc, tui.Options{Interactive: true}))
+	m = tuitest.Send(m, tuitest.Key("enter"), tuitest.Key("b"))
+	if m.State() != "selecting-library" || m.Library() != nil {
+		t.Fatalf("state = %q, library = %q, want selecting-library with none picked", m.State(), libraryID(m.Library()))
+	}
+
+	fc.FetchErr = nil
+	m = tuitest.Send(m, tuitest.Key("down"), tuitest.Key("enter"))
+	if got := libraryID(m.Library()); m.State() != "success" || got != "/remix-run/react-router" {
+		t.Errorf("state = %q, library = %q, want success with /remix-run/react-router", m.State(), got)
+	}
+}
+
+// Quitting from the error screen keeps the error
+func TestFetchFailedQuit(t *testing.T) {
+	fc := tuitest.NewFixtureClient()
+	fc.FetchErr = errOffline
+	m := tuitest.Start(newModel(t, "react", fc, tui.Options{Interactive: true}))
+	m = tuitest.Send(m, tuitest.Key("enter"), tuitest.Key("q"))
+	if m.State() != "error" || !errors.Is(m.Err(), errOffline) {
+		t.Errorf("state = %q, err = %v, want error with %v", m.State(), m.Err(), errOffline)
+	}
+}
+
+// The program runs the whole pipeline under teatest as it does on a terminal
+func TestProgram(t *testing.T) {
+	fc := tuitest.NewFixtureClient()
+	tm := tuitest.NewTestModel(t, newModel(t, "react", fc, tui.Options{Interactive: true}))
+	teatest.WaitFor(t, tm.Output(), func(out []byte) bool {
+		return bytes.Contains(out, []byte("React Router"))
+	}, teatest.WithDuration(5*time.Second))
+	tm.Send(tea.KeyMsg{Type: tea.KeyDown})
+	tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+
+	m := tuitest.FinalModel(t, tm)
+	if got := libraryID(m.Library()); m.State() != "success" || got != "/remix-run/react-router" {
+		t.Errorf("state = %q, library = %q, want success with /remix-run/react-router", m.State(), got)
+	}
+}
+
+func libraryID(lib *client.Library) string {
+	if lib == nil {
+		return ""
+	}
+	return lib.ID
+}
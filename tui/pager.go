@@ -0,0 +1,362 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hsbacot/ctx7/prefs"
+	"github.com/hsbacot/ctx7/ui"
+)
+
+// PagerResult reports how the user left the pager
+type PagerResult struct {
+	// Print is true when the user asked to print the content to stdout and exit
+	Print bool
+}
+
+// pagerFirstChunkLines is the size of the first progressively rendered chunk;
+// small enough to show almost immediately
+const pagerFirstChunkLines = 200
+
+// Renderer renders raw content for display, e.g. ui.MarkdownRenderer
+type Renderer func(string) (string, error)
+
+// renderedChunkMsg carries one progressively rendered chunk of the document
+type renderedChunkMsg struct {
+	index int
+	text  string
+}
+
+type pagerModel struct {
+	title    string
+	lines    []string
+	plain    []string // lines with styling stripped, for matching
+	sections []int    // line numbers of section headings
+
+	viewport viewport.Model
+	ready    bool
+
+	searching bool
+	search    textinput.Model
+	matches   []int // line numbers matching the last search
+	match     int
+
+	// Progressive rendering: raw chunks still to be rendered
+	render  Renderer
+	chunks  []string
+	pending int // index of the next chunk to render
+
+	print  bool
+	inline bool // Drawn in the normal screen rather than the alternate one
+}
+
+// RunPager shows content in a scrollable full-screen pager on stderr
+func RunPager(title, content string, opts ...tea.ProgramOption) (PagerResult, error) {
+	return RunRenderingPager(title, content, nil, nil, opts...)
+}
+
+// RunRenderingPager is RunPager for raw content that render turns into its
+// display form. Rendering happens chunk by chunk in the background so huge
+// documents are scrollable before they have been rendered in full. The
+// alternate-screen choice is restored from and recorded in p; the caller
+// saves it (nil uses the alternate screen).
+func RunRenderingPager(title, content string, render Renderer, p *prefs.Prefs, opts ...tea.ProgramOption) (PagerResult, error) {
+	m := newPager(title, content, render)
+	if p != nil {
+		m.inline = p.PagerInline
+	}
+	if !m.inline {
+		opts = append([]tea.ProgramOption{tea.WithAltScreen()}, opts...)
+	}
+
+	final, err := tea.NewProgram(m, opts...).Run()
+	if err != nil {
+		return PagerResult{}, err
+	}
+	m = final.(pagerModel)
+	if p != nil {
+		p.PagerInline = m.inline
+	}
+	return PagerResult{Print: m.print}, nil
+}
+
+func newPager(title, content string, render Renderer) pagerModel {
+	search := textinput.New()
+	search.Prompt = "/"
+	// Blink messages are not routed back to the input
+	search.Cursor.SetMode(cursor.CursorStatic)
+
+	m := pagerModel{
+		title:  title,
+		search: search,
+		render: render,
+	}
+	if render == nil {
+		m.appendLines(content)
+	} else {
+		m.chunks = ui.SplitMarkdown(content, pagerFirstChunkLines)
+	}
+	return m
+}
+
+// appendLines adds displayed text to the end of the document
+func (m *pagerModel) appendLines(text string) {
+	offset := len(m.lines)
+	lines := strings.Split(text, "\n")
+
+	// Section and search matching work on plain text so rendered
+	// (ANSI-styled, indented) markdown behaves like raw llms.txt
+	plain := make([]string, len(lines))
+	for i, line := range lines {
+		plain[i] = strings.TrimSpace(ansi.Strip(line))
+	}
+
+	m.lines = append(m.lines, lines...)
+	m.plain = append(m.plain, plain...)
+	for _, i := range findSections(plain) {
+		m.sections = append(m.sections, offset+i)
+	}
+	if query := m.search.Value(); query != "" && !m.searching {
+		for _, i := range findMatches(plain, query) {
+			m.matches = append(m.matches, offset+i)
+		}
+	}
+}
+
+// findSections returns the line numbers of llms.txt snippet titles and markdown headings
+func findSections(lines []string) []int {
+	var sections []int
+	for i, line := range lines {
+		if strings.HasPrefix(line, "TITLE: ") || strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "## ") {
+			sections = append(sections, i)
+		}
+	}
+	return sections
+}
+
+func (m pagerModel) Init() tea.Cmd {
+	return m.renderNext()
+}
+
+// renderNext renders the next pending chunk in the background
+func (m pagerModel) renderNext() tea.Cmd {
+	if m.render == nil || m.pending >= len(m.chunks) {
+		return nil
+	}
+	index, chunk, render := m.pending, m.chunks[m.pending], m.render
+	return func() tea.Msg {
+		text, err := render(chunk)
+		if err != nil {
+			// Fall back to the raw text rather than losing the chunk
+			text = chunk
+		}
+		return renderedChunkMsg{index: index, text: strings.TrimRight(text, "\n")}
+	}
+}
+
+// rendering reports whether chunks are still being rendered
+func (m pagerModel) rendering() bool {
+	return m.render != nil && m.pending < len(m.chunks)
+}
+
+func (m pagerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Title line + status line
+		height := msg.Height - 2
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, height)
+			m.viewport.SetContent(strings.Join(m.lines, "\n"))
+			m.ready = true
+		} else {
+			m.viewport.Width = msg.Width
+			m.viewport.Height = height
+		}
+		return m, nil
+
+	case renderedChunkMsg:
+		if msg.index != m.pending {
+			return m, nil
+		}
+		m.appendLines(msg.text)
+		m.chunks[m.pending] = "" // release the raw chunk
+		m.pending++
+		if m.ready {
+			// Chunks double in size, so re-setting the content stays
+			// linear in the document size overall
+			offset := m.viewport.YOffset
+			m.highlight()
+			m.viewport.SetYOffset(offset)
+		}
+		return m, m.renderNext()
+
+	case tea.KeyMsg:
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "p":
+			m.print = true
+			return m, tea.Quit
+		case "a":
+			// Toggle the alternate screen; inline, the document stays in
+			// the scrollback after quitting
+			m.inline = !m.inline
+			if m.inline {
+				return m, tea.ExitAltScreen
+			}
+			return m, tea.EnterAltScreen
+		case "/":
+			m.searching = true
+			m.search.SetValue("")
+			m.search.Focus()
+			return m, nil
+		case "n":
+			m.jumpToMatch(m.match + 1)
+			return m, nil
+		case "N":
+			m.jumpToMatch(m.match - 1)
+			return m, nil
+		case "]":
+			m.jumpSection(1)
+			return m, nil
+		case "[":
+			m.jumpSection(-1)
+			return m, nil
+		case "g", "home":
+			m.viewport.GotoTop()
+			return m, nil
+		case "G", "end":
+			m.viewport.GotoBottom()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m pagerModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.searching = false
+		m.search.Blur()
+		m.matches = findMatches(m.plain, m.search.Value())
+		m.highlight()
+		m.jumpToMatch(m.firstMatchFrom(m.viewport.YOffset))
+		return m, nil
+	case "esc":
+		m.searching = false
+		m.search.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.search, cmd = m.search.Update(msg)
+	return m, cmd
+}
+
+// findMatches returns the line numbers containing query (case-insensitive)
+func findMatches(lines []string, query string) []int {
+	if query == "" {
+		return nil
+	}
+	query = strings.ToLower(query)
+
+	var matches []int
+	for i, line := range lines {
+		if strings.Contains(strings.ToLower(line), query) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// highlight re-renders the content with matching lines highlighted
+func (m *pagerModel) highlight() {
+	if len(m.matches) == 0 {
+		m.viewport.SetContent(strings.Join(m.lines, "\n"))
+		return
+	}
+
+	lines := make([]string, len(m.lines))
+	copy(lines, m.lines)
+	for _, i := range m.matches {
+		lines[i] = pagerMatchStyle.Render(lines[i])
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+func (m pagerModel) firstMatchFrom(line int) int {
+	for i, match := range m.matches {
+		if match >= line {
+			return i
+		}
+	}
+	return 0
+}
+
+func (m *pagerModel) jumpToMatch(i int) {
+	if len(m.matches) == 0 {
+		return
+	}
+	// Wrap around in both directions
+	m.match = (i%len(m.matches) + len(m.matches)) % len(m.matches)
+	m.viewport.SetYOffset(m.matches[m.match])
+}
+
+func (m *pagerModel) jumpSection(direction int) {
+	current := m.viewport.YOffset
+	if direction > 0 {
+		for _, line := range m.sections {
+			if line > current {
+				m.viewport.SetYOffset(line)
+				return
+			}
+		}
+		return
+	}
+
+	for i := len(m.sections) - 1; i >= 0; i-- {
+		if m.sections[i] < current {
+			m.viewport.SetYOffset(m.sections[i])
+			return
+		}
+	}
+}
+
+func (m pagerModel) View() string {
+	if !m.ready {
+		return ""
+	}
+
+	title := pagerTitleStyle.Render(m.title)
+	if m.rendering() {
+		title += pagerStatusStyle.Render(fmt.Sprintf("  rendering %d/%d…", m.pending, len(m.chunks)))
+	}
+
+	var status string
+	switch {
+	case m.searching:
+		status = m.search.View()
+	case len(m.matches) > 0:
+		status = pagerStatusStyle.Render(fmt.Sprintf("match %d/%d • %3.f%% • n/N next/prev • ]/[ section • p print & exit • q quit",
+			m.match+1, len(m.matches), m.viewport.ScrollPercent()*100))
+	default:
+		status = pagerStatusStyle.Render(fmt.Sprintf("%3.f%% • / search • ]/[ section • a alt screen • p print & exit • q quit",
+			m.viewport.ScrollPercent()*100))
+	}
+
+	return title + "\n" + m.viewport.View() + "\n" + status
+}
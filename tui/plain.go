@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RunPlain runs m to completion without starting Bubble Tea: nothing is
+// drawn and no terminal is needed. Each status change is written to w as a
+// plain line instead. The pickers need a terminal, so m must not be
+// interactive or offer versions or topics.
+func RunPlain(m Model, w io.Writer) Model {
+	msgs := make(chan tea.Msg)
+	done := make(chan struct{})
+	defer close(done)
+
+	// Commands run concurrently as under Bubble Tea, so one blocked on the
+	// context (waitForCancel) doesn't hold up the others
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		go func() {
+			msg := cmd()
+			if batch, ok := msg.(tea.BatchMsg); ok {
+				for _, c := range batch {
+					run(c)
+				}
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-done:
+			}
+		}()
+	}
+
+	last := m.state
+	run(m.Init())
+	for msg := range msgs {
+		switch msg.(type) {
+		case nil, spinner.TickMsg:
+			// Dropping ticks stops the animation
+			continue
+		case tea.QuitMsg:
+			return m
+		}
+
+		next, cmd := m.Update(msg)
+		m = next.(Model)
+		if m.state != last {
+			last = m.state
+			if line := m.plainStatus(); line != "" {
+				fmt.Fprintln(w, line)
+			}
+		}
+		run(cmd)
+	}
+	return m
+}
+
+// plainStatus describes the current state in one unstyled line, or ""
+// for states that aren't worth reporting
+func (m Model) plainStatus() string {
+	switch m.state {
+	case stateSearching:
+		return fmt.Sprintf("Searching %s for '%s'...", m.engine.Host(), m.query)
+
+	case stateFetching:
+		lib := "library"
+		if m.selectedLib != nil {
+			lib = m.selectedLib.Title
+		}
+		return fmt.Sprintf("Fetching llms.txt for %s...", lib)
+
+	case stateSuccess:
+		source := m.engine.Host()
+		if m.wasFromCache {
+			source = "cache"
+		}
+		switch {
+		case m.changes != nil:
+			return fmt.Sprintf("✓ Fetched from %s (%s)", source, m.changes)
+		case m.wasFromCache && m.cacheEntry != nil:
+			return fmt.Sprintf("✓ Fetched from %s (cached %s)", source, cacheAge(m.now().Sub(m.cacheEntry.Metadata.FetchedAt)))
+		}
+		return fmt.Sprintf("✓ Fetched from %s", source)
+
+	case stateError:
+		return fmt.Sprintf("✗ Error: %v", m.err)
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"context"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/resolver"
+)
+
+// prefetchCount is how many libraries at the top of the picker are
+// downloaded while the user picks
+const prefetchCount = 3
+
+// prefetcher downloads the documents of the top search results into the
+// cache while the picker is open, so the one picked is usually there by
+// the time enter is pressed. Every copy of a Model shares one.
+type prefetcher struct {
+	mu      sync.Mutex
+	running map[string]*prefetch // By library ID
+}
+
+// prefetch is one background download
+type prefetch struct {
+	key    string // Cache key of the request
+	cancel context.CancelFunc
+	done   chan struct{} // Closed once the download has finished or failed
+}
+
+// start prefetches each request not prefetched already, all at once. The
+// downloads end with ctx.
+func (p *prefetcher) start(ctx context.Context, eng *engine.Engine, reqs []engine.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == nil {
+		p.running = make(map[string]*prefetch)
+	}
+
+	for _, req := range reqs {
+		if _, ok := p.running[req.Library.ID]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		f := &prefetch{key: req.CacheKey(), cancel: cancel, done: make(chan struct{})}
+		p.running[req.Library.ID] = f
+		go func() {
+			defer close(f.done)
+			defer cancel()
+			// A failed prefetch is retried by the fetch of the pick
+			_ = eng.Prefetch(ctx, req)
+		}()
+	}
+}
+
+// keep aborts every prefetch but the one of req and returns that one's
+// done channel, or nil when req was not prefetched
+func (p *prefetcher) keep(req engine.Request) <-chan struct{} {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var done <-chan struct{}
+	for id, f := range p.running {
+		if id == req.Library.ID && f.key == req.CacheKey() {
+			done = f.done
+			continue
+		}
+		f.cancel()
+		delete(p.running, id)
+	}
+	return done
+}
+
+// prefetchTop starts prefetching the libraries at the top of the picker,
+// at the version the query named. Nothing is prefetched when a version is
+// still to be picked.
+func (m Model) prefetchTop() {
+	if m.prefetch == nil || m.showVersions {
+		return
+	}
+	var reqs []engine.Request
+	for _, lib := range m.librarySelector.top(prefetchCount) {
+		version, err := resolver.MatchVersion(lib, m.selectedVer)
+		if err != nil {
+			continue
+		}
+		reqs = append(reqs, engine.Request{Library: lib, Version: version, Branch: m.selectedBranch, Topic: m.topic})
+	}
+	m.prefetch.start(m.ctx, m.engine, reqs)
+}
+
+// awaitPrefetch checks the cache for the selected library once its
+// prefetch has finished, instead of downloading it a second time
+func (m Model) awaitPrefetch(done <-chan struct{}) tea.Cmd {
+	check := m.checkLibraryCache()
+	return func() tea.Msg {
+		select {
+		case <-done:
+			msg := check().(cacheCheckCompleteMsg)
+			msg.prefetched = true
+			return msg
+		case <-m.ctx.Done():
+			return fetchCompleteMsg{err: m.ctx.Err()}
+		}
+	}
+}
+
+// top returns the first n libraries in the picker's order
+func (m librarySelectorModel) top(n int) []client.Library {
+	var libs []client.Library
+	for _, item := range m.list.Items() {
+		if len(libs) == n {
+			break
+		}
+		if li, ok := item.(libraryItem); ok {
+			libs = append(libs, li.lib)
+		}
+	}
+	return libs
+}
@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hsbacot/ctx7/cache"
+)
+
+// fail ends the run with the error of a failed search or fetch. When the
+// results are being picked from, someone is at the keyboard: the error
+// stays on screen until they retry, go back to the picker, use the cached
+// copy or quit, so a network blip doesn't cost them the whole run.
+func (m Model) fail(err error, during state) (tea.Model, tea.Cmd) {
+	m.err = cancelledOr(err)
+	if !(m.interactive || m.showVersions) || errors.Is(m.err, ErrCancelled) {
+		m.state = stateError
+		return m, tea.Quit
+	}
+
+	m.state, m.failed, m.fallback = stateFailed, during, nil
+	if during == stateFetching {
+		m.fallback, _ = m.engine.Stale(m.request(), m.err)
+	}
+	m.downloaded, m.downloadTotal = 0, 0
+	return m, nil
+}
+
+// updateFailed handles the keys offered by failedView
+func (m Model) updateFailed(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "r":
+		m.err = nil
+		m.state = m.failed
+		if m.failed == stateSearching {
+			m.trace.Add("search", "retrying the search for %q", m.query)
+			return m, m.searchLibraries()
+		}
+		m.trace.Add("fetch", "retrying %s", m.versionLabel())
+		return m, m.fetchContent()
+
+	case "b":
+		if !m.canGoBack() {
+			return m, nil
+		}
+		m.err = nil
+		if m.showVersions && len(m.selectedLib.Versions) > 1 {
+			m.state = stateSelectingVersion
+			m.versionSelector = newVersionSelector(m.selectedLib.Versions, m.branches(), m.versionBadge(loadCachedVersions(m.cache)))
+			return m, nil
+		}
+		m.selectedLib, m.additionalLibs = nil, nil
+		m.selectedVer, m.selectedBranch = "", ""
+		return m.selectLibrary(m.searchResults), nil
+
+	case "c":
+		if m.fallback == nil {
+			return m, nil
+		}
+		m.trace.Add("cache", "serving %s fetched %s in place of the failed download", m.versionLabel(), cacheAge(m.now().Sub(m.fallback.Metadata.FetchedAt)))
+		m.cacheEntry = &cache.CacheEntry{Metadata: m.fallback.Metadata, Content: m.fallback.Content}
+		m.staleErr, m.err = m.err, nil
+		return m.succeed(m.fallback.Content, true)
+
+	case "q", "esc", "enter":
+		m.state = stateError
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// canGoBack reports whether a failed fetch has a picker to go back to
+func (m Model) canGoBack() bool {
+	if m.failed != stateFetching {
+		return false
+	}
+	return m.showVersions && len(m.selectedLib.Versions) > 1 || len(m.searchResults) > 1
+}
+
+// failedView shows the error with the keys that recover from it
+func (m Model) failedView() string {
+	keys := []string{"r retry"}
+	if m.canGoBack() {
+		keys = append(keys, "b back to the picker")
+	}
+	if m.fallback != nil {
+		keys = append(keys, "c use the copy cached "+cacheAge(m.now().Sub(m.fallback.Metadata.FetchedAt)))
+	}
+	keys = append(keys, "q quit")
+	return errorStyle.Render(fmt.Sprintf("✗ Error: %v", m.err)) + "\n" +
+		hintStyle.Render("  "+strings.Join(keys, " • ")) + "\n"
+}
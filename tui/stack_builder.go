@@ -0,0 +1,391 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hsbacot/ctx7/budget"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/stack"
+)
+
+// SearchFunc looks up the libraries matching a query, e.g. engine.Search
+type SearchFunc func(ctx context.Context, query string) ([]client.Library, error)
+
+const (
+	// maxWeight is the heaviest a library can be made in the builder
+	maxWeight = 10
+	// budgetStep is how much [ and ] change the budget by
+	budgetStep = 10000
+	// builderResults bounds the search results listed at once
+	builderResults = 10
+)
+
+// builderFocus is the part of the stack builder keys go to
+type builderFocus int
+
+const (
+	focusSearch builderFocus = iota
+	focusResults
+	focusStack
+	focusName
+)
+
+// builderMember is a library added to the stack being built
+type builderMember struct {
+	lib    client.Library
+	weight int
+}
+
+// builderSearchMsg carries the results of a builder search
+type builderSearchMsg struct {
+	query   string
+	results []client.Library
+	err     error
+}
+
+type stackBuilderModel struct {
+	ctx    context.Context
+	search SearchFunc
+	// existing are the names of the stacks there already are
+	existing []string
+
+	focus     builderFocus
+	input     textinput.Model
+	name      textinput.Model
+	searching bool
+	query     string
+	results   []client.Library
+	err       error
+
+	resultCursor int
+	stackCursor  int
+	members      []builderMember
+	budget       int
+
+	done bool
+}
+
+// RunStackBuilder lets the user put a stack together from several
+// searches, weigh its libraries and pick a budget, starting from
+// tokens. The stack's Name is what it should be saved as, "" for not at
+// all; ok is false when the user quit without building one.
+func RunStackBuilder(ctx context.Context, search SearchFunc, tokens int, existing []string, opts ...tea.ProgramOption) (s stack.Stack, ok bool, err error) {
+	final, err := tea.NewProgram(newStackBuilder(ctx, search, tokens, existing), opts...).Run()
+	if err != nil {
+		return stack.Stack{}, false, err
+	}
+	m := final.(stackBuilderModel)
+	if !m.done {
+		return stack.Stack{}, false, nil
+	}
+	return m.stack(), true, nil
+}
+
+func newStackBuilder(ctx context.Context, search SearchFunc, tokens int, existing []string) stackBuilderModel {
+	input := textinput.New()
+	input.Placeholder = "search for a library, e.g. next.js"
+	input.Prompt = "🔍 "
+	// Blink messages are not routed back to the input
+	input.Cursor.SetMode(cursor.CursorStatic)
+	input.Focus()
+
+	name := textinput.New()
+	name.Placeholder = "leave empty to not save it"
+	name.Prompt = "Save as: "
+	name.Cursor.SetMode(cursor.CursorStatic)
+
+	return stackBuilderModel{
+		ctx:      ctx,
+		search:   search,
+		existing: existing,
+		input:    input,
+		name:     name,
+		budget:   tokens,
+	}
+}
+
+// stack returns the stack that was built
+func (m stackBuilderModel) stack() stack.Stack {
+	s := stack.Stack{Name: strings.TrimSpace(m.name.Value()), Budget: m.budget}
+	titles := make([]string, len(m.members))
+	for i, member := range m.members {
+		s.Libraries = append(s.Libraries, stack.Library{Name: member.lib.ID, Weight: float64(member.weight)})
+		titles[i] = member.lib.Title
+	}
+	s.Description = strings.Join(titles, ", ")
+	return s
+}
+
+// member returns the index of lib in the stack, or -1
+func (m stackBuilderModel) member(lib client.Library) int {
+	return slices.IndexFunc(m.members, func(member builderMember) bool { return member.lib.ID == lib.ID })
+}
+
+// shares previews how the budget would be split, in estimated tokens
+func (m stackBuilderModel) shares() []int {
+	sizes := make([]int, len(m.members))
+	weights := make([]float64, len(m.members))
+	for i, member := range m.members {
+		sizes[i] = member.lib.TotalTokens
+		weights[i] = float64(member.weight)
+	}
+	if m.budget <= 0 {
+		return sizes
+	}
+	return budget.SplitWeighted(sizes, weights, m.budget)
+}
+
+func (m stackBuilderModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m stackBuilderModel) runSearch(query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := m.search(m.ctx, query)
+		return builderSearchMsg{query: query, results: results, err: err}
+	}
+}
+
+func (m stackBuilderModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case builderSearchMsg:
+		m.searching = false
+		m.query, m.err = msg.query, msg.err
+		if msg.err != nil {
+			return m, nil
+		}
+		m.results = msg.results
+		if len(m.results) > builderResults {
+			m.results = m.results[:builderResults]
+		}
+		m.resultCursor = 0
+		if len(m.results) > 0 {
+			m.setFocus(focusResults)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		switch m.focus {
+		case focusSearch:
+			return m.updateSearch(msg)
+		case focusResults:
+			return m.updateResults(msg), nil
+		case focusStack:
+			return m.updateStack(msg), nil
+		case focusName:
+			return m.updateName(msg)
+		}
+	}
+	return m, nil
+}
+
+// setFocus moves the keys to f, focusing its text input if it has one
+func (m *stackBuilderModel) setFocus(f builderFocus) {
+	m.focus = f
+	m.input.Blur()
+	m.name.Blur()
+	switch f {
+	case focusSearch:
+		m.input.Focus()
+	case focusName:
+		m.name.Focus()
+	}
+}
+
+func (m stackBuilderModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		query := strings.TrimSpace(m.input.Value())
+		if query == "" || m.searching {
+			return m, nil
+		}
+		m.searching = true
+		m.err = nil
+		return m, m.runSearch(query)
+	case "esc":
+		if len(m.results) > 0 {
+			m.setFocus(focusResults)
+			return m, nil
+		}
+		return m, tea.Quit
+	case "tab":
+		if len(m.members) > 0 {
+			m.setFocus(focusStack)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m stackBuilderModel) updateResults(msg tea.KeyMsg) stackBuilderModel {
+	switch msg.String() {
+	case "up", "k":
+		m.resultCursor = max(m.resultCursor-1, 0)
+	case "down", "j":
+		m.resultCursor = min(m.resultCursor+1, len(m.results)-1)
+	case " ", "enter":
+		lib := m.results[m.resultCursor]
+		if i := m.member(lib); i >= 0 {
+			m.members = slices.Delete(m.members, i, i+1)
+			m.stackCursor = min(m.stackCursor, max(len(m.members)-1, 0))
+		} else {
+			m.members = append(m.members, builderMember{lib: lib, weight: 1})
+		}
+	case "/", "esc":
+		m.input.SetValue("")
+		m.setFocus(focusSearch)
+	case "tab":
+		if len(m.members) > 0 {
+			m.setFocus(focusStack)
+		}
+	}
+	return m
+}
+
+func (m stackBuilderModel) updateStack(msg tea.KeyMsg) stackBuilderModel {
+	switch msg.String() {
+	case "up", "k":
+		m.stackCursor = max(m.stackCursor-1, 0)
+	case "down", "j":
+		m.stackCursor = min(m.stackCursor+1, len(m.members)-1)
+	case "left", "h", "-":
+		m.members[m.stackCursor].weight = max(m.members[m.stackCursor].weight-1, 1)
+	case "right", "l", "+", "=":
+		m.members[m.stackCursor].weight = min(m.members[m.stackCursor].weight+1, maxWeight)
+	case "x", "d", "backspace", "delete":
+		m.members = slices.Delete(m.members, m.stackCursor, m.stackCursor+1)
+		m.stackCursor = min(m.stackCursor, max(len(m.members)-1, 0))
+		if len(m.members) == 0 {
+			m.setFocus(focusSearch)
+		}
+	case "[":
+		m.budget = max((m.budget-1)/budgetStep*budgetStep, 0)
+	case "]":
+		m.budget = (m.budget/budgetStep + 1) * budgetStep
+	case "enter":
+		m.setFocus(focusName)
+	case "tab", "/", "esc":
+		m.setFocus(focusSearch)
+	}
+	return m
+}
+
+func (m stackBuilderModel) updateName(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		name := strings.TrimSpace(m.name.Value())
+		if name != "" {
+			if err := stack.ValidateName(name); err != nil {
+				m.err = err
+				return m, nil
+			}
+		}
+		m.done = true
+		return m, tea.Quit
+	case "esc":
+		m.err = nil
+		m.setFocus(focusStack)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.name, cmd = m.name.Update(msg)
+	return m, cmd
+}
+
+func (m stackBuilderModel) View() string {
+	var b strings.Builder
+	b.WriteString("\n" + builderTitleStyle.Render("  Build a stack") + "\n\n")
+	b.WriteString("  " + m.input.View() + "\n")
+
+	switch {
+	case m.searching:
+		b.WriteString(hintStyle.Render("  Searching...") + "\n")
+	case m.query != "" && m.err == nil && len(m.results) == 0:
+		b.WriteString(hintStyle.Render(fmt.Sprintf("  No libraries match '%s'", m.query)) + "\n")
+	}
+	for i, lib := range m.results {
+		mark := "[ ]"
+		if m.member(lib) >= 0 {
+			mark = successStyle.Render("[✓]")
+		}
+		line := fmt.Sprintf("%s %s  %s  🔢 %s", mark, lib.Title, hintStyle.Render(lib.ID), formatTokens(lib.TotalTokens))
+		b.WriteString(m.cursor(focusResults, i == m.resultCursor) + line + "\n")
+	}
+
+	b.WriteString("\n" + builderTitleStyle.Render("  Stack") + "\n")
+	if len(m.members) == 0 {
+		b.WriteString(hintStyle.Render("  Nothing yet: search, then mark libraries with space") + "\n")
+	}
+	shares := m.shares()
+	total := 0
+	for i, member := range m.members {
+		total += shares[i]
+		bar := strings.Repeat("■", member.weight) + strings.Repeat("□", maxWeight-member.weight)
+		size := "size unknown"
+		if member.lib.TotalTokens > 0 {
+			size = fmt.Sprintf("~%s of %s", formatNumber(shares[i]), formatTokens(member.lib.TotalTokens))
+		}
+		line := fmt.Sprintf("%s %2d  %s  %s", builderCursorStyle.Render(bar), member.weight, member.lib.Title, size)
+		b.WriteString(m.cursor(focusStack, i == m.stackCursor) + line + "\n")
+	}
+
+	limit := "no budget"
+	if m.budget > 0 {
+		limit = fmt.Sprintf("a budget of %s", formatTokens(m.budget))
+	}
+	b.WriteString("\n" + infoStyle.Render(fmt.Sprintf("  %d libraries, ~%s of %s", len(m.members), formatTokens(total), limit)) + "\n")
+
+	if m.focus == focusName {
+		b.WriteString("\n  " + m.name.View() + "\n")
+		if name := strings.TrimSpace(m.name.Value()); slices.Contains(m.existing, name) {
+			b.WriteString(hintStyle.Render(fmt.Sprintf("  Replaces the %s stack", name)) + "\n")
+		}
+	}
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Render("  "+m.err.Error()) + "\n")
+	}
+
+	b.WriteString("\n" + hintStyle.Render("  "+m.help()) + "\n")
+	return b.String()
+}
+
+// cursor marks the focused line of a section
+func (m stackBuilderModel) cursor(section builderFocus, at bool) string {
+	if m.focus == section && at {
+		return builderCursorStyle.Render("> ")
+	}
+	return "  "
+}
+
+// help lists the keys of the focused section
+func (m stackBuilderModel) help() string {
+	switch m.focus {
+	case focusResults:
+		return "↑/↓ move • space add/remove • / new search • tab stack • ctrl+c quit"
+	case focusStack:
+		return "↑/↓ move • ←/→ weight • x remove • [/] budget • enter generate • tab search"
+	case focusName:
+		return "enter generate • esc back"
+	}
+	keys := "enter search"
+	if len(m.members) > 0 {
+		keys += " • tab stack"
+	}
+	if len(m.results) > 0 {
+		return keys + " • esc results • ctrl+c quit"
+	}
+	return keys + " • esc quit"
+}
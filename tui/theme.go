@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hsbacot/ctx7/ui"
+)
+
+// The styles below are drawn from the theme; SetTheme rebuilds them
+var (
+	spinnerStyle lipgloss.Style
+	successStyle lipgloss.Style
+	errorStyle   lipgloss.Style
+	infoStyle    lipgloss.Style
+	hintStyle    lipgloss.Style
+
+	// titleStyle heads the selectors, promptStyle marks their filter line
+	titleStyle  lipgloss.Style
+	promptStyle lipgloss.Style
+
+	builderTitleStyle  lipgloss.Style
+	builderCursorStyle lipgloss.Style
+
+	detailBoxStyle   lipgloss.Style
+	detailLabelStyle lipgloss.Style
+
+	pagerTitleStyle  lipgloss.Style
+	pagerStatusStyle lipgloss.Style
+	pagerMatchStyle  lipgloss.Style
+)
+
+func init() {
+	applyTheme(ui.CurrentTheme())
+}
+
+// SetTheme changes the colors of the interactive UI and of everything ui
+// renders, such as the cache commands' freshness. Call it before any
+// model is created.
+func SetTheme(t ui.Theme) {
+	ui.SetTheme(t)
+	applyTheme(ui.CurrentTheme())
+}
+
+func applyTheme(t ui.Theme) {
+	spinnerStyle = lipgloss.NewStyle().Foreground(t.Accent)
+	successStyle = lipgloss.NewStyle().Foreground(t.Success)
+	errorStyle = lipgloss.NewStyle().Foreground(t.Error)
+	infoStyle = lipgloss.NewStyle().Foreground(t.Info)
+	hintStyle = lipgloss.NewStyle().Foreground(t.Muted)
+
+	titleStyle = lipgloss.NewStyle().Foreground(t.Accent).Bold(true).MarginLeft(2)
+	promptStyle = lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+
+	builderTitleStyle = lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	builderCursorStyle = lipgloss.NewStyle().Foreground(t.Accent)
+
+	detailBoxStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(t.Accent).Padding(0, 1)
+	detailLabelStyle = lipgloss.NewStyle().Foreground(t.Muted).Width(11)
+
+	pagerTitleStyle = lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	pagerStatusStyle = lipgloss.NewStyle().Foreground(t.Muted)
+	pagerMatchStyle = lipgloss.NewStyle().Background(t.Accent).Foreground(t.OnAccent)
+	if t.Monochrome() {
+		// Without colors a match has nothing to stand out with but reverse video
+		pagerMatchStyle = lipgloss.NewStyle().Reverse(true)
+	}
+
+	cancelHint = hintStyle.Render("(esc to cancel)")
+}
+
+// themeList styles a selector's title, and its help in monochrome
+func themeList(l *list.Model) {
+	l.Styles.Title = titleStyle
+	if ui.CurrentTheme().Monochrome() {
+		l.Help.Styles = help.Styles{}
+	}
+}
+
+// newDelegate is list.NewDefaultDelegate in the theme's colors
+func newDelegate() list.DefaultDelegate {
+	t := ui.CurrentTheme()
+	d := list.NewDefaultDelegate()
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(t.Accent).BorderForeground(t.Accent)
+	d.Styles.SelectedDesc = d.Styles.SelectedDesc.Foreground(t.Accent).BorderForeground(t.Accent)
+	if t.Monochrome() {
+		d.Styles.NormalTitle = d.Styles.NormalTitle.UnsetForeground()
+		d.Styles.NormalDesc = d.Styles.NormalDesc.UnsetForeground()
+		d.Styles.DimmedTitle = d.Styles.DimmedTitle.UnsetForeground()
+		d.Styles.DimmedDesc = d.Styles.DimmedDesc.UnsetForeground()
+		d.Styles.SelectedTitle = d.Styles.SelectedTitle.Bold(true)
+	}
+	return d
+}
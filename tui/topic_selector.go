@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hsbacot/ctx7/content"
+)
+
+type topicItem struct {
+	topic string // "" selects the whole document
+	label string
+}
+
+func (i topicItem) Title() string       { return i.label }
+func (i topicItem) Description() string { return "" }
+func (i topicItem) FilterValue() string { return i.label }
+
+type topicSelectorModel struct {
+	list      list.Model
+	choice    string
+	cancelled bool
+	done      bool
+}
+
+func newTopicSelector(topics []string) topicSelectorModel {
+	items := make([]list.Item, 0, len(topics)+1)
+	items = append(items, topicItem{label: fmt.Sprintf("All documentation (%d topics)", len(topics))})
+	for _, topic := range topics {
+		items = append(items, topicItem{topic: topic, label: topic})
+	}
+
+	delegate := newDelegate()
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+	delegate.SetHeight(1)
+
+	// Calculate height: title (2) + items (max 15) + help (2) + padding (2)
+	itemCount := len(items)
+	if itemCount > 15 {
+		itemCount = 15
+	}
+	listHeight := 2 + itemCount + 2 + 2
+
+	l := list.New(items, delegate, 80, listHeight)
+	l.Title = "Select a topic"
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(true)
+	themeList(&l)
+
+	return topicSelectorModel{list: l}
+}
+
+func (m topicSelectorModel) Update(msg tea.Msg) (topicSelectorModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// Let the list's built-in filter consume keys while typing
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+
+		switch msg.String() {
+		case "enter":
+			if item, ok := m.list.SelectedItem().(topicItem); ok {
+				m.choice = item.topic
+				m.done = true
+				return m, nil
+			}
+		case "q", "esc":
+			if m.list.FilterState() == list.FilterApplied {
+				break
+			}
+			m.cancelled = true
+			m.done = true
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height-2)
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m topicSelectorModel) View() string {
+	return "\n" + m.list.View()
+}
+
+// topicsFromContent builds a table of contents from snippet titles, falling
+// back to markdown headings for documents without TITLE: lines
+func topicsFromContent(content string) []string {
+	var titles, headings []string
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "TITLE: "):
+			title := strings.TrimSpace(strings.TrimPrefix(line, "TITLE: "))
+			if title != "" && !seen[title] {
+				seen[title] = true
+				titles = append(titles, title)
+			}
+		case strings.HasPrefix(line, "# "), strings.HasPrefix(line, "## "):
+			heading := strings.TrimSpace(strings.TrimLeft(line, "#"))
+			if heading != "" && !seen["#"+heading] {
+				seen["#"+heading] = true
+				headings = append(headings, heading)
+			}
+		}
+	}
+
+	if len(titles) > 0 {
+		return titles
+	}
+	return headings
+}
+
+// ExtractTopic returns only the parts of doc belonging to topic: every
+// snippet with that title, or the section under a matching heading
+func ExtractTopic(doc, topic string) string {
+	if content.IsTitled(doc) {
+		var blocks []string
+		for _, block := range strings.Split(doc, content.SnippetSeparator) {
+			for _, line := range strings.Split(strings.TrimSpace(block), "\n") {
+				if title, ok := strings.CutPrefix(line, "TITLE: "); ok && strings.TrimSpace(title) == topic {
+					blocks = append(blocks, strings.TrimSpace(block))
+					break
+				}
+			}
+		}
+		return strings.Join(blocks, "\n\n"+content.SnippetSeparator+"\n\n") + "\n"
+	}
+
+	// Heading-based document: take the heading and everything up to the
+	// next heading of the same or higher level
+	var section []string
+	level := 0
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, "#") {
+			lineLevel := len(line) - len(strings.TrimLeft(line, "#"))
+			heading := strings.TrimSpace(strings.TrimLeft(line, "#"))
+			if level == 0 && heading == topic {
+				level = lineLevel
+			} else if level > 0 && lineLevel <= level {
+				break
+			}
+		}
+		if level > 0 {
+			section = append(section, line)
+		}
+	}
+	return strings.Join(section, "\n") + "\n"
+}
@@ -0,0 +1,93 @@
+package tuitest
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/hsbacot/ctx7/client"
+)
+
+// Libraries are fixture search results for "react": several matches, so
+// the picker is shown, one with versions and one without documentation
+var Libraries = []client.Library{
+	{
+		ID:             "/facebook/react",
+		Title:          "React",
+		Description:    "A JavaScript library for building user interfaces",
+		Branch:         "main",
+		LastUpdateDate: "2025-06-01T00:00:00Z",
+		State:          "finalized",
+		TotalTokens:    120,
+		TotalSnippets:  2,
+		Stars:          228000,
+		TrustScore:     10,
+		BenchmarkScore: 90,
+		Versions:       []string{"v19.0.0", "v18.3.1"},
+	},
+	{
+		ID:             "/remix-run/react-router",
+		Title:          "React Router",
+		Description:    "Declarative routing for React",
+		Branch:         "main",
+		LastUpdateDate: "2025-05-01T00:00:00Z",
+		State:          "finalized",
+		TotalTokens:    80,
+		TotalSnippets:  2,
+		Stars:          54762,
+		TrustScore:     9,
+		Versions:       []string{"v6.4.0"},
+	},
+	{
+		ID:             "/someone/react-fork",
+		Title:          "react (fork)",
+		Description:    "An abandoned fork with no documentation",
+		LastUpdateDate: "2022-01-01T00:00:00Z",
+		State:          "deprecated",
+		Stars:          2,
+		TrustScore:     1,
+	},
+}
+
+// Docs are the fixture documents of Libraries, keyed as FakeClient.Docs
+// (/someone/react-fork has none)
+var Docs = map[string]string{
+	"/facebook/react": `TITLE: Using state
+DESCRIPTION: useState adds a state variable to a component.
+SOURCE: https://react.dev/reference/react/useState
+
+LANGUAGE: jsx
+CODE:
+` + "```" + `
+const [count, setCount] = useState(0);
+` + "```" + `
+
+----------------------------------------
+
+TITLE: Effects
+DESCRIPTION: useEffect synchronizes a component with an external system.
+SOURCE: https://react.dev/reference/react/useEffect
+`,
+	"/facebook/react/v18.3.1": `TITLE: Using state
+DESCRIPTION: useState in React 18.
+SOURCE: https://18.react.dev/reference/react/useState
+`,
+	"/remix-run/react-router": `TITLE: Routes
+DESCRIPTION: createBrowserRouter defines the routes of an app.
+SOURCE: https://reactrouter.com/start/library/routing
+
+----------------------------------------
+
+TITLE: Navigation
+DESCRIPTION: Link and useNavigate move between routes.
+SOURCE: https://reactrouter.com/start/library/navigating
+`,
+}
+
+// NewFixtureClient returns a FakeClient serving copies of Libraries and
+// Docs, so a test can change them without affecting others
+func NewFixtureClient() *FakeClient {
+	return &FakeClient{
+		Results: slices.Clone(Libraries),
+		Docs:    maps.Clone(Docs),
+	}
+}
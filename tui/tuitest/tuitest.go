@@ -0,0 +1,197 @@
+// Package tuitest drives tui.Model in tests and in tools embedding ctx7's
+// TUI: a scripted fake client with fixture data, an in-memory cache and
+// helpers that run the Update loop until it settles.
+package tuitest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/exp/teatest"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/tui"
+)
+
+// FakeClient is a scripted client.Searcher and client.Fetcher (a
+// tui.Client) that records every call
+type FakeClient struct {
+	mu sync.Mutex
+
+	// Results is returned from every search
+	Results   []client.Library
+	SearchErr error
+
+	// Docs maps a library ID (including any /version suffix) to its llms.txt
+	Docs     map[string]string
+	FetchErr error
+
+	// Searches and Fetches record calls in order; fetches of a branch or
+	// topic are recorded as "<id>@<branch>#<topic>"
+	Searches []string
+	Fetches  []string
+}
+
+// SearchLibraries returns the scripted results
+func (f *FakeClient) SearchLibraries(ctx context.Context, query string) ([]client.Library, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Searches = append(f.Searches, query)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.Results, f.SearchErr
+}
+
+// FetchLLMsTxt returns the scripted document for libraryID
+func (f *FakeClient) FetchLLMsTxt(ctx context.Context, libraryID string, opts client.FetchOptions) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := libraryID
+	if opts.Branch != "" {
+		call += "@" + opts.Branch
+	}
+	if opts.Topic != "" {
+		call += "#" + opts.Topic
+	}
+	f.Fetches = append(f.Fetches, call)
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if f.FetchErr != nil {
+		return "", f.FetchErr
+	}
+	content, ok := f.Docs[libraryID]
+	if !ok {
+		return "", &client.APIError{Request: "llms.txt", StatusCode: 404}
+	}
+	return content, nil
+}
+
+// FixedClock returns a clock that always reports t
+func FixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// NewCache returns an in-memory cache rooted in a temp dir whose expiry
+// checks use now
+func NewCache(t testing.TB, now func() time.Time) *cache.Cache {
+	t.Helper()
+
+	c, err := cache.NewCacheWithStore(t.TempDir(), cache.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("creating cache: %v", err)
+	}
+	c.SetClock(now)
+	return c
+}
+
+// Key builds a key message for a named key ("enter", "esc", "down") or a single rune
+func Key(name string) tea.KeyMsg {
+	switch name {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case "ctrl+c":
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	default:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+	}
+}
+
+// Start runs m's Init commands synchronously and returns the settled model
+func Start(m tui.Model) tui.Model {
+	return drive(m, []tea.Cmd{m.Init()})
+}
+
+// Send delivers msgs to m one at a time, settling all resulting commands
+// after each, and returns the final model
+func Send(m tui.Model, msgs ...tea.Msg) tui.Model {
+	for _, msg := range msgs {
+		next, cmd := m.Update(msg)
+		m = drive(next.(tui.Model), []tea.Cmd{cmd})
+	}
+	return m
+}
+
+// settleTimeout is how long drive waits for the running commands to
+// deliver another message before the model counts as settled; the watch on
+// the model's context never delivers one unless it is cancelled
+const settleTimeout = 100 * time.Millisecond
+
+// drive runs commands concurrently, as Bubble Tea does, feeding their
+// messages back into the model until it quits or settles. Spinner ticks
+// are dropped so animation never keeps the loop alive. Commands still
+// running when it returns are abandoned, so cancelling the model's context
+// afterwards goes unnoticed; send ctrl+c instead.
+func drive(m tui.Model, cmds []tea.Cmd) tui.Model {
+	msgs := make(chan tea.Msg)
+	done := make(chan struct{})
+	defer close(done)
+
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		go func() {
+			msg := cmd()
+			if batch, ok := msg.(tea.BatchMsg); ok {
+				for _, c := range batch {
+					run(c)
+				}
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-done:
+			}
+		}()
+	}
+	for _, cmd := range cmds {
+		run(cmd)
+	}
+
+	for {
+		select {
+		case msg := <-msgs:
+			switch msg.(type) {
+			case nil, spinner.TickMsg:
+				continue
+			case tea.QuitMsg:
+				return m
+			}
+			next, cmd := m.Update(msg)
+			m = next.(tui.Model)
+			run(cmd)
+		case <-time.After(settleTimeout):
+			return m
+		}
+	}
+}
+
+// NewTestModel starts m under teatest with a fixed terminal size
+func NewTestModel(t testing.TB, m tui.Model) *teatest.TestModel {
+	t.Helper()
+	return teatest.NewTestModel(t, m, teatest.WithInitialTermSize(100, 40))
+}
+
+// FinalModel waits for the teatest program to exit and returns its final model
+func FinalModel(t testing.TB, tm *teatest.TestModel) tui.Model {
+	t.Helper()
+	return tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(tui.Model)
+}
@@ -1,19 +1,32 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/client"
+	"github.com/hsbacot/ctx7/engine"
+	"github.com/hsbacot/ctx7/resolver"
 )
 
+// ErrCancelled is the error of a run the user or the caller cancelled.
+// Nothing is cached from an interrupted download and no content is
+// returned.
+var ErrCancelled = errors.New("cancelled")
+
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
+	if m.noMotion {
+		return tea.Batch(m.checkCache(), m.waitForCancel())
+	}
 	return tea.Batch(
 		m.spinner.Tick,
 		m.checkCache(),
+		m.waitForCancel(),
 	)
 }
 
@@ -23,7 +36,23 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		if msg.String() == "ctrl+c" {
-			return m, tea.Quit
+			if m.cancelling {
+				// Pressed again: stop waiting for the request to wind down
+				return m.cancelled()
+			}
+			// Abort any in-flight request rather than waiting out its timeout
+			m.cancel()
+			return m.cancelRun()
+		}
+
+		// Offer to abandon a slow search or fetch
+		if m.busy() && msg.String() == "esc" {
+			m.cancel()
+			return m.cancelRun()
+		}
+
+		if m.state == stateFailed {
+			return m.updateFailed(msg)
 		}
 
 		// Handle library selector input when in that state
@@ -32,15 +61,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.librarySelector, cmd = m.librarySelector.Update(msg)
 
 			if m.librarySelector.done {
+				m.rememberLibrarySelector()
+				if choices := m.librarySelector.choices; len(choices) > 0 {
+					// User marked several libraries: fetch the first here,
+					// the caller fetches the rest
+					m.additionalLibs = choices[1:]
+					m.trace.Add("select", "%d libraries picked; %s first", len(choices), choices[0].ID)
+					return m.useLibrary(&choices[0])
+				}
 				if m.librarySelector.choice == nil {
 					// User cancelled
-					m.err = fmt.Errorf("cancelled")
-					m.state = stateError
-					return m, tea.Quit
+					return m.cancelled()
 				}
 				// User selected a library
-				m.selectedLib = m.librarySelector.choice
-				return m, m.checkLibraryCache()
+				m.trace.Add("select", "%s picked", m.librarySelector.choice.ID)
+				return m.useLibrary(m.librarySelector.choice)
 			}
 			return m, cmd
 		}
@@ -51,46 +86,85 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.versionSelector, cmd = m.versionSelector.Update(msg)
 
 			if m.versionSelector.done {
-				if m.versionSelector.choice == "" {
+				if m.versionSelector.choice == "" && m.versionSelector.choiceBranch == "" {
 					// User cancelled
-					m.err = fmt.Errorf("cancelled")
-					m.state = stateError
-					return m, tea.Quit
+					return m.cancelled()
 				}
-				// User selected a version
+				// User selected a version or branch
 				m.selectedVer = m.versionSelector.choice
+				m.selectedBranch = m.versionSelector.choiceBranch
+				m.trace.Add("select", "%s picked", m.versionLabel())
 				// Check version-specific cache
-				if m.cache != nil && !m.noCache {
-					entry, err := m.cache.GetWithVersion(m.selectedLib.ID, m.selectedVer, 24*time.Hour)
-					if err == nil {
-						// Version cached!
-						m.content = entry.Content
-						m.wasFromCache = true
-						m.state = stateSuccess
-						return m, tea.Quit
-					}
+				if result, ok := m.engine.Cached(m.request()); ok {
+					// Version cached!
+					m.cacheEntry = &cache.CacheEntry{Metadata: result.Metadata, Content: result.Content}
+					m.content = result.Content
+					m.wasFromCache = true
+					m.state = stateSuccess
+					return m, tea.Quit
 				}
 				// Not cached, fetch it
 				m.state = stateFetching
-				versionID := fmt.Sprintf("%s/%s", m.selectedLib.ID, m.selectedVer)
-				return m, m.fetchContent(versionID)
+				return m, m.fetchContent()
 			}
 			return m, cmd
 		}
 
+		// Handle topic selector input when in that state
+		if m.state == stateSelectingTopic {
+			var cmd tea.Cmd
+			m.topicSelector, cmd = m.topicSelector.Update(msg)
+
+			if m.topicSelector.done {
+				if m.topicSelector.cancelled {
+					// User cancelled
+					return m.cancelled()
+				}
+				// An empty choice keeps the whole document
+				if m.topicSelector.choice != "" {
+					m.selectedTopic = m.topicSelector.choice
+					m.content = ExtractTopic(m.content, m.selectedTopic)
+					m.trace.Add("filter", "topic %q picked", m.selectedTopic)
+				}
+				m.state = stateSuccess
+				return m, tea.Quit
+			}
+			return m, cmd
+		}
+
+	case selectorDebounceMsg, selectorSearchMsg:
+		if m.state != stateSelectingLibrary {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.librarySelector, cmd = m.librarySelector.Update(msg)
+		return m, cmd
+
+	case tea.WindowSizeMsg:
+		// Remember the size for selectors opened later
+		m.width, m.height = msg.Width, msg.Height
+		if m.state == stateSelectingLibrary {
+			m.librarySelector = m.librarySelector.resize(msg.Width, msg.Height)
+		}
+		return m, nil
+
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
 
+	case downloadProgressMsg:
+		if m.state != stateFetching {
+			return m, nil
+		}
+		m.downloaded, m.downloadTotal = msg.read, msg.total
+		return m, waitForProgress(msg.ch)
+
 	case cacheCheckCompleteMsg:
 		if msg.found && !m.noCache && !m.showVersions {
 			// Only use cache immediately if NOT showing versions
 			m.cacheEntry = msg.entry
-			m.content = msg.entry.Content
-			m.wasFromCache = true
-			m.state = stateSuccess
-			return m, tea.Quit
+			return m.succeed(msg.entry.Content, !msg.prefetched)
 		}
 
 		// Cache miss OR showVersions is true
@@ -103,84 +177,77 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if len(versions) == 0 {
 					versions = []string{"default"}
 				}
-				m.versionSelector = newVersionSelector(versions)
+				m.versionSelector = newVersionSelector(versions, m.branches(), m.versionBadge(loadCachedVersions(m.cache)))
 				return m, nil
 			}
 			// Use cached content if we have it and not showing versions
 			if msg.found && msg.entry != nil {
-				m.content = msg.entry.Content
-				m.wasFromCache = true
-				m.state = stateSuccess
-				return m, tea.Quit
+				m.cacheEntry = msg.entry
+				return m.succeed(msg.entry.Content, true)
 			}
 			m.state = stateFetching
-			return m, m.fetchContent(m.selectedLib.ID)
+			return m, m.fetchContent()
 		}
 		// No library selected yet, proceed with search
 		m.state = stateSearching
 		return m, m.searchLibraries()
 
+	case cancelledMsg:
+		return m.cancelRun()
+
 	case searchCompleteMsg:
+		if m.cancelling {
+			return m.cancelled()
+		}
 		if msg.err != nil {
-			m.err = msg.err
-			m.state = stateError
-			return m, tea.Quit
+			return m.fail(msg.err, stateSearching)
 		}
 
 		m.searchResults = msg.results
 
 		if len(msg.results) == 0 {
-			m.err = fmt.Errorf("no libraries found")
+			m.err = engine.ErrNoLibraries
 			m.state = stateError
 			return m, tea.Quit
 		}
 
 		if len(msg.results) == 1 {
 			// Single result, check cache first
-			m.selectedLib = &msg.results[0]
-			return m, m.checkLibraryCache()
+			m.trace.Add("select", "%s, the only result", msg.results[0].ID)
+			return m.useLibrary(&msg.results[0])
 		}
 
 		// Multiple results
 		if m.interactive {
-			m.state = stateSelectingLibrary
-			m.librarySelector = newLibrarySelector(msg.results)
-			return m, nil
+			return m.selectLibrary(msg.results), nil
 		}
 
 		// Use first result, check cache
-		m.selectedLib = &msg.results[0]
-		return m, m.checkLibraryCache()
+		m.trace.Add("select", "%s, the first of %d results", msg.results[0].ID, len(msg.results))
+		return m.useLibrary(&msg.results[0])
 
 
 	case fetchCompleteMsg:
-		if msg.err != nil {
-			m.err = msg.err
-			m.state = stateError
-			return m, tea.Quit
+		if m.cancelling {
+			// Whatever arrived is discarded; an interrupted download was
+			// never committed to the cache
+			return m.cancelled()
 		}
-
-		m.content = msg.content
-		m.state = stateSuccess
-
-		// Cache the result
-		if m.cache != nil && !m.noCache && m.selectedLib != nil {
-			metadata := cache.Metadata{
-				LibraryID:      m.selectedLib.ID,
-				Title:          m.selectedLib.Title,
-				Version:        m.selectedVer,
-				FetchedAt:      time.Now(),
-				LastUpdateDate: m.selectedLib.LastUpdateDate,
-				TotalTokens:    m.selectedLib.TotalTokens,
-				TotalSnippets:  m.selectedLib.TotalSnippets,
-				Stars:          m.selectedLib.Stars,
-				TrustScore:     m.selectedLib.TrustScore,
-				Versions:       m.selectedLib.Versions,
+		if msg.err != nil {
+			if next, ok := m.offerAlternative(msg.err); ok {
+				return next, nil
 			}
-			_ = m.cache.SetWithVersion(m.selectedLib.ID, m.selectedVer, msg.content, metadata)
+			return m.fail(msg.err, stateFetching)
 		}
 
-		return m, tea.Quit
+		m.regression = msg.regression
+		if msg.stale != nil {
+			m.cacheEntry = &cache.CacheEntry{Metadata: msg.stale.Metadata, Content: msg.content}
+			m.staleErr = msg.stale.Stale
+			return m.succeed(msg.content, true)
+		}
+		m.changes = msg.changes
+		return m.succeed(msg.content, false)
 
 	case errorMsg:
 		m.err = msg.err
@@ -191,9 +258,128 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// useLibrary selects lib and checks the cache for it. A version given
+// with the query (lib@version) must be one the library lists; it matches
+// with or without a leading "v".
+func (m Model) useLibrary(lib *client.Library) (tea.Model, tea.Cmd) {
+	m.selectedLib = lib
+	version, err := resolver.MatchVersion(*lib, m.selectedVer)
+	if err != nil {
+		m.err = err
+		m.state = stateError
+		return m, tea.Quit
+	}
+	m.selectedVer = version
+
+	// Wait for a download already under way rather than start another
+	if done := m.prefetch.keep(m.request()); done != nil {
+		select {
+		case <-done:
+		default:
+			m.trace.Add("fetch", "waiting for the prefetch of %s", m.versionLabel())
+			m.state = stateFetching
+			return m, m.awaitPrefetch(done)
+		}
+	}
+	return m, m.checkLibraryCache()
+}
+
+// selectLibrary shows the library picker for results
+func (m Model) selectLibrary(results []client.Library) Model {
+	m.state = stateSelectingLibrary
+	mode, filter := sortByStars, ""
+	if m.prefs != nil {
+		mode, filter = parseSortMode(m.prefs.LibrarySort), m.prefs.LibraryFilter
+	}
+	m.librarySelector = newLibrarySelector(results, mode, filter, loadCachedVersions(m.cache), m.now(), m.maxAge, m.cardHeight, m.aliases.Favorite).
+		withSearch(m.ctx, m.engine.Search)
+	if m.prefs != nil {
+		m.librarySelector = m.librarySelector.
+			setCompact(m.prefs.LibraryView == libraryViewCompact).
+			setAscending(m.prefs.LibrarySortAscending).
+			setFullHelp(m.prefs.HelpExpanded)
+	}
+	if m.width > 0 {
+		m.librarySelector = m.librarySelector.resize(m.width, m.height)
+	}
+	m.prefetchTop()
+	return m
+}
+
+// offerAlternative returns to the version or library picker when the
+// chosen document turned out to be empty, so the user can pick another
+// instead of the run failing. It reports false when there is nothing
+// else to offer.
+func (m Model) offerAlternative(err error) (Model, bool) {
+	if !m.interactive || !errors.Is(err, engine.ErrNoDocumentation) {
+		return m, false
+	}
+	m.downloaded, m.downloadTotal = 0, 0
+
+	if m.showVersions && len(m.selectedLib.Versions) > 1 {
+		m.notice = fmt.Sprintf("%s has no documentation yet, pick another version", m.versionLabel())
+		m.state = stateSelectingVersion
+		m.versionSelector = newVersionSelector(m.selectedLib.Versions, m.branches(), m.versionBadge(loadCachedVersions(m.cache)))
+		return m, true
+	}
+
+	var others []client.Library
+	for _, lib := range m.searchResults {
+		if lib.ID != m.selectedLib.ID {
+			others = append(others, lib)
+		}
+	}
+	if len(others) == 0 {
+		return m, false
+	}
+
+	m.notice = fmt.Sprintf("%s has no documentation yet, pick another library", m.selectedLib.Title)
+	m.trace.Add("fetch", "%s has no documentation yet; offering the other results", m.selectedLib.ID)
+	m.searchResults = others
+	m.selectedLib, m.additionalLibs = nil, nil
+	m.selectedVer, m.selectedBranch = "", ""
+	return m.selectLibrary(others), true
+}
+
+// versionLabel names the selected library and version for messages
+func (m Model) versionLabel() string {
+	switch {
+	case m.selectedBranch != "":
+		return fmt.Sprintf("%s (branch %s)", m.selectedLib.Title, m.selectedBranch)
+	case m.selectedVer != "":
+		return fmt.Sprintf("%s %s", m.selectedLib.Title, m.selectedVer)
+	}
+	return m.selectedLib.Title
+}
+
+// succeed finishes with content, first offering its topics when topic
+// picking is enabled and the document has more than one
+func (m Model) succeed(content string, fromCache bool) (tea.Model, tea.Cmd) {
+	m.content = content
+	m.wasFromCache = fromCache
+	if !fromCache {
+		m.fetchedAt = m.now()
+	}
+
+	if m.pickTopic {
+		if topics := topicsFromContent(content); len(topics) > 1 {
+			m.state = stateSelectingTopic
+			m.topicSelector = newTopicSelector(topics)
+			return m, nil
+		}
+	}
+
+	m.state = stateSuccess
+	return m, tea.Quit
+}
+
 // Command functions (run async)
 
 func (m Model) checkCache() tea.Cmd {
+	if m.selectedLib != nil {
+		// Library given up front, no search needed
+		return m.checkLibraryCache()
+	}
 	return func() tea.Msg {
 		// Initial cache check is skipped - go straight to search
 		return cacheCheckCompleteMsg{found: false}
@@ -202,17 +388,13 @@ func (m Model) checkCache() tea.Cmd {
 
 func (m Model) checkLibraryCache() tea.Cmd {
 	return func() tea.Msg {
-		if m.cache == nil || m.noCache {
-			return cacheCheckCompleteMsg{found: false}
-		}
-
-		entry, err := m.cache.Get(m.selectedLib.ID, 24*time.Hour)
-		if err != nil {
+		result, ok := m.engine.Cached(m.request())
+		if !ok {
 			return cacheCheckCompleteMsg{found: false}
 		}
 
 		return cacheCheckCompleteMsg{
-			entry: entry,
+			entry: &cache.CacheEntry{Metadata: result.Metadata, Content: result.Content},
 			found: true,
 		}
 	}
@@ -220,7 +402,7 @@ func (m Model) checkLibraryCache() tea.Cmd {
 
 func (m Model) searchLibraries() tea.Cmd {
 	return func() tea.Msg {
-		results, err := m.client.SearchLibraries(m.query)
+		results, err := m.engine.Search(m.ctx, m.query)
 		return searchCompleteMsg{
 			results: results,
 			err:     err,
@@ -228,13 +410,145 @@ func (m Model) searchLibraries() tea.Cmd {
 	}
 }
 
-func (m Model) fetchContent(libraryID string) tea.Cmd {
+func (m Model) fetchContent() tea.Cmd {
+	req := m.request()
+	progress := make(chan downloadProgressMsg, 1)
+	req.Progress = func(read, total int64) {
+		select {
+		case progress <- downloadProgressMsg{read: read, total: total, ch: progress}:
+		default:
+			// The previous report hasn't been rendered yet; skip this one
+		}
+	}
+
+	fetch := func() tea.Msg {
+		defer close(progress)
+		if m.output != nil {
+			result, err := m.engine.RefreshTo(m.ctx, req, m.output)
+			if err != nil {
+				return fetchCompleteMsg{err: err}
+			}
+			if result.FromCache {
+				return fetchCompleteMsg{stale: result, regression: result.Regression}
+			}
+			return fetchCompleteMsg{changes: result.Changes, regression: result.Regression}
+		}
+
+		result, err := m.engine.Refresh(m.ctx, req)
+		if err != nil {
+			return fetchCompleteMsg{err: err}
+		}
+		if result.FromCache {
+			return fetchCompleteMsg{content: result.Content, stale: result, regression: result.Regression}
+		}
+		return fetchCompleteMsg{content: result.Content, changes: result.Changes, regression: result.Regression}
+	}
+	return tea.Batch(fetch, waitForProgress(progress))
+}
+
+// waitForProgress delivers the next download report from ch, or nothing
+// once the fetch has finished
+func waitForProgress(ch <-chan downloadProgressMsg) tea.Cmd {
 	return func() tea.Msg {
-		content, err := m.client.FetchLLMsTxt(libraryID)
-		return fetchCompleteMsg{
-			content: content,
-			err:     err,
+		msg, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// busy reports whether a network request is in flight
+func (m Model) busy() bool {
+	return m.state == stateSearching || m.state == stateFetching
+}
+
+// cancelRun ends the run after a cancellation, first waiting for an
+// in-flight request to return so it can clean up after itself
+func (m Model) cancelRun() (tea.Model, tea.Cmd) {
+	if m.busy() {
+		m.cancelling = true
+		return m, nil
+	}
+	if m.state == stateSuccess || m.state == stateError {
+		// Already finished; the quit is on its way
+		return m, nil
+	}
+	return m.cancelled()
+}
+
+// cancelled quits with ErrCancelled and drops any content
+func (m Model) cancelled() (tea.Model, tea.Cmd) {
+	m.cancel()
+	m.err = ErrCancelled
+	m.content = ""
+	m.state = stateError
+	return m, tea.Quit
+}
+
+// waitForCancel reports the cancellation of the model's context
+func (m Model) waitForCancel() tea.Cmd {
+	return func() tea.Msg {
+		<-m.ctx.Done()
+		return cancelledMsg{}
+	}
+}
+
+// cancelledOr reports a request aborted through the model's context as a
+// plain cancellation instead of the wrapped transport error
+func cancelledOr(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return ErrCancelled
+	}
+	return err
+}
+
+// request describes the document for the current library/version/branch selection
+func (m Model) request() engine.Request {
+	return engine.Request{
+		Library: *m.selectedLib,
+		Version: m.selectedVer,
+		Branch:  m.selectedBranch,
+		Topic:   m.topic,
+	}
+}
+
+// rememberLibrarySelector records the selector's sort mode and direction, filter, view
+// and help state for the next run
+func (m Model) rememberLibrarySelector() {
+	if m.prefs == nil {
+		return
+	}
+	m.prefs.LibrarySort = m.librarySelector.sortMode.String()
+	m.prefs.LibrarySortAscending = m.librarySelector.ascending
+	m.prefs.LibraryFilter = m.librarySelector.filter.Value()
+	m.prefs.LibraryView = ""
+	if m.librarySelector.compact {
+		m.prefs.LibraryView = libraryViewCompact
+	}
+	m.prefs.HelpExpanded = m.librarySelector.list.Help.ShowAll
+}
+
+// versionBadge returns the cache marker lookup for the version selector
+func (m Model) versionBadge(cached cachedVersions) func(version, branch string) string {
+	now := m.now()
+	return func(version, branch string) string {
+		if fetchedAt, ok := cached.fetched(m.selectedLib.ID, cache.RefKey(version, branch)); ok {
+			return cacheBadge(fetchedAt, now, m.maxAge)
 		}
+		return ""
 	}
 }
 
+// branches returns the branches offered in the version selector: the
+// library's default branch plus any branch requested with --branch.
+func (m Model) branches() []string {
+	var branches []string
+	if m.selectedLib.Branch != "" {
+		branches = append(branches, m.selectedLib.Branch)
+	}
+	if m.selectedBranch != "" && m.selectedBranch != m.selectedLib.Branch {
+		branches = append(branches, m.selectedBranch)
+	}
+	return branches
+}
@@ -5,26 +5,30 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/bubbles/list"
-	"github.com/charmbracelet/lipgloss"
 )
 
 type versionItem struct {
 	version string
+	branch  string
 	label   string
 }
 
 func (i versionItem) Title() string       { return i.label }
 func (i versionItem) Description() string { return "" }
-func (i versionItem) FilterValue() string { return i.version }
+func (i versionItem) FilterValue() string { return i.version + i.branch }
 
 type versionSelectorModel struct {
-	list   list.Model
-	choice string
-	done   bool
+	list         list.Model
+	choice       string
+	choiceBranch string
+	done         bool
 }
 
-func newVersionSelector(versions []string) versionSelectorModel {
-	items := make([]list.Item, len(versions))
+// newVersionSelector builds the version list. Branches are listed after
+// the versions so the latest release stays the default selection. badge
+// returns the cache marker for a version or branch, or "" if not cached.
+func newVersionSelector(versions []string, branches []string, badge func(version, branch string) string) versionSelectorModel {
+	items := make([]list.Item, len(versions), len(versions)+len(branches))
 
 	for i, ver := range versions {
 		label := ver
@@ -35,10 +39,24 @@ func newVersionSelector(versions []string) versionSelectorModel {
 		} else if i == 0 {
 			label = fmt.Sprintf("%s (latest)", ver)
 		}
+		if b := badge(ver, ""); b != "" {
+			label += "  " + b
+		}
 		items[i] = versionItem{version: ver, label: label}
 	}
 
-	delegate := list.NewDefaultDelegate()
+	for _, branch := range branches {
+		label := fmt.Sprintf("%s (branch)", branch)
+		if b := badge("", branch); b != "" {
+			label += "  " + b
+		}
+		items = append(items, versionItem{
+			branch: branch,
+			label:  label,
+		})
+	}
+
+	delegate := newDelegate()
 	delegate.ShowDescription = false
 	// Reduce spacing between items
 	delegate.SetSpacing(0)
@@ -56,10 +74,7 @@ func newVersionSelector(versions []string) versionSelectorModel {
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
 	l.SetShowHelp(true)
-	l.Styles.Title = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
-		Bold(true).
-		MarginLeft(2)
+	themeList(&l)
 
 	return versionSelectorModel{list: l}
 }
@@ -71,6 +86,7 @@ func (m versionSelectorModel) Update(msg tea.Msg) (versionSelectorModel, tea.Cmd
 		case "enter":
 			if item, ok := m.list.SelectedItem().(versionItem); ok {
 				m.choice = item.version
+				m.choiceBranch = item.branch
 				m.done = true
 				return m, nil
 			}
@@ -3,48 +3,67 @@ package tui
 import (
 	"fmt"
 
-	"github.com/charmbracelet/lipgloss"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/ui"
 )
 
-var (
-	spinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
-	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-	infoStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
-)
+// cancelHint is shown while a request is in flight
+var cancelHint string
 
 // View renders the UI based on the current state
 func (m Model) View() string {
+	if m.cancelling {
+		return fmt.Sprintf("%s Cancelling... %s\n", m.indicator(), hintStyle.Render("(ctrl+c to force)"))
+	}
+
 	switch m.state {
 	case stateCheckingCache:
 		return fmt.Sprintf("%s Checking cache...\n",
-			spinnerStyle.Render(m.spinner.View()))
+			m.indicator())
 
 	case stateSearching:
-		return fmt.Sprintf("%s Searching context7.com for '%s'...\n",
-			spinnerStyle.Render(m.spinner.View()), m.query)
+		return fmt.Sprintf("%s Searching %s for '%s'... %s\n",
+			m.indicator(), m.engine.Host(), m.query, cancelHint)
 
 	case stateSelectingLibrary:
-		return m.librarySelector.View()
+		return m.noticeView() + m.librarySelector.View()
 
 	case stateSelectingVersion:
-		return m.versionSelector.View()
+		return m.noticeView() + m.versionSelector.View()
+
+	case stateSelectingTopic:
+		return m.topicSelector.View()
 
 	case stateFetching:
 		lib := "library"
 		if m.selectedLib != nil {
 			lib = m.selectedLib.Title
 		}
-		return fmt.Sprintf("%s Fetching llms.txt for %s...\n",
-			spinnerStyle.Render(m.spinner.View()), lib)
+		status := fmt.Sprintf("%s Fetching llms.txt for %s... %s\n",
+			m.indicator(), lib, cancelHint)
+		if m.downloaded > 0 {
+			status += "  " + m.downloadView() + "\n"
+		}
+		return status
 
 	case stateSuccess:
-		source := "context7.com"
+		source := m.engine.Host()
 		if m.wasFromCache {
 			source = "cache"
 		}
+		if m.changes != nil {
+			return successStyle.Render(fmt.Sprintf("✓ Fetched from %s (%s)\n", source, m.changes))
+		}
+		if m.wasFromCache && m.cacheEntry != nil {
+			fetchedAt := m.cacheEntry.Metadata.FetchedAt
+			age := ui.RenderFreshness(cache.FreshnessOf(fetchedAt, m.now(), m.maxAge), "cached "+cacheAge(m.now().Sub(fetchedAt)))
+			return successStyle.Render(fmt.Sprintf("✓ Fetched from %s (", source)) + age + successStyle.Render(")") + "\n"
+		}
 		return successStyle.Render(fmt.Sprintf("✓ Fetched from %s\n", source))
 
+	case stateFailed:
+		return m.failedView()
+
 	case stateError:
 		return errorStyle.Render(fmt.Sprintf("✗ Error: %v\n", m.err))
 
@@ -52,3 +71,45 @@ func (m Model) View() string {
 		return ""
 	}
 }
+
+// noticeView shows the pending notice, if any, on its own line
+func (m Model) noticeView() string {
+	if m.notice == "" {
+		return ""
+	}
+	return errorStyle.Render("✗ "+m.notice) + "\n"
+}
+
+// downloadView shows fetch progress: a bar with byte counts when the size
+// is known, otherwise just the bytes received
+func (m Model) downloadView() string {
+	if m.downloadTotal <= 0 {
+		return infoStyle.Render(formatBytes(m.downloaded) + " downloaded")
+	}
+	percent := float64(m.downloaded) / float64(m.downloadTotal)
+	return m.progress.ViewAs(percent) + "  " +
+		infoStyle.Render(fmt.Sprintf("%s / %s", formatBytes(m.downloaded), formatBytes(m.downloadTotal)))
+}
+
+// indicator returns the spinner frame, or a static marker with reduced motion
+func (m Model) indicator() string {
+	if m.noMotion {
+		return spinnerStyle.Render("•")
+	}
+	return spinnerStyle.Render(m.spinner.View())
+}
+
+// formatBytes converts a byte count to a human-readable size, e.g. "1.2 MB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
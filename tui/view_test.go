@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hsbacot/ctx7/client"
+)
+
+// fetching returns a model downloading the React docs
+func fetching() Model {
+	m := NewModel("react", Options{ReducedMotion: true})
+	m.state = stateFetching
+	m.selectedLib = &client.Library{ID: "/facebook/react", Title: "React"}
+	return m
+}
+
+func TestDownloadProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		read, total int64
+		want        string // On the progress line
+		bar         bool
+	}{
+		{name: "started", read: 100, total: 1 << 20, want: "100 B / 1.0 MB", bar: true},
+		{name: "half", read: 512 << 10, total: 1 << 20, want: "50%  512.0 KB / 1.0 MB", bar: true},
+		{name: "done", read: 1 << 20, total: 1 << 20, want: "100%  1.0 MB / 1.0 MB", bar: true},
+		{name: "unknown size", read: 512 << 10, total: -1, want: "512.0 KB downloaded"},
+		{name: "no size", read: 2048, total: 0, want: "2.0 KB downloaded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, cmd := fetching().Update(downloadProgressMsg{read: tt.read, total: tt.total})
+			if cmd == nil {
+				t.Error("no command to wait for the next report")
+			}
+
+			lines := strings.Split(ansi.Strip(next.View()), "\n")
+			if !strings.Contains(lines[0], "Fetching llms.txt for React") {
+				t.Fatalf("status line = %q", lines[0])
+			}
+			if len(lines) < 2 || !strings.HasSuffix(lines[1], tt.want) {
+				t.Fatalf("progress line = %q, want it to end in %q", lines[1:], tt.want)
+			}
+			if bar := strings.ContainsAny(lines[1], "█░"); bar != tt.bar {
+				t.Errorf("progress line = %q, bar shown = %v, want %v", lines[1], bar, tt.bar)
+			}
+		})
+	}
+}
+
+// The bar fills in proportion to the bytes received
+func TestDownloadProgressFill(t *testing.T) {
+	for _, read := range []int64{1, 250, 500, 750, 1000} {
+		next, _ := fetching().Update(downloadProgressMsg{read: read, total: 1000})
+		view := ansi.Strip(next.View())
+		filled, cells := strings.Count(view, "█"), strings.Count(view, "█")+strings.Count(view, "░")
+		if want := int(read) * cells / 1000; cells == 0 || filled < want-1 || filled > want+1 {
+			t.Errorf("%d of 1000 bytes: %d of %d cells filled, want about %d", read, filled, cells, want)
+		}
+	}
+}
+
+// Nothing is shown before the first byte, and reports arriving once the
+// fetch is over are dropped
+func TestDownloadProgressHidden(t *testing.T) {
+	if view := ansi.Strip(fetching().View()); strings.Count(view, "\n") != 1 {
+		t.Errorf("view before any bytes = %q, want the status line alone", view)
+	}
+
+	m := fetching()
+	m.state = stateSuccess
+	next, cmd := m.Update(downloadProgressMsg{read: 512, total: 1024})
+	if cmd != nil || next.(Model).downloaded != 0 {
+		t.Errorf("report after the fetch was taken: downloaded = %d", next.(Model).downloaded)
+	}
+}
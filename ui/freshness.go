@@ -0,0 +1,18 @@
+package ui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hsbacot/ctx7/cache"
+)
+
+var freshnessStyles map[cache.Freshness]lipgloss.Style
+
+func init() {
+	SetTheme(theme)
+}
+
+// RenderFreshness colors s by f with the theme: success when fresh,
+// warning when stale and error when expired
+func RenderFreshness(f cache.Freshness, s string) string {
+	return freshnessStyles[f].Render(s)
+}
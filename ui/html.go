@@ -0,0 +1,250 @@
+package ui
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/hsbacot/ctx7/content"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// HTMLDoc is one library's document in an HTML page
+type HTMLDoc struct {
+	// Title and LibraryID name the library, e.g. "Next.js" and /vercel/next.js
+	Title     string
+	LibraryID string
+	Version   string
+	Content   string
+}
+
+// codeStyle is the chroma style of highlighted code blocks
+const codeStyle = "github"
+
+// tocDepth is the deepest heading level listed in the sidebar
+const tocDepth = 2
+
+//go:embed html.tmpl
+var pageSource string
+
+var page = template.Must(template.New("page").Parse(pageSource))
+
+// tocEntry is a sidebar link
+type tocEntry struct {
+	ID    string
+	Title string
+	Level int
+}
+
+// htmlSection is a rendered document and its sidebar links
+type htmlSection struct {
+	HTMLDoc
+	ID   string
+	Body template.HTML
+	TOC  []tocEntry
+}
+
+// RenderHTML writes docs as a standalone HTML page titled title: each
+// document's markdown rendered with highlighted code, and a sidebar of its
+// sections. Raw HTML in the documents is left out.
+func RenderHTML(w io.Writer, title string, docs []HTMLDoc) error {
+	var css strings.Builder
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&css, styles.Get(codeStyle)); err != nil {
+		return fmt.Errorf("failed to write code styles: %w", err)
+	}
+
+	ids := &headingIDs{seen: make(map[string]bool)}
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+			util.Prioritized(&codeBlockRenderer{formatter: formatter}, 100),
+		)),
+	)
+
+	sections := make([]htmlSection, len(docs))
+	for i, doc := range docs {
+		src := []byte(toMarkdown(doc.Content))
+		root := md.Parser().Parse(text.NewReader(src), parser.WithContext(parser.NewContext(parser.WithIDs(ids))))
+
+		var body bytes.Buffer
+		if err := md.Renderer().Render(&body, src, root); err != nil {
+			return fmt.Errorf("failed to render %s: %w", doc.LibraryID, err)
+		}
+		sections[i] = htmlSection{
+			HTMLDoc: doc,
+			ID:      string(ids.Generate([]byte(doc.LibraryID), ast.KindHeading)),
+			// Raw HTML was dropped by the renderer, so the body is safe
+			Body: template.HTML(body.String()),
+			TOC:  headings(root, src),
+		}
+	}
+
+	return page.Execute(w, struct {
+		Title    string
+		CSS      template.CSS
+		Sections []htmlSection
+	}{title, template.CSS(css.String()), sections})
+}
+
+// toMarkdown turns the TITLE: snippets of a context7 document into
+// markdown sections; other documents are markdown already
+func toMarkdown(doc string) string {
+	if !content.IsTitled(doc) {
+		return doc
+	}
+
+	var b strings.Builder
+	for _, s := range content.ParseSnippets(doc) {
+		lang := ""
+		inCode := false
+		for _, line := range strings.Split(s.Text, "\n") {
+			if strings.HasPrefix(line, "```") {
+				// Fences without a language take the snippet's LANGUAGE:
+				if !inCode && line == "```" && lang != "" {
+					line += lang
+				}
+				inCode = !inCode
+				b.WriteString(line + "\n")
+				continue
+			}
+			if inCode {
+				b.WriteString(line + "\n")
+				continue
+			}
+
+			switch key, value, _ := strings.Cut(line, ": "); key {
+			case "TITLE":
+				fmt.Fprintf(&b, "## %s\n", value)
+			case "DESCRIPTION":
+				b.WriteString(value + "\n")
+			case "SOURCE":
+				fmt.Fprintf(&b, "\n[Source](%s)\n", value)
+			case "LANGUAGE":
+				lang = strings.TrimSpace(value)
+			default:
+				if line != "CODE:" {
+					b.WriteString(line + "\n")
+				}
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// headings lists the headings of a parsed document for the sidebar
+func headings(root ast.Node, src []byte) []tocEntry {
+	var toc []tocEntry
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if h.Level <= tocDepth {
+			id, _ := h.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			toc = append(toc, tocEntry{ID: string(idBytes), Title: headingText(h, src), Level: h.Level})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
+
+// headingText returns the plain text of a heading
+func headingText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(src))
+		case *ast.String:
+			b.Write(c.Value)
+		default:
+			b.WriteString(headingText(c, src))
+		}
+	}
+	return b.String()
+}
+
+// headingIDs gives headings anchors that are unique across every
+// document of the page
+type headingIDs struct {
+	seen map[string]bool
+}
+
+func (ids *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var b strings.Builder
+	for _, r := range strings.ToLower(string(value)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	id := strings.TrimSuffix(b.String(), "-")
+	if id == "" {
+		id = "section"
+	}
+	unique := id
+	for n := 1; ids.seen[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d", id, n)
+	}
+	ids.seen[unique] = true
+	return []byte(unique)
+}
+
+func (ids *headingIDs) Put(value []byte) {
+	ids.seen[string(value)] = true
+}
+
+// codeBlockRenderer highlights fenced code blocks with chroma
+type codeBlockRenderer struct {
+	formatter *chromahtml.Formatter
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.render)
+}
+
+func (r *codeBlockRenderer) render(w util.BufWriter, src []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := n.(*ast.FencedCodeBlock)
+
+	var code strings.Builder
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		code.Write(segment.Value(src))
+	}
+
+	lexer := lexers.Get(string(block.Language(src)))
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("failed to highlight code: %w", err)
+	}
+	if err := r.formatter.Format(w, styles.Get(codeStyle), iterator); err != nil {
+		return ast.WalkStop, fmt.Errorf("failed to highlight code: %w", err)
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// pdfConverter is the program RenderPDF prints the HTML page with
+const pdfConverter = "wkhtmltopdf"
+
+// ErrNoPDFConverter is returned when wkhtmltopdf is not installed
+var ErrNoPDFConverter = errors.New("--format pdf needs wkhtmltopdf (https://wkhtmltopdf.org) on the PATH; --format html writes a page to print from a browser instead")
+
+// PDFConverter returns the path of the program RenderPDF runs
+func PDFConverter() (string, error) {
+	path, err := exec.LookPath(pdfConverter)
+	if err != nil {
+		return "", ErrNoPDFConverter
+	}
+	return path, nil
+}
+
+// RenderPDF writes docs as a PDF titled title: the page of RenderHTML,
+// printed without its sidebar and one library per page
+func RenderPDF(ctx context.Context, w io.Writer, title string, docs []HTMLDoc) error {
+	converter, err := PDFConverter()
+	if err != nil {
+		return err
+	}
+
+	var page bytes.Buffer
+	if err := RenderHTML(&page, title, docs); err != nil {
+		return err
+	}
+
+	// Read the page from stdin and write the PDF to stdout
+	cmd := exec.CommandContext(ctx, converter, "--quiet", "--print-media-type", "--encoding", "utf-8", "--title", title, "-", "-")
+	cmd.Stdin = &page
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("failed to convert to PDF: %w: %s", err, msg)
+		}
+		return fmt.Errorf("failed to convert to PDF: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/x/term"
+)
+
+// RenderMarkdown renders markdown for the terminal with syntax-highlighted
+// code blocks, wrapping at width (0 uses the terminal width)
+func RenderMarkdown(content string, width int) (string, error) {
+	render, err := MarkdownRenderer(width)
+	if err != nil {
+		return "", err
+	}
+	return render(content)
+}
+
+// MarkdownRenderer returns a function rendering markdown like RenderMarkdown,
+// detecting the terminal style once so it can be called repeatedly
+func MarkdownRenderer(width int) (func(string) (string, error), error) {
+	if width <= 0 {
+		width = TerminalWidth()
+	}
+
+	style := glamour.WithAutoStyle()
+	if theme.Monochrome() {
+		style = glamour.WithStandardStyle("notty")
+	}
+	renderer, err := glamour.NewTermRenderer(
+		style,
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create markdown renderer: %w", err)
+	}
+
+	return func(content string) (string, error) {
+		rendered, err := renderer.Render(content)
+		if err != nil {
+			return "", fmt.Errorf("failed to render markdown: %w", err)
+		}
+		return rendered, nil
+	}, nil
+}
+
+// SplitMarkdown splits content into chunks that can be rendered on their
+// own, cutting only at blank lines outside code fences. The first chunk is
+// about firstLines long and each following chunk is twice the previous one.
+func SplitMarkdown(content string, firstLines int) []string {
+	lines := strings.Split(content, "\n")
+
+	var chunks []string
+	start, size, inFence := 0, firstLines, false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if i-start+1 >= size && !inFence && strings.TrimSpace(line) == "" {
+			chunks = append(chunks, strings.Join(lines[start:i+1], "\n"))
+			start = i + 1
+			size *= 2
+		}
+	}
+	if start < len(lines) {
+		chunks = append(chunks, strings.Join(lines[start:], "\n"))
+	}
+	return chunks
+}
+
+// TerminalWidth returns the width of the terminal on stdout, or 80 if unknown
+func TerminalWidth() int {
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
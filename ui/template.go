@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hsbacot/ctx7/budget"
+	"github.com/hsbacot/ctx7/content"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// TemplateNames are the built-in --template templates
+var TemplateNames = []string{"claude-xml", "markdown-toc"}
+
+// TemplateDoc is one library's document as output templates see it
+type TemplateDoc struct {
+	// Index numbers the documents from 1
+	Index int
+	// Anchor is the markdown heading ID of Title, unique in the output
+	Anchor    string
+	Query     string
+	Title     string
+	LibraryID string
+	Version   string
+	Branch    string
+	Topic     string
+	FetchedAt time.Time
+	FromCache bool
+	Content   string
+}
+
+// Sections returns the snippets of the document
+func (d TemplateDoc) Sections() []content.Snippet {
+	return content.ParseSnippets(d.Content)
+}
+
+// Tokens returns the estimated token count of the document
+func (d TemplateDoc) Tokens() int {
+	return budget.EstimateTokens(d.Content)
+}
+
+var templateFuncs = template.FuncMap{
+	"xml":    xmlEscape,
+	"anchor": headingAnchor,
+	"trim":   strings.TrimSpace,
+}
+
+// LoadTemplate returns the built-in template called name, or parses the
+// template file at name
+func LoadTemplate(name string) (*template.Template, error) {
+	var src []byte
+	var err error
+	if slices.Contains(TemplateNames, name) {
+		src, err = builtinTemplates.ReadFile("templates/" + name + ".tmpl")
+	} else if src, err = os.ReadFile(name); os.IsNotExist(err) && !strings.ContainsAny(name, `/\.`) {
+		return nil, fmt.Errorf("unknown template %q (expected %s or a template file)", name, strings.Join(TemplateNames, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// RenderTemplate writes docs through tmpl, which sees them as .Docs and
+// the time of the run as .Generated. It numbers the documents and gives
+// each a unique anchor.
+func RenderTemplate(w io.Writer, tmpl *template.Template, docs []TemplateDoc) error {
+	seen := make(map[string]int)
+	for i := range docs {
+		docs[i].Index = i + 1
+		anchor := headingAnchor(docs[i].Title)
+		if n := seen[anchor]; n > 0 {
+			docs[i].Anchor = fmt.Sprintf("%s-%d", anchor, n)
+		} else {
+			docs[i].Anchor = anchor
+		}
+		seen[anchor]++
+	}
+
+	err := tmpl.Execute(w, struct {
+		Docs      []TemplateDoc
+		Generated time.Time
+	}{docs, time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+	return nil
+}
+
+// xmlEscape escapes s for XML text and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// headingAnchor returns the ID GitHub gives a markdown heading: lowercase,
+// spaces as dashes, punctuation dropped
+func headingAnchor(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteRune('-')
+		case r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 0x7f:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/hsbacot/ctx7/cache"
+	"github.com/hsbacot/ctx7/config"
+)
+
+// Theme is the palette ctx7 draws with
+type Theme struct {
+	// Accent marks titles, the cursor, the spinner and search matches
+	Accent lipgloss.TerminalColor
+	// Success, Warning and Error color outcomes and cache freshness
+	Success lipgloss.TerminalColor
+	Warning lipgloss.TerminalColor
+	Error   lipgloss.TerminalColor
+	// Info colors informational status lines
+	Info lipgloss.TerminalColor
+	// Muted colors hints, labels and status bars
+	Muted lipgloss.TerminalColor
+	// OnAccent is the text color drawn over Accent
+	OnAccent lipgloss.TerminalColor
+}
+
+// DefaultTheme is the preset used when the config names none
+const DefaultTheme = "dark"
+
+// Themes are the built-in presets
+var Themes = map[string]Theme{
+	"dark": {
+		Accent:   lipgloss.Color("205"),
+		Success:  lipgloss.Color("42"),
+		Warning:  lipgloss.Color("214"),
+		Error:    lipgloss.Color("196"),
+		Info:     lipgloss.Color("86"),
+		Muted:    lipgloss.Color("240"),
+		OnAccent: lipgloss.Color("0"),
+	},
+	"light": {
+		Accent:   lipgloss.Color("162"),
+		Success:  lipgloss.Color("28"),
+		Warning:  lipgloss.Color("130"),
+		Error:    lipgloss.Color("160"),
+		Info:     lipgloss.Color("31"),
+		Muted:    lipgloss.Color("244"),
+		OnAccent: lipgloss.Color("231"),
+	},
+	"monochrome": {
+		Accent:   lipgloss.NoColor{},
+		Success:  lipgloss.NoColor{},
+		Warning:  lipgloss.NoColor{},
+		Error:    lipgloss.NoColor{},
+		Info:     lipgloss.NoColor{},
+		Muted:    lipgloss.NoColor{},
+		OnAccent: lipgloss.NoColor{},
+	},
+}
+
+// ThemeNames lists the built-in presets, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Monochrome reports whether the theme draws without colors, so that
+// highlights have to use reverse video instead
+func (t Theme) Monochrome() bool {
+	_, ok := t.Accent.(lipgloss.NoColor)
+	return ok
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// parseColor accepts an ANSI 256 number such as "205" or hex like "#ff5fd7"
+func parseColor(s string) (lipgloss.TerminalColor, error) {
+	if hexColor.MatchString(s) {
+		return lipgloss.Color(s), nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= 255 {
+		return lipgloss.Color(s), nil
+	}
+	return nil, fmt.Errorf("invalid color %q (want 0-255 or #rrggbb)", s)
+}
+
+// ThemeFromConfig builds the theme of a config [theme] table: its preset
+// with the colors it sets replaced
+func ThemeFromConfig(c config.Theme) (Theme, error) {
+	preset := c.Preset
+	if preset == "" {
+		preset = DefaultTheme
+	}
+	t, ok := Themes[preset]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme preset %q (available: %v)", preset, ThemeNames())
+	}
+
+	for _, o := range []struct {
+		name  string
+		value string
+		color *lipgloss.TerminalColor
+	}{
+		{"accent", c.Accent, &t.Accent},
+		{"success", c.Success, &t.Success},
+		{"warning", c.Warning, &t.Warning},
+		{"error", c.Error, &t.Error},
+		{"info", c.Info, &t.Info},
+		{"muted", c.Muted, &t.Muted},
+	} {
+		if o.value == "" {
+			continue
+		}
+		color, err := parseColor(o.value)
+		if err != nil {
+			return Theme{}, fmt.Errorf("theme %s: %w", o.name, err)
+		}
+		*o.color = color
+	}
+	return t, nil
+}
+
+// NoColor reports whether $NO_COLOR asks for output without colors
+func NoColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// ColorForced reports whether $CLICOLOR_FORCE asks for colors even when
+// output is not a terminal
+func ColorForced() bool {
+	v := os.Getenv("CLICOLOR_FORCE")
+	return v != "" && v != "0"
+}
+
+// ColorEnabled reports whether output written to f should be colored:
+// never with $NO_COLOR, always with $CLICOLOR_FORCE, otherwise only when f
+// is a terminal
+func ColorEnabled(f *os.File) bool {
+	switch {
+	case NoColor():
+		return false
+	case ColorForced():
+		return true
+	}
+	return term.IsTerminal(f.Fd())
+}
+
+var theme = Themes[DefaultTheme]
+
+// CurrentTheme returns the theme set by SetTheme
+func CurrentTheme() Theme {
+	return theme
+}
+
+// SetTheme changes the palette of everything ui renders. $NO_COLOR wins
+// over any theme.
+func SetTheme(t Theme) {
+	if NoColor() {
+		t = Themes["monochrome"]
+	}
+	theme = t
+	freshnessStyles = map[cache.Freshness]lipgloss.Style{
+		cache.Fresh:   lipgloss.NewStyle().Foreground(t.Success),
+		cache.Stale:   lipgloss.NewStyle().Foreground(t.Warning),
+		cache.Expired: lipgloss.NewStyle().Foreground(t.Error),
+	}
+}
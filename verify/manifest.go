@@ -0,0 +1,79 @@
+// Package verify checks downloaded documents against SHA-256 manifests
+// published by mirrors, optionally signed with minisign.
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotListed is returned for a document the manifest has no checksum for
+	ErrNotListed = errors.New("document is not listed in the manifest")
+	// ErrMismatch is returned when a document's checksum differs from the manifest
+	ErrMismatch = errors.New("document checksum does not match the manifest")
+)
+
+// Manifest maps document paths to their SHA-256 checksums
+type Manifest struct {
+	sums map[string]string
+}
+
+// ParseManifest reads a manifest in sha256sum format, one
+// "<hex digest>  <path>" line per document, e.g.
+//
+//	3a7bd3e2...  vercel/next.js/llms.txt
+//	9f86d081...  vercel/next.js/v14.3.0/llms.txt
+//
+// Blank lines and # comments are skipped.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{sums: make(map[string]string)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		sum, path, ok := strings.Cut(text, " ")
+		path = strings.TrimPrefix(strings.TrimSpace(path), "*") // binary-mode marker
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid manifest line %d: expected \"<sha256>  <path>\"", line)
+		}
+		if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("invalid manifest line %d: %q is not a SHA-256 digest", line, sum)
+		}
+		m.sums[normalizePath(path)] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return m, nil
+}
+
+// Len returns the number of documents listed
+func (m *Manifest) Len() int {
+	return len(m.sums)
+}
+
+// Verify checks the SHA-256 digest of the document at path
+func (m *Manifest) Verify(path string, sum []byte) error {
+	want, ok := m.sums[normalizePath(path)]
+	if !ok {
+		return fmt.Errorf("%s: %w", path, ErrNotListed)
+	}
+	if got := hex.EncodeToString(sum); got != want {
+		return fmt.Errorf("%s: %w (got %s, want %s)", path, ErrMismatch, got, want)
+	}
+	return nil
+}
+
+// normalizePath makes "./a/b", "/a/b" and "a/b" the same entry
+func normalizePath(path string) string {
+	return strings.TrimLeft(strings.TrimPrefix(path, "./"), "/")
+}
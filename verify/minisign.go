@@ -0,0 +1,84 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrBadSignature is returned when a minisign signature does not verify
+var ErrBadSignature = errors.New("invalid minisign signature")
+
+// PublicKey is a minisign Ed25519 public key
+type PublicKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// ParsePublicKey decodes a minisign public key: the base64 line of a .pub
+// file ("RWQ..."), or the whole file including its untrusted comment
+func ParsePublicKey(s string) (*PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(lastLine(s))
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+
+	pk := &PublicKey{key: ed25519.PublicKey(raw[10:])}
+	copy(pk.keyID[:], raw[2:10])
+	return pk, nil
+}
+
+// VerifyMinisign checks a .minisig signature of message, including its
+// trusted comment. Both legacy (Ed) and prehashed (ED) signatures are
+// accepted.
+func (pk *PublicKey) VerifyMinisign(message, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) != 4 {
+		return fmt.Errorf("%w: expected 4 lines", ErrBadSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrBadSignature)
+	}
+	if !bytes.Equal(sig[2:10], pk.keyID[:]) {
+		return fmt.Errorf("%w: signed with a different key", ErrBadSignature)
+	}
+
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		digest := blake2b.Sum512(message)
+		message = digest[:]
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrBadSignature, sig[:2])
+	}
+	if !ed25519.Verify(pk.key, message, sig[10:]) {
+		return ErrBadSignature
+	}
+
+	// The global signature covers the signature and its trusted comment
+	comment, ok := strings.CutPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ok {
+		return fmt.Errorf("%w: missing trusted comment", ErrBadSignature)
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed global signature", ErrBadSignature)
+	}
+	if !ed25519.Verify(pk.key, append(sig[10:], comment...), global) {
+		return fmt.Errorf("%w: trusted comment was tampered with", ErrBadSignature)
+	}
+	return nil
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}